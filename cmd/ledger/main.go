@@ -0,0 +1,88 @@
+// Command ledger runs integrity operations on the ledger hash chain.
+//
+// Usage:
+//
+//	ledger [-config path] verify [-from seq] [-to seq]
+//	ledger [-config path] checkpoint
+//	ledger [-config path] seal
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"verve/internal/config"
+	"verve/internal/db"
+	"verve/internal/repository/postgres"
+	"verve/internal/services"
+)
+
+func main() {
+	configPath := flag.String("config", "configs/config.yaml", "path to the config file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-config path] verify [-from seq] [-to seq] | checkpoint | seal\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	database, err := db.InitDB(cfg.Database.DSN)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	checkpointKey, err := services.LoadCheckpointKey(cfg.Ledger.CheckpointKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load ledger checkpoint key: %v", err)
+	}
+	ledgerService := services.NewLedgerService(postgres.NewPostgresLedgerRepository(database), checkpointKey)
+
+	switch command, args := flag.Arg(0), flag.Args()[1:]; command {
+	case "verify":
+		verifyFlags := flag.NewFlagSet("verify", flag.ExitOnError)
+		from := verifyFlags.Int64("from", 1, "first chain position")
+		to := verifyFlags.Int64("to", 0, "last chain position, 0 for the tip of the chain")
+		verifyFlags.Parse(args)
+
+		result, err := ledgerService.VerifyChain(*from, *to)
+		if err != nil {
+			log.Fatalf("Failed to verify ledger chain: %v", err)
+		}
+		printJSON(result)
+		if !result.Valid {
+			os.Exit(1)
+		}
+	case "checkpoint":
+		checkpoint, err := ledgerService.CreateCheckpoint()
+		if err != nil {
+			log.Fatalf("Failed to create checkpoint: %v", err)
+		}
+		printJSON(checkpoint)
+	case "seal":
+		if err := ledgerService.SealUnchainedEntries(); err != nil {
+			log.Fatalf("Failed to chain ledger entries: %v", err)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func printJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Fatalf("Failed to print result: %v", err)
+	}
+}
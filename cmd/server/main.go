@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
 	"time"
 
 	"verve/internal/repository/postgres"
 	"verve/internal/services"
 
 	_ "verve/docs" // Swagger docs
+	"verve/internal/api/middleware"
 	"verve/internal/app"
+	"verve/internal/auth"
 	"verve/internal/config"
 	"verve/internal/db"
 
@@ -40,15 +44,27 @@ func main() {
 	}
 	defer database.Close()
 
+	// Load the keys tokens are signed and verified with
+	if err := auth.InitializeJWT(cfg.JWT); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// Background jobs stop when the server exits
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	// Create a new Gin router
 	router := gin.Default()
 
+	// Every request gets an ID, kept in the audit log of the changes it makes
+	router.Use(middleware.RequestID())
+
 	// enable Cors
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID", "X-Client-Version", "X-Platform"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID", "X-Client-Version", "X-Platform", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed", "X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -63,19 +79,109 @@ func main() {
 	badgeRepo := postgres.NewPostgresBadgeRepository(database)
 	achievementRuleRepo := postgres.NewPostgresAchievementRuleRepository(database)
 	userBadgeRepo := postgres.NewPostgresUserBadgeRepository(database)
+	treasuryRepo := postgres.NewPostgresTreasuryRepository(database)
+	idempotencyRepo := postgres.NewPostgresIdempotencyRepository(database)
+	pseudonymousWalletRepo := postgres.NewPostgresPseudonymousWalletRepository(database)
+	sessionRepo := postgres.NewPostgresSessionRepository(database)
+	achievementProgressRepo := postgres.NewPostgresAchievementProgressRepository(database)
+	activityRepo := postgres.NewPostgresActivityRepository(database)
+	leaderboardRepo := postgres.NewPostgresLeaderboardRepository(database)
+	auditRepo := postgres.NewPostgresAuditRepository(database)
+	outboxRepo := postgres.NewPostgresOutboxRepository(database)
+	webhookRepo := postgres.NewPostgresWebhookRepository(database)
+	notificationRepo := postgres.NewPostgresNotificationRepository(database)
+	kudosRepo := postgres.NewPostgresKudosRepository(database)
+	paymentRequestRepo := postgres.NewPostgresPaymentRequestRepository(database)
+	scheduledTransferRepo := postgres.NewPostgresScheduledTransferRepository(database)
 
 	// Initialize services
-	userService := services.NewUserService(userRepo, roleRepo)
-	walletService := services.NewWalletService(walletRepo)
-	transferService := services.NewTransferService(transferRepo, txRepo, ledgerRepo, userRepo)
-	badgeService := services.NewBadgeService(badgeRepo, achievementRuleRepo, userBadgeRepo)
+	auditService := services.NewAuditService(auditRepo)
+	userService := services.NewUserService(userRepo, roleRepo, auditService)
+	walletService := services.NewWalletService(walletRepo, txRepo)
+	transferService := services.NewTransferService(transferRepo, txRepo, userRepo, walletRepo, pseudonymousWalletRepo, auditService)
+	badgeService := services.NewBadgeService(badgeRepo, achievementRuleRepo, userBadgeRepo, auditService)
+	treasuryService := services.NewTreasuryService(treasuryRepo, userRepo, walletRepo, auditService, cfg.Treasury)
+	idempotencyService := services.NewIdempotencyService(idempotencyRepo, time.Duration(cfg.Idempotency.KeyTTLMinutes)*time.Minute)
+	checkpointKey, err := services.LoadCheckpointKey(cfg.Ledger.CheckpointKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load ledger checkpoint key: %v", err)
+	}
+	ledgerService := services.NewLedgerService(ledgerRepo, checkpointKey)
+	pseudonymousWalletService := services.NewPseudonymousWalletService(pseudonymousWalletRepo, auditService)
+	activityService := services.NewActivityService(activityRepo, walletRepo)
+	sessionService := services.NewSessionService(sessionRepo, roleRepo, activityService, time.Duration(cfg.Session.RefreshTokenTTLHours)*time.Hour)
+
+	achievementService := services.NewAchievementService(badgeRepo, achievementRuleRepo, userBadgeRepo, achievementProgressRepo, activityRepo, walletRepo, userRepo)
+	permissionService := services.NewPermissionService(roleRepo, time.Duration(cfg.Permissions.CacheTTLSeconds)*time.Second)
+	roleService := services.NewRoleService(roleRepo, userRepo, permissionService, auditService)
+	leaderboardService := services.NewLeaderboardService(leaderboardRepo, time.Duration(cfg.Leaderboard.CacheTTLSeconds)*time.Second)
+	eventDispatcher := services.NewEventDispatcher(outboxRepo, cfg.Events.MaxAttempts, time.Duration(cfg.Events.RetryDelaySeconds)*time.Second)
+	webhookService := services.NewWebhookService(
+		webhookRepo,
+		auditService,
+		&http.Client{Timeout: time.Duration(cfg.Webhooks.TimeoutSeconds) * time.Second},
+		cfg.Webhooks.MaxAttempts,
+		time.Duration(cfg.Webhooks.RetryDelaySeconds)*time.Second,
+	)
+	eventDispatcher.Subscribe("webhooks", webhookService.HandleEvent)
+	kudosService := services.NewKudosService(kudosRepo)
+	paymentRequestService := services.NewPaymentRequestService(paymentRequestRepo, walletRepo, transferService, time.Duration(cfg.PaymentRequests.ExpiryHours)*time.Hour)
+	scheduledTransferService := services.NewScheduledTransferService(scheduledTransferRepo, transferService, time.Duration(cfg.Scheduler.MissedAfterSeconds)*time.Second)
+	notificationService := services.NewNotificationService(notificationRepo, walletRepo, userRepo, badgeRepo)
+	eventDispatcher.Subscribe("notifications", notificationService.HandleEvent, services.NotificationEventTypes...)
+
+	// Transfers count towards streaks before badges are evaluated, so a transfer that
+	// extends a streak awards its badge right away
+	transferService.AddObserver(activityService)
+	transferService.AddObserver(achievementService)
+
+	// Access tokens of revoked sessions are rejected
+	middleware.UseSessionValidator(sessionService)
+	middleware.UsePermissionResolver(permissionService)
 
 	// Create a new application instance
-	application := app.NewApp(database, router, userService, walletService, transferService, badgeService)
+	application := app.NewApp(database, router, userService, walletService, transferService, badgeService, treasuryService, idempotencyService, ledgerService, pseudonymousWalletService, sessionService, achievementService, activityService, leaderboardService, roleService, auditService, eventDispatcher, webhookService, notificationService, kudosService, paymentRequestService, scheduledTransferService)
 
 	// Setup routes
 	application.SetupRoutes()
 
+	// Pick up grant cycles interrupted by a previous shutdown
+	if err := treasuryService.ResumeInterruptedCycles(); err != nil {
+		log.Printf("Failed to resume treasury grant cycles: %v", err)
+	}
+
+	// Chain ledger entries written before the ledger was hash-chained
+	if err := ledgerService.ChainLegacyEntries(); err != nil {
+		log.Printf("Failed to chain legacy ledger entries: %v", err)
+	}
+
+	// Start background jobs
+	go idempotencyService.RunExpiryPurge(ctx, time.Hour)
+	go sessionService.RunExpiryPurge(ctx, time.Hour)
+	go badgeService.RunExpiry(ctx, time.Hour)
+	go leaderboardService.RunRefresh(ctx, time.Duration(cfg.Leaderboard.CacheTTLSeconds)*time.Second)
+	if cfg.Events.DispatchIntervalSeconds > 0 {
+		go eventDispatcher.Run(ctx, time.Duration(cfg.Events.DispatchIntervalSeconds)*time.Second)
+	}
+	if cfg.Webhooks.DeliveryIntervalSeconds > 0 {
+		go webhookService.RunDeliveries(ctx, time.Duration(cfg.Webhooks.DeliveryIntervalSeconds)*time.Second)
+	}
+	if cfg.PaymentRequests.ExpiryIntervalSeconds > 0 {
+		go paymentRequestService.RunExpiry(ctx, time.Duration(cfg.PaymentRequests.ExpiryIntervalSeconds)*time.Second)
+	}
+	if cfg.Scheduler.IntervalSeconds > 0 {
+		go scheduledTransferService.RunScheduler(ctx, time.Duration(cfg.Scheduler.IntervalSeconds)*time.Second)
+	}
+	if cfg.Ledger.ReconcileIntervalMinutes > 0 {
+		go ledgerService.RunReconciliation(ctx, time.Duration(cfg.Ledger.ReconcileIntervalMinutes)*time.Minute)
+	}
+	if cfg.Ledger.CheckpointIntervalMinutes > 0 {
+		go ledgerService.RunCheckpoints(ctx, time.Duration(cfg.Ledger.CheckpointIntervalMinutes)*time.Minute)
+	}
+	if cfg.Achievements.EvaluateIntervalMinutes > 0 {
+		go achievementService.RunEvaluation(ctx, time.Duration(cfg.Achievements.EvaluateIntervalMinutes)*time.Minute)
+	}
+
 	// Start the server
 	log.Printf("Server starting on %s", cfg.Server.Address)
 	if err := application.Run(cfg.Server.Address); err != nil {
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"verve/internal/api/middleware"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAchievementRoutes sets up the admin achievement routes. Rules are written along
+// with badges, so checking them takes the permission to update badges.
+// @Summary Register achievement routes
+// @Description Register admin routes for checking achievement rules against users
+// @Tags badges
+func RegisterAchievementRoutes(router *gin.Engine, achievementService *services.AchievementService) {
+	achievementRoutes := router.Group("/api/admin/achievements")
+	achievementRoutes.Use(middleware.AuthMiddleware(), middleware.RequirePermission("update_badge"))
+	{
+		achievementRoutes.POST("/dry-run", DryRunRuleHandler(achievementService))
+	}
+}
+
+// DryRunRuleHandler evaluates a rule for a user without awarding anything
+// @Summary Dry-run an achievement rule
+// @Description Validate a rule, plain or expression, and evaluate it for a user. Every node of an expression is evaluated and explained. No badge is awarded.
+// @Tags badges
+// @Accept json
+// @Produce json
+// @Param request body DryRunRuleRequest true "User and rule to evaluate"
+// @Success 200 {object} models.RuleEvaluation
+// @Failure 400 {object} ErrorResponse "Invalid rule"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Security ApiKeyAuth
+// @Router /admin/achievements/dry-run [post]
+func DryRunRuleHandler(achievementService *services.AchievementService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req DryRunRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		evaluation, err := achievementService.DryRun(req.UserID, &models.AchievementRule{
+			RuleType:       req.RuleType,
+			ConditionValue: req.ConditionValue,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidAchievementRule):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			case errors.Is(err, sql.ErrNoRows):
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate rule"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, evaluation)
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"verve/internal/api/middleware"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterActivityRoutes sets up the activity routes under the user namespace
+// @Summary Register activity routes
+// @Description Register routes reporting the activity streaks of users
+// @Tags users
+func RegisterActivityRoutes(router *gin.Engine, activityService *services.ActivityService) {
+	router.GET("/api/user/:id/streaks", middleware.AuthMiddleware(), GetUserStreaksHandler(activityService))
+}
+
+// GetUserStreaksHandler returns the streaks of a user
+// @Summary Get activity streaks
+// @Description Get the current and longest streak of consecutive UTC days the authenticated user signed in and sent transfers
+// @Tags users
+// @Produce json
+// @Param id path integer true "User ID"
+// @Success 200 {array} models.Streak
+// @Failure 400 {object} ErrorResponse "Invalid user ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Can only view own streaks"
+// @Security ApiKeyAuth
+// @Router /user/{id}/streaks [get]
+func GetUserStreaksHandler(activityService *services.ActivityService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		if c.GetInt("userID") != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own streaks"})
+			return
+		}
+
+		streaks, err := activityService.GetStreaks(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch streaks"})
+			return
+		}
+		c.JSON(http.StatusOK, streaks)
+	}
+}
@@ -1,6 +1,10 @@
 package api
 
-import "verve/internal/models"
+import (
+	"encoding/json"
+	"time"
+	"verve/internal/models"
+)
 
 type (
 	// Common Response Types
@@ -21,6 +25,21 @@ type (
 		Rules       []models.AchievementRule `json:"rules"`
 	}
 
+	DryRunRuleRequest struct {
+		UserID         int             `json:"user_id" binding:"required" example:"42"`
+		RuleType       string          `json:"rule_type" binding:"required" example:"expression"`
+		ConditionValue json.RawMessage `json:"condition_value" binding:"required" swaggertype:"string" example:"{\"and\":[{\"transaction_count\":{\"min_transactions\":10,\"time_frame\":\"30d\"}},{\"distinct_counterparties\":{\"min_counterparties\":5,\"direction\":\"received\",\"time_frame\":\"30d\"}}]}"`
+	}
+
+	AwardBadgeRequest struct {
+		Level     string     `json:"level" example:"silver"`                    // Level of a tiered badge, empty for badges without levels
+		ExpiresAt *time.Time `json:"expires_at" example:"2025-12-31T23:59:59Z"` // The award is not held after it expires
+	}
+
+	RevokeBadgeRequest struct {
+		Reason string `json:"reason" binding:"required" example:"Awarded by mistake"`
+	}
+
 	UpdateBadgeRequest struct {
 		Name        *string `json:"name" example:"Achievement Master"`
 		Description *string `json:"description" example:"Awarded to users who complete all achievements"`
@@ -29,6 +48,90 @@ type (
 		IsActive    *bool   `json:"is_active" example:"true"`
 	}
 
+	// Audit Related Types
+	AuditLogResponse struct {
+		Items      []*models.AuditEntry `json:"items"`
+		NextCursor string               `json:"next_cursor,omitempty"`
+	}
+
+	// Kudos Related Types
+	KudosFeedResponse struct {
+		Items      []*models.Kudos `json:"items"`
+		NextCursor string          `json:"next_cursor,omitempty"`
+	}
+
+	KudosCommentRequest struct {
+		Body string `json:"body" binding:"required" example:"Well deserved!"`
+	}
+
+	KudosCommentsResponse struct {
+		Items      []*models.KudosComment `json:"items"`
+		NextCursor string                 `json:"next_cursor,omitempty"`
+	}
+
+	// Notification Related Types
+	NotificationsResponse struct {
+		Items       []*models.Notification `json:"items"`
+		NextCursor  string                 `json:"next_cursor,omitempty"`
+		UnreadCount int                    `json:"unread_count" example:"3"`
+	}
+
+	MarkNotificationsReadResponse struct {
+		Marked int64 `json:"marked" example:"3"`
+	}
+
+	// Payment Request Related Types
+	CreatePaymentRequestRequest struct {
+		RequesterWalletID int64      `json:"requester_wallet_id" binding:"required" example:"2"` // Wallet of the current user the coins are paid into
+		PayerWalletID     int64      `json:"payer_wallet_id" binding:"required" example:"1"`     // Wallet of the user asked to pay
+		Amount            int64      `json:"amount" binding:"required" example:"25"`
+		Message           string     `json:"message" example:"Lunch on Friday"`
+		ExpiresAt         *time.Time `json:"expires_at" example:"2025-07-01T00:00:00Z"` // At most 30 days ahead, a week from now when empty
+	}
+
+	AcceptPaymentRequestRequest struct {
+		Pin string `json:"pin" example:"1234"`
+	}
+
+	AcceptPaymentRequestResponse struct {
+		PaymentRequest *models.PaymentRequest `json:"payment_request"`
+		Transfer       *models.Transfer       `json:"transfer"`
+	}
+
+	PaymentRequestsResponse struct {
+		Items      []*models.PaymentRequest `json:"items"`
+		NextCursor string                   `json:"next_cursor,omitempty"`
+	}
+
+	// Role Related Types
+	CreateRoleRequest struct {
+		Name string `json:"name" binding:"required" example:"manager"`
+	}
+
+	// Webhook Related Types
+	CreateWebhookRequest struct {
+		URL         string   `json:"url" binding:"required" example:"https://hr.example.com/hooks/verve"`
+		Description string   `json:"description" example:"HR portal"`
+		EventTypes  []string `json:"event_types" binding:"required" example:"transfer.completed,badge.awarded"`
+	}
+
+	CreateWebhookResponse struct {
+		Webhook *models.WebhookEndpoint `json:"webhook"`
+		Secret  string                  `json:"secret" example:"whsec_3f9a..."` // Key of the X-Verve-Signature HMAC, shown only once
+	}
+
+	UpdateWebhookRequest struct {
+		URL         *string  `json:"url" example:"https://hr.example.com/hooks/verve"`
+		Description *string  `json:"description" example:"HR portal"`
+		EventTypes  []string `json:"event_types" example:"transfer.completed"`
+		IsActive    *bool    `json:"is_active" example:"false"`
+	}
+
+	WebhookDeliveriesResponse struct {
+		Items      []*models.WebhookDelivery `json:"items"`
+		NextCursor string                    `json:"next_cursor,omitempty"`
+	}
+
 	// User Related Types
 	UpdateUserRequest struct {
 		DisplayName            *string `json:"display_name" example:"John Doe"`
@@ -50,12 +153,33 @@ type (
 
 	LoginResponse struct {
 		Token        string `json:"token" example:"eyJhbGciOiJS..."`
-		RefreshToken string `json:"refresh_token" example:""`
+		RefreshToken string `json:"refresh_token" example:"kq3X0r9nV1c2bW8yZ5aT7uE4oP6iL0sD3fG9hJ2kM1n"`
 		User         struct {
 			Username string `json:"username" example:"john.doe@example.com"`
 		} `json:"user"`
 	}
 
+	RefreshTokenRequest struct {
+		RefreshToken string `json:"refresh_token" binding:"required" example:"kq3X0r9nV1c2bW8yZ5aT7uE4oP6iL0sD3fG9hJ2kM1n"`
+	}
+
+	RefreshTokenResponse struct {
+		Token        string `json:"token" example:"eyJhbGciOiJS..."`
+		RefreshToken string `json:"refresh_token" example:"Zt8wq1Lr4nB7xC0vM3kJ6hG9fD2sA5pO8iU1yT4rE7w"` // The refresh token used is no longer valid
+	}
+
+	LeaderboardOptOutRequest struct {
+		OptOut *bool `json:"opt_out" binding:"required" example:"true"`
+	}
+
+	LeaderboardOptOutResponse struct {
+		OptOut bool `json:"opt_out" example:"true"`
+	}
+
+	RevokeSessionsResponse struct {
+		Revoked int64 `json:"revoked" example:"2"`
+	}
+
 	// Wallet Related Types
 	CreateWalletRequest struct {
 		Currency string `json:"currency" binding:"required" example:"USD"`
@@ -63,17 +187,56 @@ type (
 
 	// Transfer Related Types
 	TransferRequest struct {
-		SenderWalletID   int64  `json:"sender_wallet_id" binding:"required" example:"1"`
+		SenderWalletID   int64                     `json:"sender_wallet_id" binding:"required" example:"1"`
+		ReceiverWalletID int64                     `json:"receiver_wallet_id" binding:"required" example:"2"`
+		Amount           int64                     `json:"amount" binding:"required" example:"1000"`
+		IsAnonymous      bool                      `json:"is_anonymous" example:"false"`
+		Message          string                    `json:"message" example:"Thanks for the help with the release!"`
+		Category         string                    `json:"category" example:"teamwork"`
+		Visibility       models.TransferVisibility `json:"visibility" example:"public"` // public, recipient_only (default) or private
+		Pin              string                    `json:"pin" example:"1234"`
+	}
+
+	PseudonymousTransferRequest struct {
+		SenderWalletID   int64  `json:"sender_wallet_id" binding:"required" example:"3"`
 		ReceiverWalletID int64  `json:"receiver_wallet_id" binding:"required" example:"2"`
 		Amount           int64  `json:"amount" binding:"required" example:"1000"`
-		IsAnonymous      bool   `json:"is_anonymous" example:"false"`
-		Pin              string `json:"pin" example:"1234"`
+		Nonce            string `json:"nonce" binding:"required" example:"6f1c2b9e4d7a4c0e"`
+		ExpiresAt        int64  `json:"expires_at" binding:"required" example:"1735689600"` // Unix seconds, at most 15 minutes ahead
+		Signature        string `json:"signature" binding:"required"`                       // Base64 signature of "sender:receiver:amount:nonce:expires_at"
 	}
 
 	TransferResponse struct {
 		Transfer *models.Transfer `json:"transfer"`
 	}
 
+	CreateScheduledTransferRequest struct {
+		SenderWalletID   int64                     `json:"sender_wallet_id" binding:"required" example:"1"`
+		ReceiverWalletID int64                     `json:"receiver_wallet_id" binding:"required" example:"2"`
+		Amount           int64                     `json:"amount" binding:"required" example:"50"`
+		IsAnonymous      bool                      `json:"is_anonymous" example:"false"`
+		Message          string                    `json:"message" example:"Monthly allowance"`
+		Category         string                    `json:"category" example:"allowance"`
+		Visibility       models.TransferVisibility `json:"visibility" example:"recipient_only"`
+		RunAt            *time.Time                `json:"run_at" example:"2025-07-01T09:00:00Z"` // Time of a one-off transfer, or the earliest run of a recurring one
+		Cron             string                    `json:"cron" example:"0 9 1 * *"`              // Recurring schedule, empty for a one-off transfer
+		Timezone         string                    `json:"timezone" example:"Europe/Berlin"`      // Defaults to UTC
+		CatchUp          models.CatchUpPolicy      `json:"catch_up" example:"latest"`             // latest (default) or skip
+		EndAt            *time.Time                `json:"end_at" example:"2025-12-31T23:59:59Z"`
+		MaxRuns          *int                      `json:"max_runs" example:"12"`
+		Pin              string                    `json:"pin" example:"1234"`
+	}
+
+	ScheduledTransfersResponse struct {
+		Items      []*models.ScheduledTransfer `json:"items"`
+		NextCursor string                      `json:"next_cursor,omitempty"`
+	}
+
+	ScheduledTransferExecutionsResponse struct {
+		Items      []*models.ScheduledTransferExecution `json:"items"`
+		NextCursor string                               `json:"next_cursor,omitempty"`
+	}
+
 	// Badge Related Types
 	BadgeResponse struct {
 		Badge models.Badge             `json:"badge"`
@@ -81,9 +244,42 @@ type (
 	}
 
 	// Pseudonymous Wallet Related Types
+	CreatePseudonymousWalletRequest struct {
+		PublicKey string `json:"public_key" binding:"required"` // Base64 uncompressed P-256 point
+		Proof     string `json:"proof" binding:"required"`      // Signature of "register-key:<public_key>"
+	}
+
 	CreatePseudonymousWalletResponse struct {
-		WalletID   int64  `json:"wallet_id"`
-		PublicKey  string `json:"public_key"`
-		PrivateKey string `json:"private_key"` // Only returned once
+		WalletID  int64  `json:"wallet_id"`
+		PublicKey string `json:"public_key"`
+	}
+
+	RotateWalletKeyRequest struct {
+		NewPublicKey string `json:"new_public_key" binding:"required"`
+		Proof        string `json:"proof" binding:"required"`     // Signature of "register-key:<new_public_key>" by the new key
+		Signature    string `json:"signature" binding:"required"` // Signature of "rotate-key:<wallet_id>:<new_public_key>" by the active key
+	}
+
+	RevokeWalletKeyRequest struct {
+		Signature string `json:"signature"` // Signature of "revoke-key:<wallet_id>:<public_key>" by the active key, not needed for admins
+		Reason    string `json:"reason" example:"Device lost"`
+	}
+
+	// Treasury Related Types
+	TreasuryResponse struct {
+		Username           string         `json:"username" example:"treasury@system.local"`
+		Wallet             *models.Wallet `json:"wallet"`
+		GrantAmountPerUser int64          `json:"grant_amount_per_user" example:"100"`
+	}
+
+	GrantCycleReportResponse struct {
+		Cycle   *models.GrantCycle    `json:"cycle"`
+		Payouts []*models.GrantPayout `json:"payouts"`
+	}
+
+	// Wallet Related Types
+	TransactionHistoryResponse struct {
+		Items      []*models.StatementEntry `json:"items"`
+		NextCursor string                   `json:"next_cursor,omitempty"`
 	}
 )
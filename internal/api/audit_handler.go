@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"verve/internal/api/middleware"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAuditRoutes sets up the admin routes reading the audit log
+// @Summary Register audit routes
+// @Description Register admin routes to query and export the audit log
+// @Tags audit
+func RegisterAuditRoutes(router *gin.Engine, auditService *services.AuditService) {
+	auditRoutes := router.Group("/api/admin/audit")
+	auditRoutes.Use(middleware.AuthMiddleware(), middleware.RequirePermission("view_audit_log"))
+	{
+		auditRoutes.GET("", ListAuditLogHandler(auditService))
+		auditRoutes.GET("/export", ExportAuditLogHandler(auditService))
+	}
+}
+
+// actorFrom returns who makes a request and the request details the audit log keeps
+func actorFrom(c *gin.Context) models.Actor {
+	return models.Actor{
+		UserID:    c.GetInt("userID"),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: c.GetString("requestID"),
+	}
+}
+
+// ListAuditLogHandler queries the audit log
+// @Summary Query the audit log
+// @Description List the entries of the audit log matching the filters, newest first
+// @Tags audit
+// @Produce json
+// @Param actor_id query integer false "Only changes made by this user"
+// @Param action query string false "Only this action, e.g. badge.award"
+// @Param target_type query string false "Only changes to this type of target, e.g. user"
+// @Param target_id query string false "Only changes to this target"
+// @Param request_id query string false "Only changes made by this request"
+// @Param from query string false "Only entries at or after this time (RFC3339)"
+// @Param to query string false "Only entries before this time (RFC3339)"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query integer false "Page size (default 50, max 500)"
+// @Success 200 {object} AuditLogResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the view_audit_log permission"
+// @Security ApiKeyAuth
+// @Router /admin/audit [get]
+func ListAuditLogHandler(auditService *services.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := parseAuditFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entries, nextBeforeID, err := auditService.Find(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the audit log"})
+			return
+		}
+
+		resp := AuditLogResponse{Items: entries}
+		if resp.Items == nil {
+			resp.Items = []*models.AuditEntry{}
+		}
+		if nextBeforeID > 0 {
+			resp.NextCursor = encodeHistoryCursor(nextBeforeID)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// ExportAuditLogHandler exports the audit log as JSON lines
+// @Summary Export the audit log
+// @Description Export every entry of the audit log matching the filters as JSON lines, one entry per line, newest first
+// @Tags audit
+// @Produce application/x-ndjson
+// @Param actor_id query integer false "Only changes made by this user"
+// @Param action query string false "Only this action, e.g. badge.award"
+// @Param target_type query string false "Only changes to this type of target, e.g. user"
+// @Param target_id query string false "Only changes to this target"
+// @Param request_id query string false "Only changes made by this request"
+// @Param from query string false "Only entries at or after this time (RFC3339)"
+// @Param to query string false "Only entries before this time (RFC3339)"
+// @Success 200 {string} string "One models.AuditEntry per line"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the view_audit_log permission"
+// @Security ApiKeyAuth
+// @Router /admin/audit/export [get]
+func ExportAuditLogHandler(auditService *services.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := parseAuditFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		c.Status(http.StatusOK)
+
+		// The response is streamed, so a failure can only cut it short
+		if err := auditService.Export(filter, c.Writer); err != nil {
+			log.Printf("Failed to export the audit log: %v", err)
+		}
+	}
+}
+
+// parseAuditFilter reads the audit log filters from the query string
+func parseAuditFilter(c *gin.Context) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		RequestID:  c.Query("request_id"),
+	}
+
+	parseTime := func(name string) (*time.Time, error) {
+		value := c.Query(name)
+		if value == "" {
+			return nil, nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.New("Invalid " + name + ", expected RFC3339")
+		}
+		return &t, nil
+	}
+
+	var err error
+	if filter.From, err = parseTime("from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTime("to"); err != nil {
+		return filter, err
+	}
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		id, err := strconv.Atoi(actorID)
+		if err != nil {
+			return filter, errors.New("Invalid actor_id")
+		}
+		filter.ActorID = &id
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if filter.BeforeID, err = decodeHistoryCursor(cursor); err != nil {
+			return filter, errors.New("Invalid cursor")
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			return filter, errors.New("Invalid limit")
+		}
+	}
+	return filter, nil
+}
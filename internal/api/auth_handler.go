@@ -54,20 +54,13 @@ func LocalLoginHandler(authService *services.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		user, token, err := authService.AuthenticateLocal(req.Username, req.Password)
+		user, tokens, err := authService.AuthenticateLocal(req.Username, req.Password, c.Request.UserAgent(), c.ClientIP())
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, LoginResponse{
-			Token: token,
-			User: struct {
-				Username string `json:"username" example:"john.doe@example.com"`
-			}{
-				Username: user.Email,
-			},
-		})
+		c.JSON(http.StatusOK, newLoginResponse(user.Email, tokens))
 	}
 }
 
@@ -136,8 +129,7 @@ func LinkOAuthHandler(authService *services.AuthService) gin.HandlerFunc {
 // @Router /auth/unlink/oauth [post]
 func UnlinkOAuthHandler(authService *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.GetInt("userID")
-		err := authService.UnlinkOAuth(userID)
+		err := authService.UnlinkOAuth(actorFrom(c), c.GetInt("userID"))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
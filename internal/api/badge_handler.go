@@ -1,10 +1,14 @@
 package api
 
 import (
+	"database/sql"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"verve/internal/api/middleware"
 	"verve/internal/models"
+	"verve/internal/repository"
 	"verve/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -24,9 +28,10 @@ func RegisterBadgeRoutes(router *gin.Engine, badgeService *services.BadgeService
 	adminBadgeRoutes := router.Group("/api/badges")
 	adminBadgeRoutes.Use(middleware.AuthMiddleware())
 	{
-		adminBadgeRoutes.POST("", middleware.RoleMiddleware("admin"), CreateBadgeHandler(badgeService))
-		adminBadgeRoutes.PUT("/:id", middleware.RoleMiddleware("admin"), UpdateBadgeHandler(badgeService))
-		adminBadgeRoutes.POST("/:id/award/:user_id", middleware.RoleMiddleware("admin"), AwardBadgeHandler(badgeService))
+		adminBadgeRoutes.POST("", middleware.RequirePermission("create_badge"), CreateBadgeHandler(badgeService))
+		adminBadgeRoutes.PUT("/:id", middleware.RequirePermission("update_badge"), UpdateBadgeHandler(badgeService))
+		adminBadgeRoutes.POST("/:id/award/:user_id", middleware.RequirePermission("assign_badge"), AwardBadgeHandler(badgeService))
+		adminBadgeRoutes.POST("/:id/revoke/:user_id", middleware.RequirePermission("revoke_badge"), RevokeBadgeHandler(badgeService))
 	}
 
 	// Public badge listing and details
@@ -44,6 +49,7 @@ func RegisterBadgeRoutes(router *gin.Engine, badgeService *services.BadgeService
 	{
 		badgeRoutes := userRoutes.Group("/badges")
 		badgeRoutes.GET("", GetUserBadgesHandler(badgeService))
+		badgeRoutes.GET("/history", GetUserBadgeHistoryHandler(badgeService))
 	}
 }
 
@@ -57,7 +63,7 @@ func RegisterBadgeRoutes(router *gin.Engine, badgeService *services.BadgeService
 // @Success 201 {object} models.Badge "Created badge"
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
-// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the create_badge permission"
 // @Security ApiKeyAuth
 // @Router /badges [post]
 func CreateBadgeHandler(badgeService *services.BadgeService) gin.HandlerFunc {
@@ -83,13 +89,12 @@ func CreateBadgeHandler(badgeService *services.BadgeService) gin.HandlerFunc {
 			}
 		}
 
-		createdBy := c.GetInt("userID")
 		badge, err := badgeService.CreateBadge(
+			actorFrom(c),
 			req.Name,
 			req.Description,
 			req.IconURL,
 			req.Points,
-			createdBy,
 			req.Rules,
 		)
 
@@ -113,7 +118,7 @@ func CreateBadgeHandler(badgeService *services.BadgeService) gin.HandlerFunc {
 // @Success 200 {object} models.Badge
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
-// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the update_badge permission"
 // @Failure 404 {object} ErrorResponse "Badge not found"
 // @Security ApiKeyAuth
 // @Router /badges/{id} [put]
@@ -139,6 +144,7 @@ func UpdateBadgeHandler(badgeService *services.BadgeService) gin.HandlerFunc {
 		}
 
 		badge, err := badgeService.UpdateBadge(
+			actorFrom(c),
 			id,
 			req.Name,
 			req.Description,
@@ -221,16 +227,19 @@ func ListBadgesHandler(badgeService *services.BadgeService) gin.HandlerFunc {
 
 // AwardBadgeHandler awards a badge to a user
 // @Summary Award badge to user
-// @Description Award a badge to a specific user
+// @Description Award a badge to a specific user, optionally at a level and until an expiry. Awarding a higher level of a badge the user holds upgrades the award.
 // @Tags badges
+// @Accept json
 // @Produce json
 // @Param id path integer true "Badge ID"
 // @Param user_id path integer true "User ID"
+// @Param award body AwardBadgeRequest false "Level and expiry of the award"
 // @Success 200 {object} SuccessResponse
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
-// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the assign_badge permission"
 // @Failure 404 {object} ErrorResponse "Badge or user not found"
+// @Failure 409 {object} ErrorResponse "User already holds the badge at this level"
 // @Security ApiKeyAuth
 // @Router /badges/{id}/award/{user_id} [post]
 func AwardBadgeHandler(badgeService *services.BadgeService) gin.HandlerFunc {
@@ -247,19 +256,80 @@ func AwardBadgeHandler(badgeService *services.BadgeService) gin.HandlerFunc {
 			return
 		}
 
-		awardedBy := c.GetInt("userID")
-		if err := badgeService.AwardBadge(userID, badgeID, &awardedBy); err != nil {
+		// The body is optional
+		var req AwardBadgeRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = badgeService.AwardBadge(actorFrom(c), userID, badgeID, req.Level, req.ExpiresAt)
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, gin.H{"message": "Badge awarded successfully"})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Badge not found"})
+		case errors.Is(err, repository.ErrBadgeAlreadyAwarded):
+			c.JSON(http.StatusConflict, gin.H{"error": "User already holds this badge at this level"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+// RevokeBadgeHandler revokes a badge a user holds
+// @Summary Revoke badge from user
+// @Description Revoke a badge a user holds. The reason is kept in the user's badge history, and the user may earn the badge again.
+// @Tags badges
+// @Accept json
+// @Produce json
+// @Param id path integer true "Badge ID"
+// @Param user_id path integer true "User ID"
+// @Param revocation body RevokeBadgeRequest true "Reason for the revocation"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the revoke_badge permission"
+// @Failure 404 {object} ErrorResponse "User does not hold the badge"
+// @Security ApiKeyAuth
+// @Router /badges/{id}/revoke/{user_id} [post]
+func RevokeBadgeHandler(badgeService *services.BadgeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		badgeID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid badge ID"})
+			return
+		}
+
+		userID, err := strconv.Atoi(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var req RevokeBadgeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Badge awarded successfully"})
+		err = badgeService.RevokeBadge(actorFrom(c), userID, badgeID, req.Reason)
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, gin.H{"message": "Badge revoked successfully"})
+		case errors.Is(err, services.ErrBadgeNotHeld):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrRevokeReasonRequired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke badge"})
+		}
 	}
 }
 
 // GetUserBadgesHandler retrieves all badges awarded to a user
 // @Summary Get user's badges
-// @Description Get all badges that have been awarded to the authenticated user
+// @Description Get the badges the authenticated user holds. Revoked and expired awards are only listed in the badge history.
 // @Tags badges
 // @Produce json
 // @Param user_id path integer true "User ID"
@@ -295,6 +365,49 @@ func GetUserBadgesHandler(badgeService *services.BadgeService) gin.HandlerFunc {
 	}
 }
 
+// GetUserBadgeHistoryHandler retrieves the badge history of a user
+// @Summary Get user's badge history
+// @Description Get every award, level upgrade, revocation and expiry of a user's badges, oldest first. Admins can view the history of any user.
+// @Tags badges
+// @Produce json
+// @Param id path integer true "User ID"
+// @Success 200 {array} models.UserBadgeEvent
+// @Failure 400 {object} ErrorResponse "Invalid user ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Can only view your own badge history"
+// @Security ApiKeyAuth
+// @Router /user/{id}/badges/history [get]
+func GetUserBadgeHistoryHandler(badgeService *services.BadgeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		// Admins audit the history of any user
+		isAdmin := false
+		for _, role := range c.GetStringSlice("roles") {
+			if role == "admin" {
+				isAdmin = true
+				break
+			}
+		}
+		if c.GetInt("userID") != userID && !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own badge history"})
+			return
+		}
+
+		history, err := badgeService.GetBadgeHistory(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch badge history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, history)
+	}
+}
+
 // GetBadgeHoldersHandler retrieves all users who have been awarded a specific badge
 // @Summary Get badge holders
 // @Description Get all users who have been awarded a specific badge
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"verve/internal/api/middleware"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterEventRoutes sets up the admin routes for domain events
+// @Summary Register event routes
+// @Description Register admin routes to inspect and retry dead domain events
+// @Tags events
+func RegisterEventRoutes(router *gin.Engine, eventDispatcher *services.EventDispatcher) {
+	eventRoutes := router.Group("/api/admin/events")
+	eventRoutes.Use(middleware.AuthMiddleware(), middleware.RequirePermission("manage_events"))
+	{
+		eventRoutes.GET("/dead", ListDeadEventsHandler(eventDispatcher))
+		eventRoutes.POST("/:id/retry", RetryDeadEventHandler(eventDispatcher))
+	}
+}
+
+// ListDeadEventsHandler lists dead events
+// @Summary List dead events
+// @Description List the most recent events that failed every delivery attempt, with the last error
+// @Tags events
+// @Produce json
+// @Param limit query integer false "Maximum number of events (default 20, max 100)"
+// @Success 200 {array} models.OutboxEvent
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_events permission"
+// @Security ApiKeyAuth
+// @Router /admin/events/dead [get]
+func ListDeadEventsHandler(eventDispatcher *services.EventDispatcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		events, err := eventDispatcher.ListDeadEvents(limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dead events"})
+			return
+		}
+
+		c.JSON(http.StatusOK, events)
+	}
+}
+
+// RetryDeadEventHandler retries a dead event
+// @Summary Retry a dead event
+// @Description Deliver a dead event again, with a fresh set of attempts, to the subscribers that did not handle it
+// @Tags events
+// @Produce json
+// @Param id path integer true "Event ID"
+// @Success 202 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse "Invalid event ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_events permission"
+// @Failure 404 {object} ErrorResponse "No dead event with this ID"
+// @Security ApiKeyAuth
+// @Router /admin/events/{id}/retry [post]
+func RetryDeadEventHandler(eventDispatcher *services.EventDispatcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+			return
+		}
+
+		if err := eventDispatcher.RetryDeadEvent(id); err != nil {
+			if errors.Is(err, services.ErrEventNotDead) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "No dead event with this ID"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry event"})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "Event queued for delivery"})
+	}
+}
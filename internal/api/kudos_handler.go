@@ -0,0 +1,308 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"verve/internal/api/middleware"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterKudosRoutes sets up the routes for the kudos feed
+// @Summary Register kudos routes
+// @Description Register routes to read the kudos feed and react to and comment on kudos
+// @Tags kudos
+func RegisterKudosRoutes(router *gin.Engine, kudosService *services.KudosService) {
+	kudosRoutes := router.Group("/api/kudos")
+	kudosRoutes.Use(middleware.AuthMiddleware())
+	{
+		kudosRoutes.GET("", GetKudosFeedHandler(kudosService))
+		kudosRoutes.GET("/:id", GetKudosHandler(kudosService))
+		kudosRoutes.PUT("/:id/reactions/:reaction", AddKudosReactionHandler(kudosService))
+		kudosRoutes.DELETE("/:id/reactions/:reaction", RemoveKudosReactionHandler(kudosService))
+		kudosRoutes.GET("/:id/comments", ListKudosCommentsHandler(kudosService))
+		kudosRoutes.POST("/:id/comments", AddKudosCommentHandler(kudosService))
+		kudosRoutes.DELETE("/:id/comments/:commentId", DeleteKudosCommentHandler(kudosService))
+	}
+}
+
+// GetKudosFeedHandler lists the kudos feed
+// @Summary Get the kudos feed
+// @Description List the public transfers, newest first, with their message, category, reactions and number of comments. Anonymous transfers are shown without their sender.
+// @Tags kudos
+// @Produce json
+// @Param category query string false "Only kudos of this category, e.g. teamwork"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query integer false "Page size (default 20, max 100)"
+// @Success 200 {object} KudosFeedResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /kudos [get]
+func GetKudosFeedHandler(kudosService *services.KudosService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := models.KudosFilter{Category: c.Query("category")}
+		var err error
+		if cursor := c.Query("cursor"); cursor != "" {
+			if filter.BeforeID, err = decodeHistoryCursor(cursor); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+		}
+		if limit := c.Query("limit"); limit != "" {
+			if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+				return
+			}
+		}
+
+		kudos, nextBeforeID, err := kudosService.Feed(c.GetInt("userID"), filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the kudos feed"})
+			return
+		}
+
+		resp := KudosFeedResponse{Items: kudos}
+		if resp.Items == nil {
+			resp.Items = []*models.Kudos{}
+		}
+		if nextBeforeID > 0 {
+			resp.NextCursor = encodeHistoryCursor(nextBeforeID)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetKudosHandler retrieves kudos
+// @Summary Get kudos
+// @Description Get a public transfer as shown in the kudos feed
+// @Tags kudos
+// @Produce json
+// @Param id path integer true "Transfer ID"
+// @Success 200 {object} models.Kudos
+// @Failure 400 {object} ErrorResponse "Invalid transfer ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "No public transfer with this ID"
+// @Security ApiKeyAuth
+// @Router /kudos/{id} [get]
+func GetKudosHandler(kudosService *services.KudosService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transferID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+			return
+		}
+
+		kudos, err := kudosService.GetKudos(c.GetInt("userID"), transferID)
+		if err != nil {
+			respondKudosError(c, err, "Failed to fetch kudos")
+			return
+		}
+
+		c.JSON(http.StatusOK, kudos)
+	}
+}
+
+// AddKudosReactionHandler reacts to kudos
+// @Summary React to kudos
+// @Description Add a reaction of the current user to kudos. Reacting twice with the same reaction has no further effect.
+// @Tags kudos
+// @Produce json
+// @Param id path integer true "Transfer ID"
+// @Param reaction path string true "Reaction: clap, heart, fire, party or thumbsup"
+// @Success 200 {object} models.Kudos
+// @Failure 400 {object} ErrorResponse "Invalid transfer ID or reaction"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "No public transfer with this ID"
+// @Security ApiKeyAuth
+// @Router /kudos/{id}/reactions/{reaction} [put]
+func AddKudosReactionHandler(kudosService *services.KudosService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transferID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+			return
+		}
+
+		kudos, err := kudosService.React(c.GetInt("userID"), transferID, c.Param("reaction"))
+		if err != nil {
+			respondKudosError(c, err, "Failed to add reaction")
+			return
+		}
+
+		c.JSON(http.StatusOK, kudos)
+	}
+}
+
+// RemoveKudosReactionHandler removes a reaction to kudos
+// @Summary Remove a reaction to kudos
+// @Description Remove a reaction of the current user from kudos
+// @Tags kudos
+// @Produce json
+// @Param id path integer true "Transfer ID"
+// @Param reaction path string true "Reaction: clap, heart, fire, party or thumbsup"
+// @Success 200 {object} models.Kudos
+// @Failure 400 {object} ErrorResponse "Invalid transfer ID or reaction"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "No public transfer with this ID"
+// @Security ApiKeyAuth
+// @Router /kudos/{id}/reactions/{reaction} [delete]
+func RemoveKudosReactionHandler(kudosService *services.KudosService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transferID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+			return
+		}
+
+		kudos, err := kudosService.Unreact(c.GetInt("userID"), transferID, c.Param("reaction"))
+		if err != nil {
+			respondKudosError(c, err, "Failed to remove reaction")
+			return
+		}
+
+		c.JSON(http.StatusOK, kudos)
+	}
+}
+
+// ListKudosCommentsHandler lists the comments on kudos
+// @Summary List comments on kudos
+// @Description List the comments on kudos, oldest first
+// @Tags kudos
+// @Produce json
+// @Param id path integer true "Transfer ID"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query integer false "Page size (default 50, max 200)"
+// @Success 200 {object} KudosCommentsResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "No public transfer with this ID"
+// @Security ApiKeyAuth
+// @Router /kudos/{id}/comments [get]
+func ListKudosCommentsHandler(kudosService *services.KudosService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transferID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+			return
+		}
+
+		var afterID int64
+		if cursor := c.Query("cursor"); cursor != "" {
+			if afterID, err = decodeHistoryCursor(cursor); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+		}
+		var limit int
+		if value := c.Query("limit"); value != "" {
+			if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+				return
+			}
+		}
+
+		comments, nextAfterID, err := kudosService.ListComments(c.GetInt("userID"), transferID, afterID, limit)
+		if err != nil {
+			respondKudosError(c, err, "Failed to fetch comments")
+			return
+		}
+
+		resp := KudosCommentsResponse{Items: comments}
+		if resp.Items == nil {
+			resp.Items = []*models.KudosComment{}
+		}
+		if nextAfterID > 0 {
+			resp.NextCursor = encodeHistoryCursor(nextAfterID)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// AddKudosCommentHandler comments on kudos
+// @Summary Comment on kudos
+// @Description Add a comment of the current user to kudos
+// @Tags kudos
+// @Accept json
+// @Produce json
+// @Param id path integer true "Transfer ID"
+// @Param comment body KudosCommentRequest true "Comment"
+// @Success 201 {object} models.KudosComment
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "No public transfer with this ID"
+// @Security ApiKeyAuth
+// @Router /kudos/{id}/comments [post]
+func AddKudosCommentHandler(kudosService *services.KudosService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transferID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+			return
+		}
+
+		var req KudosCommentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		comment, err := kudosService.Comment(c.GetInt("userID"), transferID, req.Body)
+		if err != nil {
+			respondKudosError(c, err, "Failed to add comment")
+			return
+		}
+
+		c.JSON(http.StatusCreated, comment)
+	}
+}
+
+// DeleteKudosCommentHandler deletes a comment on kudos
+// @Summary Delete a comment on kudos
+// @Description Delete a comment the current user wrote on kudos
+// @Tags kudos
+// @Produce json
+// @Param id path integer true "Transfer ID"
+// @Param commentId path integer true "Comment ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse "Invalid ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Comment not found or not written by you"
+// @Security ApiKeyAuth
+// @Router /kudos/{id}/comments/{commentId} [delete]
+func DeleteKudosCommentHandler(kudosService *services.KudosService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transferID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+			return
+		}
+		commentID, err := strconv.ParseInt(c.Param("commentId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+			return
+		}
+
+		if err := kudosService.DeleteComment(c.GetInt("userID"), transferID, commentID); err != nil {
+			respondKudosError(c, err, "Failed to delete comment")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Comment deleted"})
+	}
+}
+
+func respondKudosError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidReaction), errors.Is(err, services.ErrEmptyComment),
+		errors.Is(err, services.ErrCommentTooLong):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrKudosNotFound), errors.Is(err, services.ErrCommentNotOwned):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
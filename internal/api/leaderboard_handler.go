@@ -0,0 +1,115 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"verve/internal/api/middleware"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLeaderboardLimit = 10
+	maxLeaderboardLimit     = 100
+)
+
+// RegisterLeaderboardRoutes sets up the leaderboard routes
+// @Summary Register leaderboard routes
+// @Description Register routes ranking users and managing the leaderboard opt-out
+// @Tags leaderboards
+func RegisterLeaderboardRoutes(router *gin.Engine, leaderboardService *services.LeaderboardService) {
+	leaderboardRoutes := router.Group("/api/leaderboards")
+	leaderboardRoutes.Use(middleware.AuthMiddleware())
+	{
+		leaderboardRoutes.GET("/opt-out", GetLeaderboardOptOutHandler(leaderboardService))
+		leaderboardRoutes.PUT("/opt-out", SetLeaderboardOptOutHandler(leaderboardService))
+		leaderboardRoutes.GET("/:metric", GetLeaderboardHandler(leaderboardService))
+	}
+}
+
+// GetLeaderboardHandler ranks users by a metric
+// @Summary Get a leaderboard
+// @Description Rank users by badge points, coins received, coins sent or distinct colleagues thanked over a calendar window in UTC. Anonymous transfers and pseudonymous wallets do not count, and users who opted out are not ranked. Rankings are refreshed every few minutes.
+// @Tags leaderboards
+// @Produce json
+// @Param metric path string true "Metric" Enums(badge_points, coins_received, coins_sent, colleagues_thanked)
+// @Param window query string false "Window, defaults to weekly" Enums(daily, weekly, monthly, all_time)
+// @Param limit query integer false "Number of users to return, 10 by default and at most 100"
+// @Success 200 {object} models.Leaderboard
+// @Failure 400 {object} ErrorResponse "Invalid metric, window or limit"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /leaderboards/{metric} [get]
+func GetLeaderboardHandler(leaderboardService *services.LeaderboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := defaultLeaderboardLimit
+		if value := c.Query("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 || parsed > maxLeaderboardLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+				return
+			}
+			limit = parsed
+		}
+
+		board, err := leaderboardService.GetLeaderboard(c.Param("metric"), c.DefaultQuery("window", "weekly"), limit, c.GetInt("userID"))
+		switch {
+		case err == nil:
+			c.JSON(http.StatusOK, board)
+		case errors.Is(err, services.ErrUnknownLeaderboardMetric), errors.Is(err, services.ErrUnknownLeaderboardWindow):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute leaderboard"})
+		}
+	}
+}
+
+// GetLeaderboardOptOutHandler reports whether the authenticated user opted out of the leaderboards
+// @Summary Get leaderboard opt-out
+// @Description Report whether the authenticated user is left out of every leaderboard
+// @Tags leaderboards
+// @Produce json
+// @Success 200 {object} LeaderboardOptOutResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /leaderboards/opt-out [get]
+func GetLeaderboardOptOutHandler(leaderboardService *services.LeaderboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		optedOut, err := leaderboardService.IsOptedOut(c.GetInt("userID"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard opt-out"})
+			return
+		}
+		c.JSON(http.StatusOK, LeaderboardOptOutResponse{OptOut: optedOut})
+	}
+}
+
+// SetLeaderboardOptOutHandler opts the authenticated user out of the leaderboards, or back in
+// @Summary Set leaderboard opt-out
+// @Description Leave the authenticated user out of every leaderboard, or rank them again. The change applies right away.
+// @Tags leaderboards
+// @Accept json
+// @Produce json
+// @Param request body LeaderboardOptOutRequest true "Opt-out"
+// @Success 200 {object} LeaderboardOptOutResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /leaderboards/opt-out [put]
+func SetLeaderboardOptOutHandler(leaderboardService *services.LeaderboardService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LeaderboardOptOutRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := leaderboardService.SetOptOut(c.GetInt("userID"), *req.OptOut); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leaderboard opt-out"})
+			return
+		}
+		c.JSON(http.StatusOK, LeaderboardOptOutResponse{OptOut: *req.OptOut})
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"verve/internal/api/middleware"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterLedgerRoutes sets up the admin ledger routes
+// @Summary Register ledger routes
+// @Description Register admin routes for reconciling wallet balances against the ledger and verifying the ledger hash chain
+// @Tags ledger
+func RegisterLedgerRoutes(router *gin.Engine, ledgerService *services.LedgerService) {
+	ledgerRoutes := router.Group("/api/admin/ledger")
+	ledgerRoutes.Use(middleware.AuthMiddleware(), middleware.RequirePermission("manage_ledger"))
+	{
+		ledgerRoutes.POST("/reconcile", ReconcileLedgerHandler(ledgerService))
+		ledgerRoutes.GET("/reconciliations", ListReconciliationsHandler(ledgerService))
+		ledgerRoutes.GET("/reconciliations/:id", GetReconciliationHandler(ledgerService))
+		ledgerRoutes.GET("/verify", VerifyLedgerChainHandler(ledgerService))
+		ledgerRoutes.POST("/checkpoints", CreateLedgerCheckpointHandler(ledgerService))
+		ledgerRoutes.GET("/checkpoints", ListLedgerCheckpointsHandler(ledgerService))
+	}
+}
+
+// ReconcileLedgerHandler reconciles the ledger on demand
+// @Summary Reconcile ledger
+// @Description Recompute every wallet balance from the ledger, check debits against credits for every transaction and flag entries without a transaction. The report is stored and returned.
+// @Tags ledger
+// @Produce json
+// @Success 200 {object} models.ReconciliationReport
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Security ApiKeyAuth
+// @Router /admin/ledger/reconcile [post]
+func ReconcileLedgerHandler(ledgerService *services.LedgerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		triggeredBy := c.GetInt("userID")
+		report, err := ledgerService.Reconcile(&triggeredBy)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile ledger"})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// ListReconciliationsHandler lists recent reconciliation reports
+// @Summary List reconciliations
+// @Description List the most recent reconciliation reports, scheduled and on demand, without their discrepancies
+// @Tags ledger
+// @Produce json
+// @Param limit query integer false "Maximum number of reports (default 20, max 100)"
+// @Success 200 {array} models.ReconciliationReport
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Security ApiKeyAuth
+// @Router /admin/ledger/reconciliations [get]
+func ListReconciliationsHandler(ledgerService *services.LedgerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		reports, err := ledgerService.ListReconciliations(limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reconciliations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, reports)
+	}
+}
+
+// GetReconciliationHandler returns a stored reconciliation report
+// @Summary Get reconciliation
+// @Description Get a reconciliation report with every discrepancy it found
+// @Tags ledger
+// @Produce json
+// @Param id path integer true "Reconciliation ID"
+// @Success 200 {object} models.ReconciliationReport
+// @Failure 400 {object} ErrorResponse "Invalid reconciliation ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Reconciliation not found"
+// @Security ApiKeyAuth
+// @Router /admin/ledger/reconciliations/{id} [get]
+func GetReconciliationHandler(ledgerService *services.LedgerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reconciliation ID"})
+			return
+		}
+
+		report, err := ledgerService.GetReconciliation(id)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reconciliation not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reconciliation"})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// VerifyLedgerChainHandler verifies the ledger hash chain
+// @Summary Verify ledger chain
+// @Description Recompute the hash chain over a range of ledger positions and check the signed checkpoints in it. Reports the first broken link.
+// @Tags ledger
+// @Produce json
+// @Param from query integer false "First chain position (default 1)"
+// @Param to query integer false "Last chain position (default the tip of the chain)"
+// @Success 200 {object} models.ChainVerification
+// @Failure 400 {object} ErrorResponse "Invalid range"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Security ApiKeyAuth
+// @Router /admin/ledger/verify [get]
+func VerifyLedgerChainHandler(ledgerService *services.LedgerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var fromSeq, toSeq int64
+		var err error
+		if from := c.Query("from"); from != "" {
+			if fromSeq, err = strconv.ParseInt(from, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from"})
+				return
+			}
+		}
+		if to := c.Query("to"); to != "" {
+			if toSeq, err = strconv.ParseInt(to, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to"})
+				return
+			}
+		}
+
+		result, err := ledgerService.VerifyChain(fromSeq, toSeq)
+		if errors.Is(err, services.ErrInvalidChainRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify ledger chain"})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// CreateLedgerCheckpointHandler signs the current tip of the ledger chain
+// @Summary Create ledger checkpoint
+// @Description Sign the hash of the last ledger entry with the configured checkpoint key
+// @Tags ledger
+// @Produce json
+// @Success 201 {object} models.LedgerCheckpoint
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 422 {object} ErrorResponse "No checkpoint key configured or the ledger is empty"
+// @Security ApiKeyAuth
+// @Router /admin/ledger/checkpoints [post]
+func CreateLedgerCheckpointHandler(ledgerService *services.LedgerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checkpoint, err := ledgerService.CreateCheckpoint()
+		if err != nil {
+			if errors.Is(err, services.ErrCheckpointKeyMissing) || errors.Is(err, services.ErrLedgerEmpty) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create checkpoint"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusCreated, checkpoint)
+	}
+}
+
+// ListLedgerCheckpointsHandler lists recent ledger checkpoints
+// @Summary List ledger checkpoints
+// @Description List the most recent signed checkpoints of the ledger chain
+// @Tags ledger
+// @Produce json
+// @Param limit query integer false "Maximum number of checkpoints (default 20, max 100)"
+// @Success 200 {array} models.LedgerCheckpoint
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Security ApiKeyAuth
+// @Router /admin/ledger/checkpoints [get]
+func ListLedgerCheckpointsHandler(ledgerService *services.LedgerService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		checkpoints, err := ledgerService.ListCheckpoints(limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch checkpoints"})
+			return
+		}
+
+		c.JSON(http.StatusOK, checkpoints)
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
 	"strings"
 	"verve/internal/auth"
@@ -8,6 +9,58 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// SessionValidator reports whether the session an access token was issued for is still active
+type SessionValidator interface {
+	IsSessionActive(sessionID string) (bool, error)
+}
+
+var sessionValidator SessionValidator
+
+// UseSessionValidator makes the auth middlewares reject access tokens of revoked or
+// expired sessions, and tokens that were not issued for a session.
+func UseSessionValidator(validator SessionValidator) {
+	sessionValidator = validator
+}
+
+// checkSession aborts the request when the session of the token is no longer active
+func checkSession(c *gin.Context, claims *auth.Claims) bool {
+	if sessionValidator == nil {
+		return true
+	}
+	if claims.SessionID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return false
+	}
+
+	active, err := sessionValidator.IsSessionActive(claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate session"})
+		c.Abort()
+		return false
+	}
+	if !active {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked or has expired"})
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// SessionStillActive reports whether the session a request was authenticated with is still
+// active, for requests such as streams that outlive the check made when they started
+func SessionStillActive(c *gin.Context) bool {
+	if sessionValidator == nil {
+		return true
+	}
+	active, err := sessionValidator.IsSessionActive(c.GetString("sessionID"))
+	if err != nil {
+		log.Printf("Failed to validate session: %v", err)
+		return false
+	}
+	return active
+}
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -30,9 +83,13 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if !checkSession(c, claims) {
+			return
+		}
 
 		c.Set("userID", claims.UserID)
 		c.Set("roles", claims.Roles)
+		c.Set("sessionID", claims.SessionID)
 		c.Next()
 	}
 }
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+)
+
+// responseRecorder keeps a copy of everything the handler writes
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware makes a mutating endpoint safe to retry. Requests carrying
+// an Idempotency-Key header are executed once per user and key; retries with the
+// same payload get the original response replayed and retries with a different
+// payload are rejected. Requests without the header are passed through.
+// Must run after AuthMiddleware.
+func IdempotencyMiddleware(idempotencyService *services.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID := c.GetInt("userID")
+		fingerprint := idempotencyService.Fingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		record, err := idempotencyService.Begin(userID, key, fingerprint)
+		if err != nil {
+			if errors.Is(err, services.ErrIdempotencyKeyReused) || errors.Is(err, services.ErrIdempotencyKeyInProgress) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check idempotency key"})
+			}
+			c.Abort()
+			return
+		}
+		if record != nil {
+			c.Header(idempotencyReplayedHeader, "true")
+			c.Data(record.ResponseStatus, "application/json; charset=utf-8", record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		release := func() {
+			if err := idempotencyService.Release(userID, key); err != nil {
+				log.Printf("Failed to release idempotency key %q: %v", key, err)
+			}
+		}
+		// A panicking handler must not leave the key in progress until it expires
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				release()
+				panic(recovered)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors are not replayed so the client can retry them
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			release()
+			return
+		}
+		if err := idempotencyService.Complete(userID, key, status, recorder.body.Bytes()); err != nil {
+			log.Printf("Failed to store response for idempotency key %q: %v", key, err)
+		}
+	}
+}
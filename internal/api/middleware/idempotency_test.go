@@ -0,0 +1,130 @@
+package middleware_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"verve/internal/api/middleware"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	calls := 0
+	idempotencyService := services.NewIdempotencyService(newMockIdempotencyRepo(), time.Hour)
+	r.POST("/api/transfer",
+		func(c *gin.Context) { c.Set("userID", 1) },
+		middleware.IdempotencyMiddleware(idempotencyService),
+		func(c *gin.Context) {
+			calls++
+			c.JSON(http.StatusCreated, gin.H{"id": calls})
+		},
+	)
+
+	panics := true
+	r.POST("/api/flaky",
+		gin.RecoveryWithWriter(io.Discard),
+		func(c *gin.Context) { c.Set("userID", 1) },
+		middleware.IdempotencyMiddleware(idempotencyService),
+		func(c *gin.Context) {
+			if panics {
+				panic("handler failed")
+			}
+			c.JSON(http.StatusCreated, gin.H{"ok": true})
+		},
+	)
+
+	sendTo := func(path, key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	send := func(key, body string) *httptest.ResponseRecorder {
+		return sendTo("/api/transfer", key, body)
+	}
+
+	t.Run("Replay returns the original response", func(t *testing.T) {
+		first := send("key-1", `{"amount": 10}`)
+		assert.Equal(t, http.StatusCreated, first.Code)
+
+		replay := send("key-1", `{"amount": 10}`)
+		assert.Equal(t, http.StatusCreated, replay.Code)
+		assert.Equal(t, first.Body.String(), replay.Body.String())
+		assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Same key with a different payload is rejected", func(t *testing.T) {
+		w := send("key-1", `{"amount": 20}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Requests without a key are not deduplicated", func(t *testing.T) {
+		send("", `{"amount": 10}`)
+		send("", `{"amount": 10}`)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("A panicking handler releases the key", func(t *testing.T) {
+		w := sendTo("/api/flaky", "key-2", `{}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+		panics = false
+		w = sendTo("/api/flaky", "key-2", `{}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+}
+
+type mockIdempotencyRepo struct {
+	records map[string]*models.IdempotencyRecord
+}
+
+func newMockIdempotencyRepo() *mockIdempotencyRepo {
+	return &mockIdempotencyRepo{records: make(map[string]*models.IdempotencyRecord)}
+}
+
+func recordKey(userID int, key string) string {
+	return fmt.Sprintf("%d:%s", userID, key)
+}
+
+func (m *mockIdempotencyRepo) Reserve(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	if existing, ok := m.records[recordKey(record.UserID, record.Key)]; ok && existing.ExpiresAt.After(time.Now()) {
+		return existing, nil
+	}
+	record.Status = models.IdempotencyStatusInProgress
+	m.records[recordKey(record.UserID, record.Key)] = record
+	return nil, nil
+}
+
+func (m *mockIdempotencyRepo) Complete(userID int, key string, responseStatus int, responseBody []byte) error {
+	record := m.records[recordKey(userID, key)]
+	record.Status = models.IdempotencyStatusCompleted
+	record.ResponseStatus = responseStatus
+	record.ResponseBody = append([]byte(nil), responseBody...)
+	return nil
+}
+
+func (m *mockIdempotencyRepo) Delete(userID int, key string) error {
+	delete(m.records, recordKey(userID, key))
+	return nil
+}
+
+func (m *mockIdempotencyRepo) DeleteExpired() (int64, error) {
+	return 0, nil
+}
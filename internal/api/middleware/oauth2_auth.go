@@ -37,8 +37,12 @@ func OAuth2AuthMiddleware() gin.HandlerFunc {
 				c.Abort()
 				return
 			}
+			if !checkSession(c, claims) {
+				return
+			}
 			c.Set("userID", claims.UserID)
 			c.Set("roles", claims.Roles)
+			c.Set("sessionID", claims.SessionID)
 
 		case "OAuth":
 			// Handle OAuth2 token
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PermissionResolver reports whether any of a user's roles grants a permission
+type PermissionResolver interface {
+	HasPermission(roles []string, permission string) (bool, error)
+}
+
+var permissionResolver PermissionResolver
+
+// UsePermissionResolver sets how RequirePermission resolves the permissions of roles
+func UsePermissionResolver(resolver PermissionResolver) {
+	permissionResolver = resolver
+}
+
+// RequirePermission only lets a request through when one of the roles of the authenticated
+// user grants the permission. It must run after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles := c.GetStringSlice("roles")
+		if len(roles) == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User roles not found"})
+			c.Abort()
+			return
+		}
+		if permissionResolver == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Permissions are not configured"})
+			c.Abort()
+			return
+		}
+
+		allowed, err := permissionResolver.HasPermission(roles, permission)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve permissions"})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"verve/internal/api/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubPermissionResolver grants the permissions listed for each role
+type stubPermissionResolver map[string][]string
+
+func (s stubPermissionResolver) HasPermission(roles []string, permission string) (bool, error) {
+	for _, role := range roles {
+		for _, granted := range s[role] {
+			if granted == permission {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	middleware.UsePermissionResolver(stubPermissionResolver{"admin": {"assign_badge"}, "user": {}})
+	defer middleware.UsePermissionResolver(nil)
+
+	send := func(roles ...string) int {
+		r := gin.New()
+		r.POST("/api/badges/1/award/2",
+			func(c *gin.Context) {
+				if roles != nil {
+					c.Set("roles", roles)
+				}
+			},
+			middleware.RequirePermission("assign_badge"),
+			func(c *gin.Context) { c.Status(http.StatusOK) },
+		)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/api/badges/1/award/2", nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("user", "admin"))
+	assert.Equal(t, http.StatusForbidden, send("user"))
+	assert.Equal(t, http.StatusForbidden, send())
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of a request, from the client or assigned by the server
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDFormat bounds the request IDs accepted from clients
+var requestIDFormat = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,100}$`)
+
+// RequestID gives every request an ID, stored as "requestID" in the context and echoed in
+// the response. A well-formed ID sent by the client is kept, so it can be traced end to end.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !requestIDFormat.MatchString(requestID) {
+			b := make([]byte, 16)
+			rand.Read(b) // Never fails
+			requestID = hex.EncodeToString(b)
+		}
+
+		c.Set("requestID", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
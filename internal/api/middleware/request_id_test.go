@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"verve/internal/api/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	send := func(requestID string) (string, string) {
+		var seen string
+		r := gin.New()
+		r.Use(middleware.RequestID())
+		r.GET("/ping", func(c *gin.Context) {
+			seen = c.GetString("requestID")
+			c.Status(http.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/ping", nil)
+		if requestID != "" {
+			req.Header.Set(middleware.RequestIDHeader, requestID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return seen, w.Header().Get(middleware.RequestIDHeader)
+	}
+
+	seen, echoed := send("trace-42")
+	assert.Equal(t, "trace-42", seen)
+	assert.Equal(t, "trace-42", echoed)
+
+	seen, echoed = send("")
+	assert.Len(t, seen, 32)
+	assert.Equal(t, seen, echoed)
+
+	// Malformed IDs are replaced rather than written to the audit log
+	seen, _ = send("bad id\n")
+	assert.NotEqual(t, "bad id\n", seen)
+	assert.Len(t, seen, 32)
+}
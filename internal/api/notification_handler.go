@@ -0,0 +1,251 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"verve/internal/api/middleware"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// notificationHeartbeat is how often an idle stream is kept alive, and catches up on the
+// notifications it was not pushed
+const notificationHeartbeat = 15 * time.Second
+
+// RegisterNotificationRoutes sets up the routes for the notifications of the current user
+// @Summary Register notification routes
+// @Description Register routes to stream, list and read notifications
+// @Tags notifications
+func RegisterNotificationRoutes(router *gin.Engine, notificationService *services.NotificationService) {
+	notificationRoutes := router.Group("/api/notifications")
+	notificationRoutes.Use(middleware.AuthMiddleware())
+	{
+		notificationRoutes.GET("", ListNotificationsHandler(notificationService))
+		notificationRoutes.GET("/stream", StreamNotificationsHandler(notificationService))
+		notificationRoutes.PUT("/:id/read", MarkNotificationReadHandler(notificationService))
+		notificationRoutes.POST("/read-all", MarkAllNotificationsReadHandler(notificationService))
+	}
+}
+
+// ListNotificationsHandler lists the notifications of the current user
+// @Summary List notifications
+// @Description List the notifications of the current user, newest first, with the number of unread ones. Pass the ID of the last notification seen as since_id to catch up after a reconnect.
+// @Tags notifications
+// @Produce json
+// @Param unread query boolean false "Only unread notifications"
+// @Param since_id query integer false "Only notifications newer than this one"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query integer false "Page size (default 50, max 200)"
+// @Success 200 {object} NotificationsResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /notifications [get]
+func ListNotificationsHandler(notificationService *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var filter models.NotificationFilter
+		var err error
+		if unread := c.Query("unread"); unread != "" {
+			if filter.UnreadOnly, err = strconv.ParseBool(unread); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unread"})
+				return
+			}
+		}
+		if sinceID := c.Query("since_id"); sinceID != "" {
+			if filter.AfterID, err = strconv.ParseInt(sinceID, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since_id"})
+				return
+			}
+		}
+		if cursor := c.Query("cursor"); cursor != "" {
+			if filter.BeforeID, err = decodeHistoryCursor(cursor); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+		}
+		if limit := c.Query("limit"); limit != "" {
+			if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+				return
+			}
+		}
+
+		notifications, nextBeforeID, unread, err := notificationService.List(c.GetInt("userID"), filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+			return
+		}
+
+		resp := NotificationsResponse{Items: notifications, UnreadCount: unread}
+		if resp.Items == nil {
+			resp.Items = []*models.Notification{}
+		}
+		if nextBeforeID > 0 {
+			resp.NextCursor = encodeHistoryCursor(nextBeforeID)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// StreamNotificationsHandler streams the notifications of the current user
+// @Summary Stream notifications
+// @Description Stream the notifications of the current user as Server-Sent Events. A "ready" event opens the stream; each notification follows as an event named "notification", with the notification as JSON data and its ID as event ID. A client reconnecting with Last-Event-ID first receives the notifications it missed. Comments are sent to keep idle streams open. The stream is closed once its session is revoked or expires.
+// @Tags notifications
+// @Produce text/event-stream
+// @Param Last-Event-ID header integer false "ID of the last notification received"
+// @Success 200 {string} string "Stream of notification events"
+// @Failure 400 {object} ErrorResponse "Invalid Last-Event-ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /notifications/stream [get]
+func StreamNotificationsHandler(notificationService *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetInt("userID")
+
+		var lastID int64
+		if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+			var err error
+			if lastID, err = strconv.ParseInt(lastEventID, 10, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+				return
+			}
+		}
+
+		// Subscribe before catching up, so nothing made in between is missed
+		stream, closeStream := notificationService.Subscribe(userID)
+		defer closeStream()
+
+		if lastID == 0 {
+			// A new client only wants notifications made from now on
+			latest, _, _, err := notificationService.List(userID, models.NotificationFilter{Limit: 1})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+				return
+			}
+			if len(latest) > 0 {
+				lastID = latest[0].ID
+			}
+		}
+
+		send := func(notification *models.Notification) {
+			if notification.ID <= lastID {
+				return
+			}
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatInt(notification.ID, 10),
+				Event: "notification",
+				Data:  notification,
+			})
+			lastID = notification.ID
+		}
+		catchUp := func() {
+			for {
+				missed, err := notificationService.Since(userID, lastID)
+				if err != nil {
+					log.Printf("Failed to catch up on notifications of user %d: %v", userID, err)
+					return
+				}
+				if len(missed) == 0 {
+					return
+				}
+				for _, notification := range missed {
+					send(notification)
+				}
+			}
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		sse.Encode(c.Writer, sse.Event{Event: "ready", Data: gin.H{"last_id": lastID}})
+		catchUp()
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(notificationHeartbeat)
+		defer heartbeat.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case notification := <-stream:
+				send(notification)
+			case <-heartbeat.C:
+				// A stream ends with the session it was opened in
+				if !middleware.SessionStillActive(c) {
+					return false
+				}
+				// Notifications made on other instances, or dropped while the client lagged,
+				// are only found in the database
+				catchUp()
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			return true
+		})
+	}
+}
+
+// MarkNotificationReadHandler marks a notification as read
+// @Summary Mark a notification as read
+// @Description Mark a notification of the current user as read
+// @Tags notifications
+// @Produce json
+// @Param id path integer true "Notification ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse "Invalid notification ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Notification not found"
+// @Security ApiKeyAuth
+// @Router /notifications/{id}/read [put]
+func MarkNotificationReadHandler(notificationService *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+			return
+		}
+
+		err = notificationService.MarkRead(c.GetInt("userID"), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+	}
+}
+
+// MarkAllNotificationsReadHandler marks every notification as read
+// @Summary Mark all notifications as read
+// @Description Mark every unread notification of the current user as read
+// @Tags notifications
+// @Produce json
+// @Success 200 {object} MarkNotificationsReadResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /notifications/read-all [post]
+func MarkAllNotificationsReadHandler(notificationService *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		marked, err := notificationService.MarkAllRead(c.GetInt("userID"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+			return
+		}
+
+		c.JSON(http.StatusOK, MarkNotificationsReadResponse{Marked: marked})
+	}
+}
@@ -10,16 +10,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterOAuthRoutes(router *gin.Engine, userService *services.UserService) {
+func RegisterOAuthRoutes(router *gin.Engine, userService *services.UserService, sessionService *services.SessionService) {
 	authRoutes := router.Group("/api/auth")
 	{
 		// Google OAuth routes
 		authRoutes.GET("/google/login", GoogleLoginHandler())
-		authRoutes.GET("/google/callback", GoogleCallbackHandler(userService))
+		authRoutes.GET("/google/callback", GoogleCallbackHandler(userService, sessionService))
 
 		// Okta OAuth routes
 		authRoutes.GET("/okta/login", OktaLoginHandler())
-		authRoutes.GET("/okta/callback", OktaCallbackHandler(userService))
+		authRoutes.GET("/okta/callback", OktaCallbackHandler(userService, sessionService))
 	}
 }
 
@@ -52,7 +52,7 @@ func GoogleLoginHandler() gin.HandlerFunc {
 }
 
 // GoogleCallbackHandler handles Google OAuth callback
-func GoogleCallbackHandler(userService *services.UserService) gin.HandlerFunc {
+func GoogleCallbackHandler(userService *services.UserService, sessionService *services.SessionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		state := c.Query("state")
 		storedState, _ := c.Cookie("oauth_state")
@@ -73,26 +73,19 @@ func GoogleCallbackHandler(userService *services.UserService) gin.HandlerFunc {
 			return
 		}
 
-		user, err := userService.UpsertOAuthUser(userInfo.Email, userInfo.Name, userInfo.Picture, "google")
+		user, err := userService.UpsertOAuthUser(actorFrom(c), userInfo.Email, userInfo.Name, userInfo.Picture, "google")
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create/update user"})
 			return
 		}
 
-		token, err := auth.GenerateJWT(user.ID, user.Roles)
+		tokens, err := sessionService.StartSession(user.ID, user.Roles, c.Request.UserAgent(), c.ClientIP())
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to generate token"})
 			return
 		}
 
-		c.JSON(http.StatusOK, LoginResponse{
-			Token: token,
-			User: struct {
-				Username string `json:"username" example:"john.doe@example.com"`
-			}{
-				Username: user.Email,
-			},
-		})
+		c.JSON(http.StatusOK, newLoginResponse(user.Email, tokens))
 	}
 }
 
@@ -116,7 +109,7 @@ func OktaLoginHandler() gin.HandlerFunc {
 }
 
 // OktaCallbackHandler handles Okta OAuth callback
-func OktaCallbackHandler(userService *services.UserService) gin.HandlerFunc {
+func OktaCallbackHandler(userService *services.UserService, sessionService *services.SessionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		state := c.Query("state")
 		storedState, _ := c.Cookie("oauth_state")
@@ -137,25 +130,18 @@ func OktaCallbackHandler(userService *services.UserService) gin.HandlerFunc {
 			return
 		}
 
-		user, err := userService.UpsertOAuthUser(userInfo.Email, userInfo.Name, userInfo.Picture, "okta")
+		user, err := userService.UpsertOAuthUser(actorFrom(c), userInfo.Email, userInfo.Name, userInfo.Picture, "okta")
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create/update user"})
 			return
 		}
 
-		token, err := auth.GenerateJWT(user.ID, user.Roles)
+		tokens, err := sessionService.StartSession(user.ID, user.Roles, c.Request.UserAgent(), c.ClientIP())
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to generate token"})
 			return
 		}
 
-		c.JSON(http.StatusOK, LoginResponse{
-			Token: token,
-			User: struct {
-				Username string `json:"username" example:"john.doe@example.com"`
-			}{
-				Username: user.Email,
-			},
-		})
+		c.JSON(http.StatusOK, newLoginResponse(user.Email, tokens))
 	}
 }
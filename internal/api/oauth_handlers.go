@@ -44,7 +44,7 @@ func handleOAuthCallback(provider string, authService *services.AuthService) gin
 		fmt.Printf("OAuth userInfo: %+v\n", userInfo)
 
 		// Authenticate or create user
-		user, token, err := authService.AuthenticateOAuth(userInfo)
+		user, tokens, err := authService.AuthenticateOAuth(userInfo, c.Request.UserAgent(), c.ClientIP())
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: fmt.Sprintf("Authentication failed: %v", err)})
 			return
@@ -54,13 +54,6 @@ func handleOAuthCallback(provider string, authService *services.AuthService) gin
 			return
 		}
 
-		c.JSON(http.StatusOK, LoginResponse{
-			Token: token,
-			User: struct {
-				Username string `json:"username" example:"john.doe@example.com"`
-			}{
-				Username: user.Email,
-			},
-		})
+		c.JSON(http.StatusOK, newLoginResponse(user.Email, tokens))
 	}
 }
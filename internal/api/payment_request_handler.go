@@ -0,0 +1,290 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"verve/internal/api/middleware"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPaymentRequestRoutes sets up the routes for payment requests
+// @Summary Register payment request routes
+// @Description Register routes to ask other users for coins and to answer their requests
+// @Tags payment-requests
+func RegisterPaymentRequestRoutes(router *gin.Engine, paymentRequestService *services.PaymentRequestService, idempotencyService *services.IdempotencyService) {
+	requestRoutes := router.Group("/api/payment_requests")
+	requestRoutes.Use(middleware.AuthMiddleware())
+	{
+		requestRoutes.POST("", CreatePaymentRequestHandler(paymentRequestService))
+		requestRoutes.GET("/incoming", ListPaymentRequestsHandler(paymentRequestService.ListIncoming))
+		requestRoutes.GET("/outgoing", ListPaymentRequestsHandler(paymentRequestService.ListOutgoing))
+		requestRoutes.GET("/:id", GetPaymentRequestHandler(paymentRequestService))
+		requestRoutes.POST("/:id/accept", middleware.IdempotencyMiddleware(idempotencyService), AcceptPaymentRequestHandler(paymentRequestService))
+		requestRoutes.POST("/:id/decline", DeclinePaymentRequestHandler(paymentRequestService))
+		requestRoutes.POST("/:id/cancel", CancelPaymentRequestHandler(paymentRequestService))
+	}
+}
+
+// CreatePaymentRequestHandler asks another user for coins
+// @Summary Create a payment request
+// @Description Ask the owner of a wallet to pay coins into a wallet of the current user. The request expires at expires_at, by default after a week.
+// @Tags payment-requests
+// @Accept json
+// @Produce json
+// @Param request body CreatePaymentRequestRequest true "Payment request"
+// @Success 201 {object} models.PaymentRequest
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Wallet to be paid into not owned"
+// @Failure 404 {object} ErrorResponse "Wallet not found"
+// @Failure 422 {object} ErrorResponse "Currency mismatch or request to yourself"
+// @Security ApiKeyAuth
+// @Router /payment_requests [post]
+func CreatePaymentRequestHandler(paymentRequestService *services.PaymentRequestService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreatePaymentRequestRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		request, err := paymentRequestService.CreateRequest(
+			actorFrom(c),
+			req.RequesterWalletID,
+			req.PayerWalletID,
+			req.Amount,
+			req.Message,
+			req.ExpiresAt,
+		)
+		if err != nil {
+			respondPaymentRequestError(c, err, "Failed to create payment request")
+			return
+		}
+
+		c.JSON(http.StatusCreated, request)
+	}
+}
+
+// ListPaymentRequestsHandler lists the incoming or outgoing payment requests of the current user
+// @Summary List payment requests
+// @Description List the payment requests asking the current user to pay (incoming) or made by the current user (outgoing), newest first
+// @Tags payment-requests
+// @Produce json
+// @Param status query string false "Only requests in this status: pending, paid, declined, cancelled or expired"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query integer false "Page size (default 20, max 100)"
+// @Success 200 {object} PaymentRequestsResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /payment_requests/incoming [get]
+// @Router /payment_requests/outgoing [get]
+func ListPaymentRequestsHandler(
+	list func(userID int, status models.PaymentRequestStatus, beforeID int64, limit int) ([]*models.PaymentRequest, int64, error),
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var beforeID int64
+		var err error
+		if cursor := c.Query("cursor"); cursor != "" {
+			if beforeID, err = decodeHistoryCursor(cursor); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+		}
+		var limit int
+		if value := c.Query("limit"); value != "" {
+			if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+				return
+			}
+		}
+
+		requests, nextBeforeID, err := list(c.GetInt("userID"), models.PaymentRequestStatus(c.Query("status")), beforeID, limit)
+		if err != nil {
+			respondPaymentRequestError(c, err, "Failed to fetch payment requests")
+			return
+		}
+
+		resp := PaymentRequestsResponse{Items: requests}
+		if resp.Items == nil {
+			resp.Items = []*models.PaymentRequest{}
+		}
+		if nextBeforeID > 0 {
+			resp.NextCursor = encodeHistoryCursor(nextBeforeID)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetPaymentRequestHandler retrieves a payment request
+// @Summary Get a payment request
+// @Description Get a payment request made by or asked of the current user
+// @Tags payment-requests
+// @Produce json
+// @Param id path integer true "Payment request ID"
+// @Success 200 {object} models.PaymentRequest
+// @Failure 400 {object} ErrorResponse "Invalid payment request ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Payment request not found"
+// @Security ApiKeyAuth
+// @Router /payment_requests/{id} [get]
+func GetPaymentRequestHandler(paymentRequestService *services.PaymentRequestService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment request ID"})
+			return
+		}
+
+		request, err := paymentRequestService.GetRequest(c.GetInt("userID"), id)
+		if err != nil {
+			respondPaymentRequestError(c, err, "Failed to fetch payment request")
+			return
+		}
+
+		c.JSON(http.StatusOK, request)
+	}
+}
+
+// AcceptPaymentRequestHandler pays a payment request
+// @Summary Accept a payment request
+// @Description Pay a pending payment request asked of the current user with a transfer out of the payer wallet. The transfer is checked like any other, including the PIN; when it fails the request stays pending.
+// @Tags payment-requests
+// @Accept json
+// @Produce json
+// @Param id path integer true "Payment request ID"
+// @Param accept body AcceptPaymentRequestRequest false "PIN of the payer"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
+// @Success 200 {object} AcceptPaymentRequestResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Invalid PIN"
+// @Failure 404 {object} ErrorResponse "Payment request not found"
+// @Failure 409 {object} ErrorResponse "Payment request no longer pending"
+// @Failure 410 {object} ErrorResponse "Payment request expired"
+// @Failure 422 {object} ErrorResponse "Insufficient funds"
+// @Security ApiKeyAuth
+// @Router /payment_requests/{id}/accept [post]
+func AcceptPaymentRequestHandler(paymentRequestService *services.PaymentRequestService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment request ID"})
+			return
+		}
+
+		var req AcceptPaymentRequestRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		request, transfer, err := paymentRequestService.AcceptRequest(actorFrom(c), id, req.Pin)
+		if err != nil {
+			if transfer != nil {
+				respondTransferError(c, err, transfer)
+				return
+			}
+			respondPaymentRequestError(c, err, "Failed to accept payment request")
+			return
+		}
+
+		c.JSON(http.StatusOK, AcceptPaymentRequestResponse{PaymentRequest: request, Transfer: transfer})
+	}
+}
+
+// DeclinePaymentRequestHandler declines a payment request
+// @Summary Decline a payment request
+// @Description Decline a pending payment request asked of the current user
+// @Tags payment-requests
+// @Produce json
+// @Param id path integer true "Payment request ID"
+// @Success 200 {object} models.PaymentRequest
+// @Failure 400 {object} ErrorResponse "Invalid payment request ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Payment request not found"
+// @Failure 409 {object} ErrorResponse "Payment request no longer pending"
+// @Failure 410 {object} ErrorResponse "Payment request expired"
+// @Security ApiKeyAuth
+// @Router /payment_requests/{id}/decline [post]
+func DeclinePaymentRequestHandler(paymentRequestService *services.PaymentRequestService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment request ID"})
+			return
+		}
+
+		request, err := paymentRequestService.DeclineRequest(c.GetInt("userID"), id)
+		if err != nil {
+			respondPaymentRequestError(c, err, "Failed to decline payment request")
+			return
+		}
+
+		c.JSON(http.StatusOK, request)
+	}
+}
+
+// CancelPaymentRequestHandler cancels a payment request
+// @Summary Cancel a payment request
+// @Description Cancel a pending payment request made by the current user
+// @Tags payment-requests
+// @Produce json
+// @Param id path integer true "Payment request ID"
+// @Success 200 {object} models.PaymentRequest
+// @Failure 400 {object} ErrorResponse "Invalid payment request ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Payment request not found"
+// @Failure 409 {object} ErrorResponse "Payment request no longer pending"
+// @Failure 410 {object} ErrorResponse "Payment request expired"
+// @Security ApiKeyAuth
+// @Router /payment_requests/{id}/cancel [post]
+func CancelPaymentRequestHandler(paymentRequestService *services.PaymentRequestService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment request ID"})
+			return
+		}
+
+		request, err := paymentRequestService.CancelRequest(c.GetInt("userID"), id)
+		if err != nil {
+			respondPaymentRequestError(c, err, "Failed to cancel payment request")
+			return
+		}
+
+		c.JSON(http.StatusOK, request)
+	}
+}
+
+func respondPaymentRequestError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPaymentRequestNotFound), errors.Is(err, services.ErrPayerWalletNotFound),
+		errors.Is(err, services.ErrRequesterWalletNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRequesterWalletNotOwned):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentRequestNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentRequestExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidAmount), errors.Is(err, services.ErrMessageTooLong),
+		errors.Is(err, services.ErrInvalidPaymentRequestExpiry), errors.Is(err, services.ErrInvalidPaymentRequestStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSelfPaymentRequest), errors.Is(err, services.ErrCurrencyMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPIN), errors.Is(err, services.ErrWalletNotOwned),
+		errors.Is(err, services.ErrSenderWalletNotFound), errors.Is(err, services.ErrReceiverWalletNotFound),
+		errors.Is(err, services.ErrSelfTransfer):
+		// The transfer paying the request was rejected before it was recorded
+		c.JSON(transferErrorStatus(err), gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -1,27 +1,238 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"verve/internal/api/middleware"
+	"verve/internal/repository"
 	"verve/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+// RegisterPseudonymousWalletRoutes sets up the pseudonymous wallet routes
+// @Summary Register pseudonymous wallet routes
+// @Description Register routes for registering pseudonymous wallets and managing their keys
+// @Tags pseudonymous-wallets
 func RegisterPseudonymousWalletRoutes(router *gin.Engine, service *services.PseudonymousWalletService) {
-	router.POST("/api/pseudonymous_wallets", CreatePseudonymousWalletHandler(service))
+	// Pseudonymous wallets are controlled by signatures of the client-held key, not by a user session
+	walletRoutes := router.Group("/api/pseudonymous_wallets")
+	{
+		walletRoutes.POST("", CreatePseudonymousWalletHandler(service))
+		walletRoutes.GET("/:id/keys", GetPseudonymousWalletKeysHandler(service))
+		walletRoutes.POST("/:id/keys/rotate", RotatePseudonymousWalletKeyHandler(service))
+		walletRoutes.POST("/:id/keys/revoke", RevokePseudonymousWalletKeyHandler(service))
+	}
+
+	adminRoutes := router.Group("/api/admin/pseudonymous_wallets")
+	adminRoutes.Use(middleware.AuthMiddleware(), middleware.RequirePermission("manage_pseudonymous_wallets"))
+	{
+		adminRoutes.POST("/:id/keys/revoke", AdminRevokePseudonymousWalletKeyHandler(service))
+	}
 }
 
+// CreatePseudonymousWalletHandler registers a pseudonymous wallet
+// @Summary Create pseudonymous wallet
+// @Description Register a wallet controlled by a P-256 key generated on the client. The proof is the signature of "register-key:<public_key>" made with the matching private key, which never leaves the client.
+// @Tags pseudonymous-wallets
+// @Accept json
+// @Produce json
+// @Param wallet body CreatePseudonymousWalletRequest true "Public key and proof of possession"
+// @Success 201 {object} CreatePseudonymousWalletResponse
+// @Failure 400 {object} ErrorResponse "Invalid public key or proof"
+// @Failure 409 {object} ErrorResponse "Public key already registered"
+// @Router /pseudonymous_wallets [post]
 func CreatePseudonymousWalletHandler(service *services.PseudonymousWalletService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, pub, priv, err := service.CreatePseudonymousWallet()
+		var req CreatePseudonymousWalletRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		wallet, err := service.CreatePseudonymousWallet(actorFrom(c), req.PublicKey, req.Proof)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeWalletKeyError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, CreatePseudonymousWalletResponse{
-			WalletID:   id,
-			PublicKey:  pub,
-			PrivateKey: priv,
+		c.JSON(http.StatusCreated, CreatePseudonymousWalletResponse{
+			WalletID:  wallet.ID,
+			PublicKey: wallet.PublicKey,
 		})
 	}
 }
+
+// GetPseudonymousWalletKeysHandler lists the keys of a pseudonymous wallet
+// @Summary Get pseudonymous wallet keys
+// @Description List the active, rotated and revoked keys of a pseudonymous wallet, newest first
+// @Tags pseudonymous-wallets
+// @Produce json
+// @Param id path integer true "Wallet ID"
+// @Success 200 {array} models.PseudonymousWalletKey
+// @Failure 400 {object} ErrorResponse "Invalid wallet ID"
+// @Failure 404 {object} ErrorResponse "Wallet not found"
+// @Router /pseudonymous_wallets/{id}/keys [get]
+func GetPseudonymousWalletKeysHandler(service *services.PseudonymousWalletService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		walletID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet ID"})
+			return
+		}
+
+		keys, err := service.GetKeyHistory(walletID)
+		if err != nil {
+			writeWalletKeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, keys)
+	}
+}
+
+// RotatePseudonymousWalletKeyHandler replaces the key of a pseudonymous wallet
+// @Summary Rotate pseudonymous wallet key
+// @Description Replace the active key of a wallet. The active key signs "rotate-key:<wallet_id>:<new_public_key>" and the new key proves possession by signing "register-key:<new_public_key>".
+// @Tags pseudonymous-wallets
+// @Accept json
+// @Produce json
+// @Param id path integer true "Wallet ID"
+// @Param rotation body RotateWalletKeyRequest true "New key, its proof and the endorsement of the active key"
+// @Success 201 {object} models.PseudonymousWalletKey
+// @Failure 400 {object} ErrorResponse "Invalid public key or proof"
+// @Failure 401 {object} ErrorResponse "Invalid signature"
+// @Failure 403 {object} ErrorResponse "Key revoked"
+// @Failure 404 {object} ErrorResponse "Wallet not found"
+// @Failure 409 {object} ErrorResponse "Public key already registered or active key changed"
+// @Router /pseudonymous_wallets/{id}/keys/rotate [post]
+func RotatePseudonymousWalletKeyHandler(service *services.PseudonymousWalletService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		walletID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet ID"})
+			return
+		}
+
+		var req RotateWalletKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		key, err := service.RotateKey(actorFrom(c), walletID, req.NewPublicKey, req.Proof, req.Signature)
+		if err != nil {
+			writeWalletKeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, key)
+	}
+}
+
+// RevokePseudonymousWalletKeyHandler revokes the key of a pseudonymous wallet
+// @Summary Revoke pseudonymous wallet key
+// @Description Revoke the active key of a wallet, signed by that key over "revoke-key:<wallet_id>:<public_key>". The wallet cannot send coins afterwards.
+// @Tags pseudonymous-wallets
+// @Accept json
+// @Produce json
+// @Param id path integer true "Wallet ID"
+// @Param revocation body RevokeWalletKeyRequest true "Signature of the active key and reason"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Invalid signature"
+// @Failure 403 {object} ErrorResponse "Key already revoked"
+// @Failure 404 {object} ErrorResponse "Wallet not found"
+// @Router /pseudonymous_wallets/{id}/keys/revoke [post]
+func RevokePseudonymousWalletKeyHandler(service *services.PseudonymousWalletService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		walletID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet ID"})
+			return
+		}
+
+		var req RevokeWalletKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Signature == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "signature is required"})
+			return
+		}
+
+		if err := service.RevokeKey(actorFrom(c), walletID, req.Signature, req.Reason); err != nil {
+			writeWalletKeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, SuccessResponse{Message: "Key revoked"})
+	}
+}
+
+// AdminRevokePseudonymousWalletKeyHandler revokes a compromised key of a pseudonymous wallet
+// @Summary Revoke pseudonymous wallet key as admin
+// @Description Revoke the active key of a wallet without its signature, for keys known to be compromised
+// @Tags pseudonymous-wallets
+// @Accept json
+// @Produce json
+// @Param id path integer true "Wallet ID"
+// @Param revocation body RevokeWalletKeyRequest true "Reason"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden or key already revoked"
+// @Failure 404 {object} ErrorResponse "Wallet not found"
+// @Security ApiKeyAuth
+// @Router /admin/pseudonymous_wallets/{id}/keys/revoke [post]
+func AdminRevokePseudonymousWalletKeyHandler(service *services.PseudonymousWalletService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		walletID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet ID"})
+			return
+		}
+
+		var req RevokeWalletKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+			return
+		}
+
+		if err := service.AdminRevokeKey(actorFrom(c), walletID, req.Reason); err != nil {
+			writeWalletKeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, SuccessResponse{Message: "Key revoked"})
+	}
+}
+
+// writeWalletKeyError responds with the status matching a pseudonymous wallet key error
+func writeWalletKeyError(c *gin.Context, err error) {
+	status := walletKeyErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		c.JSON(status, gin.H{"error": "Failed to process wallet key request"})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// walletKeyErrorStatus maps pseudonymous wallet key errors to HTTP status codes
+func walletKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidPublicKey), errors.Is(err, services.ErrInvalidProofOfPossession):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidSignature):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrWalletKeyRevoked):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrPseudonymousWalletNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrPublicKeyInUse), errors.Is(err, repository.ErrWalletKeyChanged):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"verve/internal/api/middleware"
+	"verve/internal/repository"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoleRoutes sets up the admin routes managing roles and permissions
+// @Summary Register role routes
+// @Description Register admin routes for roles, the permissions they grant and the roles of users
+// @Tags roles
+func RegisterRoleRoutes(router *gin.Engine, roleService *services.RoleService) {
+	adminRoutes := router.Group("/api/admin")
+	adminRoutes.Use(middleware.AuthMiddleware(), middleware.RequirePermission("manage_roles"))
+	{
+		adminRoutes.GET("/roles", ListRolesHandler(roleService))
+		adminRoutes.POST("/roles", CreateRoleHandler(roleService))
+		adminRoutes.DELETE("/roles/:name", DeleteRoleHandler(roleService))
+		adminRoutes.PUT("/roles/:name/permissions/:permission", GrantPermissionHandler(roleService))
+		adminRoutes.DELETE("/roles/:name/permissions/:permission", RevokePermissionHandler(roleService))
+		adminRoutes.GET("/permissions", ListPermissionsHandler(roleService))
+		adminRoutes.GET("/users/:id/permissions", GetUserPermissionsHandler(roleService))
+		adminRoutes.PUT("/users/:id/roles/:name", AssignRoleHandler(roleService))
+		adminRoutes.DELETE("/users/:id/roles/:name", RemoveRoleHandler(roleService))
+	}
+}
+
+// respondRoleError maps the errors of role changes to a response
+func respondRoleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRoleName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrRoleNotFound), errors.Is(err, services.ErrPermissionNotFound),
+		errors.Is(err, services.ErrRoleNotAssigned):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrRoleExists), errors.Is(err, repository.ErrRoleInUse),
+		errors.Is(err, repository.ErrRoleAlreadyAssigned), errors.Is(err, services.ErrProtectedRole),
+		errors.Is(err, services.ErrLastAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListRolesHandler lists every role
+// @Summary List roles
+// @Description List every role with the permissions it grants
+// @Tags roles
+// @Produce json
+// @Success 200 {array} models.Role
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_roles permission"
+// @Security ApiKeyAuth
+// @Router /admin/roles [get]
+func ListRolesHandler(roleService *services.RoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles, err := roleService.ListRoles()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+			return
+		}
+		c.JSON(http.StatusOK, roles)
+	}
+}
+
+// CreateRoleHandler creates a role
+// @Summary Create a role
+// @Description Create a role that grants no permission yet
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param role body CreateRoleRequest true "Role"
+// @Success 201 {object} models.Role
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_roles permission"
+// @Failure 409 {object} ErrorResponse "Role already exists"
+// @Security ApiKeyAuth
+// @Router /admin/roles [post]
+func CreateRoleHandler(roleService *services.RoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		role, err := roleService.CreateRole(actorFrom(c), req.Name)
+		if err != nil {
+			respondRoleError(c, err, "Failed to create role")
+			return
+		}
+		c.JSON(http.StatusCreated, role)
+	}
+}
+
+// DeleteRoleHandler deletes a role
+// @Summary Delete a role
+// @Description Delete a role no user holds. The admin role cannot be deleted.
+// @Tags roles
+// @Produce json
+// @Param name path string true "Role name"
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_roles permission"
+// @Failure 404 {object} ErrorResponse "Role not found"
+// @Failure 409 {object} ErrorResponse "Role is protected or still assigned to users"
+// @Security ApiKeyAuth
+// @Router /admin/roles/{name} [delete]
+func DeleteRoleHandler(roleService *services.RoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := roleService.DeleteRole(actorFrom(c), c.Param("name")); err != nil {
+			respondRoleError(c, err, "Failed to delete role")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+	}
+}
+
+// GrantPermissionHandler grants a permission to a role
+// @Summary Grant a permission
+// @Description Grant a permission to a role. Holders of the role get the permission right away.
+// @Tags roles
+// @Produce json
+// @Param name path string true "Role name"
+// @Param permission path string true "Permission name"
+// @Success 200 {object} models.Role
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_roles permission"
+// @Failure 404 {object} ErrorResponse "Role or permission not found"
+// @Security ApiKeyAuth
+// @Router /admin/roles/{name}/permissions/{permission} [put]
+func GrantPermissionHandler(roleService *services.RoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := roleService.GrantPermission(actorFrom(c), c.Param("name"), c.Param("permission"))
+		if err != nil {
+			respondRoleError(c, err, "Failed to grant permission")
+			return
+		}
+		c.JSON(http.StatusOK, role)
+	}
+}
+
+// RevokePermissionHandler revokes a permission from a role
+// @Summary Revoke a permission
+// @Description Revoke a permission from a role. The admin role always keeps manage_roles.
+// @Tags roles
+// @Produce json
+// @Param name path string true "Role name"
+// @Param permission path string true "Permission name"
+// @Success 200 {object} models.Role
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_roles permission"
+// @Failure 404 {object} ErrorResponse "Role not found"
+// @Failure 409 {object} ErrorResponse "Role is protected"
+// @Security ApiKeyAuth
+// @Router /admin/roles/{name}/permissions/{permission} [delete]
+func RevokePermissionHandler(roleService *services.RoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := roleService.RevokePermission(actorFrom(c), c.Param("name"), c.Param("permission"))
+		if err != nil {
+			respondRoleError(c, err, "Failed to revoke permission")
+			return
+		}
+		c.JSON(http.StatusOK, role)
+	}
+}
+
+// ListPermissionsHandler lists every permission
+// @Summary List permissions
+// @Description List every permission a role can grant
+// @Tags roles
+// @Produce json
+// @Success 200 {array} models.Permission
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_roles permission"
+// @Security ApiKeyAuth
+// @Router /admin/permissions [get]
+func ListPermissionsHandler(roleService *services.RoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permissions, err := roleService.ListPermissions()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+			return
+		}
+		c.JSON(http.StatusOK, permissions)
+	}
+}
+
+// GetUserPermissionsHandler returns the effective permissions of a user
+// @Summary Get user permissions
+// @Description Get the roles of a user and every permission they grant together
+// @Tags roles
+// @Produce json
+// @Param id path integer true "User ID"
+// @Success 200 {object} models.UserPermissions
+// @Failure 400 {object} ErrorResponse "Invalid user ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_roles permission"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Security ApiKeyAuth
+// @Router /admin/users/{id}/permissions [get]
+func GetUserPermissionsHandler(roleService *services.RoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		permissions, err := roleService.GetUserPermissions(userID)
+		if err != nil {
+			respondRoleError(c, err, "Failed to fetch user permissions")
+			return
+		}
+		c.JSON(http.StatusOK, permissions)
+	}
+}
+
+// AssignRoleHandler gives a user a role
+// @Summary Assign a role
+// @Description Give a user a role. The user's tokens carry the new role from their next sign-in or token refresh.
+// @Tags roles
+// @Produce json
+// @Param id path integer true "User ID"
+// @Param name path string true "Role name"
+// @Success 200 {object} models.UserPermissions
+// @Failure 400 {object} ErrorResponse "Invalid user ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_roles permission"
+// @Failure 404 {object} ErrorResponse "User or role not found"
+// @Failure 409 {object} ErrorResponse "User already has the role"
+// @Security ApiKeyAuth
+// @Router /admin/users/{id}/roles/{name} [put]
+func AssignRoleHandler(roleService *services.RoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		permissions, err := roleService.AssignRole(actorFrom(c), userID, c.Param("name"))
+		if err != nil {
+			respondRoleError(c, err, "Failed to assign role")
+			return
+		}
+		c.JSON(http.StatusOK, permissions)
+	}
+}
+
+// RemoveRoleHandler takes a role from a user
+// @Summary Remove a role
+// @Description Take a role from a user. The last admin cannot lose the admin role.
+// @Tags roles
+// @Produce json
+// @Param id path integer true "User ID"
+// @Param name path string true "Role name"
+// @Success 200 {object} models.UserPermissions
+// @Failure 400 {object} ErrorResponse "Invalid user ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_roles permission"
+// @Failure 404 {object} ErrorResponse "User, role or assignment not found"
+// @Failure 409 {object} ErrorResponse "User is the last admin"
+// @Security ApiKeyAuth
+// @Router /admin/users/{id}/roles/{name} [delete]
+func RemoveRoleHandler(roleService *services.RoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		permissions, err := roleService.RemoveRole(actorFrom(c), userID, c.Param("name"))
+		if err != nil {
+			respondRoleError(c, err, "Failed to remove role")
+			return
+		}
+		c.JSON(http.StatusOK, permissions)
+	}
+}
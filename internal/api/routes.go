@@ -3,6 +3,7 @@ package api
 import (
 	"database/sql"
 	"net/http"
+	"verve/internal/auth"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,6 +13,21 @@ func RegisterRoutes(router *gin.Engine, db *sql.DB) {
 	{
 		api.GET("/health", HealthCheckHandler(db))
 	}
+
+	router.GET("/.well-known/jwks.json", JWKSHandler())
+}
+
+// JWKSHandler publishes the public keys Verve tokens can be verified with
+func JWKSHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jwks, err := auth.PublicJWKS()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load signing keys"})
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, jwks)
+	}
 }
 
 func HealthCheckHandler(db *sql.DB) gin.HandlerFunc {
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"verve/internal/api/middleware"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterScheduledTransferRoutes sets up the routes for scheduled transfers
+// @Summary Register scheduled transfer routes
+// @Description Register routes to schedule one-off and recurring transfers and to manage them
+// @Tags scheduled-transfers
+func RegisterScheduledTransferRoutes(router *gin.Engine, scheduledTransferService *services.ScheduledTransferService, idempotencyService *services.IdempotencyService) {
+	scheduleRoutes := router.Group("/api/scheduled_transfers")
+	scheduleRoutes.Use(middleware.AuthMiddleware())
+	{
+		scheduleRoutes.POST("", middleware.IdempotencyMiddleware(idempotencyService), CreateScheduledTransferHandler(scheduledTransferService))
+		scheduleRoutes.GET("", ListScheduledTransfersHandler(scheduledTransferService))
+		scheduleRoutes.GET("/:id", GetScheduledTransferHandler(scheduledTransferService))
+		scheduleRoutes.GET("/:id/executions", ListScheduledTransferExecutionsHandler(scheduledTransferService))
+		scheduleRoutes.POST("/:id/pause", UpdateScheduledTransferHandler(scheduledTransferService.PauseSchedule, "Failed to pause scheduled transfer"))
+		scheduleRoutes.POST("/:id/resume", UpdateScheduledTransferHandler(scheduledTransferService.ResumeSchedule, "Failed to resume scheduled transfer"))
+		scheduleRoutes.POST("/:id/cancel", UpdateScheduledTransferHandler(scheduledTransferService.CancelSchedule, "Failed to cancel scheduled transfer"))
+	}
+}
+
+// CreateScheduledTransferHandler schedules a transfer
+// @Summary Schedule a transfer
+// @Description Schedule a transfer out of a wallet of the current user, once at run_at or recurring on a cron schedule ("minute hour day-of-month month day-of-week", read in timezone) until end_at or max_runs runs. The transfer is checked like an immediate one, including the PIN, when it is scheduled. Runs missed while the scheduler was down follow catch_up: latest makes the latest missed run late and skips the others, skip skips them all.
+// @Tags scheduled-transfers
+// @Accept json
+// @Produce json
+// @Param schedule body CreateScheduledTransferRequest true "Scheduled transfer"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
+// @Success 201 {object} models.ScheduledTransfer
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Invalid PIN or sender wallet not owned"
+// @Failure 404 {object} ErrorResponse "Sender or receiver wallet not found"
+// @Failure 422 {object} ErrorResponse "Currency mismatch or self-transfer"
+// @Security ApiKeyAuth
+// @Router /scheduled_transfers [post]
+func CreateScheduledTransferHandler(scheduledTransferService *services.ScheduledTransferService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateScheduledTransferRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		schedule, err := scheduledTransferService.CreateSchedule(actorFrom(c), &models.ScheduledTransfer{
+			SenderWalletID:   req.SenderWalletID,
+			ReceiverWalletID: req.ReceiverWalletID,
+			Amount:           req.Amount,
+			IsAnonymous:      req.IsAnonymous,
+			Message:          req.Message,
+			Category:         req.Category,
+			Visibility:       req.Visibility,
+			Cron:             req.Cron,
+			Timezone:         req.Timezone,
+			CatchUp:          req.CatchUp,
+			EndAt:            req.EndAt,
+			MaxRuns:          req.MaxRuns,
+		}, req.RunAt, req.Pin)
+		if err != nil {
+			respondScheduledTransferError(c, err, "Failed to schedule transfer")
+			return
+		}
+
+		c.JSON(http.StatusCreated, schedule)
+	}
+}
+
+// ListScheduledTransfersHandler lists the scheduled transfers of the current user
+// @Summary List scheduled transfers
+// @Description List the scheduled transfers of the current user, newest first
+// @Tags scheduled-transfers
+// @Produce json
+// @Param status query string false "Only schedules in this status: active, paused, completed or cancelled"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query integer false "Page size (default 20, max 100)"
+// @Success 200 {object} ScheduledTransfersResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /scheduled_transfers [get]
+func ListScheduledTransfersHandler(scheduledTransferService *services.ScheduledTransferService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var beforeID int64
+		var err error
+		if cursor := c.Query("cursor"); cursor != "" {
+			if beforeID, err = decodeHistoryCursor(cursor); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+		}
+		var limit int
+		if value := c.Query("limit"); value != "" {
+			if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+				return
+			}
+		}
+
+		schedules, nextBeforeID, err := scheduledTransferService.ListSchedules(c.GetInt("userID"), models.ScheduledTransferStatus(c.Query("status")), beforeID, limit)
+		if err != nil {
+			respondScheduledTransferError(c, err, "Failed to fetch scheduled transfers")
+			return
+		}
+
+		resp := ScheduledTransfersResponse{Items: schedules}
+		if resp.Items == nil {
+			resp.Items = []*models.ScheduledTransfer{}
+		}
+		if nextBeforeID > 0 {
+			resp.NextCursor = encodeHistoryCursor(nextBeforeID)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// GetScheduledTransferHandler retrieves a scheduled transfer
+// @Summary Get a scheduled transfer
+// @Description Get a scheduled transfer of the current user with its next run
+// @Tags scheduled-transfers
+// @Produce json
+// @Param id path integer true "Scheduled transfer ID"
+// @Success 200 {object} models.ScheduledTransfer
+// @Failure 400 {object} ErrorResponse "Invalid scheduled transfer ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Scheduled transfer not found"
+// @Security ApiKeyAuth
+// @Router /scheduled_transfers/{id} [get]
+func GetScheduledTransferHandler(scheduledTransferService *services.ScheduledTransferService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled transfer ID"})
+			return
+		}
+
+		schedule, err := scheduledTransferService.GetSchedule(c.GetInt("userID"), id)
+		if err != nil {
+			respondScheduledTransferError(c, err, "Failed to fetch scheduled transfer")
+			return
+		}
+
+		c.JSON(http.StatusOK, schedule)
+	}
+}
+
+// ListScheduledTransferExecutionsHandler lists the runs of a scheduled transfer
+// @Summary List the runs of a scheduled transfer
+// @Description List the runs of a scheduled transfer of the current user, newest first: the transfers made, the ones that failed with their error, and the runs skipped after downtime
+// @Tags scheduled-transfers
+// @Produce json
+// @Param id path integer true "Scheduled transfer ID"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query integer false "Page size (default 20, max 100)"
+// @Success 200 {object} ScheduledTransferExecutionsResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Scheduled transfer not found"
+// @Security ApiKeyAuth
+// @Router /scheduled_transfers/{id}/executions [get]
+func ListScheduledTransferExecutionsHandler(scheduledTransferService *services.ScheduledTransferService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled transfer ID"})
+			return
+		}
+		var beforeID int64
+		if cursor := c.Query("cursor"); cursor != "" {
+			if beforeID, err = decodeHistoryCursor(cursor); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+		}
+		var limit int
+		if value := c.Query("limit"); value != "" {
+			if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+				return
+			}
+		}
+
+		executions, nextBeforeID, err := scheduledTransferService.ListExecutions(c.GetInt("userID"), id, beforeID, limit)
+		if err != nil {
+			respondScheduledTransferError(c, err, "Failed to fetch scheduled transfer runs")
+			return
+		}
+
+		resp := ScheduledTransferExecutionsResponse{Items: executions}
+		if resp.Items == nil {
+			resp.Items = []*models.ScheduledTransferExecution{}
+		}
+		if nextBeforeID > 0 {
+			resp.NextCursor = encodeHistoryCursor(nextBeforeID)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// UpdateScheduledTransferHandler pauses, resumes or cancels a scheduled transfer
+// @Summary Pause, resume or cancel a scheduled transfer
+// @Description Pause an active scheduled transfer of the current user, resume a paused one, or cancel it for good. Runs that fell due while a schedule was paused are not made when it resumes; a one-off transfer that fell due runs right away.
+// @Tags scheduled-transfers
+// @Produce json
+// @Param id path integer true "Scheduled transfer ID"
+// @Success 200 {object} models.ScheduledTransfer
+// @Failure 400 {object} ErrorResponse "Invalid scheduled transfer ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Scheduled transfer not found"
+// @Failure 409 {object} ErrorResponse "Scheduled transfer not in a status that allows this"
+// @Security ApiKeyAuth
+// @Router /scheduled_transfers/{id}/pause [post]
+// @Router /scheduled_transfers/{id}/resume [post]
+// @Router /scheduled_transfers/{id}/cancel [post]
+func UpdateScheduledTransferHandler(update func(userID int, id int64) (*models.ScheduledTransfer, error), message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled transfer ID"})
+			return
+		}
+
+		schedule, err := update(c.GetInt("userID"), id)
+		if err != nil {
+			respondScheduledTransferError(c, err, message)
+			return
+		}
+
+		c.JSON(http.StatusOK, schedule)
+	}
+}
+
+func respondScheduledTransferError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrScheduleNotActive), errors.Is(err, services.ErrScheduleNotPaused),
+		errors.Is(err, services.ErrScheduleFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCron), errors.Is(err, services.ErrInvalidTimezone),
+		errors.Is(err, services.ErrInvalidCatchUp), errors.Is(err, services.ErrInvalidRunAt),
+		errors.Is(err, services.ErrRunAtRequired), errors.Is(err, services.ErrInvalidMaxRuns),
+		errors.Is(err, services.ErrOneOffLimits), errors.Is(err, services.ErrNoScheduledRuns),
+		errors.Is(err, services.ErrInvalidScheduleState), errors.Is(err, services.ErrInvalidAmount),
+		errors.Is(err, services.ErrMessageTooLong), errors.Is(err, services.ErrInvalidCategory),
+		errors.Is(err, services.ErrInvalidVisibility):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPIN), errors.Is(err, services.ErrWalletNotOwned),
+		errors.Is(err, services.ErrSenderWalletNotFound), errors.Is(err, services.ErrReceiverWalletNotFound),
+		errors.Is(err, services.ErrSelfTransfer), errors.Is(err, services.ErrCurrencyMismatch):
+		c.JSON(transferErrorStatus(err), gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
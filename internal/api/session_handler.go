@@ -0,0 +1,171 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"verve/internal/api/middleware"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterSessionRoutes sets up the routes to refresh tokens and manage login sessions
+// @Summary Register session routes
+// @Description Register routes to refresh access tokens, log out and list or revoke the sessions of the current user
+// @Tags auth
+func RegisterSessionRoutes(router *gin.Engine, sessionService *services.SessionService) {
+	sessionRoutes := router.Group("/api/auth")
+	{
+		sessionRoutes.POST("/refresh", RefreshTokenHandler(sessionService))
+
+		protected := sessionRoutes.Group("")
+		protected.Use(middleware.AuthMiddleware())
+		{
+			protected.POST("/logout", LogoutHandler(sessionService))
+			protected.GET("/sessions", ListSessionsHandler(sessionService))
+			protected.DELETE("/sessions", RevokeOtherSessionsHandler(sessionService))
+			protected.DELETE("/sessions/:session_id", RevokeSessionHandler(sessionService))
+		}
+	}
+}
+
+// newLoginResponse builds the response of a successful sign-in
+func newLoginResponse(username string, tokens *models.SessionTokens) LoginResponse {
+	resp := LoginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}
+	resp.User.Username = username
+	return resp
+}
+
+// RefreshTokenHandler exchanges a refresh token for new tokens
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and a new refresh token. Refresh tokens are single use: presenting one that was already used revokes the whole session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} RefreshTokenResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Invalid, expired or reused refresh token"
+// @Router /auth/refresh [post]
+func RefreshTokenHandler(sessionService *services.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshTokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tokens, err := sessionService.Refresh(req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, RefreshTokenResponse{
+			Token:        tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+		})
+	}
+}
+
+// LogoutHandler ends the current session
+// @Summary Log out
+// @Description Revoke the session of the access token. Its access and refresh tokens stop working immediately.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /auth/logout [post]
+func LogoutHandler(sessionService *services.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetString("sessionID")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Token is not bound to a session"})
+			return
+		}
+
+		if err := sessionService.RevokeSession(c.GetInt("userID"), sessionID, models.SessionRevokedLogout); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+		c.JSON(http.StatusOK, SuccessResponse{Message: "Logged out"})
+	}
+}
+
+// ListSessionsHandler lists the sessions of the current user
+// @Summary List sessions
+// @Description List the active sessions of the current user, most recently used first. The session of the access token is flagged as current.
+// @Tags auth
+// @Produce json
+// @Success 200 {array} models.Session
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /auth/sessions [get]
+func ListSessionsHandler(sessionService *services.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessions, err := sessionService.ListSessions(c.GetInt("userID"), c.GetString("sessionID"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+			return
+		}
+		if sessions == nil {
+			sessions = []*models.Session{}
+		}
+		c.JSON(http.StatusOK, sessions)
+	}
+}
+
+// RevokeSessionHandler revokes one session of the current user
+// @Summary Revoke session
+// @Description Revoke a session of the current user, for example one left open on a lost device
+// @Tags auth
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Session not found"
+// @Security ApiKeyAuth
+// @Router /auth/sessions/{session_id} [delete]
+func RevokeSessionHandler(sessionService *services.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := sessionService.RevokeSession(c.GetInt("userID"), c.Param("session_id"), models.SessionRevokedByUser)
+		if err != nil {
+			if errors.Is(err, services.ErrSessionNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+			return
+		}
+		c.JSON(http.StatusOK, SuccessResponse{Message: "Session revoked"})
+	}
+}
+
+// RevokeOtherSessionsHandler revokes every session of the current user but the current one
+// @Summary Revoke other sessions
+// @Description Sign out everywhere else: revoke every session of the current user except the one of the access token
+// @Tags auth
+// @Produce json
+// @Success 200 {object} RevokeSessionsResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Security ApiKeyAuth
+// @Router /auth/sessions [delete]
+func RevokeOtherSessionsHandler(sessionService *services.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revoked, err := sessionService.RevokeOtherSessions(c.GetInt("userID"), c.GetString("sessionID"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+			return
+		}
+		c.JSON(http.StatusOK, RevokeSessionsResponse{Revoked: revoked})
+	}
+}
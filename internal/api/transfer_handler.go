@@ -1,9 +1,14 @@
 package api
 
 import (
+	"database/sql"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"verve/internal/api/middleware"
+	"verve/internal/models"
+	"verve/internal/repository"
 	"verve/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -11,26 +16,33 @@ import (
 // @Summary Register transfer routes
 // @Description Register all transfer-related routes
 // @Tags transfers
-func RegisterTransferRoutes(router *gin.Engine, transferService *services.TransferService) {
+func RegisterTransferRoutes(router *gin.Engine, transferService *services.TransferService, idempotencyService *services.IdempotencyService) {
 	transferRoutes := router.Group("/api/transfer")
 	transferRoutes.Use(middleware.AuthMiddleware())
 	{
-		transferRoutes.POST("", InitiateTransferHandler(transferService))
+		transferRoutes.POST("", middleware.IdempotencyMiddleware(idempotencyService), InitiateTransferHandler(transferService))
 		transferRoutes.GET("/:id", GetTransferStatusHandler(transferService))
 	}
+
+	// Pseudonymous transfers are authorized by the wallet's signature rather than a user session
+	router.POST("/api/transfer/pseudonymous", InitiatePseudonymousTransferHandler(transferService))
 }
 
 // InitiateTransferHandler handles the creation of a new transfer.
 // @Summary Initiate a transfer
-// @Description Create a new transfer between wallets
+// @Description Create a new transfer between wallets, optionally with a message and a category. Public transfers show in the kudos feed; the message of other transfers is seen by the receiver (recipient_only, the default) or by the sender alone (private).
 // @Tags transfers
 // @Accept json
 // @Produce json
 // @Param transfer body TransferRequest true "Transfer details"
-// @Success 200 {object} models.Transfer
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
+// @Success 201 {object} models.Transfer
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
-// @Failure 403 {object} ErrorResponse "Insufficient funds or invalid PIN"
+// @Failure 403 {object} ErrorResponse "Invalid PIN or sender wallet not owned"
+// @Failure 404 {object} ErrorResponse "Sender or receiver wallet not found"
+// @Failure 409 {object} ErrorResponse "Idempotency key reused with a different request or still in progress"
+// @Failure 422 {object} ErrorResponse "Insufficient funds, currency mismatch or self-transfer"
 // @Security ApiKeyAuth
 // @Router /transfer [post]
 func InitiateTransferHandler(transferService *services.TransferService) gin.HandlerFunc {
@@ -41,29 +53,107 @@ func InitiateTransferHandler(transferService *services.TransferService) gin.Hand
 			return
 		}
 
-		userID := c.GetInt("userID")
-
 		transfer, err := transferService.InitiateTransfer(
-			userID,
+			actorFrom(c),
 			req.SenderWalletID,
 			req.ReceiverWalletID,
 			req.Amount,
 			req.IsAnonymous,
+			models.TransferNote{Message: req.Message, Category: req.Category, Visibility: req.Visibility},
 			req.Pin,
 		)
 		if err != nil {
+			respondTransferError(c, err, transfer)
+			return
+		}
+
+		c.JSON(http.StatusCreated, transfer)
+	}
+}
+
+// InitiatePseudonymousTransferHandler handles a transfer out of a pseudonymous wallet.
+// @Summary Initiate a pseudonymous transfer
+// @Description Transfer coins out of a pseudonymous wallet. The request is authorized by an ECDSA P-256 signature over "sender_wallet_id:receiver_wallet_id:amount:nonce:expires_at" made with the wallet's private key. Each nonce can only be used once per wallet.
+// @Tags transfers
+// @Accept json
+// @Produce json
+// @Param transfer body PseudonymousTransferRequest true "Signed transfer"
+// @Success 201 {object} models.Transfer
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Invalid or expired signature"
+// @Failure 403 {object} ErrorResponse "Wallet key revoked"
+// @Failure 404 {object} ErrorResponse "Sender or receiver wallet not found"
+// @Failure 409 {object} ErrorResponse "Nonce already used"
+// @Failure 422 {object} ErrorResponse "Insufficient funds, currency mismatch or self-transfer"
+// @Router /transfer/pseudonymous [post]
+func InitiatePseudonymousTransferHandler(transferService *services.TransferService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PseudonymousTransferRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
+		transfer, err := transferService.InitiatePseudonymousTransfer(
+			actorFrom(c),
+			req.SenderWalletID,
+			req.ReceiverWalletID,
+			req.Amount,
+			req.Nonce,
+			req.ExpiresAt,
+			req.Signature,
+		)
+		if err != nil {
+			respondTransferError(c, err, transfer)
+			return
+		}
+
 		c.JSON(http.StatusCreated, transfer)
 	}
 }
 
+// respondTransferError writes the error of a transfer. transfer is set when the transfer was
+// recorded but could not be executed. Unexpected errors are logged instead of shown to the client.
+func respondTransferError(c *gin.Context, err error, transfer *models.Transfer) {
+	status := transferErrorStatus(err)
+	body := gin.H{"error": err.Error()}
+	if status == http.StatusInternalServerError {
+		log.Printf("Failed to execute transfer: %v", err)
+		body["error"] = "failed to execute transfer"
+	}
+	if transfer != nil {
+		body["transfer"] = transfer
+	}
+	c.JSON(status, body)
+}
+
+// transferErrorStatus maps transfer errors to HTTP status codes
+func transferErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidAmount), errors.Is(err, services.ErrInvalidNonce),
+		errors.Is(err, services.ErrSignatureExpiryTooFar), errors.Is(err, services.ErrMessageTooLong),
+		errors.Is(err, services.ErrInvalidCategory), errors.Is(err, services.ErrInvalidVisibility):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidPIN), errors.Is(err, services.ErrWalletNotOwned), errors.Is(err, services.ErrWalletKeyRevoked):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidSignature), errors.Is(err, services.ErrSignatureExpired):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrSenderWalletNotFound), errors.Is(err, services.ErrReceiverWalletNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrNonceReused):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrCurrencyMismatch), errors.Is(err, services.ErrSelfTransfer),
+		errors.Is(err, repository.ErrInsufficientFunds):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // GetTransferStatusHandler handles checking the status of a transfer.
 // GetTransferStatusHandler retrieves the status of a transfer
 // @Summary Get transfer status
-// @Description Get the current status of a transfer
+// @Description Get the current status of a transfer of the current user, as its sender or its receiver. The receiver of an anonymous transfer is not shown the sender wallet, and the message and category of a private transfer are only shown to the sender.
 // @Tags transfers
 // @Produce json
 // @Param id path integer true "Transfer ID"
@@ -81,11 +171,15 @@ func GetTransferStatusHandler(transferService *services.TransferService) gin.Han
 			return
 		}
 
-		transfer, err := transferService.GetTransferStatus(id)
-		if err != nil {
+		transfer, err := transferService.GetTransferStatus(c.GetInt("userID"), id)
+		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "transfer not found"})
 			return
 		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch transfer"})
+			return
+		}
 
 		c.JSON(http.StatusOK, transfer)
 	}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"verve/internal/api/middleware"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterTreasuryRoutes sets up the admin treasury routes
+// @Summary Register treasury routes
+// @Description Register admin routes for triggering and monitoring treasury grant cycles
+// @Tags treasury
+func RegisterTreasuryRoutes(router *gin.Engine, treasuryService *services.TreasuryService) {
+	treasuryRoutes := router.Group("/api/admin/treasury")
+	treasuryRoutes.Use(middleware.AuthMiddleware(), middleware.RequirePermission("manage_treasury"))
+	{
+		treasuryRoutes.GET("", GetTreasuryHandler(treasuryService))
+		treasuryRoutes.POST("/cycles", StartGrantCycleHandler(treasuryService))
+		treasuryRoutes.GET("/cycles", ListGrantCyclesHandler(treasuryService))
+		treasuryRoutes.GET("/cycles/:id", GetGrantCycleHandler(treasuryService))
+		treasuryRoutes.GET("/cycles/:id/report", GetGrantCycleReportHandler(treasuryService))
+	}
+}
+
+// GetTreasuryHandler returns the treasury wallet
+// @Summary Get treasury
+// @Description Get the treasury wallet and the configured grant amount
+// @Tags treasury
+// @Produce json
+// @Success 200 {object} TreasuryResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Treasury wallet not found"
+// @Security ApiKeyAuth
+// @Router /admin/treasury [get]
+func GetTreasuryHandler(treasuryService *services.TreasuryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, wallet, err := treasuryService.TreasuryWallet()
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, TreasuryResponse{
+			Username:           user.Username,
+			Wallet:             wallet,
+			GrantAmountPerUser: treasuryService.GrantAmountPerUser(),
+		})
+	}
+}
+
+// StartGrantCycleHandler triggers a new grant cycle
+// @Summary Start grant cycle
+// @Description Grant the configured amount from the treasury to every active user. The cycle runs in the background.
+// @Tags treasury
+// @Produce json
+// @Success 202 {object} models.GrantCycle
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 409 {object} ErrorResponse "A grant cycle is already running"
+// @Failure 422 {object} ErrorResponse "Treasury not configured"
+// @Security ApiKeyAuth
+// @Router /admin/treasury/cycles [post]
+func StartGrantCycleHandler(treasuryService *services.TreasuryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cycle, err := treasuryService.StartGrantCycle(actorFrom(c))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrGrantCycleInProgress):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			case errors.Is(err, services.ErrTreasuryNotConfigured), errors.Is(err, services.ErrTreasuryWalletMissing):
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start grant cycle"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusAccepted, cycle)
+	}
+}
+
+// ListGrantCyclesHandler lists recent grant cycles
+// @Summary List grant cycles
+// @Description List the most recent grant cycles with their progress
+// @Tags treasury
+// @Produce json
+// @Param limit query integer false "Maximum number of cycles (default 20, max 100)"
+// @Success 200 {array} models.GrantCycle
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Security ApiKeyAuth
+// @Router /admin/treasury/cycles [get]
+func ListGrantCyclesHandler(treasuryService *services.TreasuryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		cycles, err := treasuryService.ListGrantCycles(limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch grant cycles"})
+			return
+		}
+
+		c.JSON(http.StatusOK, cycles)
+	}
+}
+
+// GetGrantCycleHandler returns the progress of a grant cycle
+// @Summary Get grant cycle
+// @Description Get a grant cycle and how many payouts are pending, completed or failed
+// @Tags treasury
+// @Produce json
+// @Param id path integer true "Cycle ID"
+// @Success 200 {object} models.GrantCycle
+// @Failure 400 {object} ErrorResponse "Invalid cycle ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Cycle not found"
+// @Security ApiKeyAuth
+// @Router /admin/treasury/cycles/{id} [get]
+func GetGrantCycleHandler(treasuryService *services.TreasuryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cycle ID"})
+			return
+		}
+
+		cycle, err := treasuryService.GetGrantCycle(id)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Grant cycle not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch grant cycle"})
+			return
+		}
+
+		c.JSON(http.StatusOK, cycle)
+	}
+}
+
+// GetGrantCycleReportHandler returns the per-user results of a grant cycle
+// @Summary Get grant cycle report
+// @Description Get the payout result for every user in a grant cycle
+// @Tags treasury
+// @Produce json
+// @Param id path integer true "Cycle ID"
+// @Success 200 {object} GrantCycleReportResponse
+// @Failure 400 {object} ErrorResponse "Invalid cycle ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Cycle not found"
+// @Security ApiKeyAuth
+// @Router /admin/treasury/cycles/{id}/report [get]
+func GetGrantCycleReportHandler(treasuryService *services.TreasuryService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cycle ID"})
+			return
+		}
+
+		cycle, payouts, err := treasuryService.GetGrantCycleReport(id)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Grant cycle not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch grant cycle report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, GrantCycleReportResponse{
+			Cycle:   cycle,
+			Payouts: payouts,
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"net/http"
 	"strconv"
 	"verve/internal/api/middleware"
-	"verve/internal/auth"
 	"verve/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -14,16 +13,16 @@ import (
 // @Summary Register user routes
 // @Description Register all user-related routes including authentication and user management
 // @Tags users
-func RegisterUserRoutes(router *gin.Engine, userService *services.UserService) {
+func RegisterUserRoutes(router *gin.Engine, userService *services.UserService, sessionService *services.SessionService) {
 	userRoutes := router.Group("/api/user")
 	{
-		userRoutes.POST("/register", middleware.AuthMiddleware(), middleware.RoleMiddleware("admin"), CreateUserHandler(userService))
+		userRoutes.POST("/register", middleware.AuthMiddleware(), middleware.RequirePermission("create_user"), CreateUserHandler(userService))
 		userRoutes.GET("/connected", middleware.AuthMiddleware(), GetAllUsersHandler(userService))
 		userRoutes.GET("/:id", middleware.AuthMiddleware(), GetUserHandler(userService))
 		userRoutes.POST("/:id/pin", middleware.AuthMiddleware(), SetPinHandler(userService))
 		userRoutes.PUT("/:id", middleware.AuthMiddleware(), UpdateUserHandler(userService))
 	}
-	router.POST("/api/auth/login", LoginHandler(userService))
+	router.POST("/api/auth/login", LoginHandler(userService, sessionService))
 }
 
 // LoginHandler authenticates users
@@ -76,7 +75,7 @@ func UpdateUserHandler(userService *services.UserService) gin.HandlerFunc {
 			return
 		}
 
-		user, err := userService.UpdateUser(userID, req.DisplayName, req.ProfilePhotoURL, req.PinRequiredForTransfer)
+		user, err := userService.UpdateUser(actorFrom(c), userID, req.DisplayName, req.ProfilePhotoURL, req.PinRequiredForTransfer)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 			return
@@ -85,9 +84,9 @@ func UpdateUserHandler(userService *services.UserService) gin.HandlerFunc {
 	}
 }
 
-// CreateUserHandler handles user creation (admin only)
+// CreateUserHandler handles user creation
 // @Summary Create new user
-// @Description Create a new user with specified roles (admin only)
+// @Description Create a new user with specified roles (requires the create_user permission)
 // @Tags users
 // @Accept json
 // @Produce json
@@ -95,7 +94,7 @@ func UpdateUserHandler(userService *services.UserService) gin.HandlerFunc {
 // @Success 201 {object} models.User
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Unauthorized"
-// @Failure 403 {object} ErrorResponse "Forbidden - Admin only"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the create_user permission"
 // @Security ApiKeyAuth
 // @Router /api/user/register [post]
 func CreateUserHandler(userService *services.UserService) gin.HandlerFunc {
@@ -106,7 +105,7 @@ func CreateUserHandler(userService *services.UserService) gin.HandlerFunc {
 			return
 		}
 
-		user, err := userService.CreateUser(req.Username, req.Password, req.Pin, req.Roles)
+		user, err := userService.CreateUser(actorFrom(c), req.Username, req.Password, req.Pin, req.Roles)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 			return
@@ -125,7 +124,7 @@ func SetPinHandler(userService *services.UserService) gin.HandlerFunc {
 			return
 		}
 
-		if err := userService.SetPin(userID, req.Pin); err != nil {
+		if err := userService.SetPin(actorFrom(c), userID, req.Pin); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set PIN"})
 			return
 		}
@@ -185,7 +184,7 @@ func GetAllUsersHandler(userService *services.UserService) gin.HandlerFunc {
 
 // LoginHandler authenticates users
 // @Summary User login
-// @Description Authenticate a user and return a JWT token
+// @Description Authenticate a user and start a session, returning a JWT access token and a refresh token
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -194,7 +193,7 @@ func GetAllUsersHandler(userService *services.UserService) gin.HandlerFunc {
 // @Failure 400 {object} ErrorResponse "Invalid request"
 // @Failure 401 {object} ErrorResponse "Invalid credentials"
 // @Router /api/auth/login [post]
-func LoginHandler(userService *services.UserService) gin.HandlerFunc {
+func LoginHandler(userService *services.UserService, sessionService *services.SessionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req LoginRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -208,18 +207,12 @@ func LoginHandler(userService *services.UserService) gin.HandlerFunc {
 			return
 		}
 
-		token, err := auth.GenerateJWT(userID, roles)
+		tokens, err := sessionService.StartSession(userID, roles, c.Request.UserAgent(), c.ClientIP())
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"token": token,
-			"user": gin.H{
-				"username": req.Username,
-			},
-			"refresh_token": "",
-		})
+		c.JSON(http.StatusOK, newLoginResponse(req.Username, tokens))
 	}
 }
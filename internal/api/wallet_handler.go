@@ -1,9 +1,13 @@
 package api
 
 import (
+	"encoding/base64"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 	"verve/internal/api/middleware"
+	"verve/internal/models"
 	"verve/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +25,7 @@ func RegisterWalletRoutes(router *gin.Engine, walletService *services.WalletServ
 		walletRoutes.POST("", CreateWalletHandler(walletService))
 		walletRoutes.GET("", GetUserWalletsHandler(walletService))
 		walletRoutes.GET("/:wallet_id", GetWalletHandler(walletService))
+		walletRoutes.GET("/:wallet_id/transactions", GetWalletTransactionsHandler(walletService))
 	}
 }
 
@@ -154,3 +159,152 @@ func GetWalletHandler(walletService *services.WalletService) gin.HandlerFunc {
 		c.JSON(http.StatusOK, wallet)
 	}
 }
+
+// GetWalletTransactionsHandler retrieves the transaction history of a wallet
+// @Summary Get wallet transaction history
+// @Description Get the statement of a wallet belonging to the authenticated user, newest first, with the running balance after each entry. Senders of anonymous transfers are not shown. Transfers that paid a payment request carry its ID.
+// @Tags wallets
+// @Produce json
+// @Param id path integer true "User ID"
+// @Param wallet_id path integer true "Wallet ID"
+// @Param from query string false "Only entries at or after this time (RFC3339)"
+// @Param to query string false "Only entries before this time (RFC3339)"
+// @Param direction query string false "sent or received"
+// @Param counterparty_wallet_id query integer false "Only entries with this counterparty wallet"
+// @Param min_amount query integer false "Minimum amount"
+// @Param max_amount query integer false "Maximum amount"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query integer false "Page size (default 20, max 100)"
+// @Success 200 {object} TransactionHistoryResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Can only view your own wallet"
+// @Failure 404 {object} ErrorResponse "Wallet not found"
+// @Security ApiKeyAuth
+// @Router /user/{id}/wallets/{wallet_id}/transactions [get]
+func GetWalletTransactionsHandler(walletService *services.WalletService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		walletID, err := strconv.ParseInt(c.Param("wallet_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet ID"})
+			return
+		}
+
+		if c.GetInt("userID") != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only view your own wallet"})
+			return
+		}
+
+		filter, err := parseHistoryFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		entries, nextBeforeID, err := walletService.GetTransactionHistory(userID, walletID, filter)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrWalletNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+			case errors.Is(err, services.ErrWalletAccessDenied):
+				c.JSON(http.StatusForbidden, gin.H{"error": "This wallet does not belong to you"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+			}
+			return
+		}
+
+		resp := TransactionHistoryResponse{Items: entries}
+		if resp.Items == nil {
+			resp.Items = []*models.StatementEntry{}
+		}
+		if nextBeforeID > 0 {
+			resp.NextCursor = encodeHistoryCursor(nextBeforeID)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// parseHistoryFilter reads the transaction history filters from the query string
+func parseHistoryFilter(c *gin.Context) (models.TransactionHistoryFilter, error) {
+	var filter models.TransactionHistoryFilter
+
+	parseTime := func(name string) (*time.Time, error) {
+		value := c.Query(name)
+		if value == "" {
+			return nil, nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.New("Invalid " + name + ", expected RFC3339")
+		}
+		return &t, nil
+	}
+	parseInt := func(name string) (*int64, error) {
+		value := c.Query(name)
+		if value == "" {
+			return nil, nil
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errors.New("Invalid " + name)
+		}
+		return &n, nil
+	}
+
+	var err error
+	if filter.From, err = parseTime("from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTime("to"); err != nil {
+		return filter, err
+	}
+	if filter.CounterpartyWalletID, err = parseInt("counterparty_wallet_id"); err != nil {
+		return filter, err
+	}
+	if filter.MinAmount, err = parseInt("min_amount"); err != nil {
+		return filter, err
+	}
+	if filter.MaxAmount, err = parseInt("max_amount"); err != nil {
+		return filter, err
+	}
+
+	switch direction := c.Query("direction"); direction {
+	case "", models.DirectionSent, models.DirectionReceived:
+		filter.Direction = direction
+	default:
+		return filter, errors.New("Invalid direction, expected sent or received")
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if filter.BeforeEntryID, err = decodeHistoryCursor(cursor); err != nil {
+			return filter, errors.New("Invalid cursor")
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			return filter, errors.New("Invalid limit")
+		}
+	}
+	return filter, nil
+}
+
+// History cursors are opaque to clients; they wrap the last entry ID of a page
+func encodeHistoryCursor(entryID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(entryID, 10)))
+}
+
+func decodeHistoryCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(raw), 10, 64)
+}
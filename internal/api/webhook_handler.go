@@ -0,0 +1,300 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"verve/internal/api/middleware"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWebhookRoutes sets up the admin routes for webhook endpoints
+// @Summary Register webhook routes
+// @Description Register admin routes to manage webhook endpoints and their delivery log
+// @Tags webhooks
+func RegisterWebhookRoutes(router *gin.Engine, webhookService *services.WebhookService) {
+	webhookRoutes := router.Group("/api/admin/webhooks")
+	webhookRoutes.Use(middleware.AuthMiddleware(), middleware.RequirePermission("manage_webhooks"))
+	{
+		webhookRoutes.GET("", ListWebhooksHandler(webhookService))
+		webhookRoutes.POST("", CreateWebhookHandler(webhookService))
+		webhookRoutes.GET("/:id", GetWebhookHandler(webhookService))
+		webhookRoutes.PUT("/:id", UpdateWebhookHandler(webhookService))
+		webhookRoutes.DELETE("/:id", DeleteWebhookHandler(webhookService))
+		webhookRoutes.GET("/:id/deliveries", ListWebhookDeliveriesHandler(webhookService))
+		webhookRoutes.POST("/:id/deliveries/:deliveryId/replay", ReplayWebhookDeliveryHandler(webhookService))
+	}
+}
+
+// ListWebhooksHandler lists webhook endpoints
+// @Summary List webhook endpoints
+// @Description List every webhook endpoint and the event types it is subscribed to
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} models.WebhookEndpoint
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_webhooks permission"
+// @Security ApiKeyAuth
+// @Router /admin/webhooks [get]
+func ListWebhooksHandler(webhookService *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		endpoints, err := webhookService.ListEndpoints()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+			return
+		}
+		if endpoints == nil {
+			endpoints = []*models.WebhookEndpoint{}
+		}
+
+		c.JSON(http.StatusOK, endpoints)
+	}
+}
+
+// CreateWebhookHandler registers a webhook endpoint
+// @Summary Register a webhook endpoint
+// @Description Register an endpoint to receive events of the given types. The response holds the secret deliveries are signed with; it is not shown again.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body CreateWebhookRequest true "Endpoint details"
+// @Success 201 {object} CreateWebhookResponse
+// @Failure 400 {object} ErrorResponse "Invalid URL or event types"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_webhooks permission"
+// @Security ApiKeyAuth
+// @Router /admin/webhooks [post]
+func CreateWebhookHandler(webhookService *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		endpoint, err := webhookService.CreateEndpoint(actorFrom(c), req.URL, req.Description, req.EventTypes)
+		if err != nil {
+			respondWebhookError(c, err, "Failed to create webhook")
+			return
+		}
+
+		c.JSON(http.StatusCreated, CreateWebhookResponse{Webhook: endpoint, Secret: endpoint.Secret})
+	}
+}
+
+// GetWebhookHandler retrieves a webhook endpoint
+// @Summary Get a webhook endpoint
+// @Description Get a webhook endpoint by ID
+// @Tags webhooks
+// @Produce json
+// @Param id path integer true "Webhook ID"
+// @Success 200 {object} models.WebhookEndpoint
+// @Failure 400 {object} ErrorResponse "Invalid webhook ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_webhooks permission"
+// @Failure 404 {object} ErrorResponse "Webhook not found"
+// @Security ApiKeyAuth
+// @Router /admin/webhooks/{id} [get]
+func GetWebhookHandler(webhookService *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+			return
+		}
+
+		endpoint, err := webhookService.GetEndpoint(id)
+		if err != nil {
+			respondWebhookError(c, err, "Failed to fetch webhook")
+			return
+		}
+
+		c.JSON(http.StatusOK, endpoint)
+	}
+}
+
+// UpdateWebhookHandler updates a webhook endpoint
+// @Summary Update a webhook endpoint
+// @Description Change the URL, description or event types of an endpoint, or disable it. Omitted fields are kept.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path integer true "Webhook ID"
+// @Param webhook body UpdateWebhookRequest true "Fields to change"
+// @Success 200 {object} models.WebhookEndpoint
+// @Failure 400 {object} ErrorResponse "Invalid URL or event types"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_webhooks permission"
+// @Failure 404 {object} ErrorResponse "Webhook not found"
+// @Security ApiKeyAuth
+// @Router /admin/webhooks/{id} [put]
+func UpdateWebhookHandler(webhookService *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+			return
+		}
+
+		var req UpdateWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		endpoint, err := webhookService.UpdateEndpoint(actorFrom(c), id, req.URL, req.Description, req.EventTypes, req.IsActive)
+		if err != nil {
+			respondWebhookError(c, err, "Failed to update webhook")
+			return
+		}
+
+		c.JSON(http.StatusOK, endpoint)
+	}
+}
+
+// DeleteWebhookHandler deletes a webhook endpoint
+// @Summary Delete a webhook endpoint
+// @Description Delete an endpoint together with its delivery log
+// @Tags webhooks
+// @Produce json
+// @Param id path integer true "Webhook ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse "Invalid webhook ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_webhooks permission"
+// @Failure 404 {object} ErrorResponse "Webhook not found"
+// @Security ApiKeyAuth
+// @Router /admin/webhooks/{id} [delete]
+func DeleteWebhookHandler(webhookService *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+			return
+		}
+
+		if err := webhookService.DeleteEndpoint(actorFrom(c), id); err != nil {
+			respondWebhookError(c, err, "Failed to delete webhook")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+	}
+}
+
+// ListWebhookDeliveriesHandler lists the delivery log of a webhook endpoint
+// @Summary List webhook deliveries
+// @Description List the deliveries of an endpoint, newest first, with the outcome of their last attempt
+// @Tags webhooks
+// @Produce json
+// @Param id path integer true "Webhook ID"
+// @Param status query string false "Only deliveries in this status: pending, succeeded or failed"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query integer false "Page size (default 50, max 200)"
+// @Success 200 {object} WebhookDeliveriesResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_webhooks permission"
+// @Security ApiKeyAuth
+// @Router /admin/webhooks/{id}/deliveries [get]
+func ListWebhookDeliveriesHandler(webhookService *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+			return
+		}
+
+		filter := models.WebhookDeliveryFilter{EndpointID: id, Status: c.Query("status")}
+		switch filter.Status {
+		case "", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryFailed:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+			return
+		}
+		if cursor := c.Query("cursor"); cursor != "" {
+			if filter.BeforeID, err = decodeHistoryCursor(cursor); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return
+			}
+		}
+		if limit := c.Query("limit"); limit != "" {
+			if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+				return
+			}
+		}
+
+		deliveries, nextBeforeID, err := webhookService.ListDeliveries(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+			return
+		}
+
+		resp := WebhookDeliveriesResponse{Items: deliveries}
+		if resp.Items == nil {
+			resp.Items = []*models.WebhookDelivery{}
+		}
+		if nextBeforeID > 0 {
+			resp.NextCursor = encodeHistoryCursor(nextBeforeID)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// ReplayWebhookDeliveryHandler replays a webhook delivery
+// @Summary Replay a webhook delivery
+// @Description Queue a new delivery of the same body to the endpoint, whatever the outcome of the original
+// @Tags webhooks
+// @Produce json
+// @Param id path integer true "Webhook ID"
+// @Param deliveryId path integer true "Delivery ID"
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 400 {object} ErrorResponse "Invalid ID"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden - Requires the manage_webhooks permission"
+// @Failure 404 {object} ErrorResponse "Delivery not found"
+// @Security ApiKeyAuth
+// @Router /admin/webhooks/{id}/deliveries/{deliveryId}/replay [post]
+func ReplayWebhookDeliveryHandler(webhookService *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+			return
+		}
+		deliveryID, err := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+			return
+		}
+
+		replay, err := webhookService.ReplayDelivery(actorFrom(c), id, deliveryID)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay delivery"})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, replay)
+	}
+}
+
+func respondWebhookError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidWebhookURL), errors.Is(err, services.ErrNoWebhookEvents),
+		errors.Is(err, services.ErrUnknownEventType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -10,31 +10,78 @@ import (
 )
 
 type App struct {
-	db              *sql.DB
-	router          *gin.Engine
-	userService     *services.UserService
-	walletService   *services.WalletService
-	transferService *services.TransferService
-	badgeService    *services.BadgeService
+	db                        *sql.DB
+	router                    *gin.Engine
+	userService               *services.UserService
+	walletService             *services.WalletService
+	transferService           *services.TransferService
+	badgeService              *services.BadgeService
+	treasuryService           *services.TreasuryService
+	idempotencyService        *services.IdempotencyService
+	ledgerService             *services.LedgerService
+	pseudonymousWalletService *services.PseudonymousWalletService
+	sessionService            *services.SessionService
+	achievementService        *services.AchievementService
+	activityService           *services.ActivityService
+	leaderboardService        *services.LeaderboardService
+	roleService               *services.RoleService
+	auditService              *services.AuditService
+	eventDispatcher           *services.EventDispatcher
+	webhookService            *services.WebhookService
+	notificationService       *services.NotificationService
+	kudosService              *services.KudosService
+	paymentRequestService     *services.PaymentRequestService
+	scheduledTransferService  *services.ScheduledTransferService
 }
 
-func NewApp(db *sql.DB, router *gin.Engine, userService *services.UserService, walletService *services.WalletService, transferService *services.TransferService, badgeService *services.BadgeService) *App {
+func NewApp(db *sql.DB, router *gin.Engine, userService *services.UserService, walletService *services.WalletService, transferService *services.TransferService, badgeService *services.BadgeService, treasuryService *services.TreasuryService, idempotencyService *services.IdempotencyService, ledgerService *services.LedgerService, pseudonymousWalletService *services.PseudonymousWalletService, sessionService *services.SessionService, achievementService *services.AchievementService, activityService *services.ActivityService, leaderboardService *services.LeaderboardService, roleService *services.RoleService, auditService *services.AuditService, eventDispatcher *services.EventDispatcher, webhookService *services.WebhookService, notificationService *services.NotificationService, kudosService *services.KudosService, paymentRequestService *services.PaymentRequestService, scheduledTransferService *services.ScheduledTransferService) *App {
 	return &App{
-		db:              db,
-		router:          router,
-		userService:     userService,
-		walletService:   walletService,
-		transferService: transferService,
-		badgeService:    badgeService,
+		db:                        db,
+		router:                    router,
+		userService:               userService,
+		walletService:             walletService,
+		transferService:           transferService,
+		badgeService:              badgeService,
+		treasuryService:           treasuryService,
+		idempotencyService:        idempotencyService,
+		ledgerService:             ledgerService,
+		pseudonymousWalletService: pseudonymousWalletService,
+		sessionService:            sessionService,
+		achievementService:        achievementService,
+		activityService:           activityService,
+		leaderboardService:        leaderboardService,
+		roleService:               roleService,
+		auditService:              auditService,
+		eventDispatcher:           eventDispatcher,
+		webhookService:            webhookService,
+		notificationService:       notificationService,
+		kudosService:              kudosService,
+		paymentRequestService:     paymentRequestService,
+		scheduledTransferService:  scheduledTransferService,
 	}
 }
 
 func (a *App) SetupRoutes() {
 	api.RegisterRoutes(a.router, a.db)
-	api.RegisterUserRoutes(a.router, a.userService)
+	api.RegisterUserRoutes(a.router, a.userService, a.sessionService)
+	api.RegisterSessionRoutes(a.router, a.sessionService)
 	api.RegisterWalletRoutes(a.router, a.walletService)
-	api.RegisterTransferRoutes(a.router, a.transferService)
+	api.RegisterTransferRoutes(a.router, a.transferService, a.idempotencyService)
+	api.RegisterPaymentRequestRoutes(a.router, a.paymentRequestService, a.idempotencyService)
+	api.RegisterScheduledTransferRoutes(a.router, a.scheduledTransferService, a.idempotencyService)
+	api.RegisterKudosRoutes(a.router, a.kudosService)
 	api.RegisterBadgeRoutes(a.router, a.badgeService)
+	api.RegisterAchievementRoutes(a.router, a.achievementService)
+	api.RegisterActivityRoutes(a.router, a.activityService)
+	api.RegisterNotificationRoutes(a.router, a.notificationService)
+	api.RegisterLeaderboardRoutes(a.router, a.leaderboardService)
+	api.RegisterRoleRoutes(a.router, a.roleService)
+	api.RegisterAuditRoutes(a.router, a.auditService)
+	api.RegisterEventRoutes(a.router, a.eventDispatcher)
+	api.RegisterWebhookRoutes(a.router, a.webhookService)
+	api.RegisterTreasuryRoutes(a.router, a.treasuryService)
+	api.RegisterLedgerRoutes(a.router, a.ledgerService)
+	api.RegisterPseudonymousWalletRoutes(a.router, a.pseudonymousWalletService)
 }
 
 func (a *App) Run(addr string) error {
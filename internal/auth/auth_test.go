@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 	"verve/internal/api"
 	"verve/internal/models"
 	"verve/internal/services"
@@ -31,7 +32,9 @@ func TestAuthFlow(t *testing.T) {
 	// Initialize auth service with mock repository
 	repo := newMockUserRepo()
 	repo.users["admin@example.com"].PasswordHash = hash
-	authService := services.NewAuthService(repo)
+	activityService := services.NewActivityService(&mockActivityRepo{}, nil)
+	sessionService := services.NewSessionService(&mockSessionRepo{}, nil, activityService, 0)
+	authService := services.NewAuthService(repo, sessionService, services.NewAuditService(&mockAuditRepo{}))
 
 	// Initialize test OAuth config
 	auth.InitializeTestOAuth2Config(&auth.OAuth2Config{
@@ -67,14 +70,16 @@ func TestAuthFlow(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		var resp struct {
-			Token string `json:"token"`
-			User  struct {
+			Token        string `json:"token"`
+			RefreshToken string `json:"refresh_token"`
+			User         struct {
 				Username string `json:"username"`
 			} `json:"user"`
 		}
 		err := json.NewDecoder(w.Body).Decode(&resp)
 		assert.NoError(t, err)
 		assert.NotEmpty(t, resp.Token)
+		assert.NotEmpty(t, resp.RefreshToken)
 		assert.Equal(t, "admin@example.com", resp.User.Username)
 	})
 
@@ -150,6 +155,66 @@ func TestAuthFlow(t *testing.T) {
 	})
 }
 
+// Mock activity repository for testing, activity is recorded but never read
+type mockActivityRepo struct{}
+
+func (m *mockActivityRepo) Record(event *models.ActivityEvent) error {
+	return nil
+}
+
+func (m *mockActivityRepo) FindActiveDays(userID int, activityType string, minActivities int) ([]time.Time, error) {
+	return nil, nil
+}
+
+// Mock audit repository for testing, entries are discarded
+type mockAuditRepo struct{}
+
+func (m *mockAuditRepo) Record(entry *models.AuditEntry) error {
+	return nil
+}
+
+func (m *mockAuditRepo) Find(filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	return nil, nil
+}
+
+// Mock session repository for testing, sessions are only ever started
+type mockSessionRepo struct {
+	sessions []*models.Session
+}
+
+func (m *mockSessionRepo) Create(session *models.Session, refreshTokenHash string) error {
+	m.sessions = append(m.sessions, session)
+	return nil
+}
+
+func (m *mockSessionRepo) FindByID(id string) (*models.Session, error) {
+	return nil, fmt.Errorf("session not found")
+}
+
+func (m *mockSessionRepo) FindByRefreshToken(tokenHash string) (*models.Session, bool, error) {
+	return nil, false, fmt.Errorf("session not found")
+}
+
+func (m *mockSessionRepo) RotateRefreshToken(sessionID, oldHash, newHash string, expiresAt time.Time, userAgent, ipAddress string) error {
+	return nil
+}
+
+func (m *mockSessionRepo) FindActiveByUser(userID int) ([]*models.Session, error) {
+	return m.sessions, nil
+}
+
+func (m *mockSessionRepo) Revoke(sessionID, reason string) error {
+	return nil
+}
+
+func (m *mockSessionRepo) RevokeAllForUser(userID int, exceptSessionID, reason string) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockSessionRepo) DeleteExpired() (int64, error) {
+	return 0, nil
+}
+
 // Mock user repository for testing
 type mockUserRepo struct {
 	users map[string]*models.User
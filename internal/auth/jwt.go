@@ -1,46 +1,342 @@
 package auth
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
 	"time"
+	"verve/internal/config"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey = []byte("my_secret_key")
+const defaultTokenTTL = 24 * time.Hour
 
 type Claims struct {
-	UserID int      `json:"user_id"`
-	Roles  []string `json:"roles"`
+	UserID    int      `json:"user_id"`
+	Roles     []string `json:"roles"`
+	SessionID string   `json:"sid,omitempty"` // Session the token was issued for
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID int, roles []string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// jwtKey is a key tokens can be verified with, and signed with when it has a private part
+type jwtKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{} // nil for keys that only verify tokens issued before a rotation
+	verifyKey interface{}
+}
+
+// keySet holds every key accepted for verification and the one used to sign new tokens
+type keySet struct {
+	signing *jwtKey
+	keys    map[string]*jwtKey
+	issuer  string
+	ttl     time.Duration
+}
+
+var (
+	jwtKeys     *keySet
+	jwtKeysLock sync.RWMutex
+)
+
+// InitializeJWT loads the signing keys from config. Without any configured key an
+// ephemeral ES256 key is generated, so tokens do not survive a restart. Its key ID is the
+// thumbprint of its public key, so JWKS caches never mistake it for the key of an earlier run.
+func InitializeJWT(cfg config.JWTConfig) error {
+	ks, err := loadKeySet(cfg)
+	if err != nil {
+		return err
+	}
+	jwtKeysLock.Lock()
+	jwtKeys = ks
+	jwtKeysLock.Unlock()
+	return nil
+}
+
+func currentKeySet() (*keySet, error) {
+	jwtKeysLock.RLock()
+	ks := jwtKeys
+	jwtKeysLock.RUnlock()
+	if ks != nil {
+		return ks, nil
+	}
+
+	jwtKeysLock.Lock()
+	defer jwtKeysLock.Unlock()
+	if jwtKeys == nil {
+		var err error
+		if jwtKeys, err = loadKeySet(config.JWTConfig{}); err != nil {
+			return nil, err
+		}
+	}
+	return jwtKeys, nil
+}
+
+func loadKeySet(cfg config.JWTConfig) (*keySet, error) {
+	ks := &keySet{
+		keys:   make(map[string]*jwtKey),
+		issuer: cfg.Issuer,
+		ttl:    time.Duration(cfg.TokenTTLMinutes) * time.Minute,
+	}
+	if ks.ttl <= 0 {
+		ks.ttl = defaultTokenTTL
+	}
+
+	if len(cfg.Keys) == 0 {
+		log.Printf("No JWT signing keys configured, using an ephemeral key")
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		ks.signing = &jwtKey{id: ecThumbprint(&priv.PublicKey), method: jwt.SigningMethodES256, signKey: priv, verifyKey: &priv.PublicKey}
+		ks.keys[ks.signing.id] = ks.signing
+		return ks, nil
+	}
+
+	for _, keyCfg := range cfg.Keys {
+		key, err := loadKey(keyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", keyCfg.ID, err)
+		}
+		if _, exists := ks.keys[key.id]; exists {
+			return nil, fmt.Errorf("jwt key %q: duplicate key ID", key.id)
+		}
+		ks.keys[key.id] = key
+	}
+
+	signingID := cfg.SigningKeyID
+	if signingID == "" {
+		signingID = cfg.Keys[0].ID
+	}
+	ks.signing = ks.keys[signingID]
+	if ks.signing == nil {
+		return nil, fmt.Errorf("signing key %q is not configured", signingID)
+	}
+	if ks.signing.signKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", signingID)
+	}
+	return ks, nil
+}
+
+func loadKey(cfg config.JWTKeyConfig) (*jwtKey, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("id is required")
+	}
+	key := &jwtKey{id: cfg.ID}
+
+	switch strings.ToUpper(cfg.Algorithm) {
+	case "HS256":
+		secret, err := readKeyMaterial(cfg.Secret, cfg.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("HS256 secret must be at least 32 bytes")
+		}
+		key.method, key.signKey, key.verifyKey = jwt.SigningMethodHS256, secret, secret
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		if err := loadKeyPair(cfg, key, func(pem []byte) (interface{}, interface{}, error) {
+			priv, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, nil, err
+			}
+			return priv, &priv.PublicKey, nil
+		}, func(pem []byte) (interface{}, error) {
+			return jwt.ParseRSAPublicKeyFromPEM(pem)
+		}); err != nil {
+			return nil, err
+		}
+	case "ES256":
+		key.method = jwt.SigningMethodES256
+		if err := loadKeyPair(cfg, key, func(pem []byte) (interface{}, interface{}, error) {
+			priv, err := jwt.ParseECPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, nil, err
+			}
+			if priv.Curve != elliptic.P256() {
+				return nil, nil, errors.New("ES256 requires a P-256 key")
+			}
+			return priv, &priv.PublicKey, nil
+		}, func(pem []byte) (interface{}, error) {
+			pub, err := jwt.ParseECPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			if pub.Curve != elliptic.P256() {
+				return nil, errors.New("ES256 requires a P-256 key")
+			}
+			return pub, nil
+		}); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, expected RS256, ES256 or HS256", cfg.Algorithm)
+	}
+	return key, nil
+}
+
+// loadKeyPair loads the private key of an asymmetric key, or only its public key for verify-only keys
+func loadKeyPair(
+	cfg config.JWTKeyConfig,
+	key *jwtKey,
+	parsePrivate func([]byte) (interface{}, interface{}, error),
+	parsePublic func([]byte) (interface{}, error),
+) error {
+	if cfg.PrivateKey != "" || cfg.PrivateKeyFile != "" {
+		pem, err := readKeyMaterial(cfg.PrivateKey, cfg.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		key.signKey, key.verifyKey, err = parsePrivate(pem)
+		return err
+	}
+
+	pem, err := readKeyMaterial(cfg.PublicKey, cfg.PublicKeyFile)
+	if err != nil {
+		return err
+	}
+	key.verifyKey, err = parsePublic(pem)
+	return err
+}
+
+// readKeyMaterial returns the inline value, or the content of the file when no inline value is set
+func readKeyMaterial(inline, file string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if file == "" {
+		return nil, errors.New("no key material configured")
+	}
+	return os.ReadFile(file)
+}
+
+// GenerateJWT issues an access token for a session of a user
+func GenerateJWT(userID int, roles []string, sessionID string) (string, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
-		Roles:  roles,
+		UserID:    userID,
+		Roles:     roles,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			Issuer:    ks.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ks.ttl)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	token.Header["kid"] = ks.signing.id
+	return token.SignedString(ks.signing.signKey)
 }
 
 func ValidateToken(tokenStr string) (*Claims, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	options := []jwt.ParserOption{jwt.WithExpirationRequired()}
+	if ks.issuer != "" {
+		options = append(options, jwt.WithIssuer(ks.issuer))
+	}
+
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID %q", kid)
+		}
+		// A token must use the algorithm of its key, so a public key is never used as an HMAC secret
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.verifyKey, nil
+	}, options...)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if !token.Valid {
-		return nil, err
+		return nil, errors.New("invalid token")
 	}
 
 	return claims, nil
 }
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS returns the public keys tokens can be verified with. HS256 secrets are never published.
+func PublicJWKS() (*JWKS, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := &JWKS{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			jwk.Kty = "EC"
+			jwk.Crv = "P-256"
+			jwk.X, jwk.Y = ecCoordinates(pub)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
+}
+
+// ecCoordinates encodes the coordinates of a P-256 public key for a JWK
+func ecCoordinates(pub *ecdsa.PublicKey) (x, y string) {
+	xBytes, yBytes := make([]byte, 32), make([]byte, 32)
+	return base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(xBytes)),
+		base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(yBytes))
+}
+
+// ecThumbprint returns the JWK thumbprint (RFC 7638) of a P-256 public key
+func ecThumbprint(pub *ecdsa.PublicKey) string {
+	x, y := ecCoordinates(pub)
+	sum := sha256.Sum256([]byte(`{"crv":"P-256","kty":"EC","x":"` + x + `","y":"` + y + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,131 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"verve/internal/auth"
+	"verve/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTKeyRotation(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	ecPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	rsaPubDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	rsaPubPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaPubDER}))
+
+	// Restore an ephemeral key for the other tests of the package
+	defer auth.InitializeJWT(config.JWTConfig{})
+
+	// Tokens are first signed with the RSA key
+	require.NoError(t, auth.InitializeJWT(config.JWTConfig{
+		Issuer: "verve",
+		Keys:   []config.JWTKeyConfig{{ID: "old", Algorithm: "RS256", PrivateKey: rsaPEM}},
+	}))
+	oldToken, err := auth.GenerateJWT(1, []string{"user"}, "session-1")
+	require.NoError(t, err)
+
+	// After rotation the RSA key only verifies, and new tokens use the EC key
+	require.NoError(t, auth.InitializeJWT(config.JWTConfig{
+		Issuer:       "verve",
+		SigningKeyID: "new",
+		Keys: []config.JWTKeyConfig{
+			{ID: "new", Algorithm: "ES256", PrivateKey: ecPEM},
+			{ID: "old", Algorithm: "RS256", PublicKey: rsaPubPEM},
+		},
+	}))
+
+	t.Run("Tokens of the previous key stay valid", func(t *testing.T) {
+		claims, err := auth.ValidateToken(oldToken)
+		require.NoError(t, err)
+		assert.Equal(t, 1, claims.UserID)
+		assert.Equal(t, "session-1", claims.SessionID)
+	})
+
+	t.Run("New tokens carry the new key ID", func(t *testing.T) {
+		token, err := auth.GenerateJWT(2, nil, "session-2")
+		require.NoError(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+		require.NoError(t, err)
+		assert.Equal(t, "new", parsed.Header["kid"])
+		assert.Equal(t, "ES256", parsed.Method.Alg())
+
+		claims, err := auth.ValidateToken(token)
+		require.NoError(t, err)
+		assert.Equal(t, 2, claims.UserID)
+	})
+
+	t.Run("Public key cannot be used as an HMAC secret", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, &auth.Claims{UserID: 3})
+		token.Header["kid"] = "old"
+		forged, err := token.SignedString([]byte(rsaPubPEM))
+		require.NoError(t, err)
+
+		_, err = auth.ValidateToken(forged)
+		assert.Error(t, err)
+	})
+
+	t.Run("Unknown key IDs are rejected", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, &auth.Claims{UserID: 4})
+		token.Header["kid"] = "unknown"
+		signed, err := token.SignedString(ecKey)
+		require.NoError(t, err)
+
+		_, err = auth.ValidateToken(signed)
+		assert.Error(t, err)
+	})
+
+	t.Run("JWKS publishes both public keys", func(t *testing.T) {
+		jwks, err := auth.PublicJWKS()
+		require.NoError(t, err)
+		require.Len(t, jwks.Keys, 2)
+
+		byID := map[string]auth.JWK{}
+		for _, key := range jwks.Keys {
+			byID[key.Kid] = key
+		}
+		assert.Equal(t, "EC", byID["new"].Kty)
+		assert.Equal(t, "P-256", byID["new"].Crv)
+		assert.Equal(t, "RSA", byID["old"].Kty)
+		assert.Equal(t, "AQAB", byID["old"].E)
+	})
+}
+
+func TestEphemeralKeyID(t *testing.T) {
+	ephemeralKey := func() auth.JWK {
+		require.NoError(t, auth.InitializeJWT(config.JWTConfig{}))
+		jwks, err := auth.PublicJWKS()
+		require.NoError(t, err)
+		require.Len(t, jwks.Keys, 1)
+		return jwks.Keys[0]
+	}
+
+	first := ephemeralKey()
+	second := ephemeralKey()
+
+	// A restart brings a new key under a new ID, so cached keys are never reused for it
+	assert.NotEqual(t, first.Kid, second.Kid)
+
+	// The key ID is the RFC 7638 thumbprint of the published key
+	sum := sha256.Sum256([]byte(`{"crv":"P-256","kty":"EC","x":"` + second.X + `","y":"` + second.Y + `"}`))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(sum[:]), second.Kid)
+}
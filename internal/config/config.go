@@ -7,9 +7,20 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	Treasury TreasuryConfig `yaml:"treasury"`
+	Server          ServerConfig          `yaml:"server"`
+	Database        DatabaseConfig        `yaml:"database"`
+	Treasury        TreasuryConfig        `yaml:"treasury"`
+	Idempotency     IdempotencyConfig     `yaml:"idempotency"`
+	Ledger          LedgerConfig          `yaml:"ledger"`
+	JWT             JWTConfig             `yaml:"jwt"`
+	Session         SessionConfig         `yaml:"session"`
+	Achievements    AchievementsConfig    `yaml:"achievements"`
+	Leaderboard     LeaderboardConfig     `yaml:"leaderboard"`
+	Permissions     PermissionsConfig     `yaml:"permissions"`
+	Events          EventsConfig          `yaml:"events"`
+	Webhooks        WebhooksConfig        `yaml:"webhooks"`
+	PaymentRequests PaymentRequestsConfig `yaml:"payment_requests"`
+	Scheduler       SchedulerConfig       `yaml:"scheduler"`
 }
 
 type TreasuryConfig struct {
@@ -18,6 +29,75 @@ type TreasuryConfig struct {
 	GrantDelayMs       int    `yaml:"grant_delay_ms"`
 }
 
+type IdempotencyConfig struct {
+	KeyTTLMinutes int `yaml:"key_ttl_minutes"`
+}
+
+type LedgerConfig struct {
+	ReconcileIntervalMinutes  int    `yaml:"reconcile_interval_minutes"`
+	CheckpointIntervalMinutes int    `yaml:"checkpoint_interval_minutes"`
+	CheckpointKeyFile         string `yaml:"checkpoint_key_file"`
+}
+
+type JWTConfig struct {
+	Issuer          string         `yaml:"issuer"`
+	TokenTTLMinutes int            `yaml:"token_ttl_minutes"`
+	SigningKeyID    string         `yaml:"signing_key_id"` // Defaults to the first key
+	Keys            []JWTKeyConfig `yaml:"keys"`
+}
+
+// JWTKeyConfig is a key tokens are signed or verified with. Key material is given inline
+// or as a file; keys without a private key only verify tokens issued before a rotation.
+type JWTKeyConfig struct {
+	ID             string `yaml:"id"`
+	Algorithm      string `yaml:"algorithm"` // RS256, ES256 or HS256
+	PrivateKey     string `yaml:"private_key"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKey      string `yaml:"public_key"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+	Secret         string `yaml:"secret"`
+	SecretFile     string `yaml:"secret_file"`
+}
+
+type SessionConfig struct {
+	RefreshTokenTTLHours int `yaml:"refresh_token_ttl_hours"` // A session expires when not refreshed for this long
+}
+
+type AchievementsConfig struct {
+	EvaluateIntervalMinutes int `yaml:"evaluate_interval_minutes"`
+}
+
+type LeaderboardConfig struct {
+	CacheTTLSeconds int `yaml:"cache_ttl_seconds"` // Leaderboards are recomputed this often
+}
+
+type PermissionsConfig struct {
+	CacheTTLSeconds int `yaml:"cache_ttl_seconds"` // How long the permissions of a role are cached
+}
+
+type EventsConfig struct {
+	DispatchIntervalSeconds int `yaml:"dispatch_interval_seconds"` // How often the outbox is checked for due events
+	MaxAttempts             int `yaml:"max_attempts"`              // Failed attempts before an event is dead
+	RetryDelaySeconds       int `yaml:"retry_delay_seconds"`       // Delay before the first retry, doubled for each later one
+}
+
+type WebhooksConfig struct {
+	DeliveryIntervalSeconds int `yaml:"delivery_interval_seconds"` // How often due deliveries are posted
+	TimeoutSeconds          int `yaml:"timeout_seconds"`           // Time an endpoint has to respond
+	MaxAttempts             int `yaml:"max_attempts"`              // Failed attempts before a delivery fails for good
+	RetryDelaySeconds       int `yaml:"retry_delay_seconds"`       // Delay before the first retry, doubled for each later one
+}
+
+type PaymentRequestsConfig struct {
+	ExpiryHours           int `yaml:"expiry_hours"`            // Lifetime of requests created without an expiry
+	ExpiryIntervalSeconds int `yaml:"expiry_interval_seconds"` // How often requests past their expiry are closed
+}
+
+type SchedulerConfig struct {
+	IntervalSeconds    int `yaml:"interval_seconds"`     // How often due scheduled transfers are run
+	MissedAfterSeconds int `yaml:"missed_after_seconds"` // A run this late is missed, see the catch-up policy of its schedule
+}
+
 type ServerConfig struct {
 	Address string `yaml:"address"`
 }
@@ -44,6 +124,9 @@ func LoadConfig(path string) (*Config, error) {
 	if dsn := os.Getenv("DATABASE_DSN"); dsn != "" {
 		config.Database.DSN = dsn
 	}
+	if keyFile := os.Getenv("LEDGER_CHECKPOINT_KEY_FILE"); keyFile != "" {
+		config.Ledger.CheckpointKeyFile = keyFile
+	}
 
 	return config, nil
 }
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Activity types recorded for users
+const (
+	ActivityTypeLogin    = "login" // A sign-in, or a session refreshed when the user came back
+	ActivityTypeTransfer = "transfer"
+)
+
+// ActivityTypes lists every activity type, in the order streaks are reported
+var ActivityTypes = []string{ActivityTypeLogin, ActivityTypeTransfer}
+
+// ActivityEvent is one activity of a user
+type ActivityEvent struct {
+	ID           int64     `json:"id"`
+	UserID       int       `json:"user_id"`
+	ActivityType string    `json:"activity_type"`
+	ReferenceID  *int64    `json:"reference_id,omitempty"` // e.g., the transfer of a transfer activity
+	OccurredAt   time.Time `json:"occurred_at"`
+}
+
+// Streak summarises the consecutive UTC days a user was active
+// @Description Consecutive days of activity of one type
+type Streak struct {
+	ActivityType  string     `json:"activity_type" example:"transfer"`
+	CurrentDays   int        `json:"current_days" example:"4"` // Still running while the user was active today or yesterday
+	LongestDays   int        `json:"longest_days" example:"12"`
+	ActiveToday   bool       `json:"active_today" example:"true"`
+	LastActiveDay *time.Time `json:"last_active_day,omitempty"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records a change: who made it, from which request, what it changed, and the
+// state before and after
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    *int            `json:"actor_id"` // NULL for changes made by the system
+	Action     string          `json:"action" example:"role.permission.grant"`
+	TargetType string          `json:"target_type" example:"role"`
+	TargetID   string          `json:"target_id" example:"manager"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	Diff       json.RawMessage `json:"diff,omitempty" swaggertype:"object"` // Fields that changed, each with its value before and after
+	IPAddress  string          `json:"ip_address,omitempty" example:"203.0.113.7"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	RequestID  string          `json:"request_id,omitempty" example:"4f9c0a7e2b1d6e83"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// Actor is who makes a change and the request they make it from. The zero Actor is the
+// system, making a change outside of any request.
+type Actor struct {
+	UserID    int // 0 for the system
+	IPAddress string
+	UserAgent string
+	RequestID string
+}
+
+// AuditFilter narrows a query of the audit log. Zero fields match every entry.
+type AuditFilter struct {
+	ActorID    *int
+	Action     string
+	TargetType string
+	TargetID   string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+	BeforeID   int64 // Cursor: only entries older than this one, 0 for the first page
+	Limit      int
+}
+
+// Audited actions
+const (
+	AuditActionRoleCreate           = "role.create"
+	AuditActionRoleDelete           = "role.delete"
+	AuditActionRolePermissionGrant  = "role.permission.grant"
+	AuditActionRolePermissionRevoke = "role.permission.revoke"
+	AuditActionUserRoleAssign       = "user.role.assign"
+	AuditActionUserRoleRemove       = "user.role.remove"
+	AuditActionUserCreate           = "user.create"
+	AuditActionUserUpdate           = "user.update"
+	AuditActionUserPinChange        = "user.pin.change"
+	AuditActionUserOAuthSignUp      = "user.oauth.signup"
+	AuditActionUserOAuthLink        = "user.oauth.link"
+	AuditActionUserOAuthUnlink      = "user.oauth.unlink"
+	AuditActionBadgeCreate          = "badge.create"
+	AuditActionBadgeUpdate          = "badge.update"
+	AuditActionBadgeAward           = "badge.award"
+	AuditActionBadgeRevoke          = "badge.revoke"
+	AuditActionTransferCreate       = "transfer.create"
+	AuditActionGrantCycleStart      = "treasury.grant_cycle.start"
+	AuditActionWalletRegister       = "pseudonymous_wallet.register"
+	AuditActionWalletKeyRotate      = "pseudonymous_wallet.key.rotate"
+	AuditActionWalletKeyRevoke      = "pseudonymous_wallet.key.revoke"
+	AuditActionWebhookCreate        = "webhook.create"
+	AuditActionWebhookUpdate        = "webhook.update"
+	AuditActionWebhookDelete        = "webhook.delete"
+	AuditActionWebhookReplay        = "webhook.delivery.replay"
+)
@@ -23,24 +23,81 @@ type AchievementRule struct {
 	BadgeID        int             `json:"badge_id"`
 	RuleType       string          `json:"rule_type"`
 	ConditionValue json.RawMessage `json:"condition_value" swaggertype:"string" example:"{\"min_transactions\":10,\"time_frame\":\"24h\"}"`
+	Level          string          `json:"level,omitempty" example:"silver"` // Level of a tiered badge the rule unlocks, empty for every level
 	CreatedAt      time.Time       `json:"created_at"`
 	CreatedBy      int             `json:"created_by"`
 	IsActive       bool            `json:"is_active"`
 }
 
 type UserBadge struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	BadgeID   int       `json:"badge_id"`
-	AwardedAt time.Time `json:"awarded_at"`
-	AwardedBy *int      `json:"awarded_by"` // Pointer to allow NULL for system-awarded badges
+	ID           int        `json:"id"`
+	UserID       int        `json:"user_id"`
+	BadgeID      int        `json:"badge_id"`
+	Level        string     `json:"level,omitempty"` // Empty for badges without levels
+	AwardedAt    time.Time  `json:"awarded_at"`
+	AwardedBy    *int       `json:"awarded_by"` // Pointer to allow NULL for system-awarded badges
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy    *int       `json:"revoked_by,omitempty"`
+	RevokeReason string     `json:"revoke_reason,omitempty"`
 }
 
+// Levels of a tiered badge, lowest first
+const (
+	BadgeLevelBronze = "bronze"
+	BadgeLevelSilver = "silver"
+	BadgeLevelGold   = "gold"
+)
+
+// BadgeLevels lists the levels of a tiered badge, lowest first
+var BadgeLevels = []string{BadgeLevelBronze, BadgeLevelSilver, BadgeLevelGold}
+
+// BadgeLevelRank orders badge levels: 0 for no level, then 1 for bronze up to 3 for gold.
+// It returns -1 for an unknown level.
+func BadgeLevelRank(level string) int {
+	if level == "" {
+		return 0
+	}
+	for i, known := range BadgeLevels {
+		if level == known {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// UserBadgeEvent records a change to a user's badge award
+type UserBadgeEvent struct {
+	ID            int64     `json:"id"`
+	UserBadgeID   int       `json:"user_badge_id"`
+	UserID        int       `json:"user_id"`
+	BadgeID       int       `json:"badge_id"`
+	EventType     string    `json:"event_type"`
+	PreviousLevel string    `json:"previous_level,omitempty"`
+	Level         string    `json:"level,omitempty"`
+	ActorID       *int      `json:"actor_id"` // NULL for changes made by the system
+	Reason        string    `json:"reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// User badge event types
+const (
+	UserBadgeEventAwarded  = "awarded"
+	UserBadgeEventUpgraded = "upgraded"
+	UserBadgeEventRevoked  = "revoked"
+	UserBadgeEventExpired  = "expired"
+)
+
 // Rule types and condition structures
 const (
-	RuleTypeTransactionCount = "transaction_count"
-	RuleTypeTransferAmount   = "transfer_amount"
-	RuleTypeConsecutiveDays  = "consecutive_days"
+	RuleTypeTransactionCount       = "transaction_count"
+	RuleTypeTransferAmount         = "transfer_amount"
+	RuleTypeConsecutiveDays        = "consecutive_days"
+	RuleTypeDistinctCounterparties = "distinct_counterparties"
+	RuleTypeFirstTransfer          = "first_transfer"
+	RuleTypeHasBadge               = "has_badge"
+	RuleTypeAccountAge             = "account_age"
+	RuleTypeExpression             = "expression" // condition_value holds a RuleExpression
 )
 
 type TransactionCountCondition struct {
@@ -48,6 +105,9 @@ type TransactionCountCondition struct {
 	TimeFrame       string `json:"time_frame"` // e.g., "24h", "7d", "30d"
 }
 
+// DirectionTotal counts coins sent and received
+const DirectionTotal = "total"
+
 type TransferAmountCondition struct {
 	MinAmount int64  `json:"min_amount"`
 	TimeFrame string `json:"time_frame"`
@@ -60,3 +120,21 @@ type ConsecutiveDaysCondition struct {
 	ActivityType  string `json:"activity_type"` // e.g., "login", "transfer"
 	MinActivities int    `json:"min_activities"`
 }
+
+type DistinctCounterpartiesCondition struct {
+	MinCounterparties int    `json:"min_counterparties"`
+	TimeFrame         string `json:"time_frame"`
+	Direction         string `json:"direction"` // "sent", "received", or "total"
+}
+
+type FirstTransferCondition struct {
+	Direction string `json:"direction"` // "sent" (default) or "received"
+}
+
+type HasBadgeCondition struct {
+	BadgeID int `json:"badge_id"`
+}
+
+type AccountAgeCondition struct {
+	MinAge string `json:"min_age"` // e.g., "90d"
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent is a domain event written to the outbox in the same transaction as the change
+// it describes, and delivered to every subscriber at least once
+type OutboxEvent struct {
+	ID            int64           `json:"id"`
+	EventType     string          `json:"event_type" example:"transfer.completed"`
+	AggregateType string          `json:"aggregate_type" example:"transfer"`
+	AggregateID   string          `json:"aggregate_id" example:"42"`
+	Payload       json.RawMessage `json:"payload" swaggertype:"object"`
+	Status        string          `json:"status" example:"pending"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	DeliveredTo   []string        `json:"delivered_to"` // Subscribers that handled the event
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ProcessedAt   *time.Time      `json:"processed_at,omitempty"`
+}
+
+// Outbox event statuses
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusProcessed = "processed"
+	OutboxStatusDead      = "dead" // Every attempt failed; the event waits for an admin to retry it
+)
+
+// Domain event types
+const (
+	EventTypeTransferCompleted = "transfer.completed"
+	EventTypeBadgeAwarded      = "badge.awarded"
+	EventTypeUserCreated       = "user.created"
+	EventTypeWalletCreated     = "wallet.created"
+	EventTypeGrantPaid         = "grant.paid"
+)
+
+// IsEventType reports whether eventType is a domain event type
+func IsEventType(eventType string) bool {
+	switch eventType {
+	case EventTypeTransferCompleted, EventTypeBadgeAwarded, EventTypeUserCreated, EventTypeWalletCreated, EventTypeGrantPaid:
+		return true
+	}
+	return false
+}
+
+// TransferCompletedEvent is the payload of a transfer.completed event. The sender of an
+// anonymous transfer must not be revealed to its receiver.
+type TransferCompletedEvent struct {
+	TransferID       int64     `json:"transfer_id"`
+	TransactionID    int64     `json:"transaction_id"`
+	SenderWalletID   int64     `json:"sender_wallet_id"`
+	ReceiverWalletID int64     `json:"receiver_wallet_id"`
+	Amount           int64     `json:"amount"`
+	IsAnonymous      bool      `json:"is_anonymous"`
+	CompletedAt      time.Time `json:"completed_at"`
+}
+
+// BadgeAwardedEvent is the payload of a badge.awarded event, for new awards and upgrades
+type BadgeAwardedEvent struct {
+	UserBadgeID   int    `json:"user_badge_id"`
+	UserID        int    `json:"user_id"`
+	BadgeID       int    `json:"badge_id"`
+	Level         string `json:"level,omitempty"`
+	PreviousLevel string `json:"previous_level,omitempty"` // Set when an award was upgraded
+	AwardedBy     *int   `json:"awarded_by,omitempty"`     // Nil for automatic awards
+}
+
+// UserCreatedEvent is the payload of a user.created event
+type UserCreatedEvent struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Provider string `json:"provider,omitempty"`
+}
+
+// WalletCreatedEvent is the payload of a wallet.created event
+type WalletCreatedEvent struct {
+	WalletID       int64  `json:"wallet_id"`
+	UserID         int    `json:"user_id,omitempty"` // 0 for pseudonymous wallets
+	Currency       string `json:"currency,omitempty"`
+	IsPseudonymous bool   `json:"is_pseudonymous"`
+}
+
+// GrantPaidEvent is the payload of a grant.paid event, for a payout of a treasury grant cycle
+type GrantPaidEvent struct {
+	PayoutID      int64 `json:"payout_id"`
+	CycleID       int64 `json:"cycle_id"`
+	UserID        int   `json:"user_id"`
+	WalletID      int64 `json:"wallet_id"`
+	Amount        int64 `json:"amount"`
+	TransactionID int64 `json:"transaction_id"`
+}
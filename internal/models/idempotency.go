@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	IdempotencyStatusInProgress = "in_progress"
+	IdempotencyStatusCompleted  = "completed"
+)
+
+// IdempotencyRecord remembers the outcome of a request sent with an Idempotency-Key header
+type IdempotencyRecord struct {
+	UserID         int       `json:"user_id"`
+	Key            string    `json:"key"`
+	RequestHash    string    `json:"request_hash"` // SHA-256 of method, path and body
+	Status         string    `json:"status"`
+	ResponseStatus int       `json:"response_status"`
+	ResponseBody   []byte    `json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
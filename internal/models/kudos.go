@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Kudos is a public transfer as shown in the kudos feed
+type Kudos struct {
+	ID           int64            `json:"-"`                     // ID of the transfer, orders the feed
+	TransferID   int64            `json:"transfer_id,omitempty"` // Left out for anonymous transfers
+	Sender       *KudosUser       `json:"sender,omitempty"`      // Nil for anonymous and pseudonymous senders
+	Receiver     *KudosUser       `json:"receiver,omitempty"`    // Nil for pseudonymous receivers
+	Amount       int64            `json:"amount" example:"10"`
+	Message      string           `json:"message,omitempty" example:"Thanks for the help with the release!"`
+	Category     string           `json:"category,omitempty" example:"teamwork"`
+	Reactions    []*KudosReaction `json:"reactions"`
+	CommentCount int              `json:"comment_count"`
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+// KudosUser is how a user is shown in the kudos feed
+type KudosUser struct {
+	UserID          int    `json:"user_id" example:"1"`
+	DisplayName     string `json:"display_name" example:"Alice"`
+	ProfilePhotoURL string `json:"profile_photo_url,omitempty"`
+}
+
+// KudosReaction counts the users who reacted to kudos with a reaction
+type KudosReaction struct {
+	Reaction string `json:"reaction" example:"clap"`
+	Count    int    `json:"count" example:"3"`
+	Reacted  bool   `json:"reacted"` // Whether the current user is one of them
+}
+
+// KudosComment is a comment on kudos
+type KudosComment struct {
+	ID         int64     `json:"id"`
+	TransferID int64     `json:"transfer_id"`
+	Author     KudosUser `json:"author"`
+	Body       string    `json:"body" example:"Well deserved!"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// KudosReactions are the reactions users can give kudos
+var KudosReactions = []string{"clap", "heart", "fire", "party", "thumbsup"}
+
+// KudosFilter narrows the kudos feed. Zero fields match all kudos.
+type KudosFilter struct {
+	Category string
+	BeforeID int64 // Cursor: only kudos older than this transfer, 0 for the first page
+	Limit    int
+}
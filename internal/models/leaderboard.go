@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Metrics users are ranked by on a leaderboard
+const (
+	LeaderboardMetricBadgePoints       = "badge_points"       // Points of the badges the user holds
+	LeaderboardMetricCoinsReceived     = "coins_received"     // Coins received through transfers
+	LeaderboardMetricCoinsSent         = "coins_sent"         // Coins sent through transfers
+	LeaderboardMetricColleaguesThanked = "colleagues_thanked" // Distinct colleagues the user sent coins to
+)
+
+// LeaderboardMetrics lists every leaderboard metric
+var LeaderboardMetrics = []string{
+	LeaderboardMetricBadgePoints,
+	LeaderboardMetricCoinsReceived,
+	LeaderboardMetricCoinsSent,
+	LeaderboardMetricColleaguesThanked,
+}
+
+// Windows a leaderboard covers. Windows are calendar periods in UTC, weeks start on Monday.
+const (
+	LeaderboardWindowDaily   = "daily"
+	LeaderboardWindowWeekly  = "weekly"
+	LeaderboardWindowMonthly = "monthly"
+	LeaderboardWindowAllTime = "all_time"
+)
+
+// LeaderboardEntry is the rank of a user on a leaderboard. Users with the same score share a rank.
+type LeaderboardEntry struct {
+	Rank        int    `json:"rank" example:"1"`
+	UserID      int    `json:"user_id" example:"42"`
+	DisplayName string `json:"display_name" example:"John Doe"`
+	Score       int64  `json:"score" example:"350"`
+}
+
+// Leaderboard ranks users by a metric over a window
+type Leaderboard struct {
+	Metric     string              `json:"metric" example:"coins_received"`
+	Window     string              `json:"window" example:"weekly"`
+	Since      *time.Time          `json:"since,omitempty"` // Start of the window, empty for all time
+	ComputedAt time.Time           `json:"computed_at"`
+	Entries    []*LeaderboardEntry `json:"entries"`
+	Me         *LeaderboardEntry   `json:"me,omitempty"` // The requesting user, when ranked
+}
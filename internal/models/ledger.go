@@ -0,0 +1,101 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+type DiscrepancyType string
+
+const (
+	// DiscrepancyBalanceDrift means a wallet balance differs from the sum of its ledger entries
+	DiscrepancyBalanceDrift DiscrepancyType = "balance_drift"
+	// DiscrepancyTransactionMismatch means one side of a transaction does not add up to its amount
+	DiscrepancyTransactionMismatch DiscrepancyType = "transaction_mismatch"
+	// DiscrepancyMisroutedEntry means a ledger entry is booked on a wallet that is not part of its transaction
+	DiscrepancyMisroutedEntry DiscrepancyType = "misrouted_entry"
+	// DiscrepancyOrphanEntry means a ledger entry has no transaction and never moved a balance
+	DiscrepancyOrphanEntry DiscrepancyType = "orphan_entry"
+)
+
+// LedgerDiscrepancy is a single problem found while reconciling the ledger
+type LedgerDiscrepancy struct {
+	Type          DiscrepancyType `json:"type"`
+	WalletID      *int64          `json:"wallet_id,omitempty"`
+	TransactionID *int64          `json:"transaction_id,omitempty"`
+	EntryID       *int64          `json:"entry_id,omitempty"`
+	EntryType     string          `json:"entry_type,omitempty"` // debit or credit
+	Expected      int64           `json:"expected"`
+	Actual        int64           `json:"actual"`
+}
+
+// ReconciliationReport is the outcome of checking wallet balances and transactions against the ledger
+type ReconciliationReport struct {
+	ID                  int64                `json:"id"`
+	TriggeredBy         *int                 `json:"triggered_by"` // NULL for scheduled runs
+	WalletsChecked      int                  `json:"wallets_checked"`
+	TransactionsChecked int                  `json:"transactions_checked"`
+	EntriesChecked      int                  `json:"entries_checked"`
+	DiscrepancyCount    int                  `json:"discrepancy_count"`
+	Discrepancies       []*LedgerDiscrepancy `json:"discrepancies"`
+	StartedAt           time.Time            `json:"started_at"`
+	CompletedAt         time.Time            `json:"completed_at"`
+}
+
+// ComputeHash returns the chain hash of the entry: a SHA-256 over its content and
+// the hash of the previous entry in the chain. The first entry has an empty PrevHash.
+func (e *LedgerEntry) ComputeHash() string {
+	content, _ := json.Marshal([]interface{}{
+		e.ChainSeq,
+		e.TransactionID,
+		e.WalletID,
+		e.EntryType,
+		e.Amount,
+		e.Extra,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		e.PrevHash,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// LedgerCheckpoint is a signed statement of the chain hash at a given position
+type LedgerCheckpoint struct {
+	ID        int64     `json:"id"`
+	ChainSeq  int64     `json:"chain_seq"`
+	EntryHash string    `json:"entry_hash"`
+	Signature string    `json:"signature"`
+	PublicKey string    `json:"public_key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChainBreak describes the first entry whose link in the chain does not hold
+type ChainBreak struct {
+	ChainSeq     int64  `json:"chain_seq"`
+	EntryID      int64  `json:"entry_id,omitempty"` // 0 when the entry is missing
+	Reason       string `json:"reason"`
+	ExpectedHash string `json:"expected_hash,omitempty"`
+	ActualHash   string `json:"actual_hash,omitempty"`
+}
+
+// CheckpointFailure describes a checkpoint that does not match the chain or its signature
+type CheckpointFailure struct {
+	CheckpointID int64  `json:"checkpoint_id"`
+	ChainSeq     int64  `json:"chain_seq"`
+	Reason       string `json:"reason"`
+}
+
+// ChainVerification is the outcome of verifying the ledger chain over a range of positions
+type ChainVerification struct {
+	FromSeq               int64                `json:"from_seq"`
+	ToSeq                 int64                `json:"to_seq"`
+	EntriesChecked        int64                `json:"entries_checked"`
+	CheckpointsChecked    int                  `json:"checkpoints_checked"`
+	CheckpointsUnverified int                  `json:"checkpoints_unverified"` // Not checked for want of a checkpoint key
+	Valid                 bool                 `json:"valid"`
+	FirstBrokenLink       *ChainBreak          `json:"first_broken_link,omitempty"`
+	CheckpointFailures    []*CheckpointFailure `json:"checkpoint_failures,omitempty"`
+	UnchainedEntries      int64                `json:"unchained_entries"` // Entries written outside the chain
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Notification tells a user about something that happened to them
+type Notification struct {
+	ID        int64           `json:"id"`
+	UserID    int             `json:"user_id"`
+	Type      string          `json:"type" example:"transfer.received"`
+	Message   string          `json:"message" example:"alice sent you 10 coins"`
+	Data      json.RawMessage `json:"data,omitempty" swaggertype:"object"`
+	EventID   int64           `json:"event_id,omitempty"`
+	ReadAt    *time.Time      `json:"read_at,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Notification types
+const (
+	NotificationTransferReceived = "transfer.received"
+	NotificationBadgeAwarded     = "badge.awarded"
+	NotificationGrantReceived    = "grant.received"
+)
+
+// NotificationFilter narrows the notifications of a user. Zero fields match every notification.
+type NotificationFilter struct {
+	UnreadOnly bool
+	AfterID    int64 // Only notifications newer than this one, to catch up after a reconnect
+	BeforeID   int64 // Cursor: only notifications older than this one, 0 for the first page
+	Limit      int
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+type PaymentRequestStatus string
+
+const (
+	PaymentRequestStatusPending   PaymentRequestStatus = "pending"
+	PaymentRequestStatusPaid      PaymentRequestStatus = "paid" // In the transaction that moved the coins
+	PaymentRequestStatusDeclined  PaymentRequestStatus = "declined"
+	PaymentRequestStatusCancelled PaymentRequestStatus = "cancelled"
+	PaymentRequestStatusExpired   PaymentRequestStatus = "expired"
+)
+
+// paymentRequestTransitions lists the statuses each status of a payment request may move to
+var paymentRequestTransitions = map[PaymentRequestStatus][]PaymentRequestStatus{
+	PaymentRequestStatusPending: {
+		PaymentRequestStatusPaid, PaymentRequestStatusDeclined,
+		PaymentRequestStatusCancelled, PaymentRequestStatusExpired,
+	},
+}
+
+// CanBecome reports whether a payment request in this status may move to next
+func (s PaymentRequestStatus) CanBecome(next PaymentRequestStatus) bool {
+	for _, allowed := range paymentRequestTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// PaymentRequest asks the owner of a wallet to pay coins into a wallet of the requester
+type PaymentRequest struct {
+	ID                int64                `json:"id"`
+	RequesterUserID   int                  `json:"requester_user_id"`
+	RequesterWalletID int64                `json:"requester_wallet_id"` // Wallet the coins are paid into
+	PayerUserID       int                  `json:"payer_user_id"`
+	PayerWalletID     int64                `json:"payer_wallet_id"` // Wallet the coins are paid from
+	Amount            int64                `json:"amount" example:"25"`
+	Message           string               `json:"message,omitempty" example:"Lunch on Friday"`
+	Status            PaymentRequestStatus `json:"status"`
+	TransferID        *int64               `json:"transfer_id"` // Set once paid
+	ExpiresAt         time.Time            `json:"expires_at"`
+	CreatedAt         time.Time            `json:"created_at"`
+	UpdatedAt         time.Time            `json:"updated_at"`
+}
+
+// PaymentRequestFilter narrows a list of payment requests. Zero fields match all requests.
+type PaymentRequestFilter struct {
+	RequesterUserID int // Outgoing requests of a user
+	PayerUserID     int // Incoming requests of a user
+	Status          PaymentRequestStatus
+	BeforeID        int64 // Cursor: only requests older than this one, 0 for the first page
+	Limit           int
+}
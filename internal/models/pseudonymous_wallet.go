@@ -1,7 +1,29 @@
 package models
 
+import "time"
+
 type PseudonymousWallet struct {
 	ID             int64  `json:"id"`
-	PublicKey      string `json:"public_key"`
+	PublicKey      string `json:"public_key"` // Active key, empty once it is revoked
 	IsPseudonymous bool   `json:"is_pseudonymous"`
 }
+
+type WalletKeyStatus string
+
+const (
+	WalletKeyStatusActive  WalletKeyStatus = "active"
+	WalletKeyStatusRotated WalletKeyStatus = "rotated"
+	WalletKeyStatusRevoked WalletKeyStatus = "revoked"
+)
+
+// PseudonymousWalletKey is a public key that controls, or once controlled, a pseudonymous wallet
+type PseudonymousWalletKey struct {
+	ID                int64           `json:"id"`
+	WalletID          int64           `json:"wallet_id"`
+	PublicKey         string          `json:"public_key"`
+	Status            WalletKeyStatus `json:"status"`
+	RotationSignature string          `json:"rotation_signature,omitempty"` // Signature of the previous key endorsing this one
+	RevocationReason  string          `json:"revocation_reason,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	RetiredAt         *time.Time      `json:"retired_at"`
+}
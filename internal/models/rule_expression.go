@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Operators combining the operands of a rule expression
+const (
+	RuleOperatorAnd = "and"
+	RuleOperatorOr  = "or"
+	RuleOperatorNot = "not"
+)
+
+// RuleExpression is a node of an expression rule. In JSON every node is an object with a
+// single key: "and" or "or" with a list of nodes, "not" with one node, or a rule type with
+// its condition, for example
+//
+//	{"and": [
+//	  {"transaction_count": {"min_transactions": 10, "time_frame": "30d"}},
+//	  {"distinct_counterparties": {"min_counterparties": 5, "direction": "received", "time_frame": "30d"}}
+//	]}
+type RuleExpression struct {
+	Operator  string            // and, or, not, or empty for a condition
+	Operands  []*RuleExpression // Exactly one for not
+	Condition string            // Rule type of a condition
+	Value     json.RawMessage   // Condition of the rule type
+}
+
+func (e *RuleExpression) UnmarshalJSON(data []byte) error {
+	var node map[string]json.RawMessage
+	if err := json.Unmarshal(data, &node); err != nil {
+		return errors.New("an expression node must be an object")
+	}
+	if len(node) != 1 {
+		return fmt.Errorf("an expression node must have exactly one key, found %d", len(node))
+	}
+
+	for key, value := range node {
+		switch key {
+		case RuleOperatorAnd, RuleOperatorOr:
+			e.Operator = key
+			if err := json.Unmarshal(value, &e.Operands); err != nil {
+				return fmt.Errorf("%q must hold a list of expressions: %w", key, err)
+			}
+		case RuleOperatorNot:
+			operand := &RuleExpression{}
+			if err := json.Unmarshal(value, operand); err != nil {
+				return err
+			}
+			e.Operator = key
+			e.Operands = []*RuleExpression{operand}
+		default:
+			e.Condition = key
+			e.Value = value
+		}
+	}
+	return nil
+}
+
+func (e RuleExpression) MarshalJSON() ([]byte, error) {
+	switch e.Operator {
+	case RuleOperatorAnd, RuleOperatorOr:
+		return json.Marshal(map[string][]*RuleExpression{e.Operator: e.Operands})
+	case RuleOperatorNot:
+		if len(e.Operands) != 1 {
+			return nil, errors.New("not takes exactly one expression")
+		}
+		return json.Marshal(map[string]*RuleExpression{e.Operator: e.Operands[0]})
+	}
+	return json.Marshal(map[string]json.RawMessage{e.Condition: e.Value})
+}
+
+// RuleEvaluation explains how a rule, or one node of an expression rule, evaluated for a user
+type RuleEvaluation struct {
+	Node      string            `json:"node" example:"transaction_count"` // Operator or rule type
+	Condition json.RawMessage   `json:"condition,omitempty" swaggertype:"string" example:"{\"min_transactions\":10,\"time_frame\":\"30d\"}"`
+	Met       bool              `json:"met"`
+	Detail    string            `json:"detail,omitempty" example:"7 of 10 transactions in the last 30d"`
+	Operands  []*RuleEvaluation `json:"operands,omitempty"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+type ScheduledTransferStatus string
+
+const (
+	ScheduledTransferStatusActive    ScheduledTransferStatus = "active"
+	ScheduledTransferStatusPaused    ScheduledTransferStatus = "paused"
+	ScheduledTransferStatusCompleted ScheduledTransferStatus = "completed" // No runs are left
+	ScheduledTransferStatusCancelled ScheduledTransferStatus = "cancelled"
+)
+
+// CatchUpPolicy is what happens to the runs of a schedule missed while the scheduler was down
+type CatchUpPolicy string
+
+const (
+	CatchUpLatest CatchUpPolicy = "latest" // The latest missed run is made late, earlier ones are skipped
+	CatchUpSkip   CatchUpPolicy = "skip"   // Missed runs are skipped
+)
+
+// ScheduledTransfer is a transfer made at a later time, once or on a recurring schedule
+type ScheduledTransfer struct {
+	ID               int64                   `json:"id"`
+	UserID           int                     `json:"user_id"` // Owner of the sender wallet who made the schedule
+	SenderWalletID   int64                   `json:"sender_wallet_id"`
+	ReceiverWalletID int64                   `json:"receiver_wallet_id"`
+	Amount           int64                   `json:"amount" example:"50"`
+	IsAnonymous      bool                    `json:"is_anonymous"`
+	Message          string                  `json:"message,omitempty" example:"Monthly allowance"`
+	Category         string                  `json:"category,omitempty" example:"allowance"`
+	Visibility       TransferVisibility      `json:"visibility" example:"recipient_only"`
+	Cron             string                  `json:"cron,omitempty" example:"0 9 1 * *"` // Empty for a one-off transfer
+	Timezone         string                  `json:"timezone" example:"Europe/Berlin"`   // Location the cron expression is read in
+	CatchUp          CatchUpPolicy           `json:"catch_up" example:"latest"`
+	EndAt            *time.Time              `json:"end_at"`   // No runs after this time
+	MaxRuns          *int                    `json:"max_runs"` // No runs after this many were made
+	RunCount         int                     `json:"run_count"`
+	NextRunAt        *time.Time              `json:"next_run_at"` // Nil once no runs are left
+	Status           ScheduledTransferStatus `json:"status"`
+	CreatedAt        time.Time               `json:"created_at"`
+	UpdatedAt        time.Time               `json:"updated_at"`
+}
+
+type ScheduledTransferExecutionStatus string
+
+const (
+	ScheduledTransferExecutionRunning   ScheduledTransferExecutionStatus = "running"
+	ScheduledTransferExecutionSucceeded ScheduledTransferExecutionStatus = "succeeded"
+	ScheduledTransferExecutionFailed    ScheduledTransferExecutionStatus = "failed"
+	ScheduledTransferExecutionSkipped   ScheduledTransferExecutionStatus = "skipped"
+)
+
+// ScheduledTransferExecution records a run of a scheduled transfer, or the runs skipped
+// after downtime
+type ScheduledTransferExecution struct {
+	ID           int64                            `json:"id"`
+	ScheduleID   int64                            `json:"schedule_id"`
+	ScheduledFor time.Time                        `json:"scheduled_for"` // Time the run was due, the first skipped one for skipped runs
+	Status       ScheduledTransferExecutionStatus `json:"status"`
+	SkippedRuns  int                              `json:"skipped_runs,omitempty"`
+	TransferID   *int64                           `json:"transfer_id"`
+	Error        string                           `json:"error,omitempty"`
+	StartedAt    time.Time                        `json:"started_at"`
+	FinishedAt   *time.Time                       `json:"finished_at"`
+}
+
+// ScheduledTransferFilter narrows a list of scheduled transfers. Zero fields match all.
+type ScheduledTransferFilter struct {
+	UserID   int
+	Status   ScheduledTransferStatus
+	BeforeID int64 // Cursor: only schedules older than this one, 0 for the first page
+	Limit    int
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+const (
+	SessionRevokedLogout       = "logout"
+	SessionRevokedByUser       = "revoked_by_user"
+	SessionRevokedTokenReuse   = "refresh_token_reused"
+	SessionRevokedOtherSession = "signed_out_elsewhere"
+)
+
+// Session is a login of a user on one device. Access tokens carry its ID so they stop
+// being accepted as soon as the session is revoked.
+type Session struct {
+	ID            string     `json:"id" example:"3f2b9c0e8a7d4e1f9b6c5d4e3f2a1b0c"`
+	UserID        int        `json:"user_id" example:"1"`
+	UserAgent     string     `json:"user_agent" example:"Mozilla/5.0"`
+	IPAddress     string     `json:"ip_address" example:"203.0.113.7"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+	Current       bool       `json:"current"` // Set when listing, for the session of the caller
+}
+
+// Active reports whether the session can still be used at the given time
+func (s *Session) Active(at time.Time) bool {
+	return s.RevokedAt == nil && at.Before(s.ExpiresAt)
+}
+
+// SessionTokens are handed to a client when a session starts or is refreshed
+type SessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+	Session      *Session
+}
@@ -1,18 +1,57 @@
 package models
 
+import "time"
+
 type Transaction struct {
 	ID               int64  `json:"id"`
 	SenderWalletID   int64  `json:"sender_wallet_id"`
 	ReceiverWalletID int64  `json:"receiver_wallet_id"`
 	Amount           int64  `json:"amount"`
+	TransferID       *int64 `json:"transfer_id"`
 	CreatedAt        string `json:"created_at"`
 }
 
 type LedgerEntry struct {
-	ID            int64  `json:"id"`
-	TransactionID int64  `json:"transaction_id"`
-	WalletID      int64  `json:"wallet_id"`
-	EntryType     string `json:"entry_type"` // debit or credit
-	Amount        int64  `json:"amount"`
-	CreatedAt     string `json:"created_at"`
+	ID            int64     `json:"id"`
+	TransactionID int64     `json:"transaction_id"` // 0 for entries without a transaction
+	WalletID      int64     `json:"wallet_id"`
+	EntryType     string    `json:"entry_type"` // debit or credit
+	Amount        int64     `json:"amount"`
+	Extra         string    `json:"extra,omitempty"`
+	ChainSeq      int64     `json:"chain_seq"`
+	PrevHash      string    `json:"prev_hash"`
+	Hash          string    `json:"hash"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// StatementEntry is one line of a wallet statement, built from the wallet's ledger entries
+type StatementEntry struct {
+	EntryID              int64     `json:"entry_id"`
+	TransactionID        int64     `json:"transaction_id"`
+	TransferID           *int64    `json:"transfer_id"`
+	Direction            string    `json:"direction"` // sent or received
+	Amount               int64     `json:"amount"`
+	RunningBalance       int64     `json:"running_balance"` // Wallet balance right after this entry
+	CounterpartyWalletID *int64    `json:"counterparty_wallet_id"`
+	CounterpartyUserID   *int      `json:"counterparty_user_id"`
+	IsAnonymous          bool      `json:"is_anonymous"`
+	PaymentRequestID     *int64    `json:"payment_request_id,omitempty"` // Set when the transfer paid a payment request
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+const (
+	DirectionSent     = "sent"
+	DirectionReceived = "received"
+)
+
+// TransactionHistoryFilter narrows down a wallet statement
+type TransactionHistoryFilter struct {
+	From                 *time.Time
+	To                   *time.Time
+	Direction            string // sent, received or empty for both
+	CounterpartyWalletID *int64
+	MinAmount            *int64
+	MaxAmount            *int64
+	BeforeEntryID        int64 // Cursor: only entries older than this one, 0 for the first page
+	Limit                int
 }
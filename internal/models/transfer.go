@@ -10,13 +10,45 @@ const (
 	TransferStatusFailed    TransferStatus = "failed"
 )
 
+// TransferVisibility is who may see the message and category of a transfer
+type TransferVisibility string
+
+const (
+	TransferVisibilityPublic        TransferVisibility = "public"         // Everyone, in the kudos feed
+	TransferVisibilityRecipientOnly TransferVisibility = "recipient_only" // The sender and the receiver
+	TransferVisibilityPrivate       TransferVisibility = "private"        // The sender only
+)
+
+// TransferNote is what a sender says about a transfer, and who may see it
+type TransferNote struct {
+	Message    string             // Optional, at most 500 characters
+	Category   string             // Optional tag such as "teamwork"
+	Visibility TransferVisibility // recipient_only when empty
+}
+
 type Transfer struct {
-	ID               int64          `json:"id"`
-	SenderWalletID   int64          `json:"sender_wallet_id"`
-	ReceiverWalletID int64          `json:"receiver_wallet_id"`
-	Amount           int64          `json:"amount"`
-	Status           TransferStatus `json:"status"`
-	IsAnonymous      bool           `json:"is_anonymous"`
-	CreatedAt        time.Time      `json:"created_at"`
-	UpdatedAt        time.Time      `json:"updated_at"`
+	ID               int64              `json:"id"`
+	SenderWalletID   int64              `json:"sender_wallet_id"`
+	ReceiverWalletID int64              `json:"receiver_wallet_id"`
+	Amount           int64              `json:"amount"`
+	Status           TransferStatus     `json:"status"`
+	IsAnonymous      bool               `json:"is_anonymous"`
+	Message          string             `json:"message,omitempty" example:"Thanks for the help with the release!"`
+	Category         string             `json:"category,omitempty" example:"teamwork"`
+	Visibility       TransferVisibility `json:"visibility" example:"public"`
+	TransactionID    *int64             `json:"transaction_id"` // Set once the coins have moved
+	FailureReason    string             `json:"failure_reason,omitempty"`
+	Authorization    *TransferSignature `json:"authorization,omitempty"` // Set for signed pseudonymous transfers
+	PaymentRequestID *int64             `json:"-"`                       // Request the transfer pays, marked paid as the coins move
+	CreatedAt        time.Time          `json:"created_at"`
+	UpdatedAt        time.Time          `json:"updated_at"`
+}
+
+// TransferSignature is the client signature authorizing a transfer out of a pseudonymous wallet.
+// It is recorded on the debit ledger entry so the transfer can be verified from the ledger alone.
+type TransferSignature struct {
+	Nonce     string    `json:"nonce"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Signature string    `json:"signature"`
+	PublicKey string    `json:"public_key"`
 }
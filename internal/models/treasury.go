@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+type GrantCycleStatus string
+
+const (
+	GrantCycleStatusRunning   GrantCycleStatus = "running"
+	GrantCycleStatusCompleted GrantCycleStatus = "completed"
+	GrantCycleStatusFailed    GrantCycleStatus = "failed"
+)
+
+type GrantPayoutStatus string
+
+const (
+	GrantPayoutStatusPending   GrantPayoutStatus = "pending"
+	GrantPayoutStatusCompleted GrantPayoutStatus = "completed" // Paid in the same transaction
+	GrantPayoutStatusFailed    GrantPayoutStatus = "failed"
+)
+
+// GrantCycle is a single distribution of coins from the treasury to every active user
+type GrantCycle struct {
+	ID               int64            `json:"id"`
+	TreasuryWalletID int64            `json:"treasury_wallet_id"`
+	AmountPerUser    int64            `json:"amount_per_user"`
+	MintedAmount     int64            `json:"minted_amount"`
+	Status           GrantCycleStatus `json:"status"`
+	FailureReason    string           `json:"failure_reason,omitempty"`
+	StartedBy        *int             `json:"started_by"` // NULL when resumed by the system
+	CreatedAt        time.Time        `json:"created_at"`
+	CompletedAt      *time.Time       `json:"completed_at"`
+	Progress         GrantProgress    `json:"progress"`
+}
+
+// GrantProgress counts the payouts of a cycle by status
+type GrantProgress struct {
+	Total     int `json:"total"`
+	Pending   int `json:"pending"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// GrantPayout records the grant paid (or attempted) to one user within a cycle
+type GrantPayout struct {
+	ID            int64             `json:"id"`
+	CycleID       int64             `json:"cycle_id"`
+	UserID        int               `json:"user_id"`
+	WalletID      int64             `json:"wallet_id"`
+	Amount        int64             `json:"amount"`
+	Status        GrantPayoutStatus `json:"status"`
+	TransactionID *int64            `json:"transaction_id"`
+	FailureReason string            `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
@@ -21,8 +21,16 @@ type User struct {
 }
 
 type Role struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// UserPermissions are the roles of a user and the permissions they grant together
+type UserPermissions struct {
+	UserID      int      `json:"user_id" example:"42"`
+	Roles       []string `json:"roles" example:"user,admin"`
+	Permissions []string `json:"permissions" example:"assign_badge,create_badge"`
 }
 
 type Permission struct {
@@ -6,7 +6,7 @@ import "time"
 // @Description A digital wallet that can hold a specific currency
 type Wallet struct {
 	ID          int64      `json:"id" example:"1"`
-	UserID      int        `json:"user_id" example:"1"`
+	UserID      int        `json:"user_id" example:"1"` // 0 for pseudonymous wallets
 	Currency    string     `json:"currency" example:"USD"`
 	Balance     int64      `json:"balance" example:"10000"`
 	CreatedAt   time.Time  `json:"created_at"`
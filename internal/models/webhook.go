@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEndpoint is a URL registered by an admin to receive the domain events of some types
+type WebhookEndpoint struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url" example:"https://hr.example.com/hooks/verve"`
+	Description string    `json:"description,omitempty" example:"HR portal"`
+	EventTypes  []string  `json:"event_types" example:"transfer.completed,badge.awarded"`
+	Secret      string    `json:"-"` // Key of the HMAC-SHA256 signature of every delivery
+	IsActive    bool      `json:"is_active"`
+	CreatedBy   int       `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is the delivery of an event to an endpoint, with the outcome of its last
+// attempt. A replay is a new delivery of the same body.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	EndpointID     int             `json:"endpoint_id"`
+	EventID        int64           `json:"event_id"`
+	EventType      string          `json:"event_type" example:"transfer.completed"`
+	Body           json.RawMessage `json:"body" swaggertype:"object"` // Exactly what is posted to the endpoint
+	Status         string          `json:"status" example:"pending"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code,omitempty" example:"502"`
+	LastError      string          `json:"last_error,omitempty"`
+	ReplayOfID     *int64          `json:"replay_of_id,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed" // Every attempt failed; the delivery can be replayed
+)
+
+// WebhookPayload is the body posted to an endpoint for an event
+type WebhookPayload struct {
+	ID        int64           `json:"id"` // ID of the event, the same across retries and replays
+	Type      string          `json:"type" example:"transfer.completed"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+// WebhookDeliveryFilter narrows the delivery log of an endpoint. Zero fields match every delivery.
+type WebhookDeliveryFilter struct {
+	EndpointID int
+	Status     string
+	BeforeID   int64 // Cursor: only deliveries older than this one, 0 for the first page
+	Limit      int
+}
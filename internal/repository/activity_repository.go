@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"time"
+	"verve/internal/models"
+)
+
+type ActivityRepository interface {
+	// Record stores an event and counts it in the rollup of its UTC day
+	Record(event *models.ActivityEvent) error
+	// FindActiveDays returns the UTC days, oldest first, with at least minActivities activities of the type
+	FindActiveDays(userID int, activityType string, minActivities int) ([]time.Time, error)
+}
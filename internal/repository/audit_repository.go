@@ -0,0 +1,10 @@
+package repository
+
+import "verve/internal/models"
+
+// AuditRepository appends to the audit log. Entries are never updated or deleted.
+type AuditRepository interface {
+	Record(entry *models.AuditEntry) error
+	// Find lists up to filter.Limit entries matching the filter, newest first
+	Find(filter models.AuditFilter) ([]*models.AuditEntry, error)
+}
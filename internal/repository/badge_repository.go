@@ -1,6 +1,13 @@
 package repository
 
-import "verve/internal/models"
+import (
+	"errors"
+	"time"
+	"verve/internal/models"
+)
+
+// ErrBadgeAlreadyAwarded is returned when the user already holds the badge
+var ErrBadgeAlreadyAwarded = errors.New("badge was already awarded to the user")
 
 type BadgeRepository interface {
 	Create(badge *models.Badge) error
@@ -18,9 +25,42 @@ type AchievementRuleRepository interface {
 	Delete(id int) error
 }
 
+// UserBadgeRepository stores badge awards. An award is held until it is revoked or expires,
+// and every change to an award is kept in the user's badge history.
 type UserBadgeRepository interface {
+	// Award inserts a new award, closing an expired award of the same badge first
 	Award(userBadge *models.UserBadge) error
+	// FindByUserID returns the awards the user holds
 	FindByUserID(userID int) ([]*models.UserBadge, error)
+	// FindByBadgeID returns the awards of a badge that are held
 	FindByBadgeID(badgeID int) ([]*models.UserBadge, error)
 	HasBadge(userID, badgeID int) (bool, error)
+	// FindHeld returns the award of a badge the user holds, or sql.ErrNoRows
+	FindHeld(userID, badgeID int) (*models.UserBadge, error)
+	// WasRevoked reports whether the latest award of a badge to the user was revoked
+	// rather than left to expire
+	WasRevoked(userID, badgeID int) (bool, error)
+	// UpgradeLevel raises the level of a held award, or returns sql.ErrNoRows when the
+	// award is no longer held at fromLevel
+	UpgradeLevel(userBadgeID int, fromLevel, toLevel string, actorID *int) error
+	// Revoke revokes a held award, or returns sql.ErrNoRows when it is not held
+	Revoke(userBadgeID, revokedBy int, reason string) error
+	// ExpireDue closes the awards that expired by now and returns how many were closed
+	ExpireDue(now time.Time) (int64, error)
+	// FindHistory returns every change to the user's awards, oldest first
+	FindHistory(userID int) ([]*models.UserBadgeEvent, error)
+}
+
+// AchievementProgressRepository reads the activity achievement rules are evaluated against.
+// Only transfers count: coins minted or paid out as grants move no coins between people.
+// A zero since covers the whole history of the user.
+type AchievementProgressRepository interface {
+	// CountTransactions counts the transactions the user sent or received coins in
+	CountTransactions(userID int, since time.Time) (int, error)
+	// SumTransferAmount sums the coins the user sent, received or both ("") in a currency, or in all currencies ("")
+	SumTransferAmount(userID int, since time.Time, direction, currency string) (int64, error)
+	// CountCounterparties counts the other users the user sent coins to, received coins from, or both ("")
+	CountCounterparties(userID int, since time.Time, direction string) (int, error)
+	// HasTransferred reports whether the user ever completed a transfer as sender or as receiver
+	HasTransferred(userID int, direction string) (bool, error)
 }
@@ -0,0 +1,12 @@
+package repository
+
+import "verve/internal/models"
+
+type IdempotencyRepository interface {
+	// Reserve stores a new in-progress record. When an unexpired record already
+	// holds the key it is returned instead and nothing is stored.
+	Reserve(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	Complete(userID int, key string, responseStatus int, responseBody []byte) error
+	Delete(userID int, key string) error
+	DeleteExpired() (int64, error)
+}
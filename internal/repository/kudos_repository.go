@@ -0,0 +1,23 @@
+package repository
+
+import "verve/internal/models"
+
+// KudosRepository reads the kudos feed, the public transfers that completed, and stores the
+// reactions and comments users leave on them
+type KudosRepository interface {
+	// FindFeed lists up to filter.Limit kudos matching the filter, newest first, with the
+	// reactions of viewerID marked
+	FindFeed(viewerID int, filter models.KudosFilter) ([]*models.Kudos, error)
+	// FindByTransferID returns the kudos of a transfer, or sql.ErrNoRows when the transfer is
+	// not public or did not complete
+	FindByTransferID(viewerID int, transferID int64) (*models.Kudos, error)
+	AddReaction(transferID int64, userID int, reaction string) error
+	RemoveReaction(transferID int64, userID int, reaction string) error
+	// AddComment stores a comment and fills in its ID, author and creation time
+	AddComment(comment *models.KudosComment) error
+	// FindComments lists up to limit comments on a transfer after afterID, oldest first
+	FindComments(transferID, afterID int64, limit int) ([]*models.KudosComment, error)
+	// DeleteComment deletes a comment of a user, or returns sql.ErrNoRows when the user did
+	// not write such a comment
+	DeleteComment(transferID, commentID int64, userID int) error
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"time"
+	"verve/internal/models"
+)
+
+// LeaderboardRepository ranks users for leaderboards. Only named transfers between users
+// count: anonymous transfers and transfers from or to pseudonymous wallets are left out,
+// and users who opted out are never ranked.
+type LeaderboardRepository interface {
+	// Rank returns every user with a positive score for a metric since a time, best first.
+	// A zero since covers the whole history.
+	Rank(metric string, since time.Time) ([]*models.LeaderboardEntry, error)
+	SetOptOut(userID int, optOut bool) error
+	IsOptedOut(userID int) (bool, error)
+}
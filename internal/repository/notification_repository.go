@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"errors"
+	"verve/internal/models"
+)
+
+var ErrNotificationExists = errors.New("the user was already notified of this event")
+
+type NotificationRepository interface {
+	// Create stores a notification, or returns ErrNotificationExists when the user was
+	// already notified of its event
+	Create(notification *models.Notification) error
+	// Find lists up to filter.Limit notifications of a user matching the filter, newest first
+	Find(userID int, filter models.NotificationFilter) ([]*models.Notification, error)
+	// FindSince lists up to limit notifications of a user newer than afterID, oldest first
+	FindSince(userID int, afterID int64, limit int) ([]*models.Notification, error)
+	CountUnread(userID int) (int, error)
+	// MarkRead marks a notification of a user as read, or returns sql.ErrNoRows when the
+	// user has no such notification
+	MarkRead(userID int, id int64) error
+	// MarkAllRead marks every unread notification of a user as read and returns how many
+	MarkAllRead(userID int) (int64, error)
+}
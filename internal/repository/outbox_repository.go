@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"time"
+	"verve/internal/models"
+)
+
+// OutboxRepository reads and settles the events of the outbox. Events are written by the
+// repositories making the changes they describe, in the same transaction.
+type OutboxRepository interface {
+	// Claim leases up to limit pending events that are due, oldest first. Claimed events are
+	// not due again until the lease ends, so concurrent dispatchers skip them.
+	Claim(now time.Time, lease time.Duration, limit int) ([]*models.OutboxEvent, error)
+	// MarkDelivered records that a subscriber handled an event
+	MarkDelivered(eventID int64, subscriber string) error
+	MarkProcessed(eventID int64) error
+	// Reschedule records a failed attempt and when to try again
+	Reschedule(eventID int64, attempts int, nextAttemptAt time.Time, lastError string) error
+	// MarkDead records the last failed attempt of an event that will not be tried again
+	MarkDead(eventID int64, attempts int, lastError string) error
+	FindDead(limit int) ([]*models.OutboxEvent, error)
+	// Requeue makes a dead event pending again with a fresh set of attempts, or returns
+	// sql.ErrNoRows when the event is not dead
+	Requeue(eventID int64) error
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"verve/internal/models"
+)
+
+// ErrPaymentRequestNotPending is returned when a transfer pays a payment request that is no
+// longer pending
+var ErrPaymentRequestNotPending = errors.New("payment request is no longer pending")
+
+// PaymentRequestRepository stores payment requests and moves them through their states.
+// A pending request past its expiry reads as expired even before ExpireDue ran.
+type PaymentRequestRepository interface {
+	Create(request *models.PaymentRequest) error
+	FindByID(id int64) (*models.PaymentRequest, error)
+	// Find lists up to filter.Limit requests matching the filter, newest first
+	Find(filter models.PaymentRequestFilter) ([]*models.PaymentRequest, error)
+	// UpdateStatus moves a request from one status to another and returns it, or returns
+	// sql.ErrNoRows when the request is no longer in status from. Pending requests past their
+	// expiry cannot move.
+	UpdateStatus(id int64, from, to models.PaymentRequestStatus) (*models.PaymentRequest, error)
+	// ExpireDue moves the pending requests whose expiry passed to expired
+	ExpireDue(now time.Time) (int64, error)
+}
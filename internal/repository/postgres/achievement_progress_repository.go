@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+type postgresAchievementProgressRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresAchievementProgressRepository(db *sql.DB) repository.AchievementProgressRepository {
+	return &postgresAchievementProgressRepository{DB: db}
+}
+
+func (r *postgresAchievementProgressRepository) CountTransactions(userID int, since time.Time) (int, error) {
+	var count int
+	err := r.DB.QueryRow(`
+		SELECT COUNT(DISTINCT le.transaction_id)
+		FROM ledger_entries le
+		JOIN wallets w ON w.id = le.wallet_id
+		JOIN transactions t ON t.id = le.transaction_id
+		WHERE w.user_id = $1 AND t.transfer_id IS NOT NULL AND le.created_at >= $2`,
+		userID, since,
+	).Scan(&count)
+	return count, err
+}
+
+// entryTypeOf returns the ledger entry type of a direction, or "" for both directions
+func entryTypeOf(direction string) string {
+	switch direction {
+	case models.DirectionSent:
+		return "debit"
+	case models.DirectionReceived:
+		return "credit"
+	}
+	return ""
+}
+
+func (r *postgresAchievementProgressRepository) SumTransferAmount(userID int, since time.Time, direction, currency string) (int64, error) {
+	entryType := entryTypeOf(direction)
+
+	var total int64
+	err := r.DB.QueryRow(`
+		SELECT COALESCE(SUM(le.amount), 0)
+		FROM ledger_entries le
+		JOIN wallets w ON w.id = le.wallet_id
+		JOIN transactions t ON t.id = le.transaction_id
+		WHERE w.user_id = $1 AND t.transfer_id IS NOT NULL AND le.created_at >= $2
+			AND ($3 = '' OR le.entry_type = $3)
+			AND ($4 = '' OR w.currency = $4)`,
+		userID, since, entryType, currency,
+	).Scan(&total)
+	return total, err
+}
+
+func (r *postgresAchievementProgressRepository) CountCounterparties(userID int, since time.Time, direction string) (int, error) {
+	// Pseudonymous wallets have no user to count, and the treasury paying grants is no colleague
+	var count int
+	err := r.DB.QueryRow(`
+		SELECT COUNT(DISTINCT cw.user_id)
+		FROM ledger_entries le
+		JOIN wallets w ON w.id = le.wallet_id
+		JOIN transactions t ON t.id = le.transaction_id
+		JOIN wallets cw ON cw.id = CASE WHEN le.entry_type = 'debit' THEN t.receiver_wallet_id ELSE t.sender_wallet_id END
+		WHERE w.user_id = $1 AND t.transfer_id IS NOT NULL AND le.created_at >= $2
+			AND ($3 = '' OR le.entry_type = $3)
+			AND cw.user_id IS NOT NULL AND cw.user_id <> $1`,
+		userID, since, entryTypeOf(direction),
+	).Scan(&count)
+	return count, err
+}
+
+func (r *postgresAchievementProgressRepository) HasTransferred(userID int, direction string) (bool, error) {
+	walletColumn := "t.sender_wallet_id"
+	if direction == models.DirectionReceived {
+		walletColumn = "t.receiver_wallet_id"
+	}
+
+	var transferred bool
+	err := r.DB.QueryRow(fmt.Sprintf(`
+		SELECT EXISTS (
+			SELECT 1 FROM transfers t
+			JOIN wallets w ON w.id = %s
+			WHERE w.user_id = $1 AND t.status = 'completed'
+		)`, walletColumn),
+		userID,
+	).Scan(&transferred)
+	return transferred, err
+}
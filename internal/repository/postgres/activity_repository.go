@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+type postgresActivityRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresActivityRepository(db *sql.DB) repository.ActivityRepository {
+	return &postgresActivityRepository{DB: db}
+}
+
+func (r *postgresActivityRepository) Record(event *models.ActivityEvent) error {
+	// One statement, so an event is never stored without being counted
+	return r.DB.QueryRow(`
+		WITH event AS (
+			INSERT INTO activity_events (user_id, activity_type, reference_id)
+			VALUES ($1, $2, $3)
+			RETURNING id, occurred_at
+		), rollup AS (
+			INSERT INTO activity_daily_rollups (user_id, activity_type, day, count)
+			SELECT $1, $2, (occurred_at AT TIME ZONE 'UTC')::date, 1 FROM event
+			ON CONFLICT (user_id, activity_type, day) DO UPDATE SET count = activity_daily_rollups.count + 1
+		)
+		SELECT id, occurred_at FROM event`,
+		event.UserID, event.ActivityType, event.ReferenceID,
+	).Scan(&event.ID, &event.OccurredAt)
+}
+
+func (r *postgresActivityRepository) FindActiveDays(userID int, activityType string, minActivities int) ([]time.Time, error) {
+	rows, err := r.DB.Query(`
+		SELECT day
+		FROM activity_daily_rollups
+		WHERE user_id = $1 AND activity_type = $2 AND count >= $3
+		ORDER BY day`,
+		userID, activityType, minActivities,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		days = append(days, day.UTC())
+	}
+	return days, rows.Err()
+}
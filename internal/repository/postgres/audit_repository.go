@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+type postgresAuditRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresAuditRepository(db *sql.DB) repository.AuditRepository {
+	return &postgresAuditRepository{DB: db}
+}
+
+func (r *postgresAuditRepository) Record(entry *models.AuditEntry) error {
+	return r.DB.QueryRow(`
+		INSERT INTO audit_log (actor_id, action, target_type, target_id, before_state, after_state, diff,
+			ip_address, user_agent, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`,
+		entry.ActorID, entry.Action, entry.TargetType, entry.TargetID,
+		nullableJSON(entry.Before), nullableJSON(entry.After), nullableJSON(entry.Diff),
+		nullableString(entry.IPAddress), nullableString(entry.UserAgent), nullableString(entry.RequestID),
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+func (r *postgresAuditRepository) Find(filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	query := `
+		SELECT id, actor_id, action, target_type, target_id, before_state, after_state, diff,
+			COALESCE(ip_address, ''), COALESCE(user_agent, ''), COALESCE(request_id, ''), created_at
+		FROM audit_log`
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+	if filter.ActorID != nil {
+		addCondition("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.TargetType != "" {
+		addCondition("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		addCondition("target_id = $%d", filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		addCondition("request_id = $%d", filter.RequestID)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditEntry
+	for rows.Next() {
+		entry := &models.AuditEntry{}
+		var before, after, diff []byte
+		if err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.TargetType, &entry.TargetID,
+			&before, &after, &diff, &entry.IPAddress, &entry.UserAgent, &entry.RequestID, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.Before, entry.After, entry.Diff = before, after, diff
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// nullableJSON stores an empty document as NULL
+func nullableJSON(document []byte) interface{} {
+	if len(document) == 0 {
+		return nil
+	}
+	return string(document)
+}
+
+// nullableString stores an empty string as NULL
+func nullableString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
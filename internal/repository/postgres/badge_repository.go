@@ -2,8 +2,14 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
 	"verve/internal/models"
 	"verve/internal/repository"
+
+	"github.com/lib/pq"
 )
 
 type postgresBadgeRepository struct {
@@ -93,26 +99,26 @@ func NewPostgresAchievementRuleRepository(db *sql.DB) repository.AchievementRule
 
 func (r *postgresAchievementRuleRepository) Create(rule *models.AchievementRule) error {
 	return r.DB.QueryRow(`
-		INSERT INTO achievement_rules (badge_id, rule_type, condition_value, created_by, is_active)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO achievement_rules (badge_id, rule_type, condition_value, level, created_by, is_active)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
 		RETURNING id, created_at`,
-		rule.BadgeID, rule.RuleType, rule.ConditionValue, rule.CreatedBy, rule.IsActive,
+		rule.BadgeID, rule.RuleType, rule.ConditionValue, rule.Level, rule.CreatedBy, rule.IsActive,
 	).Scan(&rule.ID, &rule.CreatedAt)
 }
 
 func (r *postgresAchievementRuleRepository) Update(rule *models.AchievementRule) error {
 	_, err := r.DB.Exec(`
 		UPDATE achievement_rules
-		SET rule_type = $1, condition_value = $2, is_active = $3
-		WHERE id = $4`,
-		rule.RuleType, rule.ConditionValue, rule.IsActive, rule.ID,
+		SET rule_type = $1, condition_value = $2, level = NULLIF($3, ''), is_active = $4
+		WHERE id = $5`,
+		rule.RuleType, rule.ConditionValue, rule.Level, rule.IsActive, rule.ID,
 	)
 	return err
 }
 
 func (r *postgresAchievementRuleRepository) FindByBadgeID(badgeID int) ([]*models.AchievementRule, error) {
 	rows, err := r.DB.Query(`
-		SELECT id, badge_id, rule_type, condition_value, created_at, created_by, is_active
+		SELECT id, badge_id, rule_type, condition_value, COALESCE(level, ''), created_at, created_by, is_active
 		FROM achievement_rules
 		WHERE badge_id = $1`,
 		badgeID,
@@ -126,7 +132,7 @@ func (r *postgresAchievementRuleRepository) FindByBadgeID(badgeID int) ([]*model
 	for rows.Next() {
 		rule := &models.AchievementRule{}
 		err := rows.Scan(
-			&rule.ID, &rule.BadgeID, &rule.RuleType, &rule.ConditionValue,
+			&rule.ID, &rule.BadgeID, &rule.RuleType, &rule.ConditionValue, &rule.Level,
 			&rule.CreatedAt, &rule.CreatedBy, &rule.IsActive,
 		)
 		if err != nil {
@@ -139,7 +145,7 @@ func (r *postgresAchievementRuleRepository) FindByBadgeID(badgeID int) ([]*model
 
 func (r *postgresAchievementRuleRepository) FindAll(includeInactive bool) ([]*models.AchievementRule, error) {
 	query := `
-		SELECT id, badge_id, rule_type, condition_value, created_at, created_by, is_active
+		SELECT id, badge_id, rule_type, condition_value, COALESCE(level, ''), created_at, created_by, is_active
 		FROM achievement_rules`
 	if !includeInactive {
 		query += " WHERE is_active = true"
@@ -155,7 +161,7 @@ func (r *postgresAchievementRuleRepository) FindAll(includeInactive bool) ([]*mo
 	for rows.Next() {
 		rule := &models.AchievementRule{}
 		err := rows.Scan(
-			&rule.ID, &rule.BadgeID, &rule.RuleType, &rule.ConditionValue,
+			&rule.ID, &rule.BadgeID, &rule.RuleType, &rule.ConditionValue, &rule.Level,
 			&rule.CreatedAt, &rule.CreatedBy, &rule.IsActive,
 		)
 		if err != nil {
@@ -179,22 +185,27 @@ func NewPostgresUserBadgeRepository(db *sql.DB) repository.UserBadgeRepository {
 	return &postgresUserBadgeRepository{DB: db}
 }
 
-func (r *postgresUserBadgeRepository) Award(userBadge *models.UserBadge) error {
-	return r.DB.QueryRow(`
-		INSERT INTO user_badges (user_id, badge_id, awarded_by)
-		VALUES ($1, $2, $3)
-		RETURNING id, awarded_at`,
-		userBadge.UserID, userBadge.BadgeID, userBadge.AwardedBy,
-	).Scan(&userBadge.ID, &userBadge.AwardedAt)
+// heldAward restricts a query to awards that were neither revoked nor expired
+const heldAward = "revoked_at IS NULL AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)"
+
+const selectUserBadge = `
+	SELECT id, user_id, badge_id, COALESCE(level, ''), awarded_at, awarded_by,
+		expires_at, revoked_at, revoked_by, COALESCE(revoke_reason, '')
+	FROM user_badges`
+
+func scanUserBadge(row interface{ Scan(...interface{}) error }) (*models.UserBadge, error) {
+	ub := &models.UserBadge{}
+	if err := row.Scan(
+		&ub.ID, &ub.UserID, &ub.BadgeID, &ub.Level, &ub.AwardedAt, &ub.AwardedBy,
+		&ub.ExpiresAt, &ub.RevokedAt, &ub.RevokedBy, &ub.RevokeReason,
+	); err != nil {
+		return nil, err
+	}
+	return ub, nil
 }
 
-func (r *postgresUserBadgeRepository) FindByUserID(userID int) ([]*models.UserBadge, error) {
-	rows, err := r.DB.Query(`
-		SELECT id, user_id, badge_id, awarded_at, awarded_by
-		FROM user_badges
-		WHERE user_id = $1`,
-		userID,
-	)
+func (r *postgresUserBadgeRepository) findUserBadges(query string, args ...interface{}) ([]*models.UserBadge, error) {
+	rows, err := r.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -202,14 +213,85 @@ func (r *postgresUserBadgeRepository) FindByUserID(userID int) ([]*models.UserBa
 
 	var userBadges []*models.UserBadge
 	for rows.Next() {
-		ub := &models.UserBadge{}
-		err := rows.Scan(&ub.ID, &ub.UserID, &ub.BadgeID, &ub.AwardedAt, &ub.AwardedBy)
+		ub, err := scanUserBadge(rows)
 		if err != nil {
 			return nil, err
 		}
 		userBadges = append(userBadges, ub)
 	}
-	return userBadges, nil
+	return userBadges, rows.Err()
+}
+
+// expireAwards closes the expired awards matching the condition and records their expiry
+const expireAwards = `
+	WITH expired AS (
+		UPDATE user_badges
+		SET revoked_at = expires_at, revoke_reason = 'expired'
+		WHERE revoked_at IS NULL AND expires_at <= $1 %s
+		RETURNING id, user_id, badge_id, level, expires_at
+	)
+	INSERT INTO user_badge_events (user_badge_id, user_id, badge_id, event_type, level, created_at)
+	SELECT id, user_id, badge_id, 'expired', level, expires_at FROM expired`
+
+func (r *postgresUserBadgeRepository) Award(userBadge *models.UserBadge) (err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// An expired award still occupies the badge until it is closed
+	if _, err = tx.Exec(
+		fmt.Sprintf(expireAwards, "AND user_id = $2 AND badge_id = $3"),
+		time.Now(), userBadge.UserID, userBadge.BadgeID,
+	); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO user_badges (user_id, badge_id, level, awarded_by, expires_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING id, awarded_at`,
+		userBadge.UserID, userBadge.BadgeID, userBadge.Level, userBadge.AwardedBy, userBadge.ExpiresAt,
+	).Scan(&userBadge.ID, &userBadge.AwardedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "user_badges_active_award_key" {
+		err = repository.ErrBadgeAlreadyAwarded
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`
+		INSERT INTO user_badge_events (user_badge_id, user_id, badge_id, event_type, level, actor_id, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)`,
+		userBadge.ID, userBadge.UserID, userBadge.BadgeID, models.UserBadgeEventAwarded,
+		userBadge.Level, userBadge.AwardedBy, userBadge.AwardedAt,
+	); err != nil {
+		return err
+	}
+
+	if err = writeEvent(tx, models.EventTypeBadgeAwarded, "user_badge", strconv.Itoa(userBadge.ID), models.BadgeAwardedEvent{
+		UserBadgeID: userBadge.ID,
+		UserID:      userBadge.UserID,
+		BadgeID:     userBadge.BadgeID,
+		Level:       userBadge.Level,
+		AwardedBy:   userBadge.AwardedBy,
+	}); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *postgresUserBadgeRepository) FindByUserID(userID int) ([]*models.UserBadge, error) {
+	return r.findUserBadges(selectUserBadge+" WHERE user_id = $1 AND "+heldAward, userID)
 }
 
 func (r *postgresUserBadgeRepository) HasBadge(userID, badgeID int) (bool, error) {
@@ -217,34 +299,134 @@ func (r *postgresUserBadgeRepository) HasBadge(userID, badgeID int) (bool, error
 	err := r.DB.QueryRow(`
 		SELECT EXISTS(
 			SELECT 1 FROM user_badges
-			WHERE user_id = $1 AND badge_id = $2
+			WHERE user_id = $1 AND badge_id = $2 AND `+heldAward+`
 		)`,
 		userID, badgeID,
 	).Scan(&exists)
 	return exists, err
 }
 
+func (r *postgresUserBadgeRepository) WasRevoked(userID, badgeID int) (bool, error) {
+	// Expiry closes an award without anyone revoking it
+	var revoked bool
+	err := r.DB.QueryRow(`
+		SELECT COALESCE((
+			SELECT revoked_by IS NOT NULL FROM user_badges
+			WHERE user_id = $1 AND badge_id = $2
+			ORDER BY id DESC
+			LIMIT 1
+		), FALSE)`,
+		userID, badgeID,
+	).Scan(&revoked)
+	return revoked, err
+}
+
 func (r *postgresUserBadgeRepository) FindByBadgeID(badgeID int) ([]*models.UserBadge, error) {
+	return r.findUserBadges(selectUserBadge+" WHERE badge_id = $1 AND "+heldAward+" ORDER BY awarded_at DESC", badgeID)
+}
+
+func (r *postgresUserBadgeRepository) FindHeld(userID, badgeID int) (*models.UserBadge, error) {
+	return scanUserBadge(r.DB.QueryRow(selectUserBadge+" WHERE user_id = $1 AND badge_id = $2 AND "+heldAward, userID, badgeID))
+}
+
+func (r *postgresUserBadgeRepository) UpgradeLevel(userBadgeID int, fromLevel, toLevel string, actorID *int) (err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	event := models.BadgeAwardedEvent{UserBadgeID: userBadgeID, Level: toLevel, PreviousLevel: fromLevel, AwardedBy: actorID}
+	err = tx.QueryRow(`
+		WITH upgraded AS (
+			UPDATE user_badges
+			SET level = $3
+			WHERE id = $1 AND COALESCE(level, '') = $2 AND `+heldAward+`
+			RETURNING id, user_id, badge_id
+		)
+		INSERT INTO user_badge_events (user_badge_id, user_id, badge_id, event_type, previous_level, level, actor_id)
+		SELECT id, user_id, badge_id, $4, NULLIF($2, ''), $3, $5 FROM upgraded
+		RETURNING user_id, badge_id`,
+		userBadgeID, fromLevel, toLevel, models.UserBadgeEventUpgraded, actorID,
+	).Scan(&event.UserID, &event.BadgeID)
+	if err != nil {
+		return err
+	}
+
+	if err = writeEvent(tx, models.EventTypeBadgeAwarded, "user_badge", strconv.Itoa(userBadgeID), event); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *postgresUserBadgeRepository) Revoke(userBadgeID, revokedBy int, reason string) error {
+	result, err := r.DB.Exec(`
+		WITH revoked AS (
+			UPDATE user_badges
+			SET revoked_at = CURRENT_TIMESTAMP, revoked_by = $2, revoke_reason = $3
+			WHERE id = $1 AND `+heldAward+`
+			RETURNING id, user_id, badge_id, level, revoked_at
+		)
+		INSERT INTO user_badge_events (user_badge_id, user_id, badge_id, event_type, level, actor_id, reason, created_at)
+		SELECT id, user_id, badge_id, $4, level, $2, $3, revoked_at FROM revoked`,
+		userBadgeID, revokedBy, reason, models.UserBadgeEventRevoked,
+	)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+func (r *postgresUserBadgeRepository) ExpireDue(now time.Time) (int64, error) {
+	result, err := r.DB.Exec(fmt.Sprintf(expireAwards, ""), now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *postgresUserBadgeRepository) FindHistory(userID int) ([]*models.UserBadgeEvent, error) {
 	rows, err := r.DB.Query(`
-		SELECT ub.id, ub.user_id, ub.badge_id, ub.awarded_at, ub.awarded_by
-		FROM user_badges ub
-		WHERE ub.badge_id = $1
-		ORDER BY ub.awarded_at DESC`,
-		badgeID,
+		SELECT id, user_badge_id, user_id, badge_id, event_type, COALESCE(previous_level, ''),
+			COALESCE(level, ''), actor_id, COALESCE(reason, ''), created_at
+		FROM user_badge_events
+		WHERE user_id = $1
+		ORDER BY created_at, id`,
+		userID,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var userBadges []*models.UserBadge
+	var events []*models.UserBadgeEvent
 	for rows.Next() {
-		ub := &models.UserBadge{}
-		err := rows.Scan(&ub.ID, &ub.UserID, &ub.BadgeID, &ub.AwardedAt, &ub.AwardedBy)
-		if err != nil {
+		event := &models.UserBadgeEvent{}
+		if err := rows.Scan(
+			&event.ID, &event.UserBadgeID, &event.UserID, &event.BadgeID, &event.EventType, &event.PreviousLevel,
+			&event.Level, &event.ActorID, &event.Reason, &event.CreatedAt,
+		); err != nil {
 			return nil, err
 		}
-		userBadges = append(userBadges, ub)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// requireRowAffected returns sql.ErrNoRows when a statement changed nothing
+func requireRowAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
 	}
-	return userBadges, nil
+	return nil
 }
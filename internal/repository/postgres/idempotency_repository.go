@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"database/sql"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+type postgresIdempotencyRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresIdempotencyRepository(db *sql.DB) repository.IdempotencyRepository {
+	return &postgresIdempotencyRepository{DB: db}
+}
+
+func (r *postgresIdempotencyRepository) Reserve(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// An expired key is free to be used again
+	if _, err = tx.Exec(
+		"DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND expires_at <= CURRENT_TIMESTAMP",
+		record.UserID, record.Key,
+	); err != nil {
+		return nil, err
+	}
+
+	record.Status = models.IdempotencyStatusInProgress
+	err = tx.QueryRow(`
+		INSERT INTO idempotency_keys (user_id, key, request_hash, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, key) DO NOTHING
+		RETURNING created_at`,
+		record.UserID, record.Key, record.RequestHash, record.Status, record.ExpiresAt,
+	).Scan(&record.CreatedAt)
+	if err == nil {
+		err = tx.Commit()
+		return nil, err
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	existing := &models.IdempotencyRecord{}
+	var responseStatus sql.NullInt64
+	if err = tx.QueryRow(`
+		SELECT user_id, key, request_hash, status, response_status, response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2`,
+		record.UserID, record.Key,
+	).Scan(
+		&existing.UserID, &existing.Key, &existing.RequestHash, &existing.Status,
+		&responseStatus, &existing.ResponseBody, &existing.CreatedAt, &existing.ExpiresAt,
+	); err != nil {
+		return nil, err
+	}
+	existing.ResponseStatus = int(responseStatus.Int64)
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (r *postgresIdempotencyRepository) Complete(userID int, key string, responseStatus int, responseBody []byte) error {
+	_, err := r.DB.Exec(`
+		UPDATE idempotency_keys
+		SET status = $1, response_status = $2, response_body = $3
+		WHERE user_id = $4 AND key = $5`,
+		models.IdempotencyStatusCompleted, responseStatus, responseBody, userID, key,
+	)
+	return err
+}
+
+func (r *postgresIdempotencyRepository) Delete(userID int, key string) error {
+	_, err := r.DB.Exec("DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2", userID, key)
+	return err
+}
+
+func (r *postgresIdempotencyRepository) DeleteExpired() (int64, error) {
+	res, err := r.DB.Exec("DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
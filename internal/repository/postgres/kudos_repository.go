@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"verve/internal/models"
+	"verve/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+type postgresKudosRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresKudosRepository(db *sql.DB) repository.KudosRepository {
+	return &postgresKudosRepository{DB: db}
+}
+
+// kudosQuery selects public transfers that completed. The sender of an anonymous transfer is
+// never joined, so it cannot leak into the feed.
+const kudosQuery = `
+	SELECT tr.id, tr.is_anonymous, tr.amount, COALESCE(tr.message, ''), COALESCE(tr.category, ''), tr.created_at,
+		su.id, COALESCE(NULLIF(su.display_name, ''), su.username), COALESCE(su.profile_photo_url, ''),
+		ru.id, COALESCE(NULLIF(ru.display_name, ''), ru.username), COALESCE(ru.profile_photo_url, ''),
+		(SELECT COUNT(*) FROM kudos_comments c WHERE c.transfer_id = tr.id)
+	FROM transfers tr
+	JOIN wallets sw ON sw.id = tr.sender_wallet_id
+	JOIN wallets rw ON rw.id = tr.receiver_wallet_id
+	LEFT JOIN users su ON su.id = sw.user_id AND NOT tr.is_anonymous
+	LEFT JOIN users ru ON ru.id = rw.user_id`
+
+func (r *postgresKudosRepository) FindFeed(viewerID int, filter models.KudosFilter) ([]*models.Kudos, error) {
+	conditions := []string{"tr.visibility = 'public'", "tr.status = 'completed'"}
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Category != "" {
+		addCondition("tr.category = $%d", filter.Category)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("tr.id < $%d", filter.BeforeID)
+	}
+
+	args = append(args, filter.Limit)
+	query := kudosQuery + " WHERE " + strings.Join(conditions, " AND ") +
+		fmt.Sprintf(" ORDER BY tr.id DESC LIMIT $%d", len(args))
+
+	return r.findKudos(viewerID, query, args...)
+}
+
+func (r *postgresKudosRepository) FindByTransferID(viewerID int, transferID int64) (*models.Kudos, error) {
+	kudos, err := r.findKudos(viewerID, kudosQuery+" WHERE tr.id = $1 AND tr.visibility = 'public' AND tr.status = 'completed'", transferID)
+	if err != nil {
+		return nil, err
+	}
+	if len(kudos) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return kudos[0], nil
+}
+
+// findKudos runs a kudos query and fills in the reactions of the kudos it finds
+func (r *postgresKudosRepository) findKudos(viewerID int, query string, args ...interface{}) ([]*models.Kudos, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var kudos []*models.Kudos
+	byTransfer := make(map[int64]*models.Kudos)
+	var transferIDs []int64
+	for rows.Next() {
+		k := &models.Kudos{Reactions: []*models.KudosReaction{}}
+		var senderID, receiverID sql.NullInt64
+		var senderName, senderPhoto, receiverName, receiverPhoto sql.NullString
+		var isAnonymous bool
+		if err := rows.Scan(&k.ID, &isAnonymous, &k.Amount, &k.Message, &k.Category, &k.CreatedAt,
+			&senderID, &senderName, &senderPhoto, &receiverID, &receiverName, &receiverPhoto,
+			&k.CommentCount); err != nil {
+			return nil, err
+		}
+		if !isAnonymous {
+			// The transfer ID of anonymous kudos could be used to look the sender up
+			k.TransferID = k.ID
+		}
+		if senderID.Valid {
+			k.Sender = &models.KudosUser{UserID: int(senderID.Int64), DisplayName: senderName.String, ProfilePhotoURL: senderPhoto.String}
+		}
+		if receiverID.Valid {
+			k.Receiver = &models.KudosUser{UserID: int(receiverID.Int64), DisplayName: receiverName.String, ProfilePhotoURL: receiverPhoto.String}
+		}
+		kudos = append(kudos, k)
+		byTransfer[k.ID] = k
+		transferIDs = append(transferIDs, k.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(kudos) == 0 {
+		return kudos, nil
+	}
+
+	reactionRows, err := r.DB.Query(`
+		SELECT transfer_id, reaction, COUNT(*), BOOL_OR(user_id = $2)
+		FROM kudos_reactions
+		WHERE transfer_id = ANY($1)
+		GROUP BY transfer_id, reaction
+		ORDER BY transfer_id, reaction`,
+		pq.Array(transferIDs), viewerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer reactionRows.Close()
+
+	for reactionRows.Next() {
+		var transferID int64
+		reaction := &models.KudosReaction{}
+		if err := reactionRows.Scan(&transferID, &reaction.Reaction, &reaction.Count, &reaction.Reacted); err != nil {
+			return nil, err
+		}
+		byTransfer[transferID].Reactions = append(byTransfer[transferID].Reactions, reaction)
+	}
+	return kudos, reactionRows.Err()
+}
+
+func (r *postgresKudosRepository) AddReaction(transferID int64, userID int, reaction string) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO kudos_reactions (transfer_id, user_id, reaction) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`,
+		transferID, userID, reaction,
+	)
+	return err
+}
+
+func (r *postgresKudosRepository) RemoveReaction(transferID int64, userID int, reaction string) error {
+	_, err := r.DB.Exec(
+		"DELETE FROM kudos_reactions WHERE transfer_id = $1 AND user_id = $2 AND reaction = $3",
+		transferID, userID, reaction,
+	)
+	return err
+}
+
+func (r *postgresKudosRepository) AddComment(comment *models.KudosComment) error {
+	return r.DB.QueryRow(`
+		WITH inserted AS (
+			INSERT INTO kudos_comments (transfer_id, user_id, body) VALUES ($1, $2, $3)
+			RETURNING id, user_id, created_at
+		)
+		SELECT i.id, i.created_at, COALESCE(NULLIF(u.display_name, ''), u.username), COALESCE(u.profile_photo_url, '')
+		FROM inserted i
+		JOIN users u ON u.id = i.user_id`,
+		comment.TransferID, comment.Author.UserID, comment.Body,
+	).Scan(&comment.ID, &comment.CreatedAt, &comment.Author.DisplayName, &comment.Author.ProfilePhotoURL)
+}
+
+func (r *postgresKudosRepository) FindComments(transferID, afterID int64, limit int) ([]*models.KudosComment, error) {
+	rows, err := r.DB.Query(`
+		SELECT c.id, c.transfer_id, c.user_id, COALESCE(NULLIF(u.display_name, ''), u.username),
+			COALESCE(u.profile_photo_url, ''), c.body, c.created_at
+		FROM kudos_comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.transfer_id = $1 AND c.id > $2
+		ORDER BY c.id
+		LIMIT $3`,
+		transferID, afterID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []*models.KudosComment
+	for rows.Next() {
+		comment := &models.KudosComment{}
+		if err := rows.Scan(&comment.ID, &comment.TransferID, &comment.Author.UserID, &comment.Author.DisplayName,
+			&comment.Author.ProfilePhotoURL, &comment.Body, &comment.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+func (r *postgresKudosRepository) DeleteComment(transferID, commentID int64, userID int) error {
+	result, err := r.DB.Exec(
+		"DELETE FROM kudos_comments WHERE id = $1 AND transfer_id = $2 AND user_id = $3",
+		commentID, transferID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
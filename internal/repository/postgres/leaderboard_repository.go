@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+type postgresLeaderboardRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresLeaderboardRepository(db *sql.DB) repository.LeaderboardRepository {
+	return &postgresLeaderboardRepository{DB: db}
+}
+
+// namedTransfers are the transfers between two different users since $1 that name both of them
+const namedTransfers = `
+	WITH named_transfers AS (
+		SELECT sw.user_id AS sender_id, rw.user_id AS receiver_id, t.amount
+		FROM transactions t
+		JOIN transfers tr ON tr.id = t.transfer_id
+		JOIN wallets sw ON sw.id = t.sender_wallet_id
+		JOIN wallets rw ON rw.id = t.receiver_wallet_id
+		WHERE t.created_at >= $1 AND NOT tr.is_anonymous
+			AND NOT sw.is_pseudonymous AND NOT rw.is_pseudonymous
+			AND sw.user_id IS NOT NULL AND rw.user_id IS NOT NULL AND sw.user_id <> rw.user_id
+	)`
+
+// leaderboardScores selects the user_id and score of every ranked user per metric
+var leaderboardScores = map[string]string{
+	models.LeaderboardMetricBadgePoints: `
+		SELECT ub.user_id, SUM(b.points) AS score
+		FROM user_badges ub
+		JOIN badges b ON b.id = ub.badge_id
+		WHERE ub.awarded_at >= $1 AND ` + heldAward + `
+		GROUP BY ub.user_id`,
+	models.LeaderboardMetricCoinsReceived: namedTransfers + `
+		SELECT receiver_id AS user_id, SUM(amount) AS score FROM named_transfers GROUP BY receiver_id`,
+	models.LeaderboardMetricCoinsSent: namedTransfers + `
+		SELECT sender_id AS user_id, SUM(amount) AS score FROM named_transfers GROUP BY sender_id`,
+	models.LeaderboardMetricColleaguesThanked: namedTransfers + `
+		SELECT sender_id AS user_id, COUNT(DISTINCT receiver_id) AS score FROM named_transfers GROUP BY sender_id`,
+}
+
+func (r *postgresLeaderboardRepository) Rank(metric string, since time.Time) ([]*models.LeaderboardEntry, error) {
+	scores, ok := leaderboardScores[metric]
+	if !ok {
+		return nil, fmt.Errorf("unknown leaderboard metric %q", metric)
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT s.user_id, COALESCE(NULLIF(u.display_name, ''), u.username), s.score,
+			RANK() OVER (ORDER BY s.score DESC)
+		FROM (`+scores+`) s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.score > 0
+			AND NOT EXISTS (SELECT 1 FROM leaderboard_opt_outs o WHERE o.user_id = s.user_id)
+		ORDER BY s.score DESC, s.user_id`,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.LeaderboardEntry
+	for rows.Next() {
+		entry := &models.LeaderboardEntry{}
+		if err := rows.Scan(&entry.UserID, &entry.DisplayName, &entry.Score, &entry.Rank); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (r *postgresLeaderboardRepository) SetOptOut(userID int, optOut bool) error {
+	if !optOut {
+		_, err := r.DB.Exec("DELETE FROM leaderboard_opt_outs WHERE user_id = $1", userID)
+		return err
+	}
+	_, err := r.DB.Exec(
+		"INSERT INTO leaderboard_opt_outs (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING",
+		userID,
+	)
+	return err
+}
+
+func (r *postgresLeaderboardRepository) IsOptedOut(userID int) (bool, error) {
+	var optedOut bool
+	err := r.DB.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM leaderboard_opt_outs WHERE user_id = $1)",
+		userID,
+	).Scan(&optedOut)
+	return optedOut, err
+}
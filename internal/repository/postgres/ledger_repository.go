@@ -1,7 +1,11 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"time"
+	"verve/internal/models"
 	"verve/internal/repository"
 )
 
@@ -13,11 +17,383 @@ func NewPostgresLedgerRepository(db *sql.DB) repository.LedgerRepository {
 	return &postgresLedgerRepository{DB: db}
 }
 
-// LogAnonymousTransfer logs an anonymous transfer to the ledger (append-only)
-func (r *postgresLedgerRepository) LogAnonymousTransfer(senderWalletID, receiverWalletID, amount int64, pubKey interface{}, signature string) error {
-	_, err := r.DB.Exec(
-		`INSERT INTO ledger_entries (transaction_id, wallet_id, entry_type, amount, created_at, extra) VALUES (NULL, $1, 'debit', $3, CURRENT_TIMESTAMP, $5), (NULL, $2, 'credit', $3, CURRENT_TIMESTAMP, $6)`,
-		senderWalletID, receiverWalletID, amount, senderWalletID, signature, pubKey,
-	)
+// appendLedgerEntries writes ledger entries at the tip of the hash chain. The chain
+// head stays locked until the DB transaction ends, so entries are chained in commit order.
+func appendLedgerEntries(tx *sql.Tx, entries ...*models.LedgerEntry) error {
+	var seq int64
+	var hash string
+	if err := tx.QueryRow("SELECT last_seq, last_hash FROM ledger_chain_head FOR UPDATE").Scan(&seq, &hash); err != nil {
+		return err
+	}
+
+	// Postgres keeps microseconds, so the hash must be computed over the stored precision
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for _, entry := range entries {
+		seq++
+		entry.ChainSeq = seq
+		entry.PrevHash = hash
+		entry.CreatedAt = now
+		entry.Hash = entry.ComputeHash()
+
+		if err := tx.QueryRow(`
+			INSERT INTO ledger_entries (transaction_id, wallet_id, entry_type, amount, extra, chain_seq, prev_hash, entry_hash, created_at)
+			VALUES (NULLIF($1::INTEGER, 0), $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9)
+			RETURNING id`,
+			entry.TransactionID, entry.WalletID, entry.EntryType, entry.Amount, entry.Extra,
+			entry.ChainSeq, entry.PrevHash, entry.Hash, entry.CreatedAt,
+		).Scan(&entry.ID); err != nil {
+			return err
+		}
+		hash = entry.Hash
+	}
+
+	_, err := tx.Exec("UPDATE ledger_chain_head SET last_seq = $1, last_hash = $2", seq, hash)
 	return err
 }
+
+const selectLedgerEntry = `
+	SELECT id, COALESCE(transaction_id, 0), COALESCE(wallet_id, 0), entry_type, amount, COALESCE(extra, ''),
+		COALESCE(chain_seq, 0), COALESCE(prev_hash, ''), COALESCE(entry_hash, ''), COALESCE(created_at, 'epoch')
+	FROM ledger_entries`
+
+func scanLedgerEntries(rows *sql.Rows) ([]*models.LedgerEntry, error) {
+	var entries []*models.LedgerEntry
+	for rows.Next() {
+		entry := &models.LedgerEntry{}
+		if err := rows.Scan(
+			&entry.ID, &entry.TransactionID, &entry.WalletID, &entry.EntryType, &entry.Amount, &entry.Extra,
+			&entry.ChainSeq, &entry.PrevHash, &entry.Hash, &entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (r *postgresLedgerRepository) SealUnchainedEntries() (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var seq int64
+	var hash string
+	if err = tx.QueryRow("SELECT last_seq, last_hash FROM ledger_chain_head FOR UPDATE").Scan(&seq, &hash); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(selectLedgerEntry + " WHERE chain_seq IS NULL ORDER BY id")
+	if err != nil {
+		return 0, err
+	}
+	entries, err := scanLedgerEntries(rows)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		err = tx.Commit()
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		seq++
+		entry.ChainSeq = seq
+		entry.PrevHash = hash
+		entry.Hash = entry.ComputeHash()
+		if _, err = tx.Exec(
+			"UPDATE ledger_entries SET chain_seq = $1, prev_hash = $2, entry_hash = $3 WHERE id = $4",
+			entry.ChainSeq, entry.PrevHash, entry.Hash, entry.ID,
+		); err != nil {
+			return 0, err
+		}
+		hash = entry.Hash
+	}
+
+	if _, err = tx.Exec("UPDATE ledger_chain_head SET last_seq = $1, last_hash = $2", seq, hash); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(entries)), nil
+}
+
+func (r *postgresLedgerRepository) ChainHead() (int64, string, error) {
+	var seq int64
+	var hash string
+	err := r.DB.QueryRow("SELECT last_seq, last_hash FROM ledger_chain_head").Scan(&seq, &hash)
+	return seq, hash, err
+}
+
+func (r *postgresLedgerRepository) FindChainEntries(fromSeq, toSeq int64, limit int) ([]*models.LedgerEntry, error) {
+	rows, err := r.DB.Query(
+		selectLedgerEntry+" WHERE chain_seq >= $1 AND chain_seq <= $2 ORDER BY chain_seq LIMIT $3",
+		fromSeq, toSeq, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLedgerEntries(rows)
+}
+
+func (r *postgresLedgerRepository) CountUnchainedEntries() (int64, error) {
+	var count int64
+	err := r.DB.QueryRow("SELECT COUNT(*) FROM ledger_entries WHERE chain_seq IS NULL").Scan(&count)
+	return count, err
+}
+
+func (r *postgresLedgerRepository) CreateCheckpoint(checkpoint *models.LedgerCheckpoint) error {
+	return r.DB.QueryRow(
+		"INSERT INTO ledger_checkpoints (chain_seq, entry_hash, signature, public_key) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		checkpoint.ChainSeq, checkpoint.EntryHash, checkpoint.Signature, checkpoint.PublicKey,
+	).Scan(&checkpoint.ID, &checkpoint.CreatedAt)
+}
+
+func (r *postgresLedgerRepository) queryCheckpoints(query string, args ...interface{}) ([]*models.LedgerCheckpoint, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []*models.LedgerCheckpoint
+	for rows.Next() {
+		checkpoint := &models.LedgerCheckpoint{}
+		if err := rows.Scan(
+			&checkpoint.ID, &checkpoint.ChainSeq, &checkpoint.EntryHash, &checkpoint.Signature,
+			&checkpoint.PublicKey, &checkpoint.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, rows.Err()
+}
+
+func (r *postgresLedgerRepository) FindCheckpoints(limit int) ([]*models.LedgerCheckpoint, error) {
+	return r.queryCheckpoints(
+		"SELECT id, chain_seq, entry_hash, signature, public_key, created_at FROM ledger_checkpoints ORDER BY id DESC LIMIT $1",
+		limit,
+	)
+}
+
+func (r *postgresLedgerRepository) FindCheckpointsInRange(fromSeq, toSeq int64) ([]*models.LedgerCheckpoint, error) {
+	return r.queryCheckpoints(
+		"SELECT id, chain_seq, entry_hash, signature, public_key, created_at FROM ledger_checkpoints WHERE chain_seq >= $1 AND chain_seq <= $2 ORDER BY chain_seq, id",
+		fromSeq, toSeq,
+	)
+}
+
+func (r *postgresLedgerRepository) Reconcile() (*models.ReconciliationReport, error) {
+	// Balances and ledger entries are written in the same DB transaction, so all
+	// checks must read one snapshot to avoid reporting transfers in flight.
+	tx, err := r.DB.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &models.ReconciliationReport{Discrepancies: []*models.LedgerDiscrepancy{}}
+	if err := tx.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM wallets), (SELECT COUNT(*) FROM transactions), (SELECT COUNT(*) FROM ledger_entries)`,
+	).Scan(&report.WalletsChecked, &report.TransactionsChecked, &report.EntriesChecked); err != nil {
+		return nil, err
+	}
+
+	checks := []func(*sql.Tx) ([]*models.LedgerDiscrepancy, error){
+		findBalanceDrift,
+		findTransactionMismatches,
+		findMisroutedEntries,
+		findOrphanEntries,
+	}
+	for _, check := range checks {
+		discrepancies, err := check(tx)
+		if err != nil {
+			return nil, err
+		}
+		report.Discrepancies = append(report.Discrepancies, discrepancies...)
+	}
+	report.DiscrepancyCount = len(report.Discrepancies)
+
+	return report, tx.Commit()
+}
+
+// findBalanceDrift recomputes every wallet balance from its ledger entries
+func findBalanceDrift(tx *sql.Tx) ([]*models.LedgerDiscrepancy, error) {
+	rows, err := tx.Query(`
+		SELECT w.id, w.balance,
+			COALESCE(SUM(CASE WHEN le.entry_type = 'credit' THEN le.amount ELSE -le.amount END), 0) AS ledger_balance
+		FROM wallets w
+		LEFT JOIN ledger_entries le ON le.wallet_id = w.id AND le.transaction_id IS NOT NULL
+		GROUP BY w.id
+		HAVING w.balance <> COALESCE(SUM(CASE WHEN le.entry_type = 'credit' THEN le.amount ELSE -le.amount END), 0)
+		ORDER BY w.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discrepancies []*models.LedgerDiscrepancy
+	for rows.Next() {
+		d := &models.LedgerDiscrepancy{Type: models.DiscrepancyBalanceDrift}
+		var walletID int64
+		if err := rows.Scan(&walletID, &d.Actual, &d.Expected); err != nil {
+			return nil, err
+		}
+		d.WalletID = &walletID
+		discrepancies = append(discrepancies, d)
+	}
+	return discrepancies, rows.Err()
+}
+
+// findTransactionMismatches checks that each side of a transaction adds up to its amount.
+// Mints have no sender, so they are expected to have no debit side.
+func findTransactionMismatches(tx *sql.Tx) ([]*models.LedgerDiscrepancy, error) {
+	rows, err := tx.Query(`
+		WITH sides AS (
+			SELECT t.id, t.amount,
+				CASE WHEN t.sender_wallet_id IS NULL THEN 0 ELSE t.amount END AS expected_debits,
+				COALESCE(SUM(le.amount) FILTER (WHERE le.entry_type = 'debit'), 0) AS debits,
+				COALESCE(SUM(le.amount) FILTER (WHERE le.entry_type = 'credit'), 0) AS credits
+			FROM transactions t
+			LEFT JOIN ledger_entries le ON le.transaction_id = t.id
+			GROUP BY t.id
+		)
+		SELECT id, 'debit', expected_debits, debits FROM sides WHERE debits <> expected_debits
+		UNION ALL
+		SELECT id, 'credit', amount, credits FROM sides WHERE credits <> amount
+		ORDER BY 1, 2`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discrepancies []*models.LedgerDiscrepancy
+	for rows.Next() {
+		d := &models.LedgerDiscrepancy{Type: models.DiscrepancyTransactionMismatch}
+		var transactionID int64
+		if err := rows.Scan(&transactionID, &d.EntryType, &d.Expected, &d.Actual); err != nil {
+			return nil, err
+		}
+		d.TransactionID = &transactionID
+		discrepancies = append(discrepancies, d)
+	}
+	return discrepancies, rows.Err()
+}
+
+// findMisroutedEntries finds debits not on the sender wallet and credits not on the receiver wallet
+func findMisroutedEntries(tx *sql.Tx) ([]*models.LedgerDiscrepancy, error) {
+	rows, err := tx.Query(`
+		SELECT le.id, le.wallet_id, le.transaction_id, le.entry_type, le.amount
+		FROM ledger_entries le
+		JOIN transactions t ON t.id = le.transaction_id
+		WHERE (le.entry_type = 'debit' AND le.wallet_id IS DISTINCT FROM t.sender_wallet_id)
+			OR (le.entry_type = 'credit' AND le.wallet_id IS DISTINCT FROM t.receiver_wallet_id)
+		ORDER BY le.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEntryDiscrepancies(rows, models.DiscrepancyMisroutedEntry)
+}
+
+// findOrphanEntries finds ledger entries without a transaction, such as the ones
+// anonymous transfers used to log without changing any balance
+func findOrphanEntries(tx *sql.Tx) ([]*models.LedgerDiscrepancy, error) {
+	rows, err := tx.Query(`
+		SELECT id, wallet_id, transaction_id, entry_type, amount
+		FROM ledger_entries
+		WHERE transaction_id IS NULL
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEntryDiscrepancies(rows, models.DiscrepancyOrphanEntry)
+}
+
+func scanEntryDiscrepancies(rows *sql.Rows, discrepancyType models.DiscrepancyType) ([]*models.LedgerDiscrepancy, error) {
+	var discrepancies []*models.LedgerDiscrepancy
+	for rows.Next() {
+		d := &models.LedgerDiscrepancy{Type: discrepancyType}
+		var entryID int64
+		if err := rows.Scan(&entryID, &d.WalletID, &d.TransactionID, &d.EntryType, &d.Actual); err != nil {
+			return nil, err
+		}
+		d.EntryID = &entryID
+		discrepancies = append(discrepancies, d)
+	}
+	return discrepancies, rows.Err()
+}
+
+func (r *postgresLedgerRepository) SaveReconciliation(report *models.ReconciliationReport) error {
+	discrepancies, err := json.Marshal(report.Discrepancies)
+	if err != nil {
+		return err
+	}
+	return r.DB.QueryRow(`
+		INSERT INTO ledger_reconciliations
+			(triggered_by, wallets_checked, transactions_checked, entries_checked, discrepancy_count, discrepancies, started_at, completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		report.TriggeredBy, report.WalletsChecked, report.TransactionsChecked, report.EntriesChecked,
+		report.DiscrepancyCount, discrepancies, report.StartedAt, report.CompletedAt,
+	).Scan(&report.ID)
+}
+
+func (r *postgresLedgerRepository) FindReconciliationByID(id int64) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{}
+	var discrepancies []byte
+	err := r.DB.QueryRow(`
+		SELECT id, triggered_by, wallets_checked, transactions_checked, entries_checked, discrepancy_count,
+			discrepancies, started_at, completed_at
+		FROM ledger_reconciliations
+		WHERE id = $1`, id,
+	).Scan(
+		&report.ID, &report.TriggeredBy, &report.WalletsChecked, &report.TransactionsChecked, &report.EntriesChecked,
+		&report.DiscrepancyCount, &discrepancies, &report.StartedAt, &report.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(discrepancies, &report.Discrepancies); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (r *postgresLedgerRepository) FindReconciliations(limit int) ([]*models.ReconciliationReport, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, triggered_by, wallets_checked, transactions_checked, entries_checked, discrepancy_count,
+			started_at, completed_at
+		FROM ledger_reconciliations
+		ORDER BY id DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []*models.ReconciliationReport
+	for rows.Next() {
+		report := &models.ReconciliationReport{}
+		if err := rows.Scan(
+			&report.ID, &report.TriggeredBy, &report.WalletsChecked, &report.TransactionsChecked, &report.EntriesChecked,
+			&report.DiscrepancyCount, &report.StartedAt, &report.CompletedAt,
+		); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
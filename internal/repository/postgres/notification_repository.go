@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+type postgresNotificationRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresNotificationRepository(db *sql.DB) repository.NotificationRepository {
+	return &postgresNotificationRepository{DB: db}
+}
+
+const selectNotification = `
+	SELECT id, user_id, type, message, data, COALESCE(event_id, 0), read_at, created_at
+	FROM notifications`
+
+func (r *postgresNotificationRepository) Create(notification *models.Notification) error {
+	var eventID interface{}
+	if notification.EventID != 0 {
+		eventID = notification.EventID
+	}
+	err := r.DB.QueryRow(`
+		INSERT INTO notifications (user_id, type, message, data, event_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, event_id) DO NOTHING
+		RETURNING id, created_at`,
+		notification.UserID, notification.Type, notification.Message, nullableJSON(notification.Data), eventID,
+	).Scan(&notification.ID, &notification.CreatedAt)
+	if err == sql.ErrNoRows {
+		return repository.ErrNotificationExists
+	}
+	return err
+}
+
+func (r *postgresNotificationRepository) Find(userID int, filter models.NotificationFilter) ([]*models.Notification, error) {
+	query := selectNotification
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	addCondition("user_id = $%d", userID)
+	if filter.UnreadOnly {
+		conditions = append(conditions, "read_at IS NULL")
+	}
+	if filter.AfterID > 0 {
+		addCondition("id > $%d", filter.AfterID)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	return r.findNotifications(query, args...)
+}
+
+func (r *postgresNotificationRepository) FindSince(userID int, afterID int64, limit int) ([]*models.Notification, error) {
+	return r.findNotifications(selectNotification+`
+		WHERE user_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3`,
+		userID, afterID, limit,
+	)
+}
+
+func (r *postgresNotificationRepository) findNotifications(query string, args ...interface{}) ([]*models.Notification, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*models.Notification
+	for rows.Next() {
+		notification := &models.Notification{}
+		var data []byte
+		if err := rows.Scan(&notification.ID, &notification.UserID, &notification.Type, &notification.Message,
+			&data, &notification.EventID, &notification.ReadAt, &notification.CreatedAt); err != nil {
+			return nil, err
+		}
+		notification.Data = data
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+func (r *postgresNotificationRepository) CountUnread(userID int) (int, error) {
+	var count int
+	err := r.DB.QueryRow(
+		"SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL",
+		userID,
+	).Scan(&count)
+	return count, err
+}
+
+func (r *postgresNotificationRepository) MarkRead(userID int, id int64) error {
+	result, err := r.DB.Exec(`
+		UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+func (r *postgresNotificationRepository) MarkAllRead(userID int) (int64, error) {
+	result, err := r.DB.Exec(
+		"UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND read_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
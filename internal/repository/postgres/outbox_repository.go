@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+type postgresOutboxRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresOutboxRepository(db *sql.DB) repository.OutboxRepository {
+	return &postgresOutboxRepository{DB: db}
+}
+
+// writeEvent adds an event to the outbox in the transaction of the change it describes, so
+// the event is published if and only if the change is committed
+func writeEvent(tx *sql.Tx, eventType, aggregateType, aggregateID string, payload interface{}) error {
+	document, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO event_outbox (event_type, aggregate_type, aggregate_id, payload)
+		VALUES ($1, $2, $3, $4)`,
+		eventType, aggregateType, aggregateID, string(document),
+	)
+	return err
+}
+
+const outboxEventColumns = `id, event_type, aggregate_type, aggregate_id, payload, status, attempts,
+	next_attempt_at, delivered_to, COALESCE(last_error, ''), created_at, processed_at`
+
+func (r *postgresOutboxRepository) Claim(now time.Time, lease time.Duration, limit int) ([]*models.OutboxEvent, error) {
+	events, err := r.findEvents(`
+		UPDATE event_outbox
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM event_outbox
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+outboxEventColumns,
+		now, now.Add(lease), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+func (r *postgresOutboxRepository) MarkDelivered(eventID int64, subscriber string) error {
+	_, err := r.DB.Exec(`
+		UPDATE event_outbox
+		SET delivered_to = array_append(delivered_to, $2)
+		WHERE id = $1 AND NOT ($2 = ANY(delivered_to))`,
+		eventID, subscriber,
+	)
+	return err
+}
+
+func (r *postgresOutboxRepository) MarkProcessed(eventID int64) error {
+	_, err := r.DB.Exec(`
+		UPDATE event_outbox
+		SET status = 'processed', processed_at = CURRENT_TIMESTAMP, last_error = NULL
+		WHERE id = $1`,
+		eventID,
+	)
+	return err
+}
+
+func (r *postgresOutboxRepository) Reschedule(eventID int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	_, err := r.DB.Exec(`
+		UPDATE event_outbox SET attempts = $2, next_attempt_at = $3, last_error = $4 WHERE id = $1`,
+		eventID, attempts, nextAttemptAt, lastError,
+	)
+	return err
+}
+
+func (r *postgresOutboxRepository) MarkDead(eventID int64, attempts int, lastError string) error {
+	_, err := r.DB.Exec(`
+		UPDATE event_outbox SET status = 'dead', attempts = $2, last_error = $3 WHERE id = $1`,
+		eventID, attempts, lastError,
+	)
+	return err
+}
+
+func (r *postgresOutboxRepository) FindDead(limit int) ([]*models.OutboxEvent, error) {
+	return r.findEvents("SELECT "+outboxEventColumns+" FROM event_outbox WHERE status = 'dead' ORDER BY id DESC LIMIT $1", limit)
+}
+
+func (r *postgresOutboxRepository) Requeue(eventID int64) error {
+	result, err := r.DB.Exec(`
+		UPDATE event_outbox
+		SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'dead'`,
+		eventID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+func (r *postgresOutboxRepository) findEvents(query string, args ...interface{}) ([]*models.OutboxEvent, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		event := &models.OutboxEvent{}
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.EventType, &event.AggregateType, &event.AggregateID, &payload,
+			&event.Status, &event.Attempts, &event.NextAttemptAt, pq.Array(&event.DeliveredTo),
+			&event.LastError, &event.CreatedAt, &event.ProcessedAt); err != nil {
+			return nil, err
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+type postgresPaymentRequestRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresPaymentRequestRepository(db *sql.DB) repository.PaymentRequestRepository {
+	return &postgresPaymentRequestRepository{DB: db}
+}
+
+// paymentRequestColumns reads a pending request past its expiry as expired
+const paymentRequestColumns = `id, requester_user_id, requester_wallet_id, payer_user_id, payer_wallet_id,
+	amount, COALESCE(message, ''),
+	CASE WHEN status = 'pending' AND expires_at <= NOW() THEN 'expired' ELSE status END,
+	transfer_id, expires_at, created_at, updated_at`
+
+func scanPaymentRequest(row rowScanner) (*models.PaymentRequest, error) {
+	request := &models.PaymentRequest{}
+	if err := row.Scan(
+		&request.ID, &request.RequesterUserID, &request.RequesterWalletID, &request.PayerUserID,
+		&request.PayerWalletID, &request.Amount, &request.Message, &request.Status,
+		&request.TransferID, &request.ExpiresAt, &request.CreatedAt, &request.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (r *postgresPaymentRequestRepository) Create(request *models.PaymentRequest) error {
+	return r.DB.QueryRow(`
+		INSERT INTO payment_requests (requester_user_id, requester_wallet_id, payer_user_id, payer_wallet_id,
+			amount, message, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`,
+		request.RequesterUserID, request.RequesterWalletID, request.PayerUserID, request.PayerWalletID,
+		request.Amount, nullableString(request.Message), request.Status, request.ExpiresAt,
+	).Scan(&request.ID, &request.CreatedAt, &request.UpdatedAt)
+}
+
+func (r *postgresPaymentRequestRepository) FindByID(id int64) (*models.PaymentRequest, error) {
+	return scanPaymentRequest(r.DB.QueryRow(
+		"SELECT "+paymentRequestColumns+" FROM payment_requests WHERE id = $1", id,
+	))
+}
+
+func (r *postgresPaymentRequestRepository) Find(filter models.PaymentRequestFilter) ([]*models.PaymentRequest, error) {
+	query := "SELECT " + paymentRequestColumns + " FROM payment_requests"
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.RequesterUserID != 0 {
+		addCondition("requester_user_id = $%d", filter.RequesterUserID)
+	}
+	if filter.PayerUserID != 0 {
+		addCondition("payer_user_id = $%d", filter.PayerUserID)
+	}
+	switch filter.Status {
+	case "":
+	case models.PaymentRequestStatusPending:
+		addCondition("status = $%d AND expires_at > NOW()", filter.Status)
+	case models.PaymentRequestStatusExpired:
+		addCondition("(status = $%d OR (status = 'pending' AND expires_at <= NOW()))", filter.Status)
+	default:
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*models.PaymentRequest
+	for rows.Next() {
+		request, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+func (r *postgresPaymentRequestRepository) UpdateStatus(id int64, from, to models.PaymentRequestStatus) (*models.PaymentRequest, error) {
+	return scanPaymentRequest(r.DB.QueryRow(`
+		UPDATE payment_requests SET status = $3
+		WHERE id = $1 AND status = $2 AND (status <> 'pending' OR expires_at > NOW())
+		RETURNING `+paymentRequestColumns,
+		id, from, to,
+	))
+}
+
+func (r *postgresPaymentRequestRepository) ExpireDue(now time.Time) (int64, error) {
+	result, err := r.DB.Exec(
+		"UPDATE payment_requests SET status = 'expired' WHERE status = 'pending' AND expires_at <= $1",
+		now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// markPaymentRequestPaid marks a pending payment request paid by a transfer within the
+// transaction that executes the transfer
+func markPaymentRequestPaid(tx *sql.Tx, id, transferID int64) error {
+	result, err := tx.Exec(`
+		UPDATE payment_requests SET status = 'paid', transfer_id = $2
+		WHERE id = $1 AND status = 'pending' AND expires_at > CURRENT_TIMESTAMP`,
+		id, transferID,
+	)
+	if err != nil {
+		return err
+	}
+	marked, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if marked == 0 {
+		return repository.ErrPaymentRequestNotPending
+	}
+	return nil
+}
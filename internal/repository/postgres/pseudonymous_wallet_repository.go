@@ -2,8 +2,12 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
+	"strconv"
 	"verve/internal/models"
 	"verve/internal/repository"
+
+	"github.com/lib/pq"
 )
 
 type postgresPseudonymousWalletRepository struct {
@@ -14,22 +18,59 @@ func NewPostgresPseudonymousWalletRepository(db *sql.DB) repository.Pseudonymous
 	return &postgresPseudonymousWalletRepository{DB: db}
 }
 
+// isPublicKeyConflict reports whether err is a duplicate of an already registered key
+func isPublicKeyConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "pseudonymous_wallet_keys_public_key_key"
+}
+
 func (r *postgresPseudonymousWalletRepository) CreatePseudonymousWallet(publicKey string) (*models.PseudonymousWallet, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
 	var id int64
 	isPseudonymous := true
-	if err := r.DB.QueryRow(
+	if err = tx.QueryRow(
 		"INSERT INTO wallets (public_key, is_pseudonymous, balance) VALUES ($1, $2, 0) RETURNING id",
 		publicKey, isPseudonymous,
 	).Scan(&id); err != nil {
 		return nil, err
 	}
+
+	if _, err = tx.Exec(
+		"INSERT INTO pseudonymous_wallet_keys (wallet_id, public_key, status) VALUES ($1, $2, $3)",
+		id, publicKey, models.WalletKeyStatusActive,
+	); err != nil {
+		if isPublicKeyConflict(err) {
+			return nil, repository.ErrPublicKeyInUse
+		}
+		return nil, err
+	}
+
+	if err = writeEvent(tx, models.EventTypeWalletCreated, "wallet", strconv.FormatInt(id, 10), models.WalletCreatedEvent{
+		WalletID:       id,
+		IsPseudonymous: true,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
 	return &models.PseudonymousWallet{ID: id, PublicKey: publicKey, IsPseudonymous: true}, nil
 }
 
 func (r *postgresPseudonymousWalletRepository) FindByID(id int64) (*models.PseudonymousWallet, error) {
 	w := &models.PseudonymousWallet{}
 	if err := r.DB.QueryRow(
-		"SELECT id, public_key, is_pseudonymous FROM wallets WHERE id = $1 AND is_pseudonymous = TRUE", id,
+		"SELECT id, COALESCE(public_key, ''), is_pseudonymous FROM wallets WHERE id = $1 AND is_pseudonymous = TRUE", id,
 	).Scan(&w.ID, &w.PublicKey, &w.IsPseudonymous); err != nil {
 		return nil, err
 	}
@@ -45,3 +86,120 @@ func (r *postgresPseudonymousWalletRepository) FindByPublicKey(publicKey string)
 	}
 	return w, nil
 }
+
+// lockActiveKey locks the wallet and checks that publicKey is still its active key
+func lockActiveKey(tx *sql.Tx, walletID int64, publicKey string) error {
+	var current sql.NullString
+	if err := tx.QueryRow(
+		"SELECT public_key FROM wallets WHERE id = $1 AND is_pseudonymous = TRUE FOR UPDATE", walletID,
+	).Scan(&current); err != nil {
+		return err
+	}
+	if !current.Valid || current.String != publicKey {
+		return repository.ErrWalletKeyChanged
+	}
+	return nil
+}
+
+func (r *postgresPseudonymousWalletRepository) RotateKey(walletID int64, oldPublicKey, newPublicKey, rotationSignature string) (*models.PseudonymousWalletKey, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = lockActiveKey(tx, walletID, oldPublicKey); err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec(
+		"UPDATE pseudonymous_wallet_keys SET status = $1, retired_at = CURRENT_TIMESTAMP WHERE wallet_id = $2 AND status = $3",
+		models.WalletKeyStatusRotated, walletID, models.WalletKeyStatusActive,
+	); err != nil {
+		return nil, err
+	}
+
+	key := &models.PseudonymousWalletKey{
+		WalletID:          walletID,
+		PublicKey:         newPublicKey,
+		Status:            models.WalletKeyStatusActive,
+		RotationSignature: rotationSignature,
+	}
+	if err = tx.QueryRow(
+		"INSERT INTO pseudonymous_wallet_keys (wallet_id, public_key, status, rotation_signature) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		key.WalletID, key.PublicKey, key.Status, key.RotationSignature,
+	).Scan(&key.ID, &key.CreatedAt); err != nil {
+		if isPublicKeyConflict(err) {
+			err = repository.ErrPublicKeyInUse
+		}
+		return nil, err
+	}
+
+	if _, err = tx.Exec("UPDATE wallets SET public_key = $1 WHERE id = $2", newPublicKey, walletID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *postgresPseudonymousWalletRepository) RevokeKey(walletID int64, publicKey, reason string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = lockActiveKey(tx, walletID, publicKey); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(
+		"UPDATE pseudonymous_wallet_keys SET status = $1, revocation_reason = $2, retired_at = CURRENT_TIMESTAMP WHERE wallet_id = $3 AND status = $4",
+		models.WalletKeyStatusRevoked, reason, walletID, models.WalletKeyStatusActive,
+	); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("UPDATE wallets SET public_key = NULL WHERE id = $1", walletID); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *postgresPseudonymousWalletRepository) FindKeys(walletID int64) ([]*models.PseudonymousWalletKey, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, wallet_id, public_key, status, COALESCE(rotation_signature, ''), COALESCE(revocation_reason, ''),
+			created_at, retired_at
+		FROM pseudonymous_wallet_keys
+		WHERE wallet_id = $1
+		ORDER BY id DESC`, walletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*models.PseudonymousWalletKey
+	for rows.Next() {
+		key := &models.PseudonymousWalletKey{}
+		if err := rows.Scan(
+			&key.ID, &key.WalletID, &key.PublicKey, &key.Status, &key.RotationSignature, &key.RevocationReason,
+			&key.CreatedAt, &key.RetiredAt,
+		); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
@@ -2,8 +2,11 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
 	"verve/internal/models"
 	"verve/internal/repository"
+
+	"github.com/lib/pq"
 )
 
 type postgresRoleRepository struct {
@@ -26,6 +29,11 @@ func (r *postgresRoleRepository) FindByName(name string) (*models.Role, error) {
 
 func (r *postgresRoleRepository) AssignToUser(userID, roleID int) error {
 	_, err := r.DB.Exec("INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2)", userID, roleID)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "user_roles_pkey" {
+		return repository.ErrRoleAlreadyAssigned
+	}
 	return err
 }
 
@@ -46,3 +54,179 @@ func (r *postgresRoleRepository) GetForUser(userID int) ([]string, error) {
 	}
 	return roles, nil
 }
+
+func (r *postgresRoleRepository) GetPermissions(roleName string) ([]string, error) {
+	rows, err := r.DB.Query(`
+		SELECT p.name
+		FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		JOIN roles r ON r.id = rp.role_id
+		WHERE r.name = $1
+		ORDER BY p.name`,
+		roleName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []string
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+func (r *postgresRoleRepository) FindAll() ([]*models.Role, error) {
+	rows, err := r.DB.Query(`
+		SELECT r.id, r.name, COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		GROUP BY r.id, r.name
+		ORDER BY r.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []*models.Role
+	for rows.Next() {
+		role := &models.Role{}
+		if err := rows.Scan(&role.ID, &role.Name, pq.Array(&role.Permissions)); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (r *postgresRoleRepository) Create(role *models.Role) error {
+	err := r.DB.QueryRow("INSERT INTO roles (name) VALUES ($1) RETURNING id", role.Name).Scan(&role.ID)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "roles_name_key" {
+		return repository.ErrRoleExists
+	}
+	return err
+}
+
+func (r *postgresRoleRepository) Delete(roleID int) (err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the role so it cannot be assigned while it is deleted
+	if _, err = tx.Exec("SELECT id FROM roles WHERE id = $1 FOR UPDATE", roleID); err != nil {
+		return err
+	}
+	var inUse bool
+	if err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM user_roles WHERE role_id = $1)", roleID).Scan(&inUse); err != nil {
+		return err
+	}
+	if inUse {
+		err = repository.ErrRoleInUse
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM role_permissions WHERE role_id = $1", roleID); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM roles WHERE id = $1", roleID)
+	if err != nil {
+		return err
+	}
+	if err = requireRowAffected(result); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *postgresRoleRepository) FindAllPermissions() ([]*models.Permission, error) {
+	rows, err := r.DB.Query("SELECT id, name FROM permissions ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []*models.Permission
+	for rows.Next() {
+		permission := &models.Permission{}
+		if err := rows.Scan(&permission.ID, &permission.Name); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+func (r *postgresRoleRepository) GrantPermission(roleID int, permission string) error {
+	var permissionID int
+	if err := r.DB.QueryRow("SELECT id FROM permissions WHERE name = $1", permission).Scan(&permissionID); err != nil {
+		return err
+	}
+	_, err := r.DB.Exec(
+		"INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		roleID, permissionID,
+	)
+	return err
+}
+
+func (r *postgresRoleRepository) RevokePermission(roleID int, permission string) error {
+	_, err := r.DB.Exec(`
+		DELETE FROM role_permissions
+		WHERE role_id = $1 AND permission_id = (SELECT id FROM permissions WHERE name = $2)`,
+		roleID, permission,
+	)
+	return err
+}
+
+func (r *postgresRoleRepository) RemoveFromUser(userID, roleID int, keepLastHolder bool) (err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the role so two users cannot both give it up as its last holders
+	if _, err = tx.Exec("SELECT id FROM roles WHERE id = $1 FOR UPDATE", roleID); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2", userID, roleID)
+	if err != nil {
+		return err
+	}
+	if err = requireRowAffected(result); err != nil {
+		return err
+	}
+
+	if keepLastHolder {
+		var holders int
+		if err = tx.QueryRow("SELECT COUNT(*) FROM user_roles WHERE role_id = $1", roleID).Scan(&holders); err != nil {
+			return err
+		}
+		if holders == 0 {
+			err = repository.ErrLastRoleHolder
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
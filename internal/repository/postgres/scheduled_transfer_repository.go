@@ -0,0 +1,308 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+type postgresScheduledTransferRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresScheduledTransferRepository(db *sql.DB) repository.ScheduledTransferRepository {
+	return &postgresScheduledTransferRepository{DB: db}
+}
+
+const scheduledTransferColumns = `id, user_id, sender_wallet_id, receiver_wallet_id, amount, is_anonymous,
+	COALESCE(message, ''), COALESCE(category, ''), visibility, COALESCE(cron, ''), timezone, catch_up,
+	end_at, max_runs, run_count, next_run_at, status, created_at, updated_at`
+
+func scanScheduledTransfer(row rowScanner) (*models.ScheduledTransfer, error) {
+	schedule := &models.ScheduledTransfer{}
+	if err := row.Scan(
+		&schedule.ID, &schedule.UserID, &schedule.SenderWalletID, &schedule.ReceiverWalletID,
+		&schedule.Amount, &schedule.IsAnonymous, &schedule.Message, &schedule.Category,
+		&schedule.Visibility, &schedule.Cron, &schedule.Timezone, &schedule.CatchUp,
+		&schedule.EndAt, &schedule.MaxRuns, &schedule.RunCount, &schedule.NextRunAt,
+		&schedule.Status, &schedule.CreatedAt, &schedule.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (r *postgresScheduledTransferRepository) findSchedules(query string, args ...interface{}) ([]*models.ScheduledTransfer, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*models.ScheduledTransfer
+	for rows.Next() {
+		schedule, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *postgresScheduledTransferRepository) Create(schedule *models.ScheduledTransfer) error {
+	return r.DB.QueryRow(`
+		INSERT INTO scheduled_transfers (user_id, sender_wallet_id, receiver_wallet_id, amount, is_anonymous,
+			message, category, visibility, cron, timezone, catch_up, end_at, max_runs, next_run_at, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING id, created_at, updated_at`,
+		schedule.UserID, schedule.SenderWalletID, schedule.ReceiverWalletID, schedule.Amount,
+		schedule.IsAnonymous, nullableString(schedule.Message), nullableString(schedule.Category),
+		schedule.Visibility, nullableString(schedule.Cron), schedule.Timezone, schedule.CatchUp,
+		schedule.EndAt, schedule.MaxRuns, schedule.NextRunAt, schedule.Status,
+	).Scan(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
+}
+
+func (r *postgresScheduledTransferRepository) FindByID(id int64) (*models.ScheduledTransfer, error) {
+	return scanScheduledTransfer(r.DB.QueryRow(
+		"SELECT "+scheduledTransferColumns+" FROM scheduled_transfers WHERE id = $1", id,
+	))
+}
+
+func (r *postgresScheduledTransferRepository) Find(filter models.ScheduledTransferFilter) ([]*models.ScheduledTransfer, error) {
+	query := "SELECT " + scheduledTransferColumns + " FROM scheduled_transfers"
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != 0 {
+		addCondition("user_id = $%d", filter.UserID)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	return r.findSchedules(query, args...)
+}
+
+func (r *postgresScheduledTransferRepository) UpdateStatus(id int64, from, to models.ScheduledTransferStatus, nextRunAt *time.Time) (*models.ScheduledTransfer, error) {
+	return scanScheduledTransfer(r.DB.QueryRow(`
+		UPDATE scheduled_transfers SET status = $3, next_run_at = $4
+		WHERE id = $1 AND status = $2
+		RETURNING `+scheduledTransferColumns,
+		id, from, to, nextRunAt,
+	))
+}
+
+func (r *postgresScheduledTransferRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]*models.ScheduledTransfer, error) {
+	schedules, err := r.findSchedules(`
+		UPDATE scheduled_transfers
+		SET locked_until = $2
+		WHERE id IN (
+			SELECT id FROM scheduled_transfers
+			WHERE status = 'active' AND next_run_at <= $1 AND (locked_until IS NULL OR locked_until < $1)
+			ORDER BY next_run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+scheduledTransferColumns,
+		now, now.Add(lease), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return schedules, nil
+}
+
+func (r *postgresScheduledTransferRepository) Advance(id int64, runs int, nextRunAt *time.Time) error {
+	_, err := r.DB.Exec(`
+		UPDATE scheduled_transfers
+		SET run_count = run_count + $2,
+			next_run_at = CASE WHEN status = 'cancelled' THEN NULL ELSE $3::timestamptz END,
+			status = CASE WHEN $3::timestamptz IS NULL AND status <> 'cancelled' THEN 'completed' ELSE status END,
+			locked_until = NULL
+		WHERE id = $1`,
+		id, runs, nextRunAt,
+	)
+	return err
+}
+
+const scheduledTransferExecutionColumns = `id, schedule_id, scheduled_for, status, skipped_runs, transfer_id,
+	COALESCE(error, ''), started_at, finished_at`
+
+func scanScheduledTransferExecution(row rowScanner) (*models.ScheduledTransferExecution, error) {
+	execution := &models.ScheduledTransferExecution{}
+	if err := row.Scan(
+		&execution.ID, &execution.ScheduleID, &execution.ScheduledFor, &execution.Status,
+		&execution.SkippedRuns, &execution.TransferID, &execution.Error, &execution.StartedAt,
+		&execution.FinishedAt,
+	); err != nil {
+		return nil, err
+	}
+	return execution, nil
+}
+
+func (r *postgresScheduledTransferRepository) StartExecution(scheduleID int64, scheduledFor time.Time) (*models.ScheduledTransferExecution, error) {
+	execution, err := scanScheduledTransferExecution(r.DB.QueryRow(`
+		INSERT INTO scheduled_transfer_executions (schedule_id, scheduled_for, status)
+		VALUES ($1, $2, 'running')
+		RETURNING `+scheduledTransferExecutionColumns,
+		scheduleID, scheduledFor,
+	))
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "scheduled_transfer_executions_schedule_id_scheduled_for_key" {
+		return nil, repository.ErrExecutionExists
+	}
+	return execution, err
+}
+
+func (r *postgresScheduledTransferRepository) FinishExecution(id int64, status models.ScheduledTransferExecutionStatus, transferID *int64, errorMessage string) error {
+	_, err := r.DB.Exec(`
+		UPDATE scheduled_transfer_executions
+		SET status = $2, transfer_id = $3, error = $4, finished_at = NOW()
+		WHERE id = $1`,
+		id, status, transferID, nullableString(errorMessage),
+	)
+	return err
+}
+
+func (r *postgresScheduledTransferRepository) RecordSkipped(scheduleID int64, scheduledFor time.Time, skippedRuns int) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO scheduled_transfer_executions (schedule_id, scheduled_for, status, skipped_runs, finished_at)
+		VALUES ($1, $2, 'skipped', $3, NOW())
+		ON CONFLICT (schedule_id, scheduled_for) DO NOTHING`,
+		scheduleID, scheduledFor, skippedRuns,
+	)
+	return err
+}
+
+func (r *postgresScheduledTransferRepository) FindExecutions(scheduleID, beforeID int64, limit int) ([]*models.ScheduledTransferExecution, error) {
+	query := "SELECT " + scheduledTransferExecutionColumns + " FROM scheduled_transfer_executions WHERE schedule_id = $1"
+	args := []interface{}{scheduleID}
+	if beforeID > 0 {
+		args = append(args, beforeID)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var executions []*models.ScheduledTransferExecution
+	for rows.Next() {
+		execution, err := scanScheduledTransferExecution(rows)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, execution)
+	}
+	return executions, rows.Err()
+}
+
+// RecoverRunning settles runs a crashed scheduler left running. A run whose transfer was
+// recorded after the run started is linked to it and takes its outcome; every other run
+// failed before its transfer was made, and is not made again.
+func (r *postgresScheduledTransferRepository) RecoverRunning(staleBefore time.Time) (recovered int64, err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.Query(`
+		SELECT e.id, (
+			SELECT tr.id FROM transfers tr
+			WHERE tr.sender_wallet_id = s.sender_wallet_id AND tr.receiver_wallet_id = s.receiver_wallet_id
+				AND tr.amount = s.amount AND tr.created_at >= e.started_at
+				AND NOT EXISTS (SELECT 1 FROM scheduled_transfer_executions o WHERE o.transfer_id = tr.id)
+			ORDER BY tr.id
+			LIMIT 1
+		)
+		FROM scheduled_transfer_executions e
+		JOIN scheduled_transfers s ON s.id = e.schedule_id
+		WHERE e.status = 'running' AND e.started_at < $1
+		ORDER BY e.id
+		FOR UPDATE OF e`,
+		staleBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type staleRun struct {
+		id         int64
+		transferID sql.NullInt64
+	}
+	var stale []staleRun
+	for rows.Next() {
+		var run staleRun
+		if err = rows.Scan(&run.id, &run.transferID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		stale = append(stale, run)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, run := range stale {
+		if run.transferID.Valid {
+			_, err = tx.Exec(`
+				UPDATE scheduled_transfer_executions e
+				SET transfer_id = tr.id,
+					status = CASE WHEN tr.status = 'completed' THEN 'succeeded' ELSE 'failed' END,
+					error = tr.failure_reason, finished_at = NOW()
+				FROM transfers tr
+				WHERE e.id = $1 AND tr.id = $2`,
+				run.id, run.transferID.Int64,
+			)
+		} else {
+			_, err = tx.Exec(`
+				UPDATE scheduled_transfer_executions
+				SET status = 'failed', error = 'interrupted before the transfer was made', finished_at = NOW()
+				WHERE id = $1`,
+				run.id,
+			)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(stale)), nil
+}
@@ -0,0 +1,158 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+type postgresSessionRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresSessionRepository(db *sql.DB) repository.SessionRepository {
+	return &postgresSessionRepository{DB: db}
+}
+
+const selectSession = `
+	SELECT id, user_id, user_agent, ip_address, created_at, last_used_at, expires_at, revoked_at, COALESCE(revoked_reason, '')
+	FROM sessions`
+
+func scanSession(row interface{ Scan(...interface{}) error }) (*models.Session, error) {
+	session := &models.Session{}
+	if err := row.Scan(
+		&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress, &session.CreatedAt,
+		&session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt, &session.RevokedReason,
+	); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *postgresSessionRepository) Create(session *models.Session, refreshTokenHash string) error {
+	return r.DB.QueryRow(`
+		INSERT INTO sessions (id, user_id, refresh_token_hash, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, last_used_at`,
+		session.ID, session.UserID, refreshTokenHash, session.UserAgent, session.IPAddress, session.ExpiresAt,
+	).Scan(&session.CreatedAt, &session.LastUsedAt)
+}
+
+func (r *postgresSessionRepository) FindByID(id string) (*models.Session, error) {
+	return scanSession(r.DB.QueryRow(selectSession+" WHERE id = $1", id))
+}
+
+func (r *postgresSessionRepository) FindByRefreshToken(tokenHash string) (*models.Session, bool, error) {
+	session, err := scanSession(r.DB.QueryRow(selectSession+" WHERE refresh_token_hash = $1", tokenHash))
+	if err == nil {
+		return session, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	session, err = scanSession(r.DB.QueryRow(
+		selectSession+" WHERE id = (SELECT session_id FROM session_refresh_tokens WHERE token_hash = $1)",
+		tokenHash,
+	))
+	if err != nil {
+		return nil, false, err
+	}
+	return session, false, nil
+}
+
+func (r *postgresSessionRepository) RotateRefreshToken(sessionID, oldHash, newHash string, expiresAt time.Time, userAgent, ipAddress string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the session so two refreshes with the same token cannot both succeed
+	var currentHash string
+	if err = tx.QueryRow(
+		"SELECT refresh_token_hash FROM sessions WHERE id = $1 AND revoked_at IS NULL FOR UPDATE",
+		sessionID,
+	).Scan(&currentHash); err != nil {
+		if err == sql.ErrNoRows {
+			err = repository.ErrRefreshTokenNotCurrent
+		}
+		return err
+	}
+	if currentHash != oldHash {
+		err = repository.ErrRefreshTokenNotCurrent
+		return err
+	}
+
+	if _, err = tx.Exec(
+		"INSERT INTO session_refresh_tokens (token_hash, session_id) VALUES ($1, $2)",
+		oldHash, sessionID,
+	); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`
+		UPDATE sessions
+		SET refresh_token_hash = $1, expires_at = $2, user_agent = $3, ip_address = $4, last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $5`,
+		newHash, expiresAt, userAgent, ipAddress, sessionID,
+	); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *postgresSessionRepository) FindActiveByUser(userID int) ([]*models.Session, error) {
+	rows, err := r.DB.Query(
+		selectSession+" WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP ORDER BY last_used_at DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *postgresSessionRepository) Revoke(sessionID, reason string) error {
+	_, err := r.DB.Exec(
+		"UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoked_reason = $1 WHERE id = $2 AND revoked_at IS NULL",
+		reason, sessionID,
+	)
+	return err
+}
+
+func (r *postgresSessionRepository) RevokeAllForUser(userID int, exceptSessionID, reason string) (int64, error) {
+	res, err := r.DB.Exec(`
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP, revoked_reason = $1
+		WHERE user_id = $2 AND id <> $3 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP`,
+		reason, userID, exceptSessionID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *postgresSessionRepository) DeleteExpired() (int64, error) {
+	res, err := r.DB.Exec("DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -2,7 +2,11 @@ package postgres
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"verve/internal/models"
 	"verve/internal/repository"
 )
@@ -26,65 +30,257 @@ func (r *postgresTransactionRepository) TransferCoins(senderWalletID, receiverWa
 		}
 	}()
 
-	var senderBalance int64
-	if err = tx.QueryRow("SELECT balance FROM wallets WHERE id = $1 FOR UPDATE", senderWalletID).Scan(&senderBalance); err != nil {
+	transaction, entries, err := transferCoins(tx, senderWalletID, receiverWalletID, amount, nil)
+	if err != nil {
 		return nil, nil, err
 	}
-	if senderBalance < amount {
-		return nil, nil, errors.New("insufficient funds")
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return transaction, entries, nil
+}
+
+func (r *postgresTransactionRepository) ExecuteTransfer(transfer *models.Transfer) (*models.Transaction, []*models.LedgerEntry, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, nil, err
 	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
 
-	if _, err = tx.Exec("UPDATE wallets SET balance = balance - $1 WHERE id = $2", amount, senderWalletID); err != nil {
+	// Lock the transfer so it can only ever be executed once
+	var status models.TransferStatus
+	if err = tx.QueryRow("SELECT status FROM transfers WHERE id = $1 FOR UPDATE", transfer.ID).Scan(&status); err != nil {
 		return nil, nil, err
 	}
-	if _, err = tx.Exec("UPDATE wallets SET balance = balance + $1 WHERE id = $2", amount, receiverWalletID); err != nil {
+	if status != models.TransferStatusPending {
+		err = errors.New("transfer is not pending")
 		return nil, nil, err
 	}
 
-	var transactionID int64
-	if err = tx.QueryRow(
-		"INSERT INTO transactions (sender_wallet_id, receiver_wallet_id, amount) VALUES ($1, $2, $3) RETURNING id, created_at",
-		senderWalletID, receiverWalletID, amount,
-	).Scan(&transactionID, new(string)); err != nil {
+	transaction, entries, err := transferCoins(tx, transfer.SenderWalletID, transfer.ReceiverWalletID, transfer.Amount, transfer)
+	if err != nil {
 		return nil, nil, err
 	}
 
-	// Ledger entries
-	debitEntry := &models.LedgerEntry{
-		TransactionID: transactionID,
-		WalletID:      senderWalletID,
-		EntryType:     "debit",
-		Amount:        amount,
+	if err = tx.QueryRow(
+		"UPDATE transfers SET status = $1 WHERE id = $2 RETURNING updated_at",
+		models.TransferStatusCompleted, transfer.ID,
+	).Scan(&transfer.UpdatedAt); err != nil {
+		return nil, nil, err
 	}
-	creditEntry := &models.LedgerEntry{
-		TransactionID: transactionID,
-		WalletID:      receiverWalletID,
-		EntryType:     "credit",
-		Amount:        amount,
+	if transfer.PaymentRequestID != nil {
+		if err = markPaymentRequestPaid(tx, *transfer.PaymentRequestID, transfer.ID); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	if _, err = tx.Exec(
-		"INSERT INTO ledger_entries (transaction_id, wallet_id, entry_type, amount) VALUES ($1, $2, $3, $4)",
-		debitEntry.TransactionID, debitEntry.WalletID, debitEntry.EntryType, debitEntry.Amount,
-	); err != nil {
+	if err = writeEvent(tx, models.EventTypeTransferCompleted, "transfer", strconv.FormatInt(transfer.ID, 10), models.TransferCompletedEvent{
+		TransferID:       transfer.ID,
+		TransactionID:    transaction.ID,
+		SenderWalletID:   transfer.SenderWalletID,
+		ReceiverWalletID: transfer.ReceiverWalletID,
+		Amount:           transfer.Amount,
+		IsAnonymous:      transfer.IsAnonymous,
+		CompletedAt:      transfer.UpdatedAt,
+	}); err != nil {
 		return nil, nil, err
 	}
-	if _, err = tx.Exec(
-		"INSERT INTO ledger_entries (transaction_id, wallet_id, entry_type, amount) VALUES ($1, $2, $3, $4)",
-		creditEntry.TransactionID, creditEntry.WalletID, creditEntry.EntryType, creditEntry.Amount,
-	); err != nil {
+
+	if err = tx.Commit(); err != nil {
 		return nil, nil, err
 	}
 
-	if err = tx.Commit(); err != nil {
+	transfer.Status = models.TransferStatusCompleted
+	transfer.TransactionID = &transaction.ID
+	return transaction, entries, nil
+}
+
+func (r *postgresTransactionRepository) FindWalletStatement(walletID int64, filter models.TransactionHistoryFilter) ([]*models.StatementEntry, error) {
+	// The running balance is computed over the whole ledger of the wallet before any
+	// filter applies. Entries without a transaction never moved any coins.
+	query := `
+		WITH entries AS (
+			SELECT le.id, le.transaction_id, le.entry_type, le.amount, le.created_at,
+				SUM(CASE WHEN le.entry_type = 'credit' THEN le.amount ELSE -le.amount END)
+					OVER (ORDER BY le.id) AS running_balance
+			FROM ledger_entries le
+			WHERE le.wallet_id = $1 AND le.transaction_id IS NOT NULL
+		), statement AS (
+			SELECT e.id, e.transaction_id, t.transfer_id, e.entry_type, e.amount, e.running_balance,
+				CASE WHEN e.entry_type = 'debit' THEN t.receiver_wallet_id ELSE t.sender_wallet_id END AS counterparty_wallet_id,
+				COALESCE(tr.is_anonymous, FALSE) AS is_anonymous,
+				pr.id AS payment_request_id,
+				e.created_at
+			FROM entries e
+			JOIN transactions t ON t.id = e.transaction_id
+			LEFT JOIN transfers tr ON tr.id = t.transfer_id
+			LEFT JOIN payment_requests pr ON pr.transfer_id = t.transfer_id AND pr.status = 'paid'
+		)
+		SELECT s.id, s.transaction_id, s.transfer_id, s.entry_type, s.amount, s.running_balance,
+			s.counterparty_wallet_id, cw.user_id, s.is_anonymous, s.payment_request_id, s.created_at
+		FROM statement s
+		LEFT JOIN wallets cw ON cw.id = s.counterparty_wallet_id`
+
+	var conditions []string
+	args := []interface{}{walletID}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.BeforeEntryID > 0 {
+		addCondition("s.id < $%d", filter.BeforeEntryID)
+	}
+	if filter.From != nil {
+		addCondition("s.created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("s.created_at < $%d", *filter.To)
+	}
+	switch filter.Direction {
+	case models.DirectionSent:
+		conditions = append(conditions, "s.entry_type = 'debit'")
+	case models.DirectionReceived:
+		conditions = append(conditions, "s.entry_type = 'credit'")
+	}
+	if filter.CounterpartyWalletID != nil {
+		// Anonymous senders must not be discoverable by filtering on them
+		addCondition("s.counterparty_wallet_id = $%d AND NOT (s.is_anonymous AND s.entry_type = 'credit')", *filter.CounterpartyWalletID)
+	}
+	if filter.MinAmount != nil {
+		addCondition("s.amount >= $%d", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		addCondition("s.amount <= $%d", *filter.MaxAmount)
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY s.id DESC LIMIT $%d", len(args))
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.StatementEntry
+	for rows.Next() {
+		entry := &models.StatementEntry{}
+		var entryType string
+		if err := rows.Scan(
+			&entry.EntryID, &entry.TransactionID, &entry.TransferID, &entryType, &entry.Amount,
+			&entry.RunningBalance, &entry.CounterpartyWalletID, &entry.CounterpartyUserID,
+			&entry.IsAnonymous, &entry.PaymentRequestID, &entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entry.Direction = models.DirectionReceived
+		if entryType == "debit" {
+			entry.Direction = models.DirectionSent
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// lockWallets locks both wallets of a transfer in id order and returns their balances by wallet ID.
+// Locking in a fixed order keeps two transfers in opposite directions from deadlocking.
+func lockWallets(tx *sql.Tx, senderWalletID, receiverWalletID int64) (map[int64]int64, error) {
+	rows, err := tx.Query("SELECT id, balance FROM wallets WHERE id IN ($1, $2) ORDER BY id FOR UPDATE", senderWalletID, receiverWalletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make(map[int64]int64, 2)
+	for rows.Next() {
+		var id, balance int64
+		if err := rows.Scan(&id, &balance); err != nil {
+			return nil, err
+		}
+		balances[id] = balance
+	}
+	return balances, rows.Err()
+}
+
+// transferCoins moves coins between two wallets and writes the matching transaction
+// and ledger entries inside the given DB transaction. transfer is nil for coins moved
+// outside of a transfer; the signature of a signed transfer is recorded on its debit entry.
+func transferCoins(tx *sql.Tx, senderWalletID, receiverWalletID, amount int64, transfer *models.Transfer) (*models.Transaction, []*models.LedgerEntry, error) {
+	var transferID *int64
+	var debitExtra string
+	if transfer != nil {
+		transferID = &transfer.ID
+		if transfer.Authorization != nil {
+			authorization, err := json.Marshal(transfer.Authorization)
+			if err != nil {
+				return nil, nil, err
+			}
+			debitExtra = string(authorization)
+		}
+	}
+
+	balances, err := lockWallets(tx, senderWalletID, receiverWalletID)
+	if err != nil {
+		return nil, nil, err
+	}
+	senderBalance, ok := balances[senderWalletID]
+	if !ok {
+		return nil, nil, sql.ErrNoRows
+	}
+	if _, ok := balances[receiverWalletID]; !ok {
+		return nil, nil, sql.ErrNoRows
+	}
+	if senderBalance < amount {
+		return nil, nil, repository.ErrInsufficientFunds
+	}
+
+	if _, err := tx.Exec("UPDATE wallets SET balance = balance - $1 WHERE id = $2", amount, senderWalletID); err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec("UPDATE wallets SET balance = balance + $1 WHERE id = $2", amount, receiverWalletID); err != nil {
 		return nil, nil, err
 	}
 
 	transaction := &models.Transaction{
-		ID:               transactionID,
 		SenderWalletID:   senderWalletID,
 		ReceiverWalletID: receiverWalletID,
 		Amount:           amount,
+		TransferID:       transferID,
+	}
+	if err := tx.QueryRow(
+		"INSERT INTO transactions (sender_wallet_id, receiver_wallet_id, amount, transfer_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		senderWalletID, receiverWalletID, amount, transferID,
+	).Scan(&transaction.ID, &transaction.CreatedAt); err != nil {
+		return nil, nil, err
+	}
+
+	// Ledger entries
+	debitEntry := &models.LedgerEntry{
+		TransactionID: transaction.ID,
+		WalletID:      senderWalletID,
+		EntryType:     "debit",
+		Amount:        amount,
+		Extra:         debitExtra,
+	}
+	creditEntry := &models.LedgerEntry{
+		TransactionID: transaction.ID,
+		WalletID:      receiverWalletID,
+		EntryType:     "credit",
+		Amount:        amount,
+	}
+
+	if err := appendLedgerEntries(tx, debitEntry, creditEntry); err != nil {
+		return nil, nil, err
 	}
 
 	return transaction, []*models.LedgerEntry{debitEntry, creditEntry}, nil
@@ -2,8 +2,11 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
 	"verve/internal/models"
 	"verve/internal/repository"
+
+	"github.com/lib/pq"
 )
 
 type postgresTransferRepository struct {
@@ -16,10 +19,20 @@ func NewPostgresTransferRepository(db *sql.DB) repository.TransferRepository {
 
 func (r *postgresTransferRepository) Create(transfer *models.Transfer) error {
 	query := `
-		INSERT INTO transfers (sender_wallet_id, receiver_wallet_id, amount, status, is_anonymous)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO transfers (sender_wallet_id, receiver_wallet_id, amount, status, is_anonymous,
+			nonce, signature, public_key, signature_expires_at, message, category, visibility)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		RETURNING id, created_at, updated_at`
 
+	var nonce, signature, publicKey sql.NullString
+	var expiresAt sql.NullTime
+	if auth := transfer.Authorization; auth != nil {
+		nonce = sql.NullString{String: auth.Nonce, Valid: true}
+		signature = sql.NullString{String: auth.Signature, Valid: true}
+		publicKey = sql.NullString{String: auth.PublicKey, Valid: true}
+		expiresAt = sql.NullTime{Time: auth.ExpiresAt, Valid: true}
+	}
+
 	err := r.DB.QueryRow(
 		query,
 		transfer.SenderWalletID,
@@ -27,18 +40,34 @@ func (r *postgresTransferRepository) Create(transfer *models.Transfer) error {
 		transfer.Amount,
 		transfer.Status,
 		transfer.IsAnonymous,
+		nonce,
+		signature,
+		publicKey,
+		expiresAt,
+		nullableString(transfer.Message),
+		nullableString(transfer.Category),
+		transfer.Visibility,
 	).Scan(&transfer.ID, &transfer.CreatedAt, &transfer.UpdatedAt)
 
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "idx_transfers_sender_nonce" {
+		return repository.ErrNonceAlreadyUsed
+	}
 	return err
 }
 
 func (r *postgresTransferRepository) FindByID(id int64) (*models.Transfer, error) {
 	transfer := &models.Transfer{}
 	query := `
-		SELECT id, sender_wallet_id, receiver_wallet_id, amount, status, is_anonymous, created_at, updated_at
-		FROM transfers
-		WHERE id = $1`
+		SELECT tr.id, tr.sender_wallet_id, tr.receiver_wallet_id, tr.amount, tr.status, tr.is_anonymous,
+			t.id, COALESCE(tr.failure_reason, ''), tr.nonce, tr.signature, tr.public_key, tr.signature_expires_at,
+			COALESCE(tr.message, ''), COALESCE(tr.category, ''), tr.visibility, tr.created_at, tr.updated_at
+		FROM transfers tr
+		LEFT JOIN transactions t ON t.transfer_id = tr.id
+		WHERE tr.id = $1`
 
+	var nonce, signature, publicKey sql.NullString
+	var expiresAt sql.NullTime
 	err := r.DB.QueryRow(query, id).Scan(
 		&transfer.ID,
 		&transfer.SenderWalletID,
@@ -46,6 +75,15 @@ func (r *postgresTransferRepository) FindByID(id int64) (*models.Transfer, error
 		&transfer.Amount,
 		&transfer.Status,
 		&transfer.IsAnonymous,
+		&transfer.TransactionID,
+		&transfer.FailureReason,
+		&nonce,
+		&signature,
+		&publicKey,
+		&expiresAt,
+		&transfer.Message,
+		&transfer.Category,
+		&transfer.Visibility,
 		&transfer.CreatedAt,
 		&transfer.UpdatedAt,
 	)
@@ -54,6 +92,15 @@ func (r *postgresTransferRepository) FindByID(id int64) (*models.Transfer, error
 		return nil, err
 	}
 
+	if signature.Valid {
+		transfer.Authorization = &models.TransferSignature{
+			Nonce:     nonce.String,
+			ExpiresAt: expiresAt.Time,
+			Signature: signature.String,
+			PublicKey: publicKey.String,
+		}
+	}
+
 	return transfer, nil
 }
 
@@ -62,3 +109,9 @@ func (r *postgresTransferRepository) UpdateStatus(id int64, status models.Transf
 	_, err := r.DB.Exec(query, status, id)
 	return err
 }
+
+func (r *postgresTransferRepository) MarkFailed(id int64, reason string) error {
+	query := "UPDATE transfers SET status = $1, failure_reason = $2 WHERE id = $3 AND status = $4"
+	_, err := r.DB.Exec(query, models.TransferStatusFailed, reason, id, models.TransferStatusPending)
+	return err
+}
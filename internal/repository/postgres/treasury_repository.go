@@ -0,0 +1,287 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+type postgresTreasuryRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresTreasuryRepository(db *sql.DB) repository.TreasuryRepository {
+	return &postgresTreasuryRepository{DB: db}
+}
+
+const selectGrantCycle = `
+	SELECT c.id, c.treasury_wallet_id, c.amount_per_user, c.minted_amount, c.status,
+		COALESCE(c.failure_reason, ''), c.started_by, c.created_at, c.completed_at,
+		COUNT(p.id),
+		COUNT(p.id) FILTER (WHERE p.status = 'pending'),
+		COUNT(p.id) FILTER (WHERE p.status = 'completed'),
+		COUNT(p.id) FILTER (WHERE p.status = 'failed')
+	FROM treasury_grant_cycles c
+	LEFT JOIN treasury_grant_payouts p ON p.cycle_id = c.id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanGrantCycle(row rowScanner) (*models.GrantCycle, error) {
+	cycle := &models.GrantCycle{}
+	err := row.Scan(
+		&cycle.ID, &cycle.TreasuryWalletID, &cycle.AmountPerUser, &cycle.MintedAmount, &cycle.Status,
+		&cycle.FailureReason, &cycle.StartedBy, &cycle.CreatedAt, &cycle.CompletedAt,
+		&cycle.Progress.Total, &cycle.Progress.Pending, &cycle.Progress.Completed,
+		&cycle.Progress.Failed,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return cycle, nil
+}
+
+func (r *postgresTreasuryRepository) queryGrantCycles(query string, args ...interface{}) ([]*models.GrantCycle, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cycles []*models.GrantCycle
+	for rows.Next() {
+		cycle, err := scanGrantCycle(rows)
+		if err != nil {
+			return nil, err
+		}
+		cycles = append(cycles, cycle)
+	}
+	return cycles, rows.Err()
+}
+
+func (r *postgresTreasuryRepository) Mint(walletID, amount int64) (*models.Transaction, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	res, err := tx.Exec("UPDATE wallets SET balance = balance + $1 WHERE id = $2", amount, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		err = errors.New("wallet not found")
+		return nil, err
+	}
+
+	transaction := &models.Transaction{ReceiverWalletID: walletID, Amount: amount}
+	if err = tx.QueryRow(
+		"INSERT INTO transactions (sender_wallet_id, receiver_wallet_id, amount) VALUES (NULL, $1, $2) RETURNING id, created_at",
+		walletID, amount,
+	).Scan(&transaction.ID, &transaction.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if err = appendLedgerEntries(tx, &models.LedgerEntry{
+		TransactionID: transaction.ID,
+		WalletID:      walletID,
+		EntryType:     "credit",
+		Amount:        amount,
+		Extra:         "mint",
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+func (r *postgresTreasuryRepository) CreateCycle(cycle *models.GrantCycle, excludeUserID int, currency string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	cycle.Status = models.GrantCycleStatusRunning
+	if err = tx.QueryRow(`
+		INSERT INTO treasury_grant_cycles (treasury_wallet_id, amount_per_user, status, started_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		cycle.TreasuryWalletID, cycle.AmountPerUser, cycle.Status, cycle.StartedBy,
+	).Scan(&cycle.ID, &cycle.CreatedAt); err != nil {
+		return err
+	}
+
+	// Every user holding a regular wallet in the treasury currency is a recipient;
+	// users with several such wallets are paid into their oldest one.
+	res, err := tx.Exec(`
+		INSERT INTO treasury_grant_payouts (cycle_id, user_id, wallet_id, amount)
+		SELECT $1, w.user_id, MIN(w.id), $2
+		FROM wallets w
+		WHERE w.user_id IS NOT NULL AND w.user_id <> $3
+			AND w.currency = $4 AND NOT w.is_pseudonymous
+		GROUP BY w.user_id`,
+		cycle.ID, cycle.AmountPerUser, excludeUserID, currency,
+	)
+	if err != nil {
+		return err
+	}
+	total, _ := res.RowsAffected()
+	cycle.Progress = models.GrantProgress{Total: int(total), Pending: int(total)}
+
+	return tx.Commit()
+}
+
+func (r *postgresTreasuryRepository) FindCycleByID(id int64) (*models.GrantCycle, error) {
+	return scanGrantCycle(r.DB.QueryRow(selectGrantCycle+" WHERE c.id = $1 GROUP BY c.id", id))
+}
+
+func (r *postgresTreasuryRepository) FindCycles(limit int) ([]*models.GrantCycle, error) {
+	return r.queryGrantCycles(selectGrantCycle+" GROUP BY c.id ORDER BY c.id DESC LIMIT $1", limit)
+}
+
+func (r *postgresTreasuryRepository) FindRunningCycles() ([]*models.GrantCycle, error) {
+	return r.queryGrantCycles(selectGrantCycle + " WHERE c.status = 'running' GROUP BY c.id ORDER BY c.id")
+}
+
+func (r *postgresTreasuryRepository) AddMintedAmount(cycleID, amount int64) error {
+	_, err := r.DB.Exec("UPDATE treasury_grant_cycles SET minted_amount = minted_amount + $1 WHERE id = $2", amount, cycleID)
+	return err
+}
+
+func (r *postgresTreasuryRepository) UpdateCycleStatus(id int64, status models.GrantCycleStatus, failureReason string) error {
+	_, err := r.DB.Exec(`
+		UPDATE treasury_grant_cycles
+		SET status = $1, failure_reason = NULLIF($2, ''),
+			completed_at = CASE WHEN $1 = 'running' THEN NULL ELSE CURRENT_TIMESTAMP END
+		WHERE id = $3`,
+		status, failureReason, id,
+	)
+	return err
+}
+
+func (r *postgresTreasuryRepository) FindPayouts(cycleID int64) ([]*models.GrantPayout, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, cycle_id, user_id, wallet_id, amount, status, transaction_id,
+			COALESCE(failure_reason, ''), created_at, updated_at
+		FROM treasury_grant_payouts
+		WHERE cycle_id = $1
+		ORDER BY id`,
+		cycleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payouts []*models.GrantPayout
+	for rows.Next() {
+		p := &models.GrantPayout{}
+		if err := rows.Scan(
+			&p.ID, &p.CycleID, &p.UserID, &p.WalletID, &p.Amount, &p.Status, &p.TransactionID,
+			&p.FailureReason, &p.CreatedAt, &p.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		payouts = append(payouts, p)
+	}
+	return payouts, rows.Err()
+}
+
+// PayNextPayout locks the next pending payout, so concurrent cycles skip it, and pays it in
+// the same transaction that completes it: a payout is either paid and completed or neither.
+func (r *postgresTreasuryRepository) PayNextPayout(cycleID, treasuryWalletID int64) (payout *models.GrantPayout, err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	p := &models.GrantPayout{}
+	err = tx.QueryRow(`
+		SELECT id, cycle_id, user_id, wallet_id, amount, status, transaction_id,
+			COALESCE(failure_reason, ''), created_at, updated_at
+		FROM treasury_grant_payouts
+		WHERE cycle_id = $1 AND status = 'pending'
+		ORDER BY id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`,
+		cycleID,
+	).Scan(
+		&p.ID, &p.CycleID, &p.UserID, &p.WalletID, &p.Amount, &p.Status, &p.TransactionID,
+		&p.FailureReason, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, _, err := transferCoins(tx, treasuryWalletID, p.WalletID, p.Amount, nil)
+	if err != nil {
+		return p, err
+	}
+
+	if err = tx.QueryRow(
+		"UPDATE treasury_grant_payouts SET status = 'completed', transaction_id = $1 WHERE id = $2 RETURNING updated_at",
+		transaction.ID, p.ID,
+	).Scan(&p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err = writeGrantPaidEvents(tx, []models.GrantPaidEvent{{
+		PayoutID:      p.ID,
+		CycleID:       p.CycleID,
+		UserID:        p.UserID,
+		WalletID:      p.WalletID,
+		Amount:        p.Amount,
+		TransactionID: transaction.ID,
+	}}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	p.Status = models.GrantPayoutStatusCompleted
+	p.TransactionID = &transaction.ID
+	return p, nil
+}
+
+// writeGrantPaidEvents adds a grant.paid event for each completed payout to the outbox
+func writeGrantPaidEvents(tx *sql.Tx, events []models.GrantPaidEvent) error {
+	for _, event := range events {
+		if err := writeEvent(tx, models.EventTypeGrantPaid, "grant_payout", strconv.FormatInt(event.PayoutID, 10), event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *postgresTreasuryRepository) FailPayout(id int64, reason string) error {
+	_, err := r.DB.Exec(
+		"UPDATE treasury_grant_payouts SET status = 'failed', failure_reason = $1 WHERE id = $2 AND status = 'pending'",
+		reason, id,
+	)
+	return err
+}
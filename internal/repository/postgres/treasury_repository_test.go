@@ -0,0 +1,228 @@
+package postgres_test
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+	"verve/internal/repository/postgres"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDB migrates a schema of its own in the database at TEST_DATABASE_DSN, a postgres://
+// URL, and drops it when the test ends. Tests are skipped when no database is given.
+func openTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer admin.Close()
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		admin, err := sql.Open("postgres", dsn)
+		if err == nil {
+			admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+			admin.Close()
+		}
+	})
+
+	// Every connection of the pool works in the test schema
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	db, err := sql.Open("postgres", u.String())
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := filepath.Glob("../../../migrations/*.up.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for _, migration := range migrations {
+		script, err := os.ReadFile(migration)
+		require.NoError(t, err)
+		_, err = db.Exec(string(script))
+		require.NoError(t, err, migration)
+	}
+	return db
+}
+
+func createUser(t *testing.T, db *sql.DB, username string) int {
+	var id int
+	require.NoError(t, db.QueryRow(
+		"INSERT INTO users (username, password_hash) VALUES ($1, 'x') RETURNING id", username,
+	).Scan(&id))
+	return id
+}
+
+func createWallet(t *testing.T, db *sql.DB, userID *int, currency string, balance int64, pseudonymous bool) int64 {
+	var id int64
+	require.NoError(t, db.QueryRow(
+		"INSERT INTO wallets (user_id, currency, balance, is_pseudonymous) VALUES ($1, $2, $3, $4) RETURNING id",
+		userID, currency, balance, pseudonymous,
+	).Scan(&id))
+	return id
+}
+
+// seededTreasury returns the treasury user and wallet seeded by the migrations, with the given balance
+func seededTreasury(t *testing.T, db *sql.DB, balance int64) (int, int64) {
+	var userID int
+	var walletID int64
+	require.NoError(t, db.QueryRow(`
+		UPDATE wallets w SET balance = $1
+		FROM users u
+		WHERE u.id = w.user_id AND u.username = 'treasury@system.local'
+		RETURNING u.id, w.id`,
+		balance,
+	).Scan(&userID, &walletID))
+	return userID, walletID
+}
+
+func walletBalance(t *testing.T, db *sql.DB, walletID int64) int64 {
+	var balance int64
+	require.NoError(t, db.QueryRow("SELECT balance FROM wallets WHERE id = $1", walletID).Scan(&balance))
+	return balance
+}
+
+func TestTreasuryCreateCycle(t *testing.T) {
+	db := openTestDB(t)
+	repo := postgres.NewPostgresTreasuryRepository(db)
+
+	treasury, treasuryWallet := seededTreasury(t, db, 0)
+	alice := createUser(t, db, "alice")
+	bob := createUser(t, db, "bob")
+	carol := createUser(t, db, "carol")
+	aliceWallet := createWallet(t, db, &alice, "USD", 0, false)
+	createWallet(t, db, &alice, "USD", 0, false)
+	createWallet(t, db, &bob, "EUR", 0, false)
+	carolWallet := createWallet(t, db, &carol, "USD", 0, false)
+	createWallet(t, db, nil, "USD", 0, true)
+
+	cycle := &models.GrantCycle{TreasuryWalletID: treasuryWallet, AmountPerUser: 100, StartedBy: &alice}
+	require.NoError(t, repo.CreateCycle(cycle, treasury, "USD"))
+	assert.Equal(t, models.GrantCycleStatusRunning, cycle.Status)
+	assert.Equal(t, models.GrantProgress{Total: 2, Pending: 2}, cycle.Progress)
+
+	// The treasury, wallets in other currencies and pseudonymous wallets are left out,
+	// and users with several wallets are paid into their oldest one
+	payouts, err := repo.FindPayouts(cycle.ID)
+	require.NoError(t, err)
+	require.Len(t, payouts, 2)
+	assert.Equal(t, alice, payouts[0].UserID)
+	assert.Equal(t, aliceWallet, payouts[0].WalletID)
+	assert.Equal(t, carol, payouts[1].UserID)
+	assert.Equal(t, carolWallet, payouts[1].WalletID)
+	for _, payout := range payouts {
+		assert.Equal(t, int64(100), payout.Amount)
+		assert.Equal(t, models.GrantPayoutStatusPending, payout.Status)
+	}
+
+	// Only one cycle runs at a time
+	assert.Error(t, repo.CreateCycle(&models.GrantCycle{TreasuryWalletID: treasuryWallet, AmountPerUser: 100}, treasury, "USD"))
+	stored, err := repo.FindCycleByID(cycle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, cycle.Progress, stored.Progress)
+}
+
+func TestTreasuryPayNextPayout(t *testing.T) {
+	db := openTestDB(t)
+	repo := postgres.NewPostgresTreasuryRepository(db)
+
+	treasury, treasuryWallet := seededTreasury(t, db, 150)
+	alice := createUser(t, db, "alice")
+	bob := createUser(t, db, "bob")
+	aliceWallet := createWallet(t, db, &alice, "USD", 0, false)
+	bobWallet := createWallet(t, db, &bob, "USD", 0, false)
+
+	cycle := &models.GrantCycle{TreasuryWalletID: treasuryWallet, AmountPerUser: 100}
+	require.NoError(t, repo.CreateCycle(cycle, treasury, "USD"))
+
+	t.Run("a payout is paid and completed together", func(t *testing.T) {
+		payout, err := repo.PayNextPayout(cycle.ID, treasuryWallet)
+		require.NoError(t, err)
+		require.NotNil(t, payout)
+		assert.Equal(t, aliceWallet, payout.WalletID)
+		assert.Equal(t, models.GrantPayoutStatusCompleted, payout.Status)
+		require.NotNil(t, payout.TransactionID)
+
+		assert.Equal(t, int64(100), walletBalance(t, db, aliceWallet))
+		assert.Equal(t, int64(50), walletBalance(t, db, treasuryWallet))
+	})
+
+	t.Run("a payout the treasury cannot afford stays pending", func(t *testing.T) {
+		payout, err := repo.PayNextPayout(cycle.ID, treasuryWallet)
+		assert.ErrorIs(t, err, repository.ErrInsufficientFunds)
+		require.NotNil(t, payout)
+		assert.Equal(t, bobWallet, payout.WalletID)
+
+		payouts, err := repo.FindPayouts(cycle.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.GrantPayoutStatusPending, payouts[1].Status)
+		assert.Nil(t, payouts[1].TransactionID)
+		assert.Zero(t, walletBalance(t, db, bobWallet))
+		assert.Equal(t, int64(50), walletBalance(t, db, treasuryWallet))
+
+		require.NoError(t, repo.FailPayout(payout.ID, err.Error()))
+	})
+
+	t.Run("nothing is returned once no payout is pending", func(t *testing.T) {
+		payout, err := repo.PayNextPayout(cycle.ID, treasuryWallet)
+		require.NoError(t, err)
+		assert.Nil(t, payout)
+	})
+}
+
+func TestTreasuryConcurrentPayouts(t *testing.T) {
+	db := openTestDB(t)
+	repo := postgres.NewPostgresTreasuryRepository(db)
+
+	treasury, treasuryWallet := seededTreasury(t, db, 2000)
+	wallets := map[int64]bool{}
+	for i := 0; i < 20; i++ {
+		user := createUser(t, db, fmt.Sprintf("user%d", i))
+		wallets[createWallet(t, db, &user, "USD", 0, false)] = true
+	}
+
+	cycle := &models.GrantCycle{TreasuryWalletID: treasuryWallet, AmountPerUser: 100}
+	require.NoError(t, repo.CreateCycle(cycle, treasury, "USD"))
+
+	// Servers running the same cycle never pay a payout twice
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				payout, err := repo.PayNextPayout(cycle.ID, treasuryWallet)
+				if err != nil || payout == nil {
+					assert.NoError(t, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for walletID := range wallets {
+		assert.Equal(t, int64(100), walletBalance(t, db, walletID), "wallet %d", walletID)
+	}
+	assert.Zero(t, walletBalance(t, db, treasuryWallet))
+	stored, err := repo.FindCycleByID(cycle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.GrantProgress{Total: 20, Completed: 20}, stored.Progress)
+}
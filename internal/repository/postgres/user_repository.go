@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"database/sql"
+	"strconv"
 	"verve/internal/models"
 	"verve/internal/repository"
 )
@@ -14,9 +15,18 @@ func NewPostgresUserRepository(db *sql.DB) repository.UserRepository {
 	return &postgresUserRepository{DB: db}
 }
 
-func (r *postgresUserRepository) Create(user *models.User, passwordHash, pinHash string) (int, error) {
-	var userID int
-	err := r.DB.QueryRow(`
+func (r *postgresUserRepository) Create(user *models.User, passwordHash, pinHash string) (userID int, err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = tx.QueryRow(`
 		INSERT INTO users (username, email, password_hash, pin_hash, display_name, profile_photo_url, 
 			provider, provider_user_id, pin_required_for_transfer) 
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) 
@@ -24,6 +34,19 @@ func (r *postgresUserRepository) Create(user *models.User, passwordHash, pinHash
 		user.Username, user.Email, passwordHash, pinHash, user.DisplayName,
 		user.ProfilePhotoURL, user.Provider, user.ProviderUserID,
 		user.PinRequiredForTransfer).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = writeEvent(tx, models.EventTypeUserCreated, "user", strconv.Itoa(userID), models.UserCreatedEvent{
+		UserID:   userID,
+		Username: user.Username,
+		Provider: user.Provider,
+	}); err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
 	return userID, err
 }
 
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"database/sql"
+	"strconv"
 	"verve/internal/models"
 	"verve/internal/repository"
 )
@@ -14,15 +15,38 @@ func NewPostgresWalletRepository(db *sql.DB) repository.WalletRepository {
 	return &postgresWalletRepository{DB: db}
 }
 
-func (r *postgresWalletRepository) Create(wallet *models.Wallet) error {
-	return r.DB.QueryRow(
+func (r *postgresWalletRepository) Create(wallet *models.Wallet) (err error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = tx.QueryRow(
 		"INSERT INTO wallets (user_id, currency, balance) VALUES ($1, $2, $3) RETURNING id, created_at",
 		wallet.UserID, wallet.Currency, wallet.Balance,
-	).Scan(&wallet.ID, &wallet.CreatedAt)
+	).Scan(&wallet.ID, &wallet.CreatedAt); err != nil {
+		return err
+	}
+
+	if err = writeEvent(tx, models.EventTypeWalletCreated, "wallet", strconv.FormatInt(wallet.ID, 10), models.WalletCreatedEvent{
+		WalletID: wallet.ID,
+		UserID:   wallet.UserID,
+		Currency: wallet.Currency,
+	}); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
 }
 
 func (r *postgresWalletRepository) FindByUserID(userID int) ([]models.Wallet, error) {
-	rows, err := r.DB.Query("SELECT id, user_id, balance, currency, created_at FROM wallets WHERE user_id = $1", userID)
+	rows, err := r.DB.Query("SELECT id, COALESCE(user_id, 0), balance, currency, created_at FROM wallets WHERE user_id = $1", userID)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +66,7 @@ func (r *postgresWalletRepository) FindByUserID(userID int) ([]models.Wallet, er
 func (r *postgresWalletRepository) FindByID(id int64) (*models.Wallet, error) {
 	var wallet models.Wallet
 	err := r.DB.QueryRow(
-		"SELECT id, user_id, balance, currency, created_at FROM wallets WHERE id = $1",
+		"SELECT id, COALESCE(user_id, 0), balance, currency, created_at FROM wallets WHERE id = $1",
 		id,
 	).Scan(&wallet.ID, &wallet.UserID, &wallet.Balance, &wallet.Currency, &wallet.CreatedAt)
 
@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+type postgresWebhookRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresWebhookRepository(db *sql.DB) repository.WebhookRepository {
+	return &postgresWebhookRepository{DB: db}
+}
+
+const webhookEndpointColumns = `id, url, COALESCE(description, ''), event_types, secret, is_active,
+	COALESCE(created_by, 0), created_at, updated_at`
+
+func (r *postgresWebhookRepository) CreateEndpoint(endpoint *models.WebhookEndpoint) error {
+	return r.DB.QueryRow(`
+		INSERT INTO webhook_endpoints (url, description, event_types, secret, is_active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at`,
+		endpoint.URL, nullableString(endpoint.Description), pq.Array(endpoint.EventTypes), endpoint.Secret,
+		endpoint.IsActive, nullableInt(endpoint.CreatedBy),
+	).Scan(&endpoint.ID, &endpoint.CreatedAt, &endpoint.UpdatedAt)
+}
+
+func (r *postgresWebhookRepository) FindEndpointByID(id int) (*models.WebhookEndpoint, error) {
+	endpoints, err := r.findEndpoints("SELECT "+webhookEndpointColumns+" FROM webhook_endpoints WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return endpoints[0], nil
+}
+
+func (r *postgresWebhookRepository) FindEndpoints() ([]*models.WebhookEndpoint, error) {
+	return r.findEndpoints("SELECT " + webhookEndpointColumns + " FROM webhook_endpoints ORDER BY id")
+}
+
+func (r *postgresWebhookRepository) FindSubscribedEndpoints(eventType string) ([]*models.WebhookEndpoint, error) {
+	return r.findEndpoints(`
+		SELECT `+webhookEndpointColumns+` FROM webhook_endpoints
+		WHERE is_active AND $1 = ANY(event_types)
+		ORDER BY id`,
+		eventType,
+	)
+}
+
+func (r *postgresWebhookRepository) UpdateEndpoint(endpoint *models.WebhookEndpoint) error {
+	return r.DB.QueryRow(`
+		UPDATE webhook_endpoints
+		SET url = $2, description = $3, event_types = $4, is_active = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING updated_at`,
+		endpoint.ID, endpoint.URL, nullableString(endpoint.Description), pq.Array(endpoint.EventTypes), endpoint.IsActive,
+	).Scan(&endpoint.UpdatedAt)
+}
+
+func (r *postgresWebhookRepository) DeleteEndpoint(id int) error {
+	result, err := r.DB.Exec("DELETE FROM webhook_endpoints WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+func (r *postgresWebhookRepository) findEndpoints(query string, args ...interface{}) ([]*models.WebhookEndpoint, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []*models.WebhookEndpoint
+	for rows.Next() {
+		endpoint := &models.WebhookEndpoint{}
+		if err := rows.Scan(&endpoint.ID, &endpoint.URL, &endpoint.Description, pq.Array(&endpoint.EventTypes),
+			&endpoint.Secret, &endpoint.IsActive, &endpoint.CreatedBy, &endpoint.CreatedAt, &endpoint.UpdatedAt); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+func (r *postgresWebhookRepository) EnqueueDeliveries(eventID int64, eventType string, body []byte, endpointIDs []int) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, body)
+		SELECT endpoint_id, $1, $2, $3 FROM unnest($4::integer[]) AS endpoint_id
+		ON CONFLICT (endpoint_id, event_id) WHERE replay_of_id IS NULL DO NOTHING`,
+		eventID, eventType, string(body), pq.Array(endpointIDs),
+	)
+	return err
+}
+
+const webhookDeliveryColumns = `id, endpoint_id, event_id, event_type, body, status, attempts, next_attempt_at,
+	COALESCE(last_status_code, 0), COALESCE(last_error, ''), replay_of_id, created_at, delivered_at`
+
+func (r *postgresWebhookRepository) ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]*models.WebhookDelivery, error) {
+	deliveries, err := r.findDeliveries(`
+		UPDATE webhook_deliveries
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+webhookDeliveryColumns,
+		now, now.Add(lease), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+func (r *postgresWebhookRepository) MarkDeliverySucceeded(deliveryID int64, attempts, statusCode int) error {
+	_, err := r.DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = 'succeeded', attempts = $2, last_status_code = $3, last_error = NULL, delivered_at = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		deliveryID, attempts, statusCode,
+	)
+	return err
+}
+
+func (r *postgresWebhookRepository) RescheduleDelivery(deliveryID int64, attempts, statusCode int, lastError string, nextAttemptAt time.Time) error {
+	_, err := r.DB.Exec(`
+		UPDATE webhook_deliveries
+		SET attempts = $2, last_status_code = $3, last_error = $4, next_attempt_at = $5
+		WHERE id = $1`,
+		deliveryID, attempts, nullableInt(statusCode), lastError, nextAttemptAt,
+	)
+	return err
+}
+
+func (r *postgresWebhookRepository) MarkDeliveryFailed(deliveryID int64, attempts, statusCode int, lastError string) error {
+	_, err := r.DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = 'failed', attempts = $2, last_status_code = $3, last_error = $4
+		WHERE id = $1`,
+		deliveryID, attempts, nullableInt(statusCode), lastError,
+	)
+	return err
+}
+
+func (r *postgresWebhookRepository) FindDeliveryByID(id int64) (*models.WebhookDelivery, error) {
+	deliveries, err := r.findDeliveries("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return deliveries[0], nil
+}
+
+func (r *postgresWebhookRepository) FindDeliveries(filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error) {
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries"
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.EndpointID > 0 {
+		addCondition("endpoint_id = $%d", filter.EndpointID)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	return r.findDeliveries(query, args...)
+}
+
+func (r *postgresWebhookRepository) ReplayDelivery(deliveryID int64) (*models.WebhookDelivery, error) {
+	deliveries, err := r.findDeliveries(`
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, body, replay_of_id)
+		SELECT endpoint_id, event_id, event_type, body, id FROM webhook_deliveries WHERE id = $1
+		RETURNING `+webhookDeliveryColumns,
+		deliveryID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return deliveries[0], nil
+}
+
+func (r *postgresWebhookRepository) findDeliveries(query string, args ...interface{}) ([]*models.WebhookDelivery, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery := &models.WebhookDelivery{}
+		var body []byte
+		if err := rows.Scan(&delivery.ID, &delivery.EndpointID, &delivery.EventID, &delivery.EventType, &body,
+			&delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastStatusCode,
+			&delivery.LastError, &delivery.ReplayOfID, &delivery.CreatedAt, &delivery.DeliveredAt); err != nil {
+			return nil, err
+		}
+		delivery.Body = body
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// nullableInt stores zero, such as the status code of a request that got no response, as NULL
+func nullableInt(value int) interface{} {
+	if value == 0 {
+		return nil
+	}
+	return value
+}
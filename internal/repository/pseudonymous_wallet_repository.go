@@ -1,6 +1,16 @@
 package repository
 
-import "verve/internal/models"
+import (
+	"errors"
+	"verve/internal/models"
+)
+
+var (
+	// ErrPublicKeyInUse is returned when a key is already registered, to this or another wallet
+	ErrPublicKeyInUse = errors.New("public key is already registered")
+	// ErrWalletKeyChanged is returned when the active key changed while it was being rotated or revoked
+	ErrWalletKeyChanged = errors.New("the active key of the wallet has changed")
+)
 
 // PseudonymousWalletRepository abstracts creation and lookup of pseudonymous wallets
 
@@ -8,4 +18,10 @@ type PseudonymousWalletRepository interface {
 	CreatePseudonymousWallet(publicKey string) (*models.PseudonymousWallet, error)
 	FindByID(id int64) (*models.PseudonymousWallet, error)
 	FindByPublicKey(publicKey string) (*models.PseudonymousWallet, error)
+	// RotateKey retires the active key of a wallet in favour of a new key it endorsed
+	RotateKey(walletID int64, oldPublicKey, newPublicKey, rotationSignature string) (*models.PseudonymousWalletKey, error)
+	// RevokeKey revokes the active key of a wallet, leaving the wallet without a key
+	RevokeKey(walletID int64, publicKey, reason string) error
+	// FindKeys lists every key a wallet has had, newest first
+	FindKeys(walletID int64) ([]*models.PseudonymousWalletKey, error)
 }
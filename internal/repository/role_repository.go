@@ -1,9 +1,37 @@
 package repository
 
-import "verve/internal/models"
+import (
+	"errors"
+	"verve/internal/models"
+)
+
+var (
+	ErrRoleExists          = errors.New("role already exists")
+	ErrRoleInUse           = errors.New("role is still assigned to users")
+	ErrRoleAlreadyAssigned = errors.New("user already has the role")
+	ErrLastRoleHolder      = errors.New("user is the last holder of the role")
+)
 
 type RoleRepository interface {
 	FindByName(name string) (*models.Role, error)
+	// AssignToUser assigns a role to a user, or returns ErrRoleAlreadyAssigned
 	AssignToUser(userID, roleID int) error
 	GetForUser(userID int) ([]string, error)
+	// GetPermissions returns the names of the permissions granted to a role
+	GetPermissions(roleName string) ([]string, error)
+	// FindAll returns every role with the permissions it grants
+	FindAll() ([]*models.Role, error)
+	// Create creates a role, or returns ErrRoleExists
+	Create(role *models.Role) error
+	// Delete deletes a role and its permission grants, or returns ErrRoleInUse while users hold it
+	Delete(roleID int) error
+	FindAllPermissions() ([]*models.Permission, error)
+	// GrantPermission grants a permission to a role, or returns sql.ErrNoRows when the
+	// permission does not exist. Granting a permission twice changes nothing.
+	GrantPermission(roleID int, permission string) error
+	RevokePermission(roleID int, permission string) error
+	// RemoveFromUser removes a role from a user, or returns sql.ErrNoRows when the user does
+	// not hold it. With keepLastHolder, the last user holding the role keeps it and
+	// ErrLastRoleHolder is returned.
+	RemoveFromUser(userID, roleID int, keepLastHolder bool) error
 }
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"verve/internal/models"
+)
+
+// ErrExecutionExists is returned when a run of a scheduled transfer was already started
+var ErrExecutionExists = errors.New("run of the scheduled transfer was already started")
+
+// ScheduledTransferRepository stores scheduled transfers and the record of their runs
+type ScheduledTransferRepository interface {
+	Create(schedule *models.ScheduledTransfer) error
+	FindByID(id int64) (*models.ScheduledTransfer, error)
+	// Find lists up to filter.Limit schedules matching the filter, newest first
+	Find(filter models.ScheduledTransferFilter) ([]*models.ScheduledTransfer, error)
+	// UpdateStatus moves a schedule from one status to another with its next run and returns
+	// it, or returns sql.ErrNoRows when the schedule is no longer in status from
+	UpdateStatus(id int64, from, to models.ScheduledTransferStatus, nextRunAt *time.Time) (*models.ScheduledTransfer, error)
+	// ClaimDue leases up to limit active schedules due at now. Claimed schedules are not
+	// claimed again until the lease ends or Advance releases them.
+	ClaimDue(now time.Time, lease time.Duration, limit int) ([]*models.ScheduledTransfer, error)
+	// Advance adds runs to the run count of a claimed schedule, sets its next run and releases
+	// it. A schedule without a next run is completed unless it was cancelled meanwhile.
+	Advance(id int64, runs int, nextRunAt *time.Time) error
+	// StartExecution records that a run started, or returns ErrExecutionExists when the run
+	// due at scheduledFor was started before
+	StartExecution(scheduleID int64, scheduledFor time.Time) (*models.ScheduledTransferExecution, error)
+	FinishExecution(id int64, status models.ScheduledTransferExecutionStatus, transferID *int64, errorMessage string) error
+	// RecordSkipped records that skippedRuns runs from scheduledFor on were skipped
+	RecordSkipped(scheduleID int64, scheduledFor time.Time, skippedRuns int) error
+	// FindExecutions lists up to limit runs of a schedule before beforeID, newest first
+	FindExecutions(scheduleID, beforeID int64, limit int) ([]*models.ScheduledTransferExecution, error)
+	// RecoverRunning settles runs left running since before staleBefore by a crash
+	RecoverRunning(staleBefore time.Time) (int64, error)
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"errors"
+	"time"
+	"verve/internal/models"
+)
+
+// ErrRefreshTokenNotCurrent is returned when a refresh token was rotated away before it could be used
+var ErrRefreshTokenNotCurrent = errors.New("refresh token is no longer current")
+
+type SessionRepository interface {
+	Create(session *models.Session, refreshTokenHash string) error
+	FindByID(id string) (*models.Session, error)
+	// FindByRefreshToken returns the session a refresh token was issued for, and whether
+	// it is still the current token of the session
+	FindByRefreshToken(tokenHash string) (*models.Session, bool, error)
+	// RotateRefreshToken replaces the current refresh token of a session and extends it
+	RotateRefreshToken(sessionID, oldHash, newHash string, expiresAt time.Time, userAgent, ipAddress string) error
+	FindActiveByUser(userID int) ([]*models.Session, error)
+	Revoke(sessionID, reason string) error
+	// RevokeAllForUser revokes every active session of a user except exceptSessionID
+	RevokeAllForUser(userID int, exceptSessionID, reason string) (int64, error)
+	DeleteExpired() (int64, error)
+}
@@ -1,15 +1,43 @@
 package repository
 
-import "verve/internal/models"
+import (
+	"errors"
+	"verve/internal/models"
+)
+
+// ErrInsufficientFunds is returned when the sender wallet cannot cover a transfer
+var ErrInsufficientFunds = errors.New("insufficient funds")
 
 // TransactionRepository abstracts coin transfer and ledger logging
 // All operations are performed atomically in a DB transaction
 
 type TransactionRepository interface {
 	TransferCoins(senderWalletID, receiverWalletID, amount int64) (*models.Transaction, []*models.LedgerEntry, error)
+	// ExecuteTransfer moves the coins of a pending transfer and marks it completed in the same DB transaction
+	ExecuteTransfer(transfer *models.Transfer) (*models.Transaction, []*models.LedgerEntry, error)
+	// FindWalletStatement lists a wallet's ledger entries newest first, with the running balance after each one.
+	// A counterparty filter never matches the sender of an anonymous transfer the wallet received.
+	FindWalletStatement(walletID int64, filter models.TransactionHistoryFilter) ([]*models.StatementEntry, error)
 }
 
-// LedgerRepository abstracts append-only logging for anonymous transfers
+// LedgerRepository abstracts ledger integrity checks
 type LedgerRepository interface {
-	LogAnonymousTransfer(senderWalletID, receiverWalletID, amount int64, pubKey interface{}, signature string) error
+	// Reconcile checks balances and transactions against the ledger within a single snapshot.
+	// The returned report has no ID or timestamps yet.
+	Reconcile() (*models.ReconciliationReport, error)
+	SaveReconciliation(report *models.ReconciliationReport) error
+	FindReconciliationByID(id int64) (*models.ReconciliationReport, error)
+	// FindReconciliations lists the latest reports, newest first, without their discrepancies
+	FindReconciliations(limit int) ([]*models.ReconciliationReport, error)
+
+	// SealUnchainedEntries appends entries that are not part of the hash chain yet, in ID order
+	SealUnchainedEntries() (int64, error)
+	// ChainHead returns the position and hash of the last entry in the chain
+	ChainHead() (int64, string, error)
+	// FindChainEntries lists up to limit chained entries between two positions, in chain order
+	FindChainEntries(fromSeq, toSeq int64, limit int) ([]*models.LedgerEntry, error)
+	CountUnchainedEntries() (int64, error)
+	CreateCheckpoint(checkpoint *models.LedgerCheckpoint) error
+	FindCheckpoints(limit int) ([]*models.LedgerCheckpoint, error)
+	FindCheckpointsInRange(fromSeq, toSeq int64) ([]*models.LedgerCheckpoint, error)
 }
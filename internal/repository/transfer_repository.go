@@ -1,10 +1,17 @@
 package repository
 
-import "verve/internal/models"
+import (
+	"errors"
+	"verve/internal/models"
+)
+
+// ErrNonceAlreadyUsed is returned when a signed transfer reuses a nonce of its sender wallet
+var ErrNonceAlreadyUsed = errors.New("nonce was already used")
 
 // TransferRepository defines the interface for managing transfer requests.
 type TransferRepository interface {
 	Create(transfer *models.Transfer) error
 	FindByID(id int64) (*models.Transfer, error)
 	UpdateStatus(id int64, status models.TransferStatus) error
+	MarkFailed(id int64, reason string) error
 }
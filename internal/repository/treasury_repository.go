@@ -0,0 +1,23 @@
+package repository
+
+import "verve/internal/models"
+
+// TreasuryRepository abstracts minting into the treasury and tracking grant cycles
+type TreasuryRepository interface {
+	// Mint credits newly created coins to a wallet and records a sender-less transaction
+	Mint(walletID, amount int64) (*models.Transaction, error)
+	// CreateCycle creates a running cycle and snapshots one pending payout per recipient
+	CreateCycle(cycle *models.GrantCycle, excludeUserID int, currency string) error
+	FindCycleByID(id int64) (*models.GrantCycle, error)
+	FindCycles(limit int) ([]*models.GrantCycle, error)
+	FindRunningCycles() ([]*models.GrantCycle, error)
+	AddMintedAmount(cycleID, amount int64) error
+	UpdateCycleStatus(id int64, status models.GrantCycleStatus, failureReason string) error
+	FindPayouts(cycleID int64) ([]*models.GrantPayout, error)
+	// PayNextPayout moves the coins of the next pending payout out of the treasury wallet and
+	// completes the payout in one transaction, returning nil when none are left. When the coins
+	// cannot move it returns the payout, still pending, with the error.
+	PayNextPayout(cycleID, treasuryWalletID int64) (*models.GrantPayout, error)
+	// FailPayout fails a payout that is still pending
+	FailPayout(id int64, reason string) error
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"time"
+	"verve/internal/models"
+)
+
+// WebhookRepository stores webhook endpoints and the log of their deliveries
+type WebhookRepository interface {
+	CreateEndpoint(endpoint *models.WebhookEndpoint) error
+	FindEndpointByID(id int) (*models.WebhookEndpoint, error)
+	FindEndpoints() ([]*models.WebhookEndpoint, error)
+	// FindSubscribedEndpoints lists the active endpoints subscribed to an event type
+	FindSubscribedEndpoints(eventType string) ([]*models.WebhookEndpoint, error)
+	UpdateEndpoint(endpoint *models.WebhookEndpoint) error
+	// DeleteEndpoint deletes an endpoint together with its delivery log
+	DeleteEndpoint(id int) error
+
+	// EnqueueDeliveries adds a pending delivery of an event to each endpoint. Endpoints
+	// already holding a delivery of the event are skipped.
+	EnqueueDeliveries(eventID int64, eventType string, body []byte, endpointIDs []int) error
+	// ClaimDeliveries leases up to limit pending deliveries that are due, oldest first
+	ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]*models.WebhookDelivery, error)
+	MarkDeliverySucceeded(deliveryID int64, attempts, statusCode int) error
+	// RescheduleDelivery records a failed attempt and when to try again
+	RescheduleDelivery(deliveryID int64, attempts, statusCode int, lastError string, nextAttemptAt time.Time) error
+	// MarkDeliveryFailed records the last failed attempt of a delivery that will not be tried again
+	MarkDeliveryFailed(deliveryID int64, attempts, statusCode int, lastError string) error
+	FindDeliveryByID(id int64) (*models.WebhookDelivery, error)
+	// FindDeliveries lists up to filter.Limit deliveries matching the filter, newest first
+	FindDeliveries(filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error)
+	// ReplayDelivery adds a pending delivery of the same body to the same endpoint
+	ReplayDelivery(deliveryID int64) (*models.WebhookDelivery, error)
+}
@@ -0,0 +1,516 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+// AchievementService evaluates the achievement rules of active badges and awards badges
+// whose rules a user satisfies. A badge with several rules needs all of them. The rules of
+// a tiered badge carry the level they unlock: a level is reached when its rules and the
+// rules without a level hold, and a held award is upgraded once a higher level is reached.
+type AchievementService struct {
+	badgeRepo     repository.BadgeRepository
+	ruleRepo      repository.AchievementRuleRepository
+	userBadgeRepo repository.UserBadgeRepository
+	progressRepo  repository.AchievementProgressRepository
+	activityRepo  repository.ActivityRepository
+	walletRepo    repository.WalletRepository
+	userRepo      repository.UserRepository
+}
+
+// NewAchievementService creates a new AchievementService.
+func NewAchievementService(
+	badgeRepo repository.BadgeRepository,
+	ruleRepo repository.AchievementRuleRepository,
+	userBadgeRepo repository.UserBadgeRepository,
+	progressRepo repository.AchievementProgressRepository,
+	activityRepo repository.ActivityRepository,
+	walletRepo repository.WalletRepository,
+	userRepo repository.UserRepository,
+) *AchievementService {
+	return &AchievementService{
+		badgeRepo:     badgeRepo,
+		ruleRepo:      ruleRepo,
+		userBadgeRepo: userBadgeRepo,
+		progressRepo:  progressRepo,
+		activityRepo:  activityRepo,
+		walletRepo:    walletRepo,
+		userRepo:      userRepo,
+	}
+}
+
+// ParseTimeFrame parses a rolling time frame such as "24h", "7d" or "2w".
+// An empty time frame covers the whole history and parses to zero.
+func ParseTimeFrame(timeFrame string) (time.Duration, error) {
+	if timeFrame == "" {
+		return 0, nil
+	}
+
+	units := map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	unit, ok := units[timeFrame[len(timeFrame)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid time frame %q, expected a number followed by h, d or w", timeFrame)
+	}
+	n, err := strconv.Atoi(timeFrame[:len(timeFrame)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid time frame %q, expected a number followed by h, d or w", timeFrame)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// windowStart returns when a time frame starts, or the zero time for the whole history
+func windowStart(timeFrame string, now time.Time) (time.Time, error) {
+	window, err := ParseTimeFrame(timeFrame)
+	if err != nil || window == 0 {
+		return time.Time{}, err
+	}
+	return now.Add(-window), nil
+}
+
+// badgeRules are the active rules of an active badge
+type badgeRules struct {
+	badgeID int
+	rules   []*models.AchievementRule
+	levels  []string // Levels the rules unlock, highest first, or only "" for a badge without levels
+}
+
+// levelRules returns the rules a user must meet to reach a level
+func (b badgeRules) levelRules(level string) []*models.AchievementRule {
+	var rules []*models.AchievementRule
+	for _, rule := range b.rules {
+		if rule.Level == "" || rule.Level == level {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// loadBadgeRules returns the active badges that have at least one active rule
+func (s *AchievementService) loadBadgeRules() ([]badgeRules, error) {
+	badges, err := s.badgeRepo.FindAll(false)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := s.ruleRepo.FindAll(false)
+	if err != nil {
+		return nil, err
+	}
+
+	rulesByBadge := make(map[int][]*models.AchievementRule)
+	for _, rule := range rules {
+		rulesByBadge[rule.BadgeID] = append(rulesByBadge[rule.BadgeID], rule)
+	}
+
+	var candidates []badgeRules
+	for _, badge := range badges {
+		// Badges without rules are only awarded by hand
+		if len(rulesByBadge[badge.ID]) == 0 {
+			continue
+		}
+
+		candidate := badgeRules{badgeID: badge.ID, rules: rulesByBadge[badge.ID]}
+		for i := len(models.BadgeLevels) - 1; i >= 0; i-- {
+			if len(candidate.levelRules(models.BadgeLevels[i])) > len(candidate.levelRules("")) {
+				candidate.levels = append(candidate.levels, models.BadgeLevels[i])
+			}
+		}
+		if len(candidate.levels) == 0 {
+			candidate.levels = []string{""}
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].badgeID < candidates[j].badgeID })
+	return candidates, nil
+}
+
+// EvaluateUser awards the user every badge whose rules they now satisfy, upgrades the awards
+// that reached a higher level, and returns the new and upgraded awards
+func (s *AchievementService) EvaluateUser(userID int) ([]*models.UserBadge, error) {
+	candidates, err := s.loadBadgeRules()
+	if err != nil {
+		return nil, err
+	}
+	return s.evaluate(userID, candidates, time.Now())
+}
+
+func (s *AchievementService) evaluate(userID int, candidates []badgeRules, now time.Time) ([]*models.UserBadge, error) {
+	var awarded []*models.UserBadge
+	for _, candidate := range candidates {
+		held, err := s.userBadgeRepo.FindHeld(userID, candidate.badgeID)
+		if err != nil && err != sql.ErrNoRows {
+			return awarded, err
+		}
+		heldRank := -1
+		if held != nil {
+			heldRank = models.BadgeLevelRank(held.Level)
+		} else {
+			// A revoked badge is only awarded again by an admin
+			revoked, err := s.userBadgeRepo.WasRevoked(userID, candidate.badgeID)
+			if err != nil {
+				return awarded, err
+			}
+			if revoked {
+				continue
+			}
+		}
+
+		level, met, err := s.highestLevelMet(userID, candidate, heldRank, now)
+		if err != nil {
+			return awarded, err
+		}
+		if !met {
+			continue
+		}
+
+		if held != nil {
+			if err := s.userBadgeRepo.UpgradeLevel(held.ID, held.Level, level, nil); err != nil {
+				// A concurrent evaluation or a revocation changed the award first
+				if err == sql.ErrNoRows {
+					continue
+				}
+				return awarded, err
+			}
+			held.Level = level
+			awarded = append(awarded, held)
+			continue
+		}
+
+		userBadge := &models.UserBadge{UserID: userID, BadgeID: candidate.badgeID, Level: level, AwardedBy: nil}
+		if err := s.userBadgeRepo.Award(userBadge); err != nil {
+			// A concurrent evaluation awarded it first
+			if errors.Is(err, repository.ErrBadgeAlreadyAwarded) {
+				continue
+			}
+			return awarded, err
+		}
+		awarded = append(awarded, userBadge)
+	}
+	return awarded, nil
+}
+
+// highestLevelMet returns the highest level of a badge above heldRank whose rules the user meets
+func (s *AchievementService) highestLevelMet(userID int, candidate badgeRules, heldRank int, now time.Time) (string, bool, error) {
+	for _, level := range candidate.levels {
+		if models.BadgeLevelRank(level) <= heldRank {
+			break
+		}
+		met, err := s.meetsRules(userID, candidate.levelRules(level), now)
+		if err != nil || met {
+			return level, met, err
+		}
+	}
+	return "", false, nil
+}
+
+func (s *AchievementService) meetsRules(userID int, rules []*models.AchievementRule, now time.Time) (bool, error) {
+	for _, rule := range rules {
+		expr, err := parseRule(rule)
+		if err != nil {
+			// A malformed rule never holds, and must not stop the other badges from being awarded
+			log.Printf("Skipping achievement rule %d of badge %d: %v", rule.ID, rule.BadgeID, err)
+			return false, nil
+		}
+
+		evaluation, err := s.evaluateExpression(userID, expr, now, false)
+		if err != nil || !evaluation.Met {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// DryRun evaluates a rule for a user without awarding anything, explaining which
+// sub-conditions of the rule the user meets
+func (s *AchievementService) DryRun(userID int, rule *models.AchievementRule) (*models.RuleEvaluation, error) {
+	expr, err := parseRule(rule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAchievementRule, err)
+	}
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return nil, err
+	}
+	return s.evaluateExpression(userID, expr, time.Now(), true)
+}
+
+// evaluateExpression evaluates an expression for a user. Unless every node has to be
+// explained, and and or stop at the first operand that decides their result.
+func (s *AchievementService) evaluateExpression(userID int, expr *models.RuleExpression, now time.Time, explain bool) (*models.RuleEvaluation, error) {
+	evaluation := &models.RuleEvaluation{Node: expr.Operator}
+
+	switch expr.Operator {
+	case models.RuleOperatorAnd, models.RuleOperatorOr:
+		// The operand value that decides the result: an unmet operand for and, a met one for or
+		deciding := expr.Operator == models.RuleOperatorOr
+		evaluation.Met = !deciding
+		for _, operand := range expr.Operands {
+			result, err := s.evaluateExpression(userID, operand, now, explain)
+			if err != nil {
+				return nil, err
+			}
+			evaluation.Operands = append(evaluation.Operands, result)
+			if result.Met == deciding {
+				evaluation.Met = deciding
+				if !explain {
+					break
+				}
+			}
+		}
+
+	case models.RuleOperatorNot:
+		result, err := s.evaluateExpression(userID, expr.Operands[0], now, explain)
+		if err != nil {
+			return nil, err
+		}
+		evaluation.Operands = []*models.RuleEvaluation{result}
+		evaluation.Met = !result.Met
+
+	default:
+		evaluation.Node = expr.Condition
+		evaluation.Condition = expr.Value
+		met, detail, err := s.evaluateCondition(userID, expr.Condition, expr.Value, now)
+		if err != nil {
+			return nil, err
+		}
+		evaluation.Met = met
+		evaluation.Detail = detail
+	}
+
+	return evaluation, nil
+}
+
+// evaluateCondition checks a validated condition, describing what was measured
+func (s *AchievementService) evaluateCondition(userID int, ruleType string, value json.RawMessage, now time.Time) (bool, string, error) {
+	switch ruleType {
+	case models.RuleTypeTransactionCount:
+		var condition models.TransactionCountCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return false, "", err
+		}
+		since, err := windowStart(condition.TimeFrame, now)
+		if err != nil {
+			return false, "", err
+		}
+		count, err := s.progressRepo.CountTransactions(userID, since)
+		if err != nil {
+			return false, "", err
+		}
+		detail := fmt.Sprintf("%d of %d transactions%s", count, condition.MinTransactions, inTimeFrame(condition.TimeFrame))
+		return count >= condition.MinTransactions, detail, nil
+
+	case models.RuleTypeTransferAmount:
+		var condition models.TransferAmountCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return false, "", err
+		}
+		since, err := windowStart(condition.TimeFrame, now)
+		if err != nil {
+			return false, "", err
+		}
+		direction := condition.Direction
+		if direction == models.DirectionTotal {
+			direction = ""
+		}
+		total, err := s.progressRepo.SumTransferAmount(userID, since, direction, condition.Currency)
+		if err != nil {
+			return false, "", err
+		}
+		detail := fmt.Sprintf("%d of %d coins %s%s", total, condition.MinAmount, directionLabel(direction), inTimeFrame(condition.TimeFrame))
+		return total >= condition.MinAmount, detail, nil
+
+	case models.RuleTypeConsecutiveDays:
+		var condition models.ConsecutiveDaysCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return false, "", err
+		}
+		minActivities := condition.MinActivities
+		if minActivities <= 0 {
+			minActivities = 1
+		}
+		days, err := s.activityRepo.FindActiveDays(userID, condition.ActivityType, minActivities)
+		if err != nil {
+			return false, "", err
+		}
+		streak := longestStreak(days)
+		detail := fmt.Sprintf("longest %s streak of %d days, %d needed", condition.ActivityType, streak, condition.Days)
+		return streak >= condition.Days, detail, nil
+
+	case models.RuleTypeDistinctCounterparties:
+		var condition models.DistinctCounterpartiesCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return false, "", err
+		}
+		since, err := windowStart(condition.TimeFrame, now)
+		if err != nil {
+			return false, "", err
+		}
+		direction := condition.Direction
+		if direction == models.DirectionTotal {
+			direction = ""
+		}
+		count, err := s.progressRepo.CountCounterparties(userID, since, direction)
+		if err != nil {
+			return false, "", err
+		}
+		detail := fmt.Sprintf("%d of %d distinct colleagues%s%s", count, condition.MinCounterparties, counterpartyLabel(direction), inTimeFrame(condition.TimeFrame))
+		return count >= condition.MinCounterparties, detail, nil
+
+	case models.RuleTypeFirstTransfer:
+		var condition models.FirstTransferCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return false, "", err
+		}
+		direction := condition.Direction
+		if direction == "" {
+			direction = models.DirectionSent
+		}
+		transferred, err := s.progressRepo.HasTransferred(userID, direction)
+		if err != nil {
+			return false, "", err
+		}
+		if transferred {
+			return true, "has " + direction + " a transfer", nil
+		}
+		return false, "has not " + direction + " a transfer yet", nil
+
+	case models.RuleTypeHasBadge:
+		var condition models.HasBadgeCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return false, "", err
+		}
+		hasBadge, err := s.userBadgeRepo.HasBadge(userID, condition.BadgeID)
+		if err != nil {
+			return false, "", err
+		}
+		if hasBadge {
+			return true, fmt.Sprintf("holds badge %d", condition.BadgeID), nil
+		}
+		return false, fmt.Sprintf("does not hold badge %d", condition.BadgeID), nil
+
+	case models.RuleTypeAccountAge:
+		var condition models.AccountAgeCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return false, "", err
+		}
+		minAge, err := ParseTimeFrame(condition.MinAge)
+		if err != nil {
+			return false, "", err
+		}
+		user, err := s.userRepo.FindByID(userID)
+		if err != nil {
+			return false, "", err
+		}
+		age := now.Sub(user.CreatedAt)
+		detail := fmt.Sprintf("account is %d days old, %s needed", int(age.Hours()/24), condition.MinAge)
+		return age >= minAge, detail, nil
+	}
+
+	return false, "", fmt.Errorf("unsupported rule type %q", ruleType)
+}
+
+// inTimeFrame describes the window of a time frame for an evaluation detail
+func inTimeFrame(timeFrame string) string {
+	if timeFrame == "" {
+		return ""
+	}
+	return " in the last " + timeFrame
+}
+
+func directionLabel(direction string) string {
+	switch direction {
+	case models.DirectionSent:
+		return "sent"
+	case models.DirectionReceived:
+		return "received"
+	}
+	return "sent or received"
+}
+
+func counterpartyLabel(direction string) string {
+	switch direction {
+	case models.DirectionSent:
+		return " sent to"
+	case models.DirectionReceived:
+		return " received from"
+	}
+	return ""
+}
+
+// TransferCompleted evaluates the owners of both wallets of a completed transfer in the background
+func (s *AchievementService) TransferCompleted(transfer *models.Transfer) {
+	go func() {
+		for _, walletID := range []int64{transfer.SenderWalletID, transfer.ReceiverWalletID} {
+			wallet, err := s.walletRepo.FindByID(walletID)
+			if err != nil {
+				log.Printf("Failed to load wallet %d to evaluate achievements: %v", walletID, err)
+				continue
+			}
+			// Pseudonymous wallets have no owner to award
+			if wallet == nil || wallet.UserID == 0 {
+				continue
+			}
+			if _, err := s.EvaluateUser(wallet.UserID); err != nil {
+				log.Printf("Failed to evaluate achievements of user %d: %v", wallet.UserID, err)
+			}
+		}
+	}()
+}
+
+// EvaluateAll evaluates every user and returns the number of badges awarded or upgraded
+func (s *AchievementService) EvaluateAll() (int, error) {
+	candidates, err := s.loadBadgeRules()
+	if err != nil {
+		return 0, err
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	users, err := s.userRepo.FindAll()
+	if err != nil {
+		return 0, err
+	}
+
+	awarded := 0
+	now := time.Now()
+	for _, user := range users {
+		userAwards, err := s.evaluate(user.ID, candidates, now)
+		awarded += len(userAwards)
+		if err != nil {
+			log.Printf("Failed to evaluate achievements of user %d: %v", user.ID, err)
+		}
+	}
+	return awarded, nil
+}
+
+// RunEvaluation evaluates every user every interval until ctx is cancelled, so badges
+// whose rules hold without a new transfer, such as login streaks, are awarded too.
+func (s *AchievementService) RunEvaluation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			awarded, err := s.EvaluateAll()
+			if err != nil {
+				log.Printf("Failed to evaluate achievements: %v", err)
+				continue
+			}
+			if awarded > 0 {
+				log.Printf("Achievement evaluation awarded %d badges", awarded)
+			}
+		}
+	}
+}
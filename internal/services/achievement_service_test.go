@@ -0,0 +1,675 @@
+package services_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeFrame(t *testing.T) {
+	tests := []struct {
+		timeFrame string
+		expected  time.Duration
+		wantErr   bool
+	}{
+		{timeFrame: "", expected: 0},
+		{timeFrame: "24h", expected: 24 * time.Hour},
+		{timeFrame: "7d", expected: 7 * 24 * time.Hour},
+		{timeFrame: "30d", expected: 30 * 24 * time.Hour},
+		{timeFrame: "2w", expected: 14 * 24 * time.Hour},
+		{timeFrame: "0d", wantErr: true},
+		{timeFrame: "-1d", wantErr: true},
+		{timeFrame: "7", wantErr: true},
+		{timeFrame: "d", wantErr: true},
+		{timeFrame: "1y", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timeFrame, func(t *testing.T) {
+			duration, err := services.ParseTimeFrame(tt.timeFrame)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, duration)
+		})
+	}
+}
+
+func TestEvaluateUser(t *testing.T) {
+	rule := func(id, badgeID int, ruleType string, condition interface{}) *models.AchievementRule {
+		value, err := json.Marshal(condition)
+		require.NoError(t, err)
+		return &models.AchievementRule{ID: id, BadgeID: badgeID, RuleType: ruleType, ConditionValue: value, IsActive: true}
+	}
+
+	badgeRepo := &mockBadgeRepo{badges: []*models.Badge{
+		{ID: 1, Name: "Busy week", IsActive: true},
+		{ID: 2, Name: "Big spender", IsActive: true},
+		{ID: 3, Name: "Regular", IsActive: true},
+		{ID: 4, Name: "Hand picked", IsActive: true},
+	}}
+	ruleRepo := &mockAchievementRuleRepo{rules: []*models.AchievementRule{
+		rule(1, 1, models.RuleTypeTransactionCount, models.TransactionCountCondition{MinTransactions: 3, TimeFrame: "7d"}),
+		// Needs both rules
+		rule(2, 2, models.RuleTypeTransferAmount, models.TransferAmountCondition{MinAmount: 500, TimeFrame: "30d", Direction: "sent", Currency: "USD"}),
+		rule(3, 2, models.RuleTypeTransactionCount, models.TransactionCountCondition{MinTransactions: 1}),
+		rule(4, 3, models.RuleTypeConsecutiveDays, models.ConsecutiveDaysCondition{Days: 3, ActivityType: models.ActivityTypeTransfer}),
+	}}
+	userBadgeRepo := &mockUserBadgeRepo{}
+	progressRepo := &mockProgressRepo{}
+	activityRepo := &mockActivityRepo{}
+	service := services.NewAchievementService(badgeRepo, ruleRepo, userBadgeRepo, progressRepo, activityRepo, &mockWalletRepo{}, newMockUserRepo())
+
+	t.Run("Nothing is awarded below the thresholds", func(t *testing.T) {
+		progressRepo.transactions = 2
+		progressRepo.sent = 499
+		day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		activityRepo.days = []time.Time{day, day.AddDate(0, 0, 1), day.AddDate(0, 0, 3)}
+
+		awarded, err := service.EvaluateUser(1)
+		require.NoError(t, err)
+		assert.Empty(t, awarded)
+	})
+
+	t.Run("Badges are awarded by the system once their rules hold", func(t *testing.T) {
+		progressRepo.transactions = 3
+		progressRepo.sent = 500
+		day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		activityRepo.days = []time.Time{day, day.AddDate(0, 0, 2), day.AddDate(0, 0, 3), day.AddDate(0, 0, 4)}
+
+		awarded, err := service.EvaluateUser(1)
+		require.NoError(t, err)
+		require.Len(t, awarded, 3)
+		for i, badgeID := range []int{1, 2, 3} {
+			assert.Equal(t, badgeID, awarded[i].BadgeID)
+			assert.Nil(t, awarded[i].AwardedBy)
+		}
+
+		// The window, direction and currency of the rule are passed on
+		assert.Equal(t, "sent", progressRepo.lastDirection)
+		assert.Equal(t, "USD", progressRepo.lastCurrency)
+		assert.WithinDuration(t, time.Now().Add(-30*24*time.Hour), progressRepo.lastSince, time.Minute)
+	})
+
+	t.Run("Badges are awarded once", func(t *testing.T) {
+		awarded, err := service.EvaluateUser(1)
+		require.NoError(t, err)
+		assert.Empty(t, awarded)
+		assert.Len(t, userBadgeRepo.awards, 3)
+	})
+
+	t.Run("Revoked badges are not awarded again by evaluation", func(t *testing.T) {
+		badgeService := services.NewBadgeService(badgeRepo, ruleRepo, userBadgeRepo, services.NewAuditService(&mockAuditRepo{}))
+		admin := models.Actor{UserID: 2}
+		require.NoError(t, badgeService.RevokeBadge(admin, 1, 1, "Gamed the rules"))
+
+		awarded, err := service.EvaluateUser(1)
+		require.NoError(t, err)
+		assert.Empty(t, awarded)
+		has, err := userBadgeRepo.HasBadge(1, 1)
+		require.NoError(t, err)
+		assert.False(t, has)
+
+		// An admin may still award it again
+		require.NoError(t, badgeService.AwardBadge(admin, 1, 1, "", nil))
+		has, err = userBadgeRepo.HasBadge(1, 1)
+		require.NoError(t, err)
+		assert.True(t, has)
+	})
+
+	t.Run("Expired badges are earned again", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		held, err := userBadgeRepo.FindHeld(1, 2)
+		require.NoError(t, err)
+		held.ExpiresAt = &past
+		_, err = userBadgeRepo.ExpireDue(time.Now())
+		require.NoError(t, err)
+
+		awarded, err := service.EvaluateUser(1)
+		require.NoError(t, err)
+		require.Len(t, awarded, 1)
+		assert.Equal(t, 2, awarded[0].BadgeID)
+	})
+
+	t.Run("Malformed rules never hold", func(t *testing.T) {
+		ruleRepo.rules = append(ruleRepo.rules, rule(5, 4, models.RuleTypeTransactionCount, models.TransactionCountCondition{MinTransactions: 1, TimeFrame: "soon"}))
+
+		awarded, err := service.EvaluateUser(2)
+		require.NoError(t, err)
+		for _, userBadge := range awarded {
+			assert.NotEqual(t, 4, userBadge.BadgeID)
+		}
+	})
+}
+
+func TestGrantsDoNotAdvanceRules(t *testing.T) {
+	rule := func(id, badgeID int, ruleType string, condition interface{}) *models.AchievementRule {
+		value, err := json.Marshal(condition)
+		require.NoError(t, err)
+		return &models.AchievementRule{ID: id, BadgeID: badgeID, RuleType: ruleType, ConditionValue: value, IsActive: true}
+	}
+	badgeRepo := &mockBadgeRepo{badges: []*models.Badge{
+		{ID: 1, Name: "First steps", IsActive: true},
+		{ID: 2, Name: "Well funded", IsActive: true},
+		{ID: 3, Name: "Team player", IsActive: true},
+	}}
+	ruleRepo := &mockAchievementRuleRepo{rules: []*models.AchievementRule{
+		rule(1, 1, models.RuleTypeTransactionCount, models.TransactionCountCondition{MinTransactions: 1}),
+		rule(2, 2, models.RuleTypeTransferAmount, models.TransferAmountCondition{MinAmount: 50, Direction: models.DirectionReceived}),
+		// The treasury must not count as the second colleague
+		rule(3, 3, models.RuleTypeDistinctCounterparties, models.DistinctCounterpartiesCondition{MinCounterparties: 2, Direction: models.DirectionReceived}),
+	}}
+	wallets := map[int64]*models.Wallet{
+		1:  {ID: 1, UserID: 1},
+		2:  {ID: 2, UserID: 2},
+		10: {ID: 10, UserID: 3}, // Treasury
+	}
+	// Grant payouts move coins out of the treasury without a transfer
+	progressRepo := &ledgerProgressRepo{wallets: wallets, transactions: []*models.Transaction{
+		{ID: 1, SenderWalletID: 10, ReceiverWalletID: 1, Amount: 100},
+		{ID: 2, ReceiverWalletID: 1, Amount: 100}, // Mint
+	}}
+	service := services.NewAchievementService(badgeRepo, ruleRepo, &mockUserBadgeRepo{}, progressRepo, &mockActivityRepo{}, &mockWalletRepo{wallets: wallets}, newMockUserRepo())
+
+	awarded, err := service.EvaluateUser(1)
+	require.NoError(t, err)
+	assert.Empty(t, awarded)
+
+	transferID := int64(7)
+	progressRepo.transactions = append(progressRepo.transactions, &models.Transaction{ID: 3, SenderWalletID: 2, ReceiverWalletID: 1, Amount: 50, TransferID: &transferID})
+	awarded, err = service.EvaluateUser(1)
+	require.NoError(t, err)
+	require.Len(t, awarded, 2)
+	assert.Equal(t, 1, awarded[0].BadgeID)
+	assert.Equal(t, 2, awarded[1].BadgeID)
+	counterparties, err := progressRepo.CountCounterparties(1, time.Time{}, models.DirectionReceived)
+	require.NoError(t, err)
+	assert.Equal(t, 1, counterparties)
+}
+
+func TestTieredBadges(t *testing.T) {
+	rule := func(id int, level string, condition models.TransactionCountCondition) *models.AchievementRule {
+		value, err := json.Marshal(condition)
+		require.NoError(t, err)
+		return &models.AchievementRule{ID: id, BadgeID: 1, RuleType: models.RuleTypeTransactionCount, ConditionValue: value, Level: level, IsActive: true}
+	}
+
+	badgeRepo := &mockBadgeRepo{badges: []*models.Badge{{ID: 1, Name: "Generous", IsActive: true}}}
+	ruleRepo := &mockAchievementRuleRepo{rules: []*models.AchievementRule{
+		// Applies to every level
+		rule(1, "", models.TransactionCountCondition{MinTransactions: 1}),
+		rule(2, models.BadgeLevelBronze, models.TransactionCountCondition{MinTransactions: 5}),
+		rule(3, models.BadgeLevelSilver, models.TransactionCountCondition{MinTransactions: 20}),
+		rule(4, models.BadgeLevelGold, models.TransactionCountCondition{MinTransactions: 50}),
+	}}
+	userBadgeRepo := &mockUserBadgeRepo{}
+	progressRepo := &mockProgressRepo{transactions: 4}
+	service := services.NewAchievementService(badgeRepo, ruleRepo, userBadgeRepo, progressRepo, &mockActivityRepo{}, &mockWalletRepo{}, newMockUserRepo())
+
+	t.Run("No level is reached below the bronze threshold", func(t *testing.T) {
+		awarded, err := service.EvaluateUser(1)
+		require.NoError(t, err)
+		assert.Empty(t, awarded)
+	})
+
+	t.Run("The highest level reached is awarded", func(t *testing.T) {
+		progressRepo.transactions = 25
+
+		awarded, err := service.EvaluateUser(1)
+		require.NoError(t, err)
+		require.Len(t, awarded, 1)
+		assert.Equal(t, models.BadgeLevelSilver, awarded[0].Level)
+	})
+
+	t.Run("Reaching a higher level upgrades the award", func(t *testing.T) {
+		progressRepo.transactions = 60
+
+		awarded, err := service.EvaluateUser(1)
+		require.NoError(t, err)
+		require.Len(t, awarded, 1)
+		assert.Equal(t, models.BadgeLevelGold, awarded[0].Level)
+		assert.Len(t, userBadgeRepo.awards, 1)
+	})
+
+	t.Run("Awards are never downgraded", func(t *testing.T) {
+		progressRepo.transactions = 6
+
+		awarded, err := service.EvaluateUser(1)
+		require.NoError(t, err)
+		assert.Empty(t, awarded)
+
+		held, err := userBadgeRepo.FindHeld(1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, models.BadgeLevelGold, held.Level)
+	})
+
+	t.Run("The history keeps every level change", func(t *testing.T) {
+		badgeService := services.NewBadgeService(badgeRepo, ruleRepo, userBadgeRepo, services.NewAuditService(&mockAuditRepo{}))
+		history, err := badgeService.GetBadgeHistory(1)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, models.UserBadgeEventAwarded, history[0].EventType)
+		assert.Equal(t, models.BadgeLevelSilver, history[0].Level)
+		assert.Equal(t, models.UserBadgeEventUpgraded, history[1].EventType)
+		assert.Equal(t, models.BadgeLevelSilver, history[1].PreviousLevel)
+		assert.Equal(t, models.BadgeLevelGold, history[1].Level)
+	})
+}
+
+func TestRevokeBadge(t *testing.T) {
+	badgeRepo := &mockBadgeRepo{badges: []*models.Badge{{ID: 1, Name: "Helper", IsActive: true}}}
+	userBadgeRepo := &mockUserBadgeRepo{}
+	service := services.NewBadgeService(badgeRepo, &mockAchievementRuleRepo{}, userBadgeRepo, services.NewAuditService(&mockAuditRepo{}))
+	admin := models.Actor{UserID: 2}
+
+	require.NoError(t, service.AwardBadge(admin, 1, 1, "", nil))
+	assert.ErrorIs(t, service.AwardBadge(admin, 1, 1, "", nil), repository.ErrBadgeAlreadyAwarded)
+
+	t.Run("A reason is required", func(t *testing.T) {
+		assert.ErrorIs(t, service.RevokeBadge(admin, 1, 1, ""), services.ErrRevokeReasonRequired)
+	})
+
+	t.Run("Revoked badges are no longer held and can be awarded again", func(t *testing.T) {
+		require.NoError(t, service.RevokeBadge(admin, 1, 1, "Awarded by mistake"))
+
+		badges, err := service.GetUserBadges(1)
+		require.NoError(t, err)
+		assert.Empty(t, badges)
+		assert.ErrorIs(t, service.RevokeBadge(admin, 1, 1, "Twice"), services.ErrBadgeNotHeld)
+
+		require.NoError(t, service.AwardBadge(admin, 1, 1, "", nil))
+
+		history, err := service.GetBadgeHistory(1)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, models.UserBadgeEventRevoked, history[1].EventType)
+		assert.Equal(t, "Awarded by mistake", history[1].Reason)
+		assert.Equal(t, &admin.UserID, history[1].ActorID)
+	})
+
+	t.Run("Expired badges are no longer held", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		assert.ErrorIs(t, service.AwardBadge(admin, 2, 1, "", &past), services.ErrInvalidBadgeExpiry)
+
+		userBadgeRepo.awards = append(userBadgeRepo.awards, &models.UserBadge{ID: len(userBadgeRepo.awards) + 1, UserID: 2, BadgeID: 1, ExpiresAt: &past})
+		badges, err := service.GetUserBadges(2)
+		require.NoError(t, err)
+		assert.Empty(t, badges)
+	})
+
+	t.Run("Unknown levels are rejected", func(t *testing.T) {
+		assert.ErrorIs(t, service.AwardBadge(admin, 3, 1, "platinum", nil), services.ErrInvalidBadgeLevel)
+	})
+}
+
+func TestExpressionRules(t *testing.T) {
+	badgeRepo := &mockBadgeRepo{badges: []*models.Badge{{ID: 1, Name: "Newcomer", IsActive: true}}}
+	userBadgeRepo := &mockUserBadgeRepo{}
+	progressRepo := &mockProgressRepo{transactions: 12, counterparties: 3, transferred: true}
+	userRepo := newMockUserRepo()
+	userRepo.users[1].CreatedAt = time.Now().Add(-40 * 24 * time.Hour)
+	service := services.NewAchievementService(badgeRepo, &mockAchievementRuleRepo{}, userBadgeRepo, progressRepo, &mockActivityRepo{}, &mockWalletRepo{}, userRepo)
+	badgeService := services.NewBadgeService(badgeRepo, &mockAchievementRuleRepo{}, userBadgeRepo, services.NewAuditService(&mockAuditRepo{}))
+
+	expression := func(condition string) *models.AchievementRule {
+		return &models.AchievementRule{RuleType: models.RuleTypeExpression, ConditionValue: json.RawMessage(condition)}
+	}
+
+	t.Run("Dry run explains every sub-condition", func(t *testing.T) {
+		evaluation, err := service.DryRun(1, expression(`{"and": [
+			{"transaction_count": {"min_transactions": 10, "time_frame": "30d"}},
+			{"distinct_counterparties": {"min_counterparties": 5, "direction": "received", "time_frame": "30d"}},
+			{"account_age": {"min_age": "30d"}}
+		]}`))
+		require.NoError(t, err)
+
+		assert.Equal(t, "and", evaluation.Node)
+		assert.False(t, evaluation.Met)
+		require.Len(t, evaluation.Operands, 3)
+		assert.True(t, evaluation.Operands[0].Met)
+		assert.Equal(t, "12 of 10 transactions in the last 30d", evaluation.Operands[0].Detail)
+		assert.False(t, evaluation.Operands[1].Met)
+		assert.Equal(t, "3 of 5 distinct colleagues received from in the last 30d", evaluation.Operands[1].Detail)
+		assert.True(t, evaluation.Operands[2].Met)
+	})
+
+	t.Run("Or and not combine conditions", func(t *testing.T) {
+		evaluation, err := service.DryRun(1, expression(`{"or": [
+			{"not": {"first_transfer": {}}},
+			{"has_badge": {"badge_id": 1}}
+		]}`))
+		require.NoError(t, err)
+		assert.False(t, evaluation.Met)
+
+		require.NoError(t, userBadgeRepo.Award(&models.UserBadge{UserID: 1, BadgeID: 1}))
+		evaluation, err = service.DryRun(1, expression(`{"or": [
+			{"not": {"first_transfer": {}}},
+			{"has_badge": {"badge_id": 1}}
+		]}`))
+		require.NoError(t, err)
+		assert.True(t, evaluation.Met)
+		assert.False(t, evaluation.Operands[0].Met)
+		assert.True(t, evaluation.Operands[0].Operands[0].Met)
+	})
+
+	t.Run("Malformed expressions are rejected", func(t *testing.T) {
+		invalid := []string{
+			`{"and": []}`,
+			`{"and": [{"transaction_count": {"min_transactions": 0}}]}`,
+			`{"or": [{"unknown": {}}]}`,
+			`{"not": [{"first_transfer": {}}]}`,
+			`{"and": [{"first_transfer": {}}], "or": [{"first_transfer": {}}]}`,
+			`{"expression": {"first_transfer": {}}}`,
+			`{"has_badge": {"badge_id": 99}}`,
+			`{"account_age": {"min_age": "forever"}}`,
+		}
+		for _, condition := range invalid {
+			assert.Error(t, badgeService.ValidateAchievementRule(expression(condition)), condition)
+		}
+
+		_, err := service.DryRun(1, expression(`{"and": []}`))
+		assert.ErrorIs(t, err, services.ErrInvalidAchievementRule)
+	})
+
+	t.Run("Deeply nested expressions are rejected", func(t *testing.T) {
+		condition := `{"first_transfer": {}}`
+		for i := 0; i < 10; i++ {
+			condition = `{"not": ` + condition + `}`
+		}
+		assert.Error(t, badgeService.ValidateAchievementRule(expression(condition)))
+	})
+
+	t.Run("Plain rules of the new types are valid", func(t *testing.T) {
+		rule := &models.AchievementRule{RuleType: models.RuleTypeAccountAge, ConditionValue: json.RawMessage(`{"min_age": "90d"}`)}
+		assert.NoError(t, badgeService.ValidateAchievementRule(rule))
+	})
+}
+
+type mockBadgeRepo struct {
+	badges []*models.Badge
+}
+
+func (m *mockBadgeRepo) Create(badge *models.Badge) error {
+	badge.ID = len(m.badges) + 1
+	m.badges = append(m.badges, badge)
+	return nil
+}
+
+func (m *mockBadgeRepo) Update(badge *models.Badge) error {
+	return nil
+}
+
+func (m *mockBadgeRepo) FindByID(id int) (*models.Badge, error) {
+	for _, badge := range m.badges {
+		if badge.ID == id {
+			return badge, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *mockBadgeRepo) FindAll(includeInactive bool) ([]*models.Badge, error) {
+	var badges []*models.Badge
+	for _, badge := range m.badges {
+		if includeInactive || badge.IsActive {
+			badges = append(badges, badge)
+		}
+	}
+	return badges, nil
+}
+
+func (m *mockBadgeRepo) Delete(id int) error {
+	return nil
+}
+
+type mockAchievementRuleRepo struct {
+	rules []*models.AchievementRule
+}
+
+func (m *mockAchievementRuleRepo) Create(rule *models.AchievementRule) error {
+	rule.ID = len(m.rules) + 1
+	m.rules = append(m.rules, rule)
+	return nil
+}
+
+func (m *mockAchievementRuleRepo) Update(rule *models.AchievementRule) error {
+	return nil
+}
+
+func (m *mockAchievementRuleRepo) FindByBadgeID(badgeID int) ([]*models.AchievementRule, error) {
+	var rules []*models.AchievementRule
+	for _, rule := range m.rules {
+		if rule.BadgeID == badgeID {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (m *mockAchievementRuleRepo) FindAll(includeInactive bool) ([]*models.AchievementRule, error) {
+	var rules []*models.AchievementRule
+	for _, rule := range m.rules {
+		if includeInactive || rule.IsActive {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (m *mockAchievementRuleRepo) Delete(id int) error {
+	return nil
+}
+
+type mockUserBadgeRepo struct {
+	awards []*models.UserBadge
+	events []*models.UserBadgeEvent
+}
+
+func (m *mockUserBadgeRepo) held(award *models.UserBadge) bool {
+	return award.RevokedAt == nil && (award.ExpiresAt == nil || award.ExpiresAt.After(time.Now()))
+}
+
+func (m *mockUserBadgeRepo) record(award *models.UserBadge, eventType, previousLevel string, actorID *int, reason string) {
+	m.events = append(m.events, &models.UserBadgeEvent{
+		ID: int64(len(m.events) + 1), UserBadgeID: award.ID, UserID: award.UserID, BadgeID: award.BadgeID, EventType: eventType,
+		PreviousLevel: previousLevel, Level: award.Level, ActorID: actorID, Reason: reason, CreatedAt: time.Now(),
+	})
+}
+
+func (m *mockUserBadgeRepo) Award(userBadge *models.UserBadge) error {
+	if has, _ := m.HasBadge(userBadge.UserID, userBadge.BadgeID); has {
+		return repository.ErrBadgeAlreadyAwarded
+	}
+	userBadge.ID = len(m.awards) + 1
+	userBadge.AwardedAt = time.Now()
+	m.awards = append(m.awards, userBadge)
+	m.record(userBadge, models.UserBadgeEventAwarded, "", userBadge.AwardedBy, "")
+	return nil
+}
+
+func (m *mockUserBadgeRepo) FindByUserID(userID int) ([]*models.UserBadge, error) {
+	var awards []*models.UserBadge
+	for _, award := range m.awards {
+		if award.UserID == userID && m.held(award) {
+			awards = append(awards, award)
+		}
+	}
+	return awards, nil
+}
+
+func (m *mockUserBadgeRepo) FindByBadgeID(badgeID int) ([]*models.UserBadge, error) {
+	var awards []*models.UserBadge
+	for _, award := range m.awards {
+		if award.BadgeID == badgeID && m.held(award) {
+			awards = append(awards, award)
+		}
+	}
+	return awards, nil
+}
+
+func (m *mockUserBadgeRepo) HasBadge(userID, badgeID int) (bool, error) {
+	_, err := m.FindHeld(userID, badgeID)
+	return err == nil, nil
+}
+
+func (m *mockUserBadgeRepo) FindHeld(userID, badgeID int) (*models.UserBadge, error) {
+	for _, award := range m.awards {
+		if award.UserID == userID && award.BadgeID == badgeID && m.held(award) {
+			return award, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *mockUserBadgeRepo) WasRevoked(userID, badgeID int) (bool, error) {
+	for i := len(m.awards) - 1; i >= 0; i-- {
+		if award := m.awards[i]; award.UserID == userID && award.BadgeID == badgeID {
+			return award.RevokedBy != nil, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockUserBadgeRepo) UpgradeLevel(userBadgeID int, fromLevel, toLevel string, actorID *int) error {
+	award := m.awards[userBadgeID-1]
+	if !m.held(award) || award.Level != fromLevel {
+		return sql.ErrNoRows
+	}
+	award.Level = toLevel
+	m.record(award, models.UserBadgeEventUpgraded, fromLevel, actorID, "")
+	return nil
+}
+
+func (m *mockUserBadgeRepo) Revoke(userBadgeID, revokedBy int, reason string) error {
+	award := m.awards[userBadgeID-1]
+	if !m.held(award) {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	award.RevokedAt, award.RevokedBy, award.RevokeReason = &now, &revokedBy, reason
+	m.record(award, models.UserBadgeEventRevoked, "", &revokedBy, reason)
+	return nil
+}
+
+func (m *mockUserBadgeRepo) ExpireDue(now time.Time) (int64, error) {
+	var expired int64
+	for _, award := range m.awards {
+		if award.RevokedAt == nil && award.ExpiresAt != nil && !award.ExpiresAt.After(now) {
+			award.RevokedAt, award.RevokeReason = award.ExpiresAt, "expired"
+			m.record(award, models.UserBadgeEventExpired, "", nil, "")
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (m *mockUserBadgeRepo) FindHistory(userID int) ([]*models.UserBadgeEvent, error) {
+	var events []*models.UserBadgeEvent
+	for _, event := range m.events {
+		if event.UserID == userID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// ledgerProgressRepo reads the activity of users from their transactions, like the database
+type ledgerProgressRepo struct {
+	wallets      map[int64]*models.Wallet
+	transactions []*models.Transaction
+}
+
+// entries calls visit for every transfer the user took part in, with the other wallet
+func (m *ledgerProgressRepo) entries(userID int, direction string, visit func(transaction *models.Transaction, counterparty int64)) {
+	for _, transaction := range m.transactions {
+		if transaction.TransferID == nil {
+			continue
+		}
+		if sender := m.wallets[transaction.SenderWalletID]; sender != nil && sender.UserID == userID && direction != models.DirectionReceived {
+			visit(transaction, transaction.ReceiverWalletID)
+		}
+		if receiver := m.wallets[transaction.ReceiverWalletID]; receiver.UserID == userID && direction != models.DirectionSent {
+			visit(transaction, transaction.SenderWalletID)
+		}
+	}
+}
+
+func (m *ledgerProgressRepo) CountTransactions(userID int, since time.Time) (int, error) {
+	counted := make(map[int64]bool)
+	m.entries(userID, "", func(transaction *models.Transaction, _ int64) { counted[transaction.ID] = true })
+	return len(counted), nil
+}
+
+func (m *ledgerProgressRepo) SumTransferAmount(userID int, since time.Time, direction, currency string) (int64, error) {
+	var total int64
+	m.entries(userID, direction, func(transaction *models.Transaction, _ int64) { total += transaction.Amount })
+	return total, nil
+}
+
+func (m *ledgerProgressRepo) CountCounterparties(userID int, since time.Time, direction string) (int, error) {
+	counted := make(map[int]bool)
+	m.entries(userID, direction, func(_ *models.Transaction, counterparty int64) {
+		if wallet := m.wallets[counterparty]; wallet != nil && wallet.UserID != 0 && wallet.UserID != userID {
+			counted[wallet.UserID] = true
+		}
+	})
+	return len(counted), nil
+}
+
+func (m *ledgerProgressRepo) HasTransferred(userID int, direction string) (bool, error) {
+	transferred := false
+	m.entries(userID, direction, func(*models.Transaction, int64) { transferred = true })
+	return transferred, nil
+}
+
+// mockProgressRepo reports the same activity for every user
+type mockProgressRepo struct {
+	transactions   int
+	sent           int64
+	counterparties int
+	transferred    bool
+	lastSince      time.Time
+	lastDirection  string
+	lastCurrency   string
+}
+
+func (m *mockProgressRepo) CountTransactions(userID int, since time.Time) (int, error) {
+	return m.transactions, nil
+}
+
+func (m *mockProgressRepo) SumTransferAmount(userID int, since time.Time, direction, currency string) (int64, error) {
+	m.lastSince, m.lastDirection, m.lastCurrency = since, direction, currency
+	return m.sent, nil
+}
+
+func (m *mockProgressRepo) CountCounterparties(userID int, since time.Time, direction string) (int, error) {
+	return m.counterparties, nil
+}
+
+func (m *mockProgressRepo) HasTransferred(userID int, direction string) (bool, error) {
+	return m.transferred, nil
+}
+
+// mockActivityRepo records events and reports the same active days for every user
+type mockActivityRepo struct {
+	events []*models.ActivityEvent
+	days   []time.Time
+}
+
+func (m *mockActivityRepo) Record(event *models.ActivityEvent) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *mockActivityRepo) FindActiveDays(userID int, activityType string, minActivities int) ([]time.Time, error) {
+	return m.days, nil
+}
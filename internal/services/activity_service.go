@@ -0,0 +1,102 @@
+package services
+
+import (
+	"log"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+// ActivityService records what users do and reports their streaks of consecutive active days.
+type ActivityService struct {
+	activityRepo repository.ActivityRepository
+	walletRepo   repository.WalletRepository
+}
+
+// NewActivityService creates a new ActivityService.
+func NewActivityService(activityRepo repository.ActivityRepository, walletRepo repository.WalletRepository) *ActivityService {
+	return &ActivityService{activityRepo: activityRepo, walletRepo: walletRepo}
+}
+
+// Record stores an activity of a user. Recording is best effort: a failure is logged and
+// must not fail the action that was recorded.
+func (s *ActivityService) Record(userID int, activityType string, referenceID *int64) {
+	event := &models.ActivityEvent{UserID: userID, ActivityType: activityType, ReferenceID: referenceID}
+	if err := s.activityRepo.Record(event); err != nil {
+		log.Printf("Failed to record %s activity of user %d: %v", activityType, userID, err)
+	}
+}
+
+// TransferCompleted records a transfer activity for the user who sent the transfer
+func (s *ActivityService) TransferCompleted(transfer *models.Transfer) {
+	sender, err := s.walletRepo.FindByID(transfer.SenderWalletID)
+	if err != nil {
+		log.Printf("Failed to load wallet %d to record transfer activity: %v", transfer.SenderWalletID, err)
+		return
+	}
+	// Pseudonymous wallets have no user to record
+	if sender == nil || sender.UserID == 0 {
+		return
+	}
+	s.Record(sender.UserID, models.ActivityTypeTransfer, &transfer.ID)
+}
+
+// GetStreaks returns the streaks of a user for every activity type
+func (s *ActivityService) GetStreaks(userID int) ([]*models.Streak, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	streaks := make([]*models.Streak, 0, len(models.ActivityTypes))
+	for _, activityType := range models.ActivityTypes {
+		days, err := s.activityRepo.FindActiveDays(userID, activityType, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		streak := &models.Streak{
+			ActivityType: activityType,
+			CurrentDays:  currentStreak(days, today),
+			LongestDays:  longestStreak(days),
+		}
+		if len(days) > 0 {
+			lastActiveDay := days[len(days)-1]
+			streak.LastActiveDay = &lastActiveDay
+			streak.ActiveToday = lastActiveDay.Equal(today)
+		}
+		streaks = append(streaks, streak)
+	}
+	return streaks, nil
+}
+
+// longestStreak returns the longest run of consecutive days in days, sorted oldest first
+func longestStreak(days []time.Time) int {
+	longest, current := 0, 0
+	for i, day := range days {
+		if i > 0 && day.Sub(days[i-1]) == 24*time.Hour {
+			current++
+		} else {
+			current = 1
+		}
+		if current > longest {
+			longest = current
+		}
+	}
+	return longest
+}
+
+// currentStreak returns the run of consecutive days in days, sorted oldest first, that ends
+// today or yesterday. A streak ending yesterday still runs until today is over.
+func currentStreak(days []time.Time, today time.Time) int {
+	if len(days) == 0 {
+		return 0
+	}
+	last := days[len(days)-1]
+	if !last.Equal(today) && !last.Equal(today.AddDate(0, 0, -1)) {
+		return 0
+	}
+
+	streak := 1
+	for i := len(days) - 1; i > 0 && days[i].Sub(days[i-1]) == 24*time.Hour; i-- {
+		streak++
+	}
+	return streak
+}
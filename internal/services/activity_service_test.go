@@ -0,0 +1,63 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStreaks(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	day := func(offset int) time.Time { return today.AddDate(0, 0, offset) }
+
+	tests := []struct {
+		name        string
+		days        []time.Time
+		current     int
+		longest     int
+		activeToday bool
+	}{
+		{name: "No activity", days: nil, current: 0, longest: 0},
+		{name: "Streak ending today", days: []time.Time{day(-5), day(-2), day(-1), day(0)}, current: 3, longest: 3, activeToday: true},
+		{name: "Streak ending yesterday is still running", days: []time.Time{day(-2), day(-1)}, current: 2, longest: 2},
+		{name: "Streak broken before yesterday", days: []time.Time{day(-9), day(-8), day(-7), day(-6), day(-2)}, current: 0, longest: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := services.NewActivityService(&mockActivityRepo{days: tt.days}, &mockWalletRepo{})
+
+			streaks, err := service.GetStreaks(1)
+			require.NoError(t, err)
+			require.Len(t, streaks, len(models.ActivityTypes))
+
+			streak := streaks[0]
+			assert.Equal(t, tt.current, streak.CurrentDays)
+			assert.Equal(t, tt.longest, streak.LongestDays)
+			assert.Equal(t, tt.activeToday, streak.ActiveToday)
+		})
+	}
+}
+
+func TestActivityTransferCompleted(t *testing.T) {
+	activityRepo := &mockActivityRepo{}
+	walletRepo := &mockWalletRepo{wallets: map[int64]*models.Wallet{
+		1: {ID: 1, UserID: 1},
+		2: {ID: 2},
+	}}
+	service := services.NewActivityService(activityRepo, walletRepo)
+
+	service.TransferCompleted(&models.Transfer{ID: 7, SenderWalletID: 1, ReceiverWalletID: 2})
+	require.Len(t, activityRepo.events, 1)
+	assert.Equal(t, 1, activityRepo.events[0].UserID)
+	assert.Equal(t, models.ActivityTypeTransfer, activityRepo.events[0].ActivityType)
+	assert.Equal(t, int64(7), *activityRepo.events[0].ReferenceID)
+
+	// Transfers sent from pseudonymous wallets have no user to record
+	service.TransferCompleted(&models.Transfer{ID: 8, SenderWalletID: 2, ReceiverWalletID: 1})
+	assert.Len(t, activityRepo.events, 1)
+}
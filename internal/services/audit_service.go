@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+
+	// auditExportBatch is how many entries an export reads at a time
+	auditExportBatch = 1000
+)
+
+// AuditService writes the audit log for the other services and lets admins read it
+type AuditService struct {
+	auditRepo repository.AuditRepository
+}
+
+// NewAuditService creates a new AuditService.
+func NewAuditService(auditRepo repository.AuditRepository) *AuditService {
+	return &AuditService{auditRepo: auditRepo}
+}
+
+// Record appends a change made by actor to the audit log. The state before and after the
+// change is stored as JSON, nil for a target that did not exist before or no longer exists
+// after, along with the fields that changed. The change was already made, so a failure to
+// record it is logged rather than returned.
+func (s *AuditService) Record(actor models.Actor, action, targetType, targetID string, before, after interface{}) {
+	entry := &models.AuditEntry{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  actor.IPAddress,
+		UserAgent:  actor.UserAgent,
+		RequestID:  actor.RequestID,
+	}
+	if actor.UserID != 0 {
+		actorID := actor.UserID
+		entry.ActorID = &actorID
+	}
+
+	var err error
+	if entry.Before, err = marshalAuditState(before); err == nil {
+		entry.After, err = marshalAuditState(after)
+	}
+	if err == nil {
+		entry.Diff, err = diffAuditStates(entry.Before, entry.After)
+	}
+	if err == nil {
+		err = s.auditRepo.Record(entry)
+	}
+	if err != nil {
+		log.Printf("Failed to audit %s of %s %s: %v", action, targetType, targetID, err)
+	}
+}
+
+// Find returns a page of the entries matching filter, newest first, and the ID to pass as
+// filter.BeforeID for the next page, 0 when there is none
+func (s *AuditService) Find(filter models.AuditFilter) ([]*models.AuditEntry, int64, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+
+	// Fetch one extra entry to know whether another page follows
+	limit := filter.Limit
+	filter.Limit++
+	entries, err := s.auditRepo.Find(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	var nextBeforeID int64
+	if len(entries) > limit {
+		entries = entries[:limit]
+		nextBeforeID = entries[limit-1].ID
+	}
+	return entries, nextBeforeID, nil
+}
+
+// Export writes every entry matching filter to w as JSON lines, newest first. The limit of
+// the filter is ignored.
+func (s *AuditService) Export(filter models.AuditFilter, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	filter.Limit = auditExportBatch
+	for {
+		entries, err := s.auditRepo.Find(filter)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		if len(entries) < auditExportBatch {
+			return nil
+		}
+		filter.BeforeID = entries[len(entries)-1].ID
+	}
+}
+
+func marshalAuditState(state interface{}) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+// auditChange is the value of a field before and after a change
+type auditChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// diffAuditStates lists the top-level fields that differ between two states, which are JSON
+// objects or nil. States of any other shape have no diff.
+func diffAuditStates(before, after json.RawMessage) (json.RawMessage, error) {
+	var beforeFields, afterFields map[string]json.RawMessage
+	if before != nil && json.Unmarshal(before, &beforeFields) != nil {
+		return nil, nil
+	}
+	if after != nil && json.Unmarshal(after, &afterFields) != nil {
+		return nil, nil
+	}
+
+	changes := make(map[string]auditChange)
+	for field, value := range beforeFields {
+		if !bytes.Equal(value, afterFields[field]) {
+			changes[field] = auditChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			changes[field] = auditChange{After: value}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(changes)
+}
@@ -0,0 +1,135 @@
+package services_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRecord(t *testing.T) {
+	auditRepo := &mockAuditRepo{}
+	service := services.NewAuditService(auditRepo)
+	actor := models.Actor{UserID: 7, IPAddress: "203.0.113.7", UserAgent: "test-agent", RequestID: "req-1"}
+
+	type state struct {
+		Name   string `json:"name"`
+		Points int    `json:"points"`
+		Active bool   `json:"active"`
+	}
+
+	t.Run("Changes keep the request and the fields that changed", func(t *testing.T) {
+		service.Record(actor, models.AuditActionBadgeUpdate, "badge", "3",
+			state{Name: "Helper", Points: 10, Active: true}, state{Name: "Helper", Points: 20, Active: false})
+
+		require.Len(t, auditRepo.entries, 1)
+		entry := auditRepo.entries[0]
+		assert.Equal(t, 7, *entry.ActorID)
+		assert.Equal(t, "203.0.113.7", entry.IPAddress)
+		assert.Equal(t, "test-agent", entry.UserAgent)
+		assert.Equal(t, "req-1", entry.RequestID)
+		assert.JSONEq(t, `{"points":{"before":10,"after":20},"active":{"before":true,"after":false}}`, string(entry.Diff))
+	})
+
+	t.Run("Created targets diff every field", func(t *testing.T) {
+		service.Record(models.Actor{}, models.AuditActionBadgeCreate, "badge", "4", nil, state{Name: "Giver", Points: 5})
+
+		entry := auditRepo.entries[1]
+		assert.Nil(t, entry.ActorID)
+		assert.Nil(t, entry.Before)
+		assert.JSONEq(t, `{"name":{"before":null,"after":"Giver"},"points":{"before":null,"after":5},"active":{"before":null,"after":false}}`, string(entry.Diff))
+	})
+
+	t.Run("PINs are never recorded", func(t *testing.T) {
+		userService := services.NewUserService(newMockUserRepo(), &mockRoleRepo{}, service)
+		require.NoError(t, userService.SetPin(actor, 1, "1234"))
+
+		entry := auditRepo.entries[2]
+		assert.Equal(t, models.AuditActionUserPinChange, entry.Action)
+		assert.Equal(t, "1", entry.TargetID)
+		assert.JSONEq(t, `{"pin_set":{"before":false,"after":true}}`, string(entry.Diff))
+		assert.NotContains(t, string(entry.After), "1234")
+	})
+}
+
+func TestAuditQuery(t *testing.T) {
+	auditRepo := &mockAuditRepo{}
+	service := services.NewAuditService(auditRepo)
+	for i := 0; i < 1500; i++ {
+		action := models.AuditActionTransferCreate
+		if i%3 == 0 {
+			action = models.AuditActionBadgeAward
+		}
+		service.Record(models.Actor{UserID: 1 + i%2}, action, "user", "1", nil, nil)
+	}
+
+	t.Run("Pages are filtered and newest first", func(t *testing.T) {
+		actorID := 2
+		entries, nextBeforeID, err := service.Find(models.AuditFilter{ActorID: &actorID, Action: models.AuditActionBadgeAward, Limit: 100})
+		require.NoError(t, err)
+		require.Len(t, entries, 100)
+		assert.Equal(t, entries[99].ID, nextBeforeID)
+		for i, entry := range entries {
+			assert.Equal(t, 2, *entry.ActorID)
+			assert.Equal(t, models.AuditActionBadgeAward, entry.Action)
+			if i > 0 {
+				assert.Less(t, entry.ID, entries[i-1].ID)
+			}
+		}
+
+		// 250 awards were made by user 2
+		entries, nextBeforeID, err = service.Find(models.AuditFilter{ActorID: &actorID, Action: models.AuditActionBadgeAward, BeforeID: nextBeforeID, Limit: 200})
+		require.NoError(t, err)
+		assert.Len(t, entries, 150)
+		assert.Zero(t, nextBeforeID)
+	})
+
+	t.Run("Exports every matching entry as JSON lines", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, service.Export(models.AuditFilter{Action: models.AuditActionTransferCreate, Limit: 10}, &out))
+
+		lines := 0
+		scanner := bufio.NewScanner(&out)
+		for scanner.Scan() {
+			var entry models.AuditEntry
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+			assert.Equal(t, models.AuditActionTransferCreate, entry.Action)
+			lines++
+		}
+		assert.Equal(t, 1000, lines)
+	})
+}
+
+// mockAuditRepo keeps the audit log in memory, oldest entry first
+type mockAuditRepo struct {
+	entries []*models.AuditEntry
+}
+
+func (m *mockAuditRepo) Record(entry *models.AuditEntry) error {
+	entry.ID = int64(len(m.entries) + 1)
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func (m *mockAuditRepo) Find(filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	var entries []*models.AuditEntry
+	for i := len(m.entries) - 1; i >= 0 && len(entries) < filter.Limit; i-- {
+		entry := m.entries[i]
+		switch {
+		case filter.BeforeID > 0 && entry.ID >= filter.BeforeID,
+			filter.ActorID != nil && (entry.ActorID == nil || *entry.ActorID != *filter.ActorID),
+			filter.Action != "" && entry.Action != filter.Action,
+			filter.TargetType != "" && entry.TargetType != filter.TargetType,
+			filter.TargetID != "" && entry.TargetID != filter.TargetID,
+			filter.RequestID != "" && entry.RequestID != filter.RequestID:
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
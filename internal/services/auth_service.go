@@ -3,48 +3,52 @@ package services
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"verve/internal/auth"
 	"verve/internal/models"
 	"verve/internal/repository"
 )
 
 type AuthService struct {
-	userRepo repository.UserRepository
+	userRepo       repository.UserRepository
+	sessionService *SessionService
+	auditService   *AuditService
 }
 
-func NewAuthService(userRepo repository.UserRepository) *AuthService {
+func NewAuthService(userRepo repository.UserRepository, sessionService *SessionService, auditService *AuditService) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
+		userRepo:       userRepo,
+		sessionService: sessionService,
+		auditService:   auditService,
 	}
 }
 
-// AuthenticateLocal handles username/password authentication
-func (s *AuthService) AuthenticateLocal(username, password string) (*models.User, string, error) {
+// AuthenticateLocal handles username/password authentication and starts a session
+func (s *AuthService) AuthenticateLocal(username, password, userAgent, ipAddress string) (*models.User, *models.SessionTokens, error) {
 	user, err := s.userRepo.FindByUsername(username)
 	if err != nil {
-		return nil, "", errors.New("invalid credentials")
+		return nil, nil, errors.New("invalid credentials")
 	}
 
 	// Only allow local auth for users with local provider
 	if user.Provider != "" && user.Provider != "local" {
-		return nil, "", errors.New("this account uses " + user.Provider + " authentication")
+		return nil, nil, errors.New("this account uses " + user.Provider + " authentication")
 	}
 
 	if !auth.ValidatePassword(password, user.PasswordHash) {
-		return nil, "", errors.New("invalid credentials")
+		return nil, nil, errors.New("invalid credentials")
 	}
 
-	// Generate JWT token
-	token, err := auth.GenerateJWT(user.ID, user.Roles)
+	tokens, err := s.sessionService.StartSession(user.ID, user.Roles, userAgent, ipAddress)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return user, token, nil
+	return user, tokens, nil
 }
 
-// AuthenticateOAuth handles OAuth authentication
-func (s *AuthService) AuthenticateOAuth(userInfo *auth.OAuthUserInfo) (*models.User, string, error) {
+// AuthenticateOAuth handles OAuth authentication and starts a session
+func (s *AuthService) AuthenticateOAuth(userInfo *auth.OAuthUserInfo, userAgent, ipAddress string) (*models.User, *models.SessionTokens, error) {
 	// Debug log
 	fmt.Printf("AuthenticateOAuth: userInfo: %+v\n", userInfo)
 
@@ -65,7 +69,7 @@ func (s *AuthService) AuthenticateOAuth(userInfo *auth.OAuthUserInfo) (*models.U
 
 		_, err = s.userRepo.Create(user, "", "") // No password/pin for OAuth users
 		if err != nil {
-			return nil, "", err
+			return nil, nil, err
 		}
 	} else {
 		// Update existing user's OAuth info if needed
@@ -86,18 +90,17 @@ func (s *AuthService) AuthenticateOAuth(userInfo *auth.OAuthUserInfo) (*models.U
 		if needsUpdate {
 			err = s.userRepo.Update(user)
 			if err != nil {
-				return nil, "", err
+				return nil, nil, err
 			}
 		}
 	}
 
-	// Generate JWT token
-	token, err := auth.GenerateJWT(user.ID, user.Roles)
+	tokens, err := s.sessionService.StartSession(user.ID, user.Roles, userAgent, ipAddress)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return user, token, nil
+	return user, tokens, nil
 }
 
 // CreateAdminUser creates a new admin user with local authentication
@@ -125,7 +128,7 @@ func (s *AuthService) CreateAdminUser(username, email, password string) (*models
 }
 
 // LinkOAuthToLocal links an OAuth account to an existing local account
-func (s *AuthService) LinkOAuthToLocal(userID int, oauthProvider, oauthUserID string) error {
+func (s *AuthService) LinkOAuthToLocal(actor models.Actor, userID int, oauthProvider, oauthUserID string) error {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil {
 		return err
@@ -141,13 +144,19 @@ func (s *AuthService) LinkOAuthToLocal(userID int, oauthProvider, oauthUserID st
 		return errors.New("oauth account already linked to another user")
 	}
 
+	before := *user
 	user.Provider = oauthProvider
 	user.ProviderUserID = oauthUserID
-	return s.userRepo.Update(user)
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	s.auditService.Record(actor, models.AuditActionUserOAuthLink, "user", strconv.Itoa(userID), before, user)
+	return nil
 }
 
 // UnlinkOAuth removes OAuth provider from a user account
-func (s *AuthService) UnlinkOAuth(userID int) error {
+func (s *AuthService) UnlinkOAuth(actor models.Actor, userID int) error {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil {
 		return err
@@ -158,7 +167,13 @@ func (s *AuthService) UnlinkOAuth(userID int) error {
 		return errors.New("cannot unlink OAuth without setting a password first")
 	}
 
+	before := *user
 	user.Provider = "local"
 	user.ProviderUserID = ""
-	return s.userRepo.Update(user)
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	s.auditService.Record(actor, models.AuditActionUserOAuthUnlink, "user", strconv.Itoa(userID), before, user)
+	return nil
 }
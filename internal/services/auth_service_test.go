@@ -0,0 +1,38 @@
+package services_test
+
+import (
+	"testing"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthLinkAudit(t *testing.T) {
+	userRepo := newMockUserRepo()
+	userRepo.users[1].Provider = "local"
+	userRepo.users[1].PasswordHash = "hash"
+	auditRepo := &mockAuditRepo{}
+	service := services.NewAuthService(userRepo, nil, services.NewAuditService(auditRepo))
+	actor := models.Actor{UserID: 1, RequestID: "req-1"}
+
+	require.NoError(t, service.LinkOAuthToLocal(actor, 1, "google", "g-42"))
+	require.NoError(t, service.UnlinkOAuth(actor, 1))
+	assert.Equal(t, "local", userRepo.users[1].Provider)
+
+	require.Len(t, auditRepo.entries, 2)
+	for i, action := range []string{models.AuditActionUserOAuthLink, models.AuditActionUserOAuthUnlink} {
+		entry := auditRepo.entries[i]
+		assert.Equal(t, action, entry.Action)
+		assert.Equal(t, "1", entry.TargetID)
+		assert.Equal(t, 1, *entry.ActorID)
+		assert.Equal(t, "req-1", entry.RequestID)
+		assert.Contains(t, string(entry.Diff), "google")
+	}
+
+	// A user without a password keeps the provider, and nothing is recorded
+	userRepo.users[2].Provider = "google"
+	assert.Error(t, service.UnlinkOAuth(models.Actor{UserID: 2}, 2))
+	assert.Len(t, auditRepo.entries, 2)
+}
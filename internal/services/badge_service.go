@@ -1,16 +1,29 @@
 package services
 
 import (
-	"encoding/json"
+	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
 	"verve/internal/models"
 	"verve/internal/repository"
 )
 
+var (
+	ErrBadgeNotHeld         = errors.New("user does not hold this badge")
+	ErrInvalidBadgeLevel    = errors.New("level must be bronze, silver or gold")
+	ErrInvalidBadgeExpiry   = errors.New("expiry must be in the future")
+	ErrRevokeReasonRequired = errors.New("a reason is required to revoke a badge")
+)
+
 type BadgeService struct {
 	badgeRepo     repository.BadgeRepository
 	ruleRepo      repository.AchievementRuleRepository
 	userBadgeRepo repository.UserBadgeRepository
+	auditService  *AuditService
 }
 
 // GetBadgeHolders returns all users who have been awarded a specific badge
@@ -22,19 +35,21 @@ func NewBadgeService(
 	badgeRepo repository.BadgeRepository,
 	ruleRepo repository.AchievementRuleRepository,
 	userBadgeRepo repository.UserBadgeRepository,
+	auditService *AuditService,
 ) *BadgeService {
 	return &BadgeService{
 		badgeRepo:     badgeRepo,
 		ruleRepo:      ruleRepo,
 		userBadgeRepo: userBadgeRepo,
+		auditService:  auditService,
 	}
 }
 
 // CreateBadge creates a new badge with optional achievement rules
 func (s *BadgeService) CreateBadge(
+	actor models.Actor,
 	name, description, iconURL string,
 	points int,
-	createdBy int,
 	rules []models.AchievementRule,
 ) (*models.Badge, error) {
 	badge := &models.Badge{
@@ -42,7 +57,7 @@ func (s *BadgeService) CreateBadge(
 		Description: description,
 		IconURL:     iconURL,
 		Points:      points,
-		CreatedBy:   createdBy,
+		CreatedBy:   actor.UserID,
 		IsActive:    true,
 	}
 
@@ -53,18 +68,20 @@ func (s *BadgeService) CreateBadge(
 	// Create achievement rules if provided
 	for i := range rules {
 		rules[i].BadgeID = badge.ID
-		rules[i].CreatedBy = createdBy
+		rules[i].CreatedBy = actor.UserID
 		rules[i].IsActive = true
 		if err := s.ruleRepo.Create(&rules[i]); err != nil {
 			return nil, err
 		}
 	}
 
+	s.auditService.Record(actor, models.AuditActionBadgeCreate, "badge", strconv.Itoa(badge.ID), nil, badge)
 	return badge, nil
 }
 
 // UpdateBadge updates an existing badge and its rules
 func (s *BadgeService) UpdateBadge(
+	actor models.Actor,
 	id int,
 	name, description, iconURL *string,
 	points *int,
@@ -74,6 +91,7 @@ func (s *BadgeService) UpdateBadge(
 	if err != nil {
 		return nil, err
 	}
+	before := *badge
 
 	if name != nil {
 		badge.Name = *name
@@ -95,6 +113,7 @@ func (s *BadgeService) UpdateBadge(
 		return nil, err
 	}
 
+	s.auditService.Record(actor, models.AuditActionBadgeUpdate, "badge", strconv.Itoa(id), before, badge)
 	return badge, nil
 }
 
@@ -118,8 +137,24 @@ func (s *BadgeService) ListBadges(includeInactive bool) ([]*models.Badge, error)
 	return s.badgeRepo.FindAll(includeInactive)
 }
 
-// AwardBadge manually awards a badge to a user
-func (s *BadgeService) AwardBadge(userID, badgeID int, awardedBy *int) error {
+// badgeAwardState is a badge a user holds as recorded in the audit log
+type badgeAwardState struct {
+	BadgeID      int        `json:"badge_id"`
+	Level        string     `json:"level,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	RevokeReason string     `json:"revoke_reason,omitempty"`
+}
+
+// AwardBadge manually awards a badge to a user, optionally at a level and until an expiry.
+// Awarding a higher level of a badge the user holds upgrades the award.
+func (s *BadgeService) AwardBadge(actor models.Actor, userID, badgeID int, level string, expiresAt *time.Time) error {
+	if models.BadgeLevelRank(level) < 0 {
+		return ErrInvalidBadgeLevel
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return ErrInvalidBadgeExpiry
+	}
+
 	// Check if badge exists and is active
 	badge, err := s.badgeRepo.FindByID(badgeID)
 	if err != nil {
@@ -129,62 +164,126 @@ func (s *BadgeService) AwardBadge(userID, badgeID int, awardedBy *int) error {
 		return errors.New("badge is not active")
 	}
 
+	var awardedBy *int
+	if actor.UserID != 0 {
+		awardedBy = &actor.UserID
+	}
+
 	// Check if user already has the badge
-	hasBadge, err := s.userBadgeRepo.HasBadge(userID, badgeID)
-	if err != nil {
+	held, err := s.userBadgeRepo.FindHeld(userID, badgeID)
+	if err != nil && err != sql.ErrNoRows {
 		return err
 	}
-	if hasBadge {
-		return errors.New("user already has this badge")
+	if held != nil {
+		if models.BadgeLevelRank(level) <= models.BadgeLevelRank(held.Level) {
+			return repository.ErrBadgeAlreadyAwarded
+		}
+		err := s.userBadgeRepo.UpgradeLevel(held.ID, held.Level, level, awardedBy)
+		if err == sql.ErrNoRows {
+			return repository.ErrBadgeAlreadyAwarded
+		}
+		if err != nil {
+			return err
+		}
+		s.auditService.Record(actor, models.AuditActionBadgeAward, "user", strconv.Itoa(userID),
+			badgeAwardState{BadgeID: badgeID, Level: held.Level, ExpiresAt: held.ExpiresAt},
+			badgeAwardState{BadgeID: badgeID, Level: level, ExpiresAt: held.ExpiresAt})
+		return nil
 	}
 
 	userBadge := &models.UserBadge{
 		UserID:    userID,
 		BadgeID:   badgeID,
+		Level:     level,
 		AwardedBy: awardedBy,
+		ExpiresAt: expiresAt,
 	}
 
-	return s.userBadgeRepo.Award(userBadge)
+	if err := s.userBadgeRepo.Award(userBadge); err != nil {
+		return err
+	}
+	s.auditService.Record(actor, models.AuditActionBadgeAward, "user", strconv.Itoa(userID),
+		nil, badgeAwardState{BadgeID: badgeID, Level: level, ExpiresAt: expiresAt})
+	return nil
 }
 
-// GetUserBadges retrieves all badges awarded to a user
+// RevokeBadge revokes a badge a user holds. The reason is kept in the user's badge history.
+// Evaluating the achievement rules does not award the badge again, but an admin may.
+func (s *BadgeService) RevokeBadge(actor models.Actor, userID, badgeID int, reason string) error {
+	if reason == "" {
+		return ErrRevokeReasonRequired
+	}
+
+	held, err := s.userBadgeRepo.FindHeld(userID, badgeID)
+	if err == sql.ErrNoRows {
+		return ErrBadgeNotHeld
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.userBadgeRepo.Revoke(held.ID, actor.UserID, reason)
+	if err == sql.ErrNoRows {
+		return ErrBadgeNotHeld
+	}
+	if err != nil {
+		return err
+	}
+	s.auditService.Record(actor, models.AuditActionBadgeRevoke, "user", strconv.Itoa(userID),
+		badgeAwardState{BadgeID: badgeID, Level: held.Level, ExpiresAt: held.ExpiresAt},
+		badgeAwardState{BadgeID: badgeID, RevokeReason: reason})
+	return nil
+}
+
+// GetBadgeHistory returns every award, upgrade, revocation and expiry of a user's badges
+func (s *BadgeService) GetBadgeHistory(userID int) ([]*models.UserBadgeEvent, error) {
+	return s.userBadgeRepo.FindHistory(userID)
+}
+
+// RunExpiry closes the awards that expired every interval until ctx is cancelled, so their
+// expiry shows in the badge history. Expired awards are no longer held even before.
+func (s *BadgeService) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := s.userBadgeRepo.ExpireDue(time.Now())
+			if err != nil {
+				log.Printf("Failed to expire badge awards: %v", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("Expired %d badge awards", expired)
+			}
+		}
+	}
+}
+
+// GetUserBadges retrieves the badges a user holds
 func (s *BadgeService) GetUserBadges(userID int) ([]*models.UserBadge, error) {
 	return s.userBadgeRepo.FindByUserID(userID)
 }
 
-// ValidateAchievementRule validates the rule condition format based on rule type
+// ValidateAchievementRule validates the rule condition format based on rule type. Expression
+// rules are validated node by node, and the badges they require must exist.
 func (s *BadgeService) ValidateAchievementRule(rule *models.AchievementRule) error {
-	switch rule.RuleType {
-	case models.RuleTypeTransactionCount:
-		var condition models.TransactionCountCondition
-		if err := json.Unmarshal(rule.ConditionValue, &condition); err != nil {
-			return errors.New("invalid transaction count condition format")
-		}
-		if condition.MinTransactions <= 0 {
-			return errors.New("min_transactions must be positive")
-		}
-
-	case models.RuleTypeTransferAmount:
-		var condition models.TransferAmountCondition
-		if err := json.Unmarshal(rule.ConditionValue, &condition); err != nil {
-			return errors.New("invalid transfer amount condition format")
-		}
-		if condition.MinAmount <= 0 {
-			return errors.New("min_amount must be positive")
-		}
+	expr, err := parseRule(rule)
+	if err != nil {
+		return err
+	}
 
-	case models.RuleTypeConsecutiveDays:
-		var condition models.ConsecutiveDaysCondition
-		if err := json.Unmarshal(rule.ConditionValue, &condition); err != nil {
-			return errors.New("invalid consecutive days condition format")
+	for _, badgeID := range referencedBadges(expr) {
+		_, err := s.badgeRepo.FindByID(badgeID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("badge %d does not exist", badgeID)
 		}
-		if condition.Days <= 0 {
-			return errors.New("days must be positive")
+		if err != nil {
+			return err
 		}
-
-	default:
-		return errors.New("unsupported rule type")
 	}
-
 	return nil
 }
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+const (
+	// eventBatchSize is how many due events a dispatch claims at a time
+	eventBatchSize = 100
+
+	// eventLease is how long a claimed event is kept from other dispatchers. Subscribers
+	// handling an event for longer may see it delivered twice.
+	eventLease = 5 * time.Minute
+)
+
+var ErrEventNotDead = errors.New("event is not dead")
+
+// EventHandler handles an event delivered to a subscriber. Events are delivered at least
+// once, so handlers must tolerate duplicates; returning an error has the event retried.
+type EventHandler func(event *models.OutboxEvent) error
+
+type eventSubscription struct {
+	name       string
+	eventTypes map[string]bool // Empty for every type
+	handler    EventHandler
+}
+
+// EventDispatcher delivers the events of the outbox to the subscribers registered at startup.
+// An event is retried with exponential backoff until every subscriber handled it, and is
+// dead after maxAttempts failed attempts. Subscribers that handled an event are not called
+// again when it is retried for the others.
+type EventDispatcher struct {
+	outboxRepo    repository.OutboxRepository
+	subscriptions []eventSubscription
+	maxAttempts   int
+	retryDelay    time.Duration
+}
+
+// NewEventDispatcher creates a new EventDispatcher. The first retry waits retryDelay, and
+// each later one twice as long as the one before.
+func NewEventDispatcher(outboxRepo repository.OutboxRepository, maxAttempts int, retryDelay time.Duration) *EventDispatcher {
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	return &EventDispatcher{
+		outboxRepo:  outboxRepo,
+		maxAttempts: maxAttempts,
+		retryDelay:  retryDelay,
+	}
+}
+
+// Subscribe registers a handler for events of the given types, or of every type when none
+// is given. The name identifies the subscriber across restarts and must be unique.
+func (d *EventDispatcher) Subscribe(name string, handler EventHandler, eventTypes ...string) {
+	subscription := eventSubscription{name: name, eventTypes: make(map[string]bool), handler: handler}
+	for _, eventType := range eventTypes {
+		subscription.eventTypes[eventType] = true
+	}
+	d.subscriptions = append(d.subscriptions, subscription)
+}
+
+// DispatchDue delivers the events that are due and returns how many it claimed
+func (d *EventDispatcher) DispatchDue() (int, error) {
+	now := time.Now()
+	events, err := d.outboxRepo.Claim(now, eventLease, eventBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, event := range events {
+		if err := d.dispatch(event, now); err != nil {
+			return 0, err
+		}
+	}
+	return len(events), nil
+}
+
+// dispatch delivers an event to the subscribers that did not handle it yet, and settles it
+func (d *EventDispatcher) dispatch(event *models.OutboxEvent, now time.Time) error {
+	delivered := make(map[string]bool)
+	for _, name := range event.DeliveredTo {
+		delivered[name] = true
+	}
+
+	var failures []string
+	for _, subscription := range d.subscriptions {
+		if delivered[subscription.name] {
+			continue
+		}
+		if len(subscription.eventTypes) > 0 && !subscription.eventTypes[event.EventType] {
+			continue
+		}
+		if err := callHandler(subscription.handler, event); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", subscription.name, err))
+			continue
+		}
+		if err := d.outboxRepo.MarkDelivered(event.ID, subscription.name); err != nil {
+			return err
+		}
+	}
+
+	if len(failures) == 0 {
+		return d.outboxRepo.MarkProcessed(event.ID)
+	}
+
+	attempts := event.Attempts + 1
+	lastError := strings.Join(failures, "; ")
+	if attempts >= d.maxAttempts {
+		log.Printf("Event %d (%s) is dead after %d attempts: %s", event.ID, event.EventType, attempts, lastError)
+		return d.outboxRepo.MarkDead(event.ID, attempts, lastError)
+	}
+	return d.outboxRepo.Reschedule(event.ID, attempts, now.Add(d.retryDelay<<(attempts-1)), lastError)
+}
+
+// callHandler turns a panicking handler into a failed delivery
+func callHandler(handler EventHandler, event *models.OutboxEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(event)
+}
+
+// ListDeadEvents returns the most recent events that are dead
+func (d *EventDispatcher) ListDeadEvents(limit int) ([]*models.OutboxEvent, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return d.outboxRepo.FindDead(limit)
+}
+
+// RetryDeadEvent makes a dead event pending again with a fresh set of attempts
+func (d *EventDispatcher) RetryDeadEvent(eventID int64) error {
+	err := d.outboxRepo.Requeue(eventID)
+	if err == sql.ErrNoRows {
+		return ErrEventNotDead
+	}
+	return err
+}
+
+// Run delivers due events every interval until ctx is cancelled. Batches are dispatched back
+// to back while the outbox has a backlog.
+func (d *EventDispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				claimed, err := d.DispatchDue()
+				if err != nil {
+					log.Printf("Failed to dispatch events: %v", err)
+				}
+				if err != nil || claimed < eventBatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
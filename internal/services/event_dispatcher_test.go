@@ -0,0 +1,211 @@
+package services_test
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventDispatcher(t *testing.T) {
+	newOutbox := func(eventTypes ...string) *mockOutboxRepo {
+		outbox := &mockOutboxRepo{events: make(map[int64]*models.OutboxEvent)}
+		for _, eventType := range eventTypes {
+			outbox.add(eventType)
+		}
+		return outbox
+	}
+
+	t.Run("Subscribers only receive the types they asked for", func(t *testing.T) {
+		outbox := newOutbox(models.EventTypeTransferCompleted, models.EventTypeBadgeAwarded)
+		dispatcher := services.NewEventDispatcher(outbox, 3, time.Second)
+
+		var transfers, all []string
+		dispatcher.Subscribe("transfers", func(event *models.OutboxEvent) error {
+			transfers = append(transfers, event.EventType)
+			return nil
+		}, models.EventTypeTransferCompleted)
+		dispatcher.Subscribe("all", func(event *models.OutboxEvent) error {
+			all = append(all, event.EventType)
+			return nil
+		})
+
+		claimed, err := dispatcher.DispatchDue()
+		require.NoError(t, err)
+		assert.Equal(t, 2, claimed)
+		assert.Equal(t, []string{models.EventTypeTransferCompleted}, transfers)
+		assert.Equal(t, []string{models.EventTypeTransferCompleted, models.EventTypeBadgeAwarded}, all)
+		for _, event := range outbox.events {
+			assert.Equal(t, models.OutboxStatusProcessed, event.Status)
+		}
+	})
+
+	t.Run("Retries back off and skip subscribers that handled the event", func(t *testing.T) {
+		outbox := newOutbox(models.EventTypeUserCreated)
+		dispatcher := services.NewEventDispatcher(outbox, 5, time.Minute)
+
+		okCalls, failingCalls := 0, 0
+		dispatcher.Subscribe("ok", func(event *models.OutboxEvent) error {
+			okCalls++
+			return nil
+		})
+		dispatcher.Subscribe("failing", func(event *models.OutboxEvent) error {
+			failingCalls++
+			if failingCalls < 3 {
+				return errors.New("unavailable")
+			}
+			return nil
+		})
+
+		start := time.Now()
+		_, err := dispatcher.DispatchDue()
+		require.NoError(t, err)
+		event := outbox.events[1]
+		assert.Equal(t, models.OutboxStatusPending, event.Status)
+		assert.Equal(t, 1, event.Attempts)
+		assert.Equal(t, "failing: unavailable", event.LastError)
+		assert.WithinDuration(t, start.Add(time.Minute), event.NextAttemptAt, time.Second)
+
+		outbox.makeDue()
+		_, err = dispatcher.DispatchDue()
+		require.NoError(t, err)
+		assert.Equal(t, 2, event.Attempts)
+		assert.WithinDuration(t, start.Add(2*time.Minute), event.NextAttemptAt, time.Second)
+
+		outbox.makeDue()
+		_, err = dispatcher.DispatchDue()
+		require.NoError(t, err)
+		assert.Equal(t, models.OutboxStatusProcessed, event.Status)
+		assert.Equal(t, 1, okCalls)
+		assert.Equal(t, 3, failingCalls)
+	})
+
+	t.Run("Events are dead after the last attempt and can be retried", func(t *testing.T) {
+		outbox := newOutbox(models.EventTypeWalletCreated)
+		dispatcher := services.NewEventDispatcher(outbox, 2, time.Second)
+		dispatcher.Subscribe("panicking", func(event *models.OutboxEvent) error {
+			panic("boom")
+		})
+
+		for i := 0; i < 2; i++ {
+			outbox.makeDue()
+			_, err := dispatcher.DispatchDue()
+			require.NoError(t, err)
+		}
+		event := outbox.events[1]
+		assert.Equal(t, models.OutboxStatusDead, event.Status)
+		assert.Equal(t, "panicking: panic: boom", event.LastError)
+
+		outbox.makeDue()
+		claimed, err := dispatcher.DispatchDue()
+		require.NoError(t, err)
+		assert.Zero(t, claimed)
+
+		dead, err := dispatcher.ListDeadEvents(0)
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, event.ID, dead[0].ID)
+
+		require.NoError(t, dispatcher.RetryDeadEvent(event.ID))
+		assert.Equal(t, models.OutboxStatusPending, event.Status)
+		assert.Zero(t, event.Attempts)
+		assert.ErrorIs(t, dispatcher.RetryDeadEvent(event.ID), services.ErrEventNotDead)
+		assert.ErrorIs(t, dispatcher.RetryDeadEvent(42), services.ErrEventNotDead)
+	})
+}
+
+// mockOutboxRepo keeps the outbox in memory
+type mockOutboxRepo struct {
+	events map[int64]*models.OutboxEvent
+	nextID int64
+}
+
+func (m *mockOutboxRepo) add(eventType string) {
+	m.nextID++
+	m.events[m.nextID] = &models.OutboxEvent{
+		ID:            m.nextID,
+		EventType:     eventType,
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+}
+
+// makeDue ends the leases and backoffs of the pending events
+func (m *mockOutboxRepo) makeDue() {
+	for _, event := range m.events {
+		event.NextAttemptAt = time.Now().Add(-time.Second)
+	}
+}
+
+func (m *mockOutboxRepo) Claim(now time.Time, lease time.Duration, limit int) ([]*models.OutboxEvent, error) {
+	var events []*models.OutboxEvent
+	for _, event := range m.events {
+		if event.Status == models.OutboxStatusPending && !event.NextAttemptAt.After(now) {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	for _, event := range events {
+		event.NextAttemptAt = now.Add(lease)
+	}
+	return events, nil
+}
+
+func (m *mockOutboxRepo) MarkDelivered(eventID int64, subscriber string) error {
+	m.events[eventID].DeliveredTo = append(m.events[eventID].DeliveredTo, subscriber)
+	return nil
+}
+
+func (m *mockOutboxRepo) MarkProcessed(eventID int64) error {
+	now := time.Now()
+	m.events[eventID].Status = models.OutboxStatusProcessed
+	m.events[eventID].ProcessedAt = &now
+	return nil
+}
+
+func (m *mockOutboxRepo) Reschedule(eventID int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	event := m.events[eventID]
+	event.Attempts = attempts
+	event.NextAttemptAt = nextAttemptAt
+	event.LastError = lastError
+	return nil
+}
+
+func (m *mockOutboxRepo) MarkDead(eventID int64, attempts int, lastError string) error {
+	event := m.events[eventID]
+	event.Status = models.OutboxStatusDead
+	event.Attempts = attempts
+	event.LastError = lastError
+	return nil
+}
+
+func (m *mockOutboxRepo) FindDead(limit int) ([]*models.OutboxEvent, error) {
+	var events []*models.OutboxEvent
+	for _, event := range m.events {
+		if event.Status == models.OutboxStatusDead && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (m *mockOutboxRepo) Requeue(eventID int64) error {
+	event, ok := m.events[eventID]
+	if !ok || event.Status != models.OutboxStatusDead {
+		return sql.ErrNoRows
+	}
+	event.Status = models.OutboxStatusPending
+	event.Attempts = 0
+	event.NextAttemptAt = time.Now()
+	return nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+const defaultIdempotencyTTL = 24 * time.Hour
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// IdempotencyService deduplicates retried requests that carry the same Idempotency-Key.
+type IdempotencyService struct {
+	repo repository.IdempotencyRepository
+	ttl  time.Duration
+}
+
+// NewIdempotencyService creates a new IdempotencyService. Keys expire after ttl, or after 24h when ttl is zero.
+func NewIdempotencyService(repo repository.IdempotencyRepository, ttl time.Duration) *IdempotencyService {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	return &IdempotencyService{repo: repo, ttl: ttl}
+}
+
+// Fingerprint identifies a request by its method, path and body.
+func (s *IdempotencyService) Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin claims a key for a request. It returns the stored record when the same
+// request already completed, so the caller can replay its response, and nil when
+// the request should be executed.
+func (s *IdempotencyService) Begin(userID int, key, fingerprint string) (*models.IdempotencyRecord, error) {
+	existing, err := s.repo.Reserve(&models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: fingerprint,
+		ExpiresAt:   time.Now().Add(s.ttl),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, nil
+	}
+
+	if existing.RequestHash != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if existing.Status != models.IdempotencyStatusCompleted {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	return existing, nil
+}
+
+// Complete stores the response of a request so later retries can replay it.
+func (s *IdempotencyService) Complete(userID int, key string, responseStatus int, responseBody []byte) error {
+	return s.repo.Complete(userID, key, responseStatus, responseBody)
+}
+
+// Release frees a key whose request did not produce a response worth replaying.
+func (s *IdempotencyService) Release(userID int, key string) error {
+	return s.repo.Delete(userID, key)
+}
+
+// RunExpiryPurge deletes expired keys every interval until ctx is cancelled.
+func (s *IdempotencyService) RunExpiryPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.repo.DeleteExpired(); err != nil {
+				log.Printf("Failed to purge expired idempotency keys: %v", err)
+			}
+		}
+	}
+}
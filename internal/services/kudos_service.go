@@ -0,0 +1,168 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode/utf8"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+const (
+	defaultKudosLimit = 20
+	maxKudosLimit     = 100
+
+	defaultKudosCommentLimit = 50
+	maxKudosCommentLimit     = 200
+
+	maxKudosCommentLength = 1000
+)
+
+var (
+	ErrInvalidReaction = errors.New("reaction must be one of clap, heart, fire, party or thumbsup")
+	ErrEmptyComment    = errors.New("comment must not be empty")
+	ErrCommentTooLong  = errors.New("comment must be at most 1000 characters")
+	ErrKudosNotFound   = errors.New("no public transfer with this ID")
+	ErrCommentNotOwned = errors.New("comment not found or not written by you")
+)
+
+// KudosService serves the kudos feed of public transfers, and the reactions and comments
+// users leave on them
+type KudosService struct {
+	kudosRepo repository.KudosRepository
+}
+
+// NewKudosService creates a new KudosService.
+func NewKudosService(kudosRepo repository.KudosRepository) *KudosService {
+	return &KudosService{kudosRepo: kudosRepo}
+}
+
+// Feed returns a page of the kudos matching filter, newest first, and the ID to pass as
+// filter.BeforeID for the next page, 0 when there is none
+func (s *KudosService) Feed(viewerID int, filter models.KudosFilter) ([]*models.Kudos, int64, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultKudosLimit
+	}
+	if filter.Limit > maxKudosLimit {
+		filter.Limit = maxKudosLimit
+	}
+
+	// Fetch one extra kudos to know whether another page follows
+	limit := filter.Limit
+	filter.Limit++
+	kudos, err := s.kudosRepo.FindFeed(viewerID, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	var nextBeforeID int64
+	if len(kudos) > limit {
+		kudos = kudos[:limit]
+		nextBeforeID = kudos[limit-1].ID
+	}
+	return kudos, nextBeforeID, nil
+}
+
+// GetKudos returns the kudos of a public transfer
+func (s *KudosService) GetKudos(viewerID int, transferID int64) (*models.Kudos, error) {
+	kudos, err := s.kudosRepo.FindByTransferID(viewerID, transferID)
+	if err == sql.ErrNoRows {
+		return nil, ErrKudosNotFound
+	}
+	return kudos, err
+}
+
+// React adds a reaction of a user to kudos and returns the kudos with its reactions
+func (s *KudosService) React(userID int, transferID int64, reaction string) (*models.Kudos, error) {
+	if !isKudosReaction(reaction) {
+		return nil, ErrInvalidReaction
+	}
+	if _, err := s.GetKudos(userID, transferID); err != nil {
+		return nil, err
+	}
+	if err := s.kudosRepo.AddReaction(transferID, userID, reaction); err != nil {
+		return nil, err
+	}
+	return s.GetKudos(userID, transferID)
+}
+
+// Unreact removes a reaction of a user from kudos and returns the kudos with its reactions
+func (s *KudosService) Unreact(userID int, transferID int64, reaction string) (*models.Kudos, error) {
+	if !isKudosReaction(reaction) {
+		return nil, ErrInvalidReaction
+	}
+	if _, err := s.GetKudos(userID, transferID); err != nil {
+		return nil, err
+	}
+	if err := s.kudosRepo.RemoveReaction(transferID, userID, reaction); err != nil {
+		return nil, err
+	}
+	return s.GetKudos(userID, transferID)
+}
+
+func isKudosReaction(reaction string) bool {
+	for _, known := range models.KudosReactions {
+		if reaction == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Comment adds a comment of a user to kudos
+func (s *KudosService) Comment(userID int, transferID int64, body string) (*models.KudosComment, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrEmptyComment
+	}
+	if utf8.RuneCountInString(body) > maxKudosCommentLength {
+		return nil, ErrCommentTooLong
+	}
+	if _, err := s.GetKudos(userID, transferID); err != nil {
+		return nil, err
+	}
+
+	comment := &models.KudosComment{
+		TransferID: transferID,
+		Author:     models.KudosUser{UserID: userID},
+		Body:       body,
+	}
+	if err := s.kudosRepo.AddComment(comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// ListComments returns a page of the comments on kudos after afterID, oldest first, and the
+// ID to pass as afterID for the next page, 0 when there is none
+func (s *KudosService) ListComments(viewerID int, transferID, afterID int64, limit int) ([]*models.KudosComment, int64, error) {
+	if limit <= 0 {
+		limit = defaultKudosCommentLimit
+	}
+	if limit > maxKudosCommentLimit {
+		limit = maxKudosCommentLimit
+	}
+	if _, err := s.GetKudos(viewerID, transferID); err != nil {
+		return nil, 0, err
+	}
+
+	comments, err := s.kudosRepo.FindComments(transferID, afterID, limit+1)
+	if err != nil {
+		return nil, 0, err
+	}
+	var nextAfterID int64
+	if len(comments) > limit {
+		comments = comments[:limit]
+		nextAfterID = comments[limit-1].ID
+	}
+	return comments, nextAfterID, nil
+}
+
+// DeleteComment deletes a comment a user wrote on kudos
+func (s *KudosService) DeleteComment(userID int, transferID, commentID int64) error {
+	err := s.kudosRepo.DeleteComment(transferID, commentID, userID)
+	if err == sql.ErrNoRows {
+		return ErrCommentNotOwned
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package services_test
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"testing"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKudos(t *testing.T) {
+	setup := func() (*services.KudosService, *mockKudosRepo) {
+		kudosRepo := &mockKudosRepo{kudos: map[int64]*models.Kudos{}, reactions: map[kudosReactionKey]bool{}}
+		for id := int64(1); id <= 5; id++ {
+			category := "teamwork"
+			if id%2 == 0 {
+				category = "mentoring"
+			}
+			kudosRepo.kudos[id] = &models.Kudos{ID: id, TransferID: id, Amount: id, Category: category}
+		}
+		// Anonymous kudos are shown without their transfer ID
+		kudosRepo.kudos[3].TransferID = 0
+		return services.NewKudosService(kudosRepo), kudosRepo
+	}
+
+	t.Run("Feed is paged newest first", func(t *testing.T) {
+		service, _ := setup()
+
+		page, next, err := service.Feed(1, models.KudosFilter{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page, 2)
+		assert.Equal(t, int64(5), page[0].TransferID)
+		assert.Equal(t, int64(4), next)
+
+		page, next, err = service.Feed(1, models.KudosFilter{BeforeID: next, Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), page[0].ID)
+		assert.Zero(t, page[0].TransferID)
+		assert.Equal(t, int64(2), page[1].TransferID)
+
+		page, next, err = service.Feed(1, models.KudosFilter{BeforeID: next, Limit: 2})
+		require.NoError(t, err)
+		assert.Len(t, page, 1)
+		assert.Zero(t, next)
+
+		page, _, err = service.Feed(1, models.KudosFilter{Category: "mentoring"})
+		require.NoError(t, err)
+		assert.Len(t, page, 2)
+	})
+
+	t.Run("Reactions are counted once per user", func(t *testing.T) {
+		service, _ := setup()
+
+		_, err := service.React(1, 3, "clap")
+		require.NoError(t, err)
+		_, err = service.React(1, 3, "clap")
+		require.NoError(t, err)
+		kudos, err := service.React(2, 3, "clap")
+		require.NoError(t, err)
+		require.Len(t, kudos.Reactions, 1)
+		assert.Equal(t, 2, kudos.Reactions[0].Count)
+		assert.True(t, kudos.Reactions[0].Reacted)
+
+		kudos, err = service.Unreact(2, 3, "clap")
+		require.NoError(t, err)
+		assert.Equal(t, 1, kudos.Reactions[0].Count)
+		assert.False(t, kudos.Reactions[0].Reacted)
+
+		_, err = service.React(1, 3, "angry")
+		assert.ErrorIs(t, err, services.ErrInvalidReaction)
+		_, err = service.React(1, 99, "clap")
+		assert.ErrorIs(t, err, services.ErrKudosNotFound)
+	})
+
+	t.Run("Comments are paged and deleted by their author only", func(t *testing.T) {
+		service, kudosRepo := setup()
+
+		for _, body := range []string{"Well deserved!", "  Great work  ", "Congrats"} {
+			_, err := service.Comment(2, 1, body)
+			require.NoError(t, err)
+		}
+		_, err := service.Comment(2, 1, "   ")
+		assert.ErrorIs(t, err, services.ErrEmptyComment)
+		_, err = service.Comment(2, 1, strings.Repeat("a", 1001))
+		assert.ErrorIs(t, err, services.ErrCommentTooLong)
+		_, err = service.Comment(2, 99, "Hello")
+		assert.ErrorIs(t, err, services.ErrKudosNotFound)
+
+		comments, next, err := service.ListComments(1, 1, 0, 2)
+		require.NoError(t, err)
+		require.Len(t, comments, 2)
+		assert.Equal(t, "Great work", comments[1].Body)
+		comments, next, err = service.ListComments(1, 1, next, 2)
+		require.NoError(t, err)
+		assert.Len(t, comments, 1)
+		assert.Zero(t, next)
+
+		assert.ErrorIs(t, service.DeleteComment(1, 1, comments[0].ID), services.ErrCommentNotOwned)
+		require.NoError(t, service.DeleteComment(2, 1, comments[0].ID))
+		assert.Len(t, kudosRepo.comments, 2)
+	})
+}
+
+type kudosReactionKey struct {
+	transferID int64
+	userID     int
+	reaction   string
+}
+
+// mockKudosRepo keeps public transfers, reactions and comments in memory
+type mockKudosRepo struct {
+	kudos     map[int64]*models.Kudos
+	reactions map[kudosReactionKey]bool
+	comments  []*models.KudosComment
+}
+
+func (m *mockKudosRepo) view(viewerID int, kudos *models.Kudos) *models.Kudos {
+	copied := *kudos
+	copied.Reactions = nil
+	for _, reaction := range models.KudosReactions {
+		count, reacted := 0, false
+		for key := range m.reactions {
+			if key.transferID == kudos.ID && key.reaction == reaction {
+				count++
+				reacted = reacted || key.userID == viewerID
+			}
+		}
+		if count > 0 {
+			copied.Reactions = append(copied.Reactions, &models.KudosReaction{Reaction: reaction, Count: count, Reacted: reacted})
+		}
+	}
+	for _, comment := range m.comments {
+		if comment.TransferID == kudos.ID {
+			copied.CommentCount++
+		}
+	}
+	return &copied
+}
+
+func (m *mockKudosRepo) FindFeed(viewerID int, filter models.KudosFilter) ([]*models.Kudos, error) {
+	var feed []*models.Kudos
+	for _, kudos := range m.kudos {
+		if (filter.Category == "" || kudos.Category == filter.Category) &&
+			(filter.BeforeID == 0 || kudos.ID < filter.BeforeID) {
+			feed = append(feed, m.view(viewerID, kudos))
+		}
+	}
+	sort.Slice(feed, func(i, j int) bool { return feed[i].ID > feed[j].ID })
+	if len(feed) > filter.Limit {
+		feed = feed[:filter.Limit]
+	}
+	return feed, nil
+}
+
+func (m *mockKudosRepo) FindByTransferID(viewerID int, transferID int64) (*models.Kudos, error) {
+	kudos, ok := m.kudos[transferID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return m.view(viewerID, kudos), nil
+}
+
+func (m *mockKudosRepo) AddReaction(transferID int64, userID int, reaction string) error {
+	m.reactions[kudosReactionKey{transferID, userID, reaction}] = true
+	return nil
+}
+
+func (m *mockKudosRepo) RemoveReaction(transferID int64, userID int, reaction string) error {
+	delete(m.reactions, kudosReactionKey{transferID, userID, reaction})
+	return nil
+}
+
+func (m *mockKudosRepo) AddComment(comment *models.KudosComment) error {
+	comment.ID = int64(len(m.comments) + 1)
+	m.comments = append(m.comments, comment)
+	return nil
+}
+
+func (m *mockKudosRepo) FindComments(transferID, afterID int64, limit int) ([]*models.KudosComment, error) {
+	var comments []*models.KudosComment
+	for _, comment := range m.comments {
+		if comment.TransferID == transferID && comment.ID > afterID && len(comments) < limit {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+func (m *mockKudosRepo) DeleteComment(transferID, commentID int64, userID int) error {
+	for i, comment := range m.comments {
+		if comment.ID == commentID && comment.TransferID == transferID && comment.Author.UserID == userID {
+			m.comments = append(m.comments[:i], m.comments[i+1:]...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
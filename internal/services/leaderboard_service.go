@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+const defaultLeaderboardCacheTTL = 5 * time.Minute
+
+var (
+	ErrUnknownLeaderboardMetric = errors.New("metric must be badge_points, coins_received, coins_sent or colleagues_thanked")
+	ErrUnknownLeaderboardWindow = errors.New("window must be daily, weekly, monthly or all_time")
+)
+
+// leaderboardWindows lists every leaderboard window
+var leaderboardWindows = []string{
+	models.LeaderboardWindowDaily,
+	models.LeaderboardWindowWeekly,
+	models.LeaderboardWindowMonthly,
+	models.LeaderboardWindowAllTime,
+}
+
+// LeaderboardService ranks users on leaderboards. Rankings are expensive to compute, so
+// each one is cached and served from the cache until it is older than the cache TTL.
+type LeaderboardService struct {
+	leaderboardRepo repository.LeaderboardRepository
+	cacheTTL        time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*models.Leaderboard // Full rankings by metric, window and window start
+}
+
+// NewLeaderboardService creates a new LeaderboardService. Rankings are cached for cacheTTL,
+// or for 5 minutes when cacheTTL is zero.
+func NewLeaderboardService(leaderboardRepo repository.LeaderboardRepository, cacheTTL time.Duration) *LeaderboardService {
+	if cacheTTL <= 0 {
+		cacheTTL = defaultLeaderboardCacheTTL
+	}
+	return &LeaderboardService{
+		leaderboardRepo: leaderboardRepo,
+		cacheTTL:        cacheTTL,
+		cache:           make(map[string]*models.Leaderboard),
+	}
+}
+
+// leaderboardWindowStart returns when the current window starts in UTC, or the zero time for all time
+func leaderboardWindowStart(window string, now time.Time) (time.Time, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	switch window {
+	case models.LeaderboardWindowDaily:
+		return today, nil
+	case models.LeaderboardWindowWeekly:
+		// Weeks start on Monday
+		return today.AddDate(0, 0, -(int(today.Weekday())+6)%7), nil
+	case models.LeaderboardWindowMonthly:
+		return today.AddDate(0, 0, 1-today.Day()), nil
+	case models.LeaderboardWindowAllTime:
+		return time.Time{}, nil
+	}
+	return time.Time{}, ErrUnknownLeaderboardWindow
+}
+
+func validLeaderboardMetric(metric string) bool {
+	for _, known := range models.LeaderboardMetrics {
+		if metric == known {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLeaderboard returns the best limit users on a leaderboard, and the rank of the requesting user
+func (s *LeaderboardService) GetLeaderboard(metric, window string, limit, userID int) (*models.Leaderboard, error) {
+	if !validLeaderboardMetric(metric) {
+		return nil, ErrUnknownLeaderboardMetric
+	}
+
+	ranking, err := s.ranking(metric, window, time.Now(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	board := *ranking
+	if len(board.Entries) > limit {
+		board.Entries = board.Entries[:limit]
+	}
+	for _, entry := range ranking.Entries {
+		if entry.UserID == userID {
+			board.Me = entry
+			break
+		}
+	}
+	return &board, nil
+}
+
+// ranking returns the full ranking of a leaderboard, from the cache unless it is stale or refresh is set
+func (s *LeaderboardService) ranking(metric, window string, now time.Time, refresh bool) (*models.Leaderboard, error) {
+	since, err := leaderboardWindowStart(window, now)
+	if err != nil {
+		return nil, err
+	}
+	key := metric + "/" + window + "/" + since.Format(time.RFC3339)
+
+	s.mu.Lock()
+	cached, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && !refresh && now.Sub(cached.ComputedAt) < s.cacheTTL {
+		return cached, nil
+	}
+
+	entries, err := s.leaderboardRepo.Rank(metric, since)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []*models.LeaderboardEntry{}
+	}
+	ranking := &models.Leaderboard{Metric: metric, Window: window, ComputedAt: now, Entries: entries}
+	if !since.IsZero() {
+		ranking.Since = &since
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Rankings of windows that ended are never served again
+	for cachedKey, cached := range s.cache {
+		if now.Sub(cached.ComputedAt) >= s.cacheTTL {
+			delete(s.cache, cachedKey)
+		}
+	}
+	s.cache[key] = ranking
+	return ranking, nil
+}
+
+// SetOptOut opts a user out of the leaderboards, or back in. The change applies right
+// away: cached rankings are dropped.
+func (s *LeaderboardService) SetOptOut(userID int, optOut bool) error {
+	if err := s.leaderboardRepo.SetOptOut(userID, optOut); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cache = make(map[string]*models.Leaderboard)
+	s.mu.Unlock()
+	return nil
+}
+
+// IsOptedOut reports whether a user opted out of the leaderboards
+func (s *LeaderboardService) IsOptedOut(userID int) (bool, error) {
+	return s.leaderboardRepo.IsOptedOut(userID)
+}
+
+// RefreshAll recomputes every leaderboard
+func (s *LeaderboardService) RefreshAll() error {
+	now := time.Now()
+	for _, metric := range models.LeaderboardMetrics {
+		for _, window := range leaderboardWindows {
+			if _, err := s.ranking(metric, window, now, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RunRefresh recomputes every leaderboard every interval, or every cache TTL when interval
+// is zero, until ctx is cancelled, so requests are served from the cache
+func (s *LeaderboardService) RunRefresh(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = s.cacheTTL
+	}
+	if err := s.RefreshAll(); err != nil {
+		log.Printf("Failed to compute leaderboards: %v", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RefreshAll(); err != nil {
+				log.Printf("Failed to compute leaderboards: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLeaderboard(t *testing.T) {
+	leaderboardRepo := &mockLeaderboardRepo{optedOut: map[int]bool{}, entries: []*models.LeaderboardEntry{
+		{Rank: 1, UserID: 1, DisplayName: "alice", Score: 300},
+		{Rank: 2, UserID: 2, DisplayName: "bob", Score: 200},
+		{Rank: 2, UserID: 3, DisplayName: "carol", Score: 200},
+		{Rank: 4, UserID: 4, DisplayName: "dave", Score: 50},
+	}}
+	service := services.NewLeaderboardService(leaderboardRepo, time.Minute)
+
+	t.Run("The best users are returned with the rank of the requester", func(t *testing.T) {
+		board, err := service.GetLeaderboard(models.LeaderboardMetricCoinsReceived, models.LeaderboardWindowAllTime, 2, 4)
+		require.NoError(t, err)
+		require.Len(t, board.Entries, 2)
+		assert.Equal(t, 1, board.Entries[0].UserID)
+		require.NotNil(t, board.Me)
+		assert.Equal(t, 4, board.Me.Rank)
+		assert.Nil(t, board.Since)
+	})
+
+	t.Run("Rankings are served from the cache", func(t *testing.T) {
+		calls := leaderboardRepo.calls
+		_, err := service.GetLeaderboard(models.LeaderboardMetricCoinsReceived, models.LeaderboardWindowAllTime, 10, 1)
+		require.NoError(t, err)
+		assert.Equal(t, calls, leaderboardRepo.calls)
+
+		// Another window is another ranking
+		_, err = service.GetLeaderboard(models.LeaderboardMetricCoinsReceived, models.LeaderboardWindowDaily, 10, 1)
+		require.NoError(t, err)
+		assert.Equal(t, calls+1, leaderboardRepo.calls)
+	})
+
+	t.Run("Opting out drops cached rankings", func(t *testing.T) {
+		require.NoError(t, service.SetOptOut(4, true))
+		leaderboardRepo.entries = leaderboardRepo.entries[:3]
+
+		board, err := service.GetLeaderboard(models.LeaderboardMetricCoinsReceived, models.LeaderboardWindowAllTime, 10, 4)
+		require.NoError(t, err)
+		assert.Len(t, board.Entries, 3)
+		assert.Nil(t, board.Me)
+
+		optedOut, err := service.IsOptedOut(4)
+		require.NoError(t, err)
+		assert.True(t, optedOut)
+	})
+
+	t.Run("Windows are calendar periods in UTC", func(t *testing.T) {
+		now := time.Now().UTC()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+		board, err := service.GetLeaderboard(models.LeaderboardMetricBadgePoints, models.LeaderboardWindowWeekly, 10, 1)
+		require.NoError(t, err)
+		require.NotNil(t, board.Since)
+		assert.Equal(t, time.Monday, board.Since.Weekday())
+		assert.True(t, !board.Since.After(today) && today.Sub(*board.Since) < 7*24*time.Hour)
+		assert.Equal(t, *board.Since, leaderboardRepo.lastSince)
+
+		board, err = service.GetLeaderboard(models.LeaderboardMetricBadgePoints, models.LeaderboardWindowMonthly, 10, 1)
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), *board.Since)
+	})
+
+	t.Run("Unknown metrics and windows are rejected", func(t *testing.T) {
+		_, err := service.GetLeaderboard("karma", models.LeaderboardWindowDaily, 10, 1)
+		assert.ErrorIs(t, err, services.ErrUnknownLeaderboardMetric)
+
+		_, err = service.GetLeaderboard(models.LeaderboardMetricCoinsSent, "yearly", 10, 1)
+		assert.ErrorIs(t, err, services.ErrUnknownLeaderboardWindow)
+	})
+}
+
+// mockLeaderboardRepo returns the same ranking for every metric and window
+type mockLeaderboardRepo struct {
+	entries   []*models.LeaderboardEntry
+	optedOut  map[int]bool
+	calls     int
+	lastSince time.Time
+}
+
+func (m *mockLeaderboardRepo) Rank(metric string, since time.Time) ([]*models.LeaderboardEntry, error) {
+	m.calls++
+	m.lastSince = since
+	return m.entries, nil
+}
+
+func (m *mockLeaderboardRepo) SetOptOut(userID int, optOut bool) error {
+	m.optedOut[userID] = optOut
+	return nil
+}
+
+func (m *mockLeaderboardRepo) IsOptedOut(userID int) (bool, error) {
+	return m.optedOut[userID], nil
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"log"
+	"os"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+	"verve/pkg/utils"
+)
+
+const chainVerifyBatchSize = 1000
+
+var (
+	ErrCheckpointKeyMissing = errors.New("no ledger checkpoint key is configured")
+	ErrLedgerEmpty          = errors.New("the ledger has no entries yet")
+	ErrInvalidChainRange    = errors.New("invalid chain range")
+)
+
+// LedgerService verifies that wallet balances and transactions agree with the ledger,
+// and that the hash chain over the ledger entries has not been tampered with.
+type LedgerService struct {
+	ledgerRepo    repository.LedgerRepository
+	checkpointKey *ecdsa.PrivateKey
+}
+
+// NewLedgerService creates a new LedgerService. checkpointKey signs chain checkpoints
+// and may be nil, in which case no checkpoints are created.
+func NewLedgerService(ledgerRepo repository.LedgerRepository, checkpointKey *ecdsa.PrivateKey) *LedgerService {
+	return &LedgerService{ledgerRepo: ledgerRepo, checkpointKey: checkpointKey}
+}
+
+// LoadCheckpointKey reads the PEM-encoded checkpoint signing key. It returns nil when path is empty.
+func LoadCheckpointKey(path string) (*ecdsa.PrivateKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return utils.ParsePrivateKeyPEM(data)
+}
+
+// Reconcile checks the whole ledger and stores the report. triggeredBy is nil for scheduled runs.
+func (s *LedgerService) Reconcile(triggeredBy *int) (*models.ReconciliationReport, error) {
+	startedAt := time.Now()
+	report, err := s.ledgerRepo.Reconcile()
+	if err != nil {
+		return nil, err
+	}
+	report.TriggeredBy = triggeredBy
+	report.StartedAt = startedAt
+	report.CompletedAt = time.Now()
+
+	if err := s.ledgerRepo.SaveReconciliation(report); err != nil {
+		return nil, err
+	}
+	if report.DiscrepancyCount > 0 {
+		log.Printf("Ledger reconciliation %d found %d discrepancies", report.ID, report.DiscrepancyCount)
+	}
+	return report, nil
+}
+
+// GetReconciliation returns a stored reconciliation report with all its discrepancies.
+func (s *LedgerService) GetReconciliation(id int64) (*models.ReconciliationReport, error) {
+	return s.ledgerRepo.FindReconciliationByID(id)
+}
+
+// ListReconciliations returns the most recent reconciliation reports without their discrepancies.
+func (s *LedgerService) ListReconciliations(limit int) ([]*models.ReconciliationReport, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.ledgerRepo.FindReconciliations(limit)
+}
+
+// RunReconciliation reconciles the ledger every interval until ctx is cancelled.
+func (s *LedgerService) RunReconciliation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Reconcile(nil); err != nil {
+				log.Printf("Failed to reconcile ledger: %v", err)
+			}
+		}
+	}
+}
+
+// ChainLegacyEntries chains the entries written before the ledger was hash-chained.
+// It only runs while the chain is empty, so entries slipped in later are reported
+// by VerifyChain instead of being chained silently.
+func (s *LedgerService) ChainLegacyEntries() error {
+	seq, _, err := s.ledgerRepo.ChainHead()
+	if err != nil {
+		return err
+	}
+	if seq > 0 {
+		return nil
+	}
+	return s.SealUnchainedEntries()
+}
+
+// SealUnchainedEntries appends every entry that is not part of the chain yet.
+func (s *LedgerService) SealUnchainedEntries() error {
+	count, err := s.ledgerRepo.SealUnchainedEntries()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		log.Printf("Chained %d ledger entries", count)
+	}
+	return nil
+}
+
+// CreateCheckpoint signs the current tip of the chain.
+func (s *LedgerService) CreateCheckpoint() (*models.LedgerCheckpoint, error) {
+	if s.checkpointKey == nil {
+		return nil, ErrCheckpointKeyMissing
+	}
+	seq, hash, err := s.ledgerRepo.ChainHead()
+	if err != nil {
+		return nil, err
+	}
+	if seq == 0 {
+		return nil, ErrLedgerEmpty
+	}
+
+	signature, err := utils.SignMessage(s.checkpointKey, utils.CheckpointMessage(seq, hash))
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &models.LedgerCheckpoint{
+		ChainSeq:  seq,
+		EntryHash: hash,
+		Signature: signature,
+		PublicKey: utils.PublicKeyToString(&s.checkpointKey.PublicKey),
+	}
+	if err := s.ledgerRepo.CreateCheckpoint(checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// ListCheckpoints returns the most recent checkpoints.
+func (s *LedgerService) ListCheckpoints(limit int) ([]*models.LedgerCheckpoint, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.ledgerRepo.FindCheckpoints(limit)
+}
+
+// RunCheckpoints signs the tip of the chain every interval, when it moved, until ctx is cancelled.
+func (s *LedgerService) RunCheckpoints(ctx context.Context, interval time.Duration) {
+	if s.checkpointKey == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.checkpointIfMoved(); err != nil {
+				log.Printf("Failed to create ledger checkpoint: %v", err)
+			}
+		}
+	}
+}
+
+func (s *LedgerService) checkpointIfMoved() error {
+	seq, _, err := s.ledgerRepo.ChainHead()
+	if err != nil || seq == 0 {
+		return err
+	}
+	latest, err := s.ledgerRepo.FindCheckpoints(1)
+	if err != nil {
+		return err
+	}
+	if len(latest) > 0 && latest[0].ChainSeq >= seq {
+		return nil
+	}
+	_, err = s.CreateCheckpoint()
+	return err
+}
+
+// VerifyChain recomputes the hash chain between two positions, inclusive, and checks the
+// checkpoints in that range. A toSeq of 0 verifies up to the tip of the chain.
+// Checkpoints are only trusted when signed by the configured checkpoint key; without one they
+// are reported as unverified. Entries written outside the chain make it invalid.
+func (s *LedgerService) VerifyChain(fromSeq, toSeq int64) (*models.ChainVerification, error) {
+	headSeq, _, err := s.ledgerRepo.ChainHead()
+	if err != nil {
+		return nil, err
+	}
+	if fromSeq <= 0 {
+		fromSeq = 1
+	}
+	if toSeq <= 0 || toSeq > headSeq {
+		toSeq = headSeq
+	}
+	if headSeq > 0 && fromSeq > toSeq {
+		return nil, ErrInvalidChainRange
+	}
+
+	result := &models.ChainVerification{FromSeq: fromSeq, ToSeq: toSeq}
+	if result.UnchainedEntries, err = s.ledgerRepo.CountUnchainedEntries(); err != nil {
+		return nil, err
+	}
+	if headSeq == 0 {
+		result.Valid = result.UnchainedEntries == 0
+		return result, nil
+	}
+
+	// The range is linked to the entry right before it
+	var prevHash string
+	if fromSeq > 1 {
+		previous, err := s.ledgerRepo.FindChainEntries(fromSeq-1, fromSeq-1, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(previous) == 0 {
+			result.FirstBrokenLink = &models.ChainBreak{ChainSeq: fromSeq - 1, Reason: "entry is missing"}
+			return result, nil
+		}
+		prevHash = previous[0].Hash
+	}
+
+	hashes := make(map[int64]string)
+	next := fromSeq
+	for next <= toSeq && result.FirstBrokenLink == nil {
+		entries, err := s.ledgerRepo.FindChainEntries(next, toSeq, chainVerifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			result.FirstBrokenLink = &models.ChainBreak{ChainSeq: next, Reason: "entry is missing"}
+			break
+		}
+		for _, entry := range entries {
+			if result.FirstBrokenLink = checkChainLink(entry, next, prevHash); result.FirstBrokenLink != nil {
+				break
+			}
+			hashes[entry.ChainSeq] = entry.Hash
+			prevHash = entry.Hash
+			result.EntriesChecked++
+			next++
+		}
+	}
+
+	checkpoints, err := s.ledgerRepo.FindCheckpointsInRange(fromSeq, toSeq)
+	if err != nil {
+		return nil, err
+	}
+	for _, checkpoint := range checkpoints {
+		hash, checked := hashes[checkpoint.ChainSeq]
+		if !checked {
+			// Beyond the first broken link, there is nothing trustworthy to compare with
+			continue
+		}
+		if s.checkpointKey == nil {
+			// The key stored with the checkpoint proves nothing by itself
+			result.CheckpointsUnverified++
+			continue
+		}
+		result.CheckpointsChecked++
+		if reason := s.checkCheckpoint(checkpoint, hash); reason != "" {
+			result.CheckpointFailures = append(result.CheckpointFailures, &models.CheckpointFailure{
+				CheckpointID: checkpoint.ID,
+				ChainSeq:     checkpoint.ChainSeq,
+				Reason:       reason,
+			})
+		}
+	}
+
+	result.Valid = result.FirstBrokenLink == nil && len(result.CheckpointFailures) == 0 && result.UnchainedEntries == 0
+	return result, nil
+}
+
+// checkChainLink returns why an entry does not belong at position seq after prevHash, or nil
+func checkChainLink(entry *models.LedgerEntry, seq int64, prevHash string) *models.ChainBreak {
+	if entry.ChainSeq != seq {
+		return &models.ChainBreak{ChainSeq: seq, Reason: "entry is missing"}
+	}
+	if entry.PrevHash != prevHash {
+		return &models.ChainBreak{
+			ChainSeq:     seq,
+			EntryID:      entry.ID,
+			Reason:       "previous hash does not match the previous entry",
+			ExpectedHash: prevHash,
+			ActualHash:   entry.PrevHash,
+		}
+	}
+	if computed := entry.ComputeHash(); computed != entry.Hash {
+		return &models.ChainBreak{
+			ChainSeq:     seq,
+			EntryID:      entry.ID,
+			Reason:       "entry content does not match its hash",
+			ExpectedHash: computed,
+			ActualHash:   entry.Hash,
+		}
+	}
+	return nil
+}
+
+// checkCheckpoint returns why a checkpoint cannot be trusted for the given chain hash, or ""
+func (s *LedgerService) checkCheckpoint(checkpoint *models.LedgerCheckpoint, hash string) string {
+	if checkpoint.PublicKey != utils.PublicKeyToString(&s.checkpointKey.PublicKey) {
+		return "signed by an unknown key"
+	}
+	pub, err := utils.DecodePublicKey(checkpoint.PublicKey)
+	if err != nil {
+		return "invalid public key"
+	}
+	if !utils.VerifySignature(pub, utils.CheckpointMessage(checkpoint.ChainSeq, checkpoint.EntryHash), checkpoint.Signature) {
+		return "invalid signature"
+	}
+	if checkpoint.EntryHash != hash {
+		return "chain hash differs from the signed hash"
+	}
+	return ""
+}
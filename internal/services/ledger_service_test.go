@@ -0,0 +1,367 @@
+package services_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/services"
+	"verve/pkg/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyChain(t *testing.T) {
+	newChain := func(n int) *mockLedgerRepo {
+		repo := &mockLedgerRepo{}
+		for i := 1; i <= n; i++ {
+			repo.append(&models.LedgerEntry{
+				ID:            int64(100 + i),
+				TransactionID: int64(i),
+				WalletID:      1,
+				EntryType:     "credit",
+				Amount:        int64(10 * i),
+				CreatedAt:     time.Date(2024, 1, 1, 0, 0, i, 0, time.UTC),
+			})
+		}
+		return repo
+	}
+
+	t.Run("Intact chain is valid", func(t *testing.T) {
+		result, err := services.NewLedgerService(newChain(5), nil).VerifyChain(0, 0)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, int64(5), result.EntriesChecked)
+		assert.Nil(t, result.FirstBrokenLink)
+	})
+
+	t.Run("Edited entry breaks the chain", func(t *testing.T) {
+		repo := newChain(5)
+		repo.entries[2].Amount = 1000
+
+		result, err := services.NewLedgerService(repo, nil).VerifyChain(0, 0)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotNil(t, result.FirstBrokenLink)
+		assert.Equal(t, int64(3), result.FirstBrokenLink.ChainSeq)
+		assert.Equal(t, int64(103), result.FirstBrokenLink.EntryID)
+		assert.Equal(t, int64(2), result.EntriesChecked)
+	})
+
+	t.Run("Rehashed entry breaks the link to the next one", func(t *testing.T) {
+		repo := newChain(5)
+		repo.entries[2].Amount = 1000
+		repo.entries[2].Hash = repo.entries[2].ComputeHash()
+
+		result, err := services.NewLedgerService(repo, nil).VerifyChain(0, 0)
+		require.NoError(t, err)
+		require.NotNil(t, result.FirstBrokenLink)
+		assert.Equal(t, int64(4), result.FirstBrokenLink.ChainSeq)
+	})
+
+	t.Run("Deleted entry is reported as missing", func(t *testing.T) {
+		repo := newChain(5)
+		repo.entries = append(repo.entries[:3], repo.entries[4:]...)
+
+		result, err := services.NewLedgerService(repo, nil).VerifyChain(0, 0)
+		require.NoError(t, err)
+		require.NotNil(t, result.FirstBrokenLink)
+		assert.Equal(t, int64(4), result.FirstBrokenLink.ChainSeq)
+		assert.Equal(t, "entry is missing", result.FirstBrokenLink.Reason)
+	})
+
+	t.Run("Range starts from the previous entry", func(t *testing.T) {
+		repo := newChain(5)
+		repo.entries[0].Amount = 1000
+
+		result, err := services.NewLedgerService(repo, nil).VerifyChain(3, 4)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, int64(2), result.EntriesChecked)
+	})
+
+	t.Run("Checkpoints are verified against the chain and the key", func(t *testing.T) {
+		key, err := utils.GenerateECDSAKeyPair()
+		require.NoError(t, err)
+		otherKey, err := utils.GenerateECDSAKeyPair()
+		require.NoError(t, err)
+
+		repo := newChain(3)
+		ledgerService := services.NewLedgerService(repo, key)
+		_, err = ledgerService.CreateCheckpoint()
+		require.NoError(t, err)
+		_, err = services.NewLedgerService(repo, otherKey).CreateCheckpoint()
+		require.NoError(t, err)
+
+		result, err := ledgerService.VerifyChain(0, 0)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Equal(t, 2, result.CheckpointsChecked)
+		require.Len(t, result.CheckpointFailures, 1)
+		assert.Equal(t, "signed by an unknown key", result.CheckpointFailures[0].Reason)
+
+		// Without a key of its own, the verifier cannot tell a forged checkpoint from a real one
+		result, err = services.NewLedgerService(repo, nil).VerifyChain(0, 0)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Equal(t, 0, result.CheckpointsChecked)
+		assert.Equal(t, 2, result.CheckpointsUnverified)
+		assert.Empty(t, result.CheckpointFailures)
+	})
+
+	t.Run("Unchained entries make the ledger invalid", func(t *testing.T) {
+		repo := newChain(3)
+		repo.unchained = 1
+
+		result, err := services.NewLedgerService(repo, nil).VerifyChain(0, 0)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Nil(t, result.FirstBrokenLink)
+		assert.Equal(t, int64(1), result.UnchainedEntries)
+
+		result, err = services.NewLedgerService(&mockLedgerRepo{unchained: 2}, nil).VerifyChain(0, 0)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Equal(t, int64(2), result.UnchainedEntries)
+	})
+}
+
+func TestReconcile(t *testing.T) {
+	// Wallet 1 was minted 100 coins and sent 30 of them to wallet 2
+	newLedger := func() *mockLedgerRepo {
+		repo := &mockLedgerRepo{
+			balances: map[int64]int64{1: 70, 2: 30, 3: 0},
+			transactions: []*models.Transaction{
+				{ID: 1, ReceiverWalletID: 1, Amount: 100},
+				{ID: 2, SenderWalletID: 1, ReceiverWalletID: 2, Amount: 30},
+			},
+		}
+		repo.append(&models.LedgerEntry{ID: 1, TransactionID: 1, WalletID: 1, EntryType: "credit", Amount: 100})
+		repo.append(&models.LedgerEntry{ID: 2, TransactionID: 2, WalletID: 1, EntryType: "debit", Amount: 30})
+		repo.append(&models.LedgerEntry{ID: 3, TransactionID: 2, WalletID: 2, EntryType: "credit", Amount: 30})
+		return repo
+	}
+	discrepancyTypes := func(report *models.ReconciliationReport) []models.DiscrepancyType {
+		var types []models.DiscrepancyType
+		for _, d := range report.Discrepancies {
+			types = append(types, d.Type)
+		}
+		return types
+	}
+
+	t.Run("A consistent ledger has no discrepancies", func(t *testing.T) {
+		repo := newLedger()
+		adminID := 7
+		report, err := services.NewLedgerService(repo, nil).Reconcile(&adminID)
+		require.NoError(t, err)
+		assert.Empty(t, report.Discrepancies)
+		assert.Equal(t, 3, report.WalletsChecked)
+		assert.Equal(t, 2, report.TransactionsChecked)
+		assert.Equal(t, 3, report.EntriesChecked)
+		assert.Equal(t, &adminID, report.TriggeredBy)
+		assert.False(t, report.CompletedAt.Before(report.StartedAt))
+
+		stored, err := services.NewLedgerService(repo, nil).GetReconciliation(report.ID)
+		require.NoError(t, err)
+		assert.Equal(t, report, stored)
+	})
+
+	t.Run("A transaction missing its credit side is a mismatch", func(t *testing.T) {
+		repo := newLedger()
+		repo.balances[1] = 60
+		repo.transactions = append(repo.transactions, &models.Transaction{ID: 3, SenderWalletID: 1, ReceiverWalletID: 2, Amount: 10})
+		repo.append(&models.LedgerEntry{ID: 4, TransactionID: 3, WalletID: 1, EntryType: "debit", Amount: 10})
+
+		report, err := services.NewLedgerService(repo, nil).Reconcile(nil)
+		require.NoError(t, err)
+		require.Equal(t, 1, report.DiscrepancyCount)
+		d := report.Discrepancies[0]
+		assert.Equal(t, models.DiscrepancyTransactionMismatch, d.Type)
+		assert.Equal(t, int64(3), *d.TransactionID)
+		assert.Equal(t, "credit", d.EntryType)
+		assert.Equal(t, int64(10), d.Expected)
+		assert.Equal(t, int64(0), d.Actual)
+		assert.Nil(t, report.TriggeredBy)
+	})
+
+	t.Run("A balance changed outside the ledger drifts", func(t *testing.T) {
+		repo := newLedger()
+		repo.balances[2] = 40
+
+		report, err := services.NewLedgerService(repo, nil).Reconcile(nil)
+		require.NoError(t, err)
+		require.Equal(t, []models.DiscrepancyType{models.DiscrepancyBalanceDrift}, discrepancyTypes(report))
+		d := report.Discrepancies[0]
+		assert.Equal(t, int64(2), *d.WalletID)
+		assert.Equal(t, int64(30), d.Expected)
+		assert.Equal(t, int64(40), d.Actual)
+	})
+
+	t.Run("Entries on the wrong wallet or without a transaction are reported", func(t *testing.T) {
+		repo := newLedger()
+		repo.entries[2].WalletID = 3
+		repo.balances[2], repo.balances[3] = 0, 30
+		repo.append(&models.LedgerEntry{ID: 4, WalletID: 2, EntryType: "credit", Amount: 5})
+
+		report, err := services.NewLedgerService(repo, nil).Reconcile(nil)
+		require.NoError(t, err)
+		assert.Equal(t, []models.DiscrepancyType{models.DiscrepancyMisroutedEntry, models.DiscrepancyOrphanEntry}, discrepancyTypes(report))
+		assert.Equal(t, int64(3), *report.Discrepancies[0].EntryID)
+		assert.Equal(t, int64(4), *report.Discrepancies[1].EntryID)
+
+		reports, err := services.NewLedgerService(repo, nil).ListReconciliations(0)
+		require.NoError(t, err)
+		assert.Len(t, reports, 1)
+	})
+}
+
+// mockLedgerRepo keeps the hash chain in memory, and reconciles it with the balances and
+// transactions like the database does
+type mockLedgerRepo struct {
+	entries      []*models.LedgerEntry
+	checkpoints  []*models.LedgerCheckpoint
+	headSeq      int64
+	headHash     string
+	unchained    int64
+	balances     map[int64]int64
+	transactions []*models.Transaction
+	reports      []*models.ReconciliationReport
+}
+
+func (m *mockLedgerRepo) append(entry *models.LedgerEntry) {
+	m.headSeq++
+	entry.ChainSeq = m.headSeq
+	entry.PrevHash = m.headHash
+	entry.Hash = entry.ComputeHash()
+	m.headHash = entry.Hash
+	m.entries = append(m.entries, entry)
+}
+
+func (m *mockLedgerRepo) Reconcile() (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{
+		WalletsChecked:      len(m.balances),
+		TransactionsChecked: len(m.transactions),
+		EntriesChecked:      len(m.entries),
+		Discrepancies:       []*models.LedgerDiscrepancy{},
+	}
+	add := func(d models.LedgerDiscrepancy) {
+		report.Discrepancies = append(report.Discrepancies, &d)
+	}
+
+	ledgerBalances := make(map[int64]int64)
+	for _, entry := range m.entries {
+		if entry.TransactionID == 0 {
+			continue
+		}
+		if entry.EntryType == "credit" {
+			ledgerBalances[entry.WalletID] += entry.Amount
+		} else {
+			ledgerBalances[entry.WalletID] -= entry.Amount
+		}
+	}
+	for walletID := int64(1); walletID <= int64(len(m.balances)); walletID++ {
+		if m.balances[walletID] != ledgerBalances[walletID] {
+			add(models.LedgerDiscrepancy{Type: models.DiscrepancyBalanceDrift, WalletID: &walletID, Expected: ledgerBalances[walletID], Actual: m.balances[walletID]})
+		}
+	}
+
+	transactions := make(map[int64]*models.Transaction)
+	for _, transaction := range m.transactions {
+		transactions[transaction.ID] = transaction
+		sides := map[string]int64{}
+		for _, entry := range m.entries {
+			if entry.TransactionID == transaction.ID {
+				sides[entry.EntryType] += entry.Amount
+			}
+		}
+		expectedDebits := transaction.Amount
+		if transaction.SenderWalletID == 0 {
+			expectedDebits = 0 // Mints have no sender
+		}
+		transactionID := transaction.ID
+		if sides["debit"] != expectedDebits {
+			add(models.LedgerDiscrepancy{Type: models.DiscrepancyTransactionMismatch, TransactionID: &transactionID, EntryType: "debit", Expected: expectedDebits, Actual: sides["debit"]})
+		}
+		if sides["credit"] != transaction.Amount {
+			add(models.LedgerDiscrepancy{Type: models.DiscrepancyTransactionMismatch, TransactionID: &transactionID, EntryType: "credit", Expected: transaction.Amount, Actual: sides["credit"]})
+		}
+	}
+
+	for _, entry := range m.entries {
+		entryID, walletID, transactionID := entry.ID, entry.WalletID, entry.TransactionID
+		d := models.LedgerDiscrepancy{EntryID: &entryID, WalletID: &walletID, EntryType: entry.EntryType, Actual: entry.Amount}
+		transaction := transactions[entry.TransactionID]
+		switch {
+		case entry.TransactionID == 0:
+			d.Type = models.DiscrepancyOrphanEntry
+		case entry.EntryType == "debit" && entry.WalletID != transaction.SenderWalletID,
+			entry.EntryType == "credit" && entry.WalletID != transaction.ReceiverWalletID:
+			d.Type = models.DiscrepancyMisroutedEntry
+			d.TransactionID = &transactionID
+		default:
+			continue
+		}
+		add(d)
+	}
+
+	report.DiscrepancyCount = len(report.Discrepancies)
+	return report, nil
+}
+
+func (m *mockLedgerRepo) SaveReconciliation(report *models.ReconciliationReport) error {
+	report.ID = int64(len(m.reports) + 1)
+	m.reports = append(m.reports, report)
+	return nil
+}
+
+func (m *mockLedgerRepo) FindReconciliationByID(id int64) (*models.ReconciliationReport, error) {
+	if id < 1 || id > int64(len(m.reports)) {
+		return nil, sql.ErrNoRows
+	}
+	return m.reports[id-1], nil
+}
+
+func (m *mockLedgerRepo) FindReconciliations(limit int) ([]*models.ReconciliationReport, error) {
+	var reports []*models.ReconciliationReport
+	for i := len(m.reports) - 1; i >= 0 && len(reports) < limit; i-- {
+		reports = append(reports, m.reports[i])
+	}
+	return reports, nil
+}
+
+func (m *mockLedgerRepo) SealUnchainedEntries() (int64, error) {
+	return 0, nil
+}
+
+func (m *mockLedgerRepo) ChainHead() (int64, string, error) {
+	return m.headSeq, m.headHash, nil
+}
+
+func (m *mockLedgerRepo) FindChainEntries(fromSeq, toSeq int64, limit int) ([]*models.LedgerEntry, error) {
+	var entries []*models.LedgerEntry
+	for _, entry := range m.entries {
+		if entry.ChainSeq >= fromSeq && entry.ChainSeq <= toSeq && len(entries) < limit {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (m *mockLedgerRepo) CountUnchainedEntries() (int64, error) {
+	return m.unchained, nil
+}
+
+func (m *mockLedgerRepo) CreateCheckpoint(checkpoint *models.LedgerCheckpoint) error {
+	checkpoint.ID = int64(len(m.checkpoints) + 1)
+	m.checkpoints = append(m.checkpoints, checkpoint)
+	return nil
+}
+
+func (m *mockLedgerRepo) FindCheckpoints(limit int) ([]*models.LedgerCheckpoint, error) {
+	return m.checkpoints, nil
+}
+
+func (m *mockLedgerRepo) FindCheckpointsInRange(fromSeq, toSeq int64) ([]*models.LedgerCheckpoint, error) {
+	return m.checkpoints, nil
+}
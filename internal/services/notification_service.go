@@ -0,0 +1,266 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 200
+
+	// notificationStreamBuffer is how many notifications a stream holds for a slow client
+	// before it misses some
+	notificationStreamBuffer = 16
+)
+
+// NotificationService notifies users of the transfers, badges and grants they receive. Each
+// notification is stored, so clients catch up on what they missed, and pushed to the open
+// streams of its user on this instance.
+type NotificationService struct {
+	notificationRepo repository.NotificationRepository
+	walletRepo       repository.WalletRepository
+	userRepo         repository.UserRepository
+	badgeRepo        repository.BadgeRepository
+
+	mu      sync.Mutex
+	streams map[int]map[chan *models.Notification]struct{}
+}
+
+// NewNotificationService creates a new NotificationService.
+func NewNotificationService(
+	notificationRepo repository.NotificationRepository,
+	walletRepo repository.WalletRepository,
+	userRepo repository.UserRepository,
+	badgeRepo repository.BadgeRepository,
+) *NotificationService {
+	return &NotificationService{
+		notificationRepo: notificationRepo,
+		walletRepo:       walletRepo,
+		userRepo:         userRepo,
+		badgeRepo:        badgeRepo,
+		streams:          make(map[int]map[chan *models.Notification]struct{}),
+	}
+}
+
+// NotificationEventTypes are the domain events users are notified of
+var NotificationEventTypes = []string{
+	models.EventTypeTransferCompleted,
+	models.EventTypeBadgeAwarded,
+	models.EventTypeGrantPaid,
+}
+
+// HandleEvent notifies the user a domain event happened to. It is registered with the event
+// dispatcher for NotificationEventTypes.
+func (s *NotificationService) HandleEvent(event *models.OutboxEvent) error {
+	switch event.EventType {
+	case models.EventTypeTransferCompleted:
+		var transfer models.TransferCompletedEvent
+		if err := json.Unmarshal(event.Payload, &transfer); err != nil {
+			return err
+		}
+		return s.notifyTransfer(event.ID, transfer)
+	case models.EventTypeBadgeAwarded:
+		var award models.BadgeAwardedEvent
+		if err := json.Unmarshal(event.Payload, &award); err != nil {
+			return err
+		}
+		return s.notifyBadge(event.ID, award)
+	case models.EventTypeGrantPaid:
+		var grant models.GrantPaidEvent
+		if err := json.Unmarshal(event.Payload, &grant); err != nil {
+			return err
+		}
+		return s.notify(grant.UserID, models.NotificationGrantReceived, event.ID,
+			fmt.Sprintf("You received a grant of %s", formatCoins(grant.Amount)),
+			map[string]interface{}{"cycle_id": grant.CycleID, "amount": grant.Amount})
+	}
+	return nil
+}
+
+// notifyTransfer notifies the receiver of a transfer. The sender of an anonymous transfer is
+// not named.
+func (s *NotificationService) notifyTransfer(eventID int64, transfer models.TransferCompletedEvent) error {
+	receiver, err := s.walletRepo.FindByID(transfer.ReceiverWalletID)
+	if err != nil {
+		return err
+	}
+	if receiver == nil || receiver.UserID == 0 {
+		// Pseudonymous wallets have no one to notify
+		return nil
+	}
+
+	data := map[string]interface{}{"transfer_id": transfer.TransferID, "amount": transfer.Amount}
+	sender := "Someone"
+	if !transfer.IsAnonymous {
+		if sender, err = s.walletOwnerName(transfer.SenderWalletID); err != nil {
+			return err
+		}
+		data["sender_wallet_id"] = transfer.SenderWalletID
+	}
+
+	return s.notify(receiver.UserID, models.NotificationTransferReceived, eventID,
+		fmt.Sprintf("%s sent you %s", sender, formatCoins(transfer.Amount)), data)
+}
+
+// walletOwnerName returns the name a wallet's owner is shown by
+func (s *NotificationService) walletOwnerName(walletID int64) (string, error) {
+	wallet, err := s.walletRepo.FindByID(walletID)
+	if err != nil {
+		return "", err
+	}
+	if wallet == nil || wallet.UserID == 0 {
+		return "A pseudonymous wallet", nil
+	}
+	user, err := s.userRepo.FindByID(wallet.UserID)
+	if err != nil {
+		return "", err
+	}
+	if user.DisplayName != "" {
+		return user.DisplayName, nil
+	}
+	return user.Username, nil
+}
+
+func (s *NotificationService) notifyBadge(eventID int64, award models.BadgeAwardedEvent) error {
+	badge, err := s.badgeRepo.FindByID(award.BadgeID)
+	if err != nil {
+		return err
+	}
+
+	var message string
+	switch {
+	case award.PreviousLevel != "":
+		message = fmt.Sprintf("Your %s badge was upgraded to %s", badge.Name, award.Level)
+	case award.Level != "":
+		message = fmt.Sprintf("You earned the %s badge (%s)", badge.Name, award.Level)
+	default:
+		message = fmt.Sprintf("You earned the %s badge", badge.Name)
+	}
+
+	return s.notify(award.UserID, models.NotificationBadgeAwarded, eventID, message,
+		map[string]interface{}{"badge_id": award.BadgeID, "level": award.Level})
+}
+
+// notify stores a notification and pushes it to the user's streams. A user already notified
+// of the event is not notified again.
+func (s *NotificationService) notify(userID int, notificationType string, eventID int64, message string, data interface{}) error {
+	document, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	notification := &models.Notification{
+		UserID:  userID,
+		Type:    notificationType,
+		Message: message,
+		Data:    document,
+		EventID: eventID,
+	}
+
+	err = s.notificationRepo.Create(notification)
+	if errors.Is(err, repository.ErrNotificationExists) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.publish(notification)
+	return nil
+}
+
+func formatCoins(amount int64) string {
+	if amount == 1 {
+		return "1 coin"
+	}
+	return fmt.Sprintf("%d coins", amount)
+}
+
+// Subscribe opens a stream of the notifications of a user made from now on. The stream
+// only carries notifications made on this instance, and drops them when the reader falls
+// behind; readers catch up with Since. The returned function closes the stream.
+func (s *NotificationService) Subscribe(userID int) (<-chan *models.Notification, func()) {
+	stream := make(chan *models.Notification, notificationStreamBuffer)
+
+	s.mu.Lock()
+	if s.streams[userID] == nil {
+		s.streams[userID] = make(map[chan *models.Notification]struct{})
+	}
+	s.streams[userID][stream] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return stream, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.streams[userID], stream)
+			if len(s.streams[userID]) == 0 {
+				delete(s.streams, userID)
+			}
+		})
+	}
+}
+
+func (s *NotificationService) publish(notification *models.Notification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for stream := range s.streams[notification.UserID] {
+		select {
+		case stream <- notification:
+		default:
+		}
+	}
+}
+
+// Since returns the notifications of a user newer than afterID, oldest first, up to 200 at a
+// time. The ID of the last one returned is the afterID of the next call.
+func (s *NotificationService) Since(userID int, afterID int64) ([]*models.Notification, error) {
+	return s.notificationRepo.FindSince(userID, afterID, maxNotificationLimit)
+}
+
+// List returns a page of the notifications of a user matching filter, newest first, the ID
+// to pass as filter.BeforeID for the next page, 0 when there is none, and how many
+// notifications of the user are unread
+func (s *NotificationService) List(userID int, filter models.NotificationFilter) ([]*models.Notification, int64, int, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultNotificationLimit
+	}
+	if filter.Limit > maxNotificationLimit {
+		filter.Limit = maxNotificationLimit
+	}
+
+	// Fetch one extra notification to know whether another page follows
+	limit := filter.Limit
+	filter.Limit++
+	notifications, err := s.notificationRepo.Find(userID, filter)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var nextBeforeID int64
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		nextBeforeID = notifications[limit-1].ID
+	}
+
+	unread, err := s.notificationRepo.CountUnread(userID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return notifications, nextBeforeID, unread, nil
+}
+
+// MarkRead marks a notification of a user as read
+func (s *NotificationService) MarkRead(userID int, id int64) error {
+	return s.notificationRepo.MarkRead(userID, id)
+}
+
+// MarkAllRead marks every notification of a user as read and returns how many were unread
+func (s *NotificationService) MarkAllRead(userID int) (int64, error) {
+	return s.notificationRepo.MarkAllRead(userID)
+}
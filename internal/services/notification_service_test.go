@@ -0,0 +1,219 @@
+package services_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifications(t *testing.T) {
+	setup := func() (*services.NotificationService, *mockNotificationRepo) {
+		walletRepo := &mockWalletRepo{wallets: map[int64]*models.Wallet{
+			1: {ID: 1, UserID: 1},
+			2: {ID: 2, UserID: 2},
+			3: {ID: 3}, // Pseudonymous
+		}}
+		badgeRepo := &mockBadgeRepo{}
+		badgeRepo.Create(&models.Badge{Name: "Helper"})
+		notificationRepo := &mockNotificationRepo{}
+		return services.NewNotificationService(notificationRepo, walletRepo, newMockUserRepo(), badgeRepo), notificationRepo
+	}
+
+	event := func(id int64, eventType string, payload interface{}) *models.OutboxEvent {
+		document, _ := json.Marshal(payload)
+		return &models.OutboxEvent{ID: id, EventType: eventType, Payload: document}
+	}
+
+	t.Run("Receivers are notified and streamed their transfers", func(t *testing.T) {
+		service, notificationRepo := setup()
+		stream, closeStream := service.Subscribe(2)
+		defer closeStream()
+
+		transfer := event(1, models.EventTypeTransferCompleted, models.TransferCompletedEvent{TransferID: 9, SenderWalletID: 1, ReceiverWalletID: 2, Amount: 10})
+		require.NoError(t, service.HandleEvent(transfer))
+		// Events are delivered at least once
+		require.NoError(t, service.HandleEvent(transfer))
+
+		require.Len(t, notificationRepo.notifications, 1)
+		select {
+		case notification := <-stream:
+			assert.Equal(t, 2, notification.UserID)
+			assert.Equal(t, models.NotificationTransferReceived, notification.Type)
+			assert.Equal(t, "alice sent you 10 coins", notification.Message)
+		case <-time.After(time.Second):
+			t.Fatal("notification was not streamed")
+		}
+		select {
+		case <-stream:
+			t.Fatal("duplicate event was streamed again")
+		default:
+		}
+	})
+
+	t.Run("Anonymous senders are not named", func(t *testing.T) {
+		service, notificationRepo := setup()
+
+		require.NoError(t, service.HandleEvent(event(1, models.EventTypeTransferCompleted, models.TransferCompletedEvent{SenderWalletID: 1, ReceiverWalletID: 2, Amount: 1, IsAnonymous: true})))
+		require.NoError(t, service.HandleEvent(event(2, models.EventTypeTransferCompleted, models.TransferCompletedEvent{SenderWalletID: 3, ReceiverWalletID: 2, Amount: 5})))
+		// Pseudonymous wallets have no one to notify
+		require.NoError(t, service.HandleEvent(event(3, models.EventTypeTransferCompleted, models.TransferCompletedEvent{SenderWalletID: 1, ReceiverWalletID: 3, Amount: 5})))
+
+		require.Len(t, notificationRepo.notifications, 2)
+		anonymous := notificationRepo.notifications[0]
+		assert.Equal(t, "Someone sent you 1 coin", anonymous.Message)
+		assert.NotContains(t, string(anonymous.Data), "sender")
+		assert.Equal(t, "A pseudonymous wallet sent you 5 coins", notificationRepo.notifications[1].Message)
+	})
+
+	t.Run("Badges and grants are notified", func(t *testing.T) {
+		service, notificationRepo := setup()
+
+		require.NoError(t, service.HandleEvent(event(1, models.EventTypeBadgeAwarded, models.BadgeAwardedEvent{UserID: 1, BadgeID: 1})))
+		require.NoError(t, service.HandleEvent(event(2, models.EventTypeBadgeAwarded, models.BadgeAwardedEvent{UserID: 1, BadgeID: 1, Level: "gold", PreviousLevel: "silver"})))
+		require.NoError(t, service.HandleEvent(event(3, models.EventTypeGrantPaid, models.GrantPaidEvent{UserID: 1, CycleID: 4, Amount: 100})))
+
+		require.Len(t, notificationRepo.notifications, 3)
+		assert.Equal(t, "You earned the Helper badge", notificationRepo.notifications[0].Message)
+		assert.Equal(t, "Your Helper badge was upgraded to gold", notificationRepo.notifications[1].Message)
+		assert.Equal(t, models.NotificationGrantReceived, notificationRepo.notifications[2].Type)
+		assert.Equal(t, "You received a grant of 100 coins", notificationRepo.notifications[2].Message)
+	})
+
+	t.Run("Notifications are listed, caught up on and read", func(t *testing.T) {
+		service, _ := setup()
+		for i := int64(1); i <= 5; i++ {
+			require.NoError(t, service.HandleEvent(event(i, models.EventTypeGrantPaid, models.GrantPaidEvent{UserID: 1, Amount: i})))
+		}
+		require.NoError(t, service.HandleEvent(event(6, models.EventTypeGrantPaid, models.GrantPaidEvent{UserID: 2, Amount: 1})))
+
+		notifications, nextBeforeID, unread, err := service.List(1, models.NotificationFilter{Limit: 3})
+		require.NoError(t, err)
+		require.Len(t, notifications, 3)
+		assert.Equal(t, int64(5), notifications[0].ID)
+		assert.Equal(t, int64(3), nextBeforeID)
+		assert.Equal(t, 5, unread)
+
+		missed, err := service.Since(1, 3)
+		require.NoError(t, err)
+		require.Len(t, missed, 2)
+		assert.Equal(t, int64(4), missed[0].ID)
+
+		require.NoError(t, service.MarkRead(1, 5))
+		assert.ErrorIs(t, service.MarkRead(1, 6), sql.ErrNoRows, "users only read their own notifications")
+		notifications, _, unread, err = service.List(1, models.NotificationFilter{UnreadOnly: true})
+		require.NoError(t, err)
+		assert.Len(t, notifications, 4)
+		assert.Equal(t, 4, unread)
+
+		marked, err := service.MarkAllRead(1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), marked)
+	})
+
+	t.Run("Catching up pages from the oldest missed notification", func(t *testing.T) {
+		service, _ := setup()
+		for i := int64(1); i <= 250; i++ {
+			require.NoError(t, service.HandleEvent(event(i, models.EventTypeGrantPaid, models.GrantPaidEvent{UserID: 1, Amount: i})))
+		}
+
+		missed, err := service.Since(1, 10)
+		require.NoError(t, err)
+		require.Len(t, missed, 200)
+		assert.Equal(t, int64(11), missed[0].ID)
+		assert.Equal(t, int64(210), missed[199].ID)
+
+		missed, err = service.Since(1, missed[199].ID)
+		require.NoError(t, err)
+		require.Len(t, missed, 40)
+		assert.Equal(t, int64(250), missed[39].ID)
+	})
+}
+
+// mockNotificationRepo keeps notifications in memory, oldest first
+type mockNotificationRepo struct {
+	notifications []*models.Notification
+}
+
+func (m *mockNotificationRepo) Create(notification *models.Notification) error {
+	for _, existing := range m.notifications {
+		if existing.UserID == notification.UserID && existing.EventID == notification.EventID {
+			return repository.ErrNotificationExists
+		}
+	}
+	notification.ID = int64(len(m.notifications) + 1)
+	notification.CreatedAt = time.Now()
+	m.notifications = append(m.notifications, notification)
+	return nil
+}
+
+func (m *mockNotificationRepo) Find(userID int, filter models.NotificationFilter) ([]*models.Notification, error) {
+	var notifications []*models.Notification
+	for _, notification := range m.notifications {
+		switch {
+		case notification.UserID != userID,
+			filter.UnreadOnly && notification.ReadAt != nil,
+			filter.AfterID > 0 && notification.ID <= filter.AfterID,
+			filter.BeforeID > 0 && notification.ID >= filter.BeforeID:
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].ID > notifications[j].ID })
+	if len(notifications) > filter.Limit {
+		notifications = notifications[:filter.Limit]
+	}
+	return notifications, nil
+}
+
+func (m *mockNotificationRepo) FindSince(userID int, afterID int64, limit int) ([]*models.Notification, error) {
+	var notifications []*models.Notification
+	for _, notification := range m.notifications {
+		if notification.UserID == userID && notification.ID > afterID && len(notifications) < limit {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications, nil
+}
+
+func (m *mockNotificationRepo) CountUnread(userID int) (int, error) {
+	count := 0
+	for _, notification := range m.notifications {
+		if notification.UserID == userID && notification.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *mockNotificationRepo) MarkRead(userID int, id int64) error {
+	for _, notification := range m.notifications {
+		if notification.ID == id && notification.UserID == userID {
+			if notification.ReadAt == nil {
+				now := time.Now()
+				notification.ReadAt = &now
+			}
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *mockNotificationRepo) MarkAllRead(userID int) (int64, error) {
+	var marked int64
+	now := time.Now()
+	for _, notification := range m.notifications {
+		if notification.UserID == userID && notification.ReadAt == nil {
+			notification.ReadAt = &now
+			marked++
+		}
+	}
+	return marked, nil
+}
@@ -1,10 +1,13 @@
 package services
 
 import (
+	"strconv"
 	"verve/internal/models"
 )
 
-func (s *UserService) UpsertOAuthUser(email, name, picture, provider string) (*models.User, error) {
+// UpsertOAuthUser finds or creates the user signing in with an OAuth provider. The user is the
+// actor of the sign-up that creates their account.
+func (s *UserService) UpsertOAuthUser(actor models.Actor, email, name, picture, provider string) (*models.User, error) {
 	// Try to find existing user by email and provider
 	user, err := s.userRepo.FindByEmailAndProvider(email, provider)
 	if err == nil {
@@ -37,10 +40,13 @@ func (s *UserService) UpsertOAuthUser(email, name, picture, provider string) (*m
 		Roles:           []string{"user"}, // Default role
 	}
 
-	_, err = s.userRepo.Create(user, "", "") // No password/pin for OAuth users
+	userID, err := s.userRepo.Create(user, "", "") // No password/pin for OAuth users
 	if err != nil {
 		return nil, err
 	}
+	user.ID = userID
 
+	actor.UserID = userID
+	s.auditService.Record(actor, models.AuditActionUserOAuthSignUp, "user", strconv.Itoa(userID), nil, user)
 	return user, nil
 }
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+const (
+	defaultPaymentRequestLimit = 20
+	maxPaymentRequestLimit     = 100
+
+	// defaultPaymentRequestExpiry applies when no default expiry is configured
+	defaultPaymentRequestExpiry = 7 * 24 * time.Hour
+	// maxPaymentRequestLifetime bounds how far in the future a payment request may expire
+	maxPaymentRequestLifetime = 30 * 24 * time.Hour
+)
+
+var (
+	ErrPaymentRequestNotFound      = errors.New("payment request not found")
+	ErrPaymentRequestNotPending    = errors.New("payment request is no longer pending")
+	ErrPaymentRequestExpired       = errors.New("payment request has expired")
+	ErrSelfPaymentRequest          = errors.New("cannot request coins from yourself")
+	ErrRequesterWalletNotFound     = errors.New("wallet to be paid into not found")
+	ErrRequesterWalletNotOwned     = errors.New("wallet to be paid into does not belong to you")
+	ErrPayerWalletNotFound         = errors.New("payer wallet not found")
+	ErrInvalidPaymentRequestExpiry = errors.New("expiry must be in the future and at most 30 days ahead")
+	ErrInvalidPaymentRequestStatus = errors.New("status must be pending, paid, declined, cancelled or expired")
+)
+
+// PaymentRequestService lets users ask each other for coins. A request is paid by the payer
+// accepting it, which runs a regular transfer out of the payer's wallet.
+type PaymentRequestService struct {
+	requestRepo     repository.PaymentRequestRepository
+	walletRepo      repository.WalletRepository
+	transferService *TransferService
+	defaultExpiry   time.Duration
+}
+
+// NewPaymentRequestService creates a new PaymentRequestService. Requests created without an
+// expiry expire after defaultExpiry.
+func NewPaymentRequestService(
+	requestRepo repository.PaymentRequestRepository,
+	walletRepo repository.WalletRepository,
+	transferService *TransferService,
+	defaultExpiry time.Duration,
+) *PaymentRequestService {
+	if defaultExpiry <= 0 {
+		defaultExpiry = defaultPaymentRequestExpiry
+	}
+	return &PaymentRequestService{
+		requestRepo:     requestRepo,
+		walletRepo:      walletRepo,
+		transferService: transferService,
+		defaultExpiry:   defaultExpiry,
+	}
+}
+
+// CreateRequest asks the owner of payerWalletID to pay amount into requesterWalletID, a
+// wallet of the actor. The request expires at expiresAt, or after the default expiry when nil.
+func (s *PaymentRequestService) CreateRequest(
+	actor models.Actor,
+	requesterWalletID, payerWalletID, amount int64,
+	message string,
+	expiresAt *time.Time,
+) (*models.PaymentRequest, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	message = strings.TrimSpace(message)
+	if utf8.RuneCountInString(message) > maxTransferMessageLength {
+		return nil, ErrMessageTooLong
+	}
+
+	now := time.Now()
+	expiry := now.Add(s.defaultExpiry)
+	if expiresAt != nil {
+		if !expiresAt.After(now) || expiresAt.After(now.Add(maxPaymentRequestLifetime)) {
+			return nil, ErrInvalidPaymentRequestExpiry
+		}
+		expiry = *expiresAt
+	}
+
+	requesterWallet, err := s.walletRepo.FindByID(requesterWalletID)
+	if err != nil {
+		return nil, err
+	}
+	if requesterWallet == nil {
+		return nil, ErrRequesterWalletNotFound
+	}
+	if requesterWallet.UserID != actor.UserID {
+		return nil, ErrRequesterWalletNotOwned
+	}
+
+	// Only wallets of users can be asked, pseudonymous wallets have no one to accept
+	payerWallet, err := s.walletRepo.FindByID(payerWalletID)
+	if err != nil {
+		return nil, err
+	}
+	if payerWallet == nil || payerWallet.UserID == 0 {
+		return nil, ErrPayerWalletNotFound
+	}
+	if payerWallet.UserID == actor.UserID {
+		return nil, ErrSelfPaymentRequest
+	}
+	if payerWallet.Currency != requesterWallet.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	request := &models.PaymentRequest{
+		RequesterUserID:   actor.UserID,
+		RequesterWalletID: requesterWalletID,
+		PayerUserID:       payerWallet.UserID,
+		PayerWalletID:     payerWalletID,
+		Amount:            amount,
+		Message:           message,
+		Status:            models.PaymentRequestStatusPending,
+		ExpiresAt:         expiry,
+	}
+	if err := s.requestRepo.Create(request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// GetRequest returns a payment request to its requester or payer
+func (s *PaymentRequestService) GetRequest(userID int, id int64) (*models.PaymentRequest, error) {
+	request, err := s.requestRepo.FindByID(id)
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if request.RequesterUserID != userID && request.PayerUserID != userID {
+		return nil, ErrPaymentRequestNotFound
+	}
+	return request, nil
+}
+
+// ListIncoming returns a page of the requests asking userID to pay, newest first, and the
+// ID to pass as beforeID for the next page, 0 when there is none
+func (s *PaymentRequestService) ListIncoming(userID int, status models.PaymentRequestStatus, beforeID int64, limit int) ([]*models.PaymentRequest, int64, error) {
+	return s.list(models.PaymentRequestFilter{PayerUserID: userID, Status: status, BeforeID: beforeID, Limit: limit})
+}
+
+// ListOutgoing returns a page of the requests userID made, newest first, and the ID to pass
+// as beforeID for the next page, 0 when there is none
+func (s *PaymentRequestService) ListOutgoing(userID int, status models.PaymentRequestStatus, beforeID int64, limit int) ([]*models.PaymentRequest, int64, error) {
+	return s.list(models.PaymentRequestFilter{RequesterUserID: userID, Status: status, BeforeID: beforeID, Limit: limit})
+}
+
+func (s *PaymentRequestService) list(filter models.PaymentRequestFilter) ([]*models.PaymentRequest, int64, error) {
+	if filter.Status != "" && !isPaymentRequestStatus(filter.Status) {
+		return nil, 0, ErrInvalidPaymentRequestStatus
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPaymentRequestLimit
+	}
+	if filter.Limit > maxPaymentRequestLimit {
+		filter.Limit = maxPaymentRequestLimit
+	}
+
+	// Fetch one extra request to know whether another page follows
+	limit := filter.Limit
+	filter.Limit++
+	requests, err := s.requestRepo.Find(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	var nextBeforeID int64
+	if len(requests) > limit {
+		requests = requests[:limit]
+		nextBeforeID = requests[limit-1].ID
+	}
+	return requests, nextBeforeID, nil
+}
+
+func isPaymentRequestStatus(status models.PaymentRequestStatus) bool {
+	switch status {
+	case models.PaymentRequestStatusPending, models.PaymentRequestStatusPaid,
+		models.PaymentRequestStatusDeclined, models.PaymentRequestStatusCancelled, models.PaymentRequestStatusExpired:
+		return true
+	}
+	return false
+}
+
+// AcceptRequest pays a pending request asked of the actor with a transfer out of the payer
+// wallet, checked like any other transfer including the PIN. The request is marked paid in
+// the transaction that moves the coins, so it is paid once however often it is accepted.
+// When the transfer fails the request stays pending, and the failed transfer is returned
+// along with the error when it was recorded.
+func (s *PaymentRequestService) AcceptRequest(actor models.Actor, id int64, pin string) (*models.PaymentRequest, *models.Transfer, error) {
+	request, err := s.GetRequest(actor.UserID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if request.PayerUserID != actor.UserID {
+		return nil, nil, ErrPaymentRequestNotFound
+	}
+	if err := checkMove(request.Status, models.PaymentRequestStatusPaid); err != nil {
+		return nil, nil, err
+	}
+
+	transfer, err := s.transferService.InitiateRequestedTransfer(
+		actor,
+		request.ID,
+		request.PayerWalletID,
+		request.RequesterWalletID,
+		request.Amount,
+		models.TransferNote{Message: request.Message, Visibility: models.TransferVisibilityRecipientOnly},
+		pin,
+	)
+	if errors.Is(err, repository.ErrPaymentRequestNotPending) {
+		// Answered, cancelled or expired since it was read
+		return nil, transfer, s.statusError(id)
+	}
+	if err != nil {
+		return nil, transfer, err
+	}
+
+	paid, err := s.requestRepo.FindByID(id)
+	if err != nil {
+		return nil, transfer, err
+	}
+	return paid, transfer, nil
+}
+
+// DeclineRequest declines a pending request asked of userID
+func (s *PaymentRequestService) DeclineRequest(userID int, id int64) (*models.PaymentRequest, error) {
+	request, err := s.GetRequest(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if request.PayerUserID != userID {
+		return nil, ErrPaymentRequestNotFound
+	}
+	return s.move(request, models.PaymentRequestStatusDeclined)
+}
+
+// CancelRequest cancels a pending request userID made
+func (s *PaymentRequestService) CancelRequest(userID int, id int64) (*models.PaymentRequest, error) {
+	request, err := s.GetRequest(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if request.RequesterUserID != userID {
+		return nil, ErrPaymentRequestNotFound
+	}
+	return s.move(request, models.PaymentRequestStatusCancelled)
+}
+
+// move moves a request to the next status, failing when its current status does not allow
+// it, including when another caller moved it first
+func (s *PaymentRequestService) move(request *models.PaymentRequest, next models.PaymentRequestStatus) (*models.PaymentRequest, error) {
+	if err := checkMove(request.Status, next); err != nil {
+		return nil, err
+	}
+
+	moved, err := s.requestRepo.UpdateStatus(request.ID, request.Status, next)
+	if err != sql.ErrNoRows {
+		return moved, err
+	}
+	return nil, s.statusError(request.ID)
+}
+
+// checkMove fails when a request in status may not move to next
+func checkMove(status, next models.PaymentRequestStatus) error {
+	if status == models.PaymentRequestStatusExpired {
+		return ErrPaymentRequestExpired
+	}
+	if !status.CanBecome(next) {
+		return fmt.Errorf("%w: it is %s", ErrPaymentRequestNotPending, status)
+	}
+	return nil
+}
+
+// statusError explains why a request that was pending when read could not move
+func (s *PaymentRequestService) statusError(id int64) error {
+	current, err := s.requestRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if current.Status == models.PaymentRequestStatusExpired {
+		return ErrPaymentRequestExpired
+	}
+	return fmt.Errorf("%w: it is %s", ErrPaymentRequestNotPending, current.Status)
+}
+
+// RunExpiry expires the pending requests whose expiry passed every interval until ctx is
+// cancelled
+func (s *PaymentRequestService) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := s.requestRepo.ExpireDue(time.Now())
+			if err != nil {
+				log.Printf("Failed to expire payment requests: %v", err)
+			} else if expired > 0 {
+				log.Printf("Expired %d payment requests", expired)
+			}
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package services_test
+
+import (
+	"database/sql"
+	"sort"
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPaymentRequests(t *testing.T) {
+	pinHash, err := bcrypt.GenerateFromPassword([]byte("1234"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	setup := func() (*services.PaymentRequestService, *mockPaymentRequestRepo, *mockTransactionRepo, *services.TransferService) {
+		wallets := map[int64]*models.Wallet{
+			1: {ID: 1, UserID: 1, Currency: "USD", Balance: 100},
+			2: {ID: 2, UserID: 2, Currency: "USD"},
+			3: {ID: 3, Currency: "USD"}, // Pseudonymous
+			4: {ID: 4, UserID: 1, Currency: "EUR"},
+		}
+		userRepo := newMockUserRepo()
+		userRepo.users[1].PinRequiredForTransfer = true
+		userRepo.users[1].PinHash = string(pinHash)
+		transferRepo := newMockTransferRepo()
+		requestRepo := &mockPaymentRequestRepo{requests: map[int64]*models.PaymentRequest{}}
+		txRepo := &mockTransactionRepo{wallets: wallets, transferRepo: transferRepo, requestRepo: requestRepo}
+		walletRepo := &mockWalletRepo{wallets: wallets}
+		transferService := services.NewTransferService(transferRepo, txRepo, userRepo, walletRepo, nil, services.NewAuditService(&mockAuditRepo{}))
+		return services.NewPaymentRequestService(requestRepo, walletRepo, transferService, time.Hour), requestRepo, txRepo, transferService
+	}
+	bob := models.Actor{UserID: 2}
+	alice := models.Actor{UserID: 1}
+
+	t.Run("Accepting a request pays it with a PIN-checked transfer", func(t *testing.T) {
+		service, _, txRepo, _ := setup()
+		request, err := service.CreateRequest(bob, 2, 1, 30, "Lunch on Friday", nil)
+		require.NoError(t, err)
+		assert.Equal(t, models.PaymentRequestStatusPending, request.Status)
+		assert.Equal(t, 1, request.PayerUserID)
+
+		incoming, _, err := service.ListIncoming(1, models.PaymentRequestStatusPending, 0, 0)
+		require.NoError(t, err)
+		assert.Len(t, incoming, 1)
+		outgoing, _, err := service.ListOutgoing(2, "", 0, 0)
+		require.NoError(t, err)
+		assert.Len(t, outgoing, 1)
+
+		_, _, err = service.AcceptRequest(alice, request.ID, "0000")
+		assert.ErrorIs(t, err, services.ErrInvalidPIN)
+		request, err = service.GetRequest(1, request.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.PaymentRequestStatusPending, request.Status)
+
+		paid, transfer, err := service.AcceptRequest(alice, request.ID, "1234")
+		require.NoError(t, err)
+		assert.Equal(t, models.PaymentRequestStatusPaid, paid.Status)
+		require.NotNil(t, paid.TransferID)
+		assert.Equal(t, transfer.ID, *paid.TransferID)
+		assert.Equal(t, "Lunch on Friday", transfer.Message)
+		stored := txRepo.transferRepo.transfers[transfer.ID]
+		assert.Equal(t, models.TransferStatusCompleted, stored.Status)
+		assert.Equal(t, int64(1), stored.SenderWalletID)
+		assert.Equal(t, int64(2), stored.ReceiverWalletID)
+		assert.Equal(t, int64(30), stored.Amount)
+
+		_, _, err = service.AcceptRequest(alice, request.ID, "1234")
+		assert.ErrorIs(t, err, services.ErrPaymentRequestNotPending)
+	})
+
+	t.Run("A failed transfer leaves the request pending", func(t *testing.T) {
+		service, requestRepo, _, _ := setup()
+		request, err := service.CreateRequest(bob, 2, 1, 1000, "", nil)
+		require.NoError(t, err)
+
+		_, transfer, err := service.AcceptRequest(alice, request.ID, "1234")
+		assert.ErrorIs(t, err, repository.ErrInsufficientFunds)
+		require.NotNil(t, transfer)
+		assert.Equal(t, models.TransferStatusFailed, transfer.Status)
+		assert.Equal(t, models.PaymentRequestStatusPending, requestRepo.requests[request.ID].Status)
+	})
+
+	t.Run("A request answered before its transfer runs is not paid", func(t *testing.T) {
+		service, requestRepo, txRepo, transferService := setup()
+		request, err := service.CreateRequest(bob, 2, 1, 30, "", nil)
+		require.NoError(t, err)
+		_, err = service.CancelRequest(2, request.ID)
+		require.NoError(t, err)
+
+		// As when the request is cancelled while the payer accepts it
+		transfer, err := transferService.InitiateRequestedTransfer(alice, request.ID, 1, 2, 30, models.TransferNote{}, "1234")
+		assert.ErrorIs(t, err, repository.ErrPaymentRequestNotPending)
+		require.NotNil(t, transfer)
+		assert.Equal(t, models.TransferStatusFailed, txRepo.transferRepo.transfers[transfer.ID].Status)
+		assert.Equal(t, models.PaymentRequestStatusCancelled, requestRepo.requests[request.ID].Status)
+		assert.Nil(t, requestRepo.requests[request.ID].TransferID)
+	})
+
+	t.Run("Only the payer declines and only the requester cancels", func(t *testing.T) {
+		service, _, _, _ := setup()
+		first, err := service.CreateRequest(bob, 2, 1, 10, "", nil)
+		require.NoError(t, err)
+		second, err := service.CreateRequest(bob, 2, 1, 10, "", nil)
+		require.NoError(t, err)
+
+		_, err = service.DeclineRequest(2, first.ID)
+		assert.ErrorIs(t, err, services.ErrPaymentRequestNotFound)
+		_, err = service.CancelRequest(1, first.ID)
+		assert.ErrorIs(t, err, services.ErrPaymentRequestNotFound)
+		_, err = service.GetRequest(3, first.ID)
+		assert.ErrorIs(t, err, services.ErrPaymentRequestNotFound)
+
+		declined, err := service.DeclineRequest(1, first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.PaymentRequestStatusDeclined, declined.Status)
+		cancelled, err := service.CancelRequest(2, second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.PaymentRequestStatusCancelled, cancelled.Status)
+
+		_, err = service.CancelRequest(2, first.ID)
+		assert.ErrorIs(t, err, services.ErrPaymentRequestNotPending)
+		_, _, err = service.AcceptRequest(alice, second.ID, "1234")
+		assert.ErrorIs(t, err, services.ErrPaymentRequestNotPending)
+
+		declinedOnly, _, err := service.ListIncoming(1, models.PaymentRequestStatusDeclined, 0, 0)
+		require.NoError(t, err)
+		assert.Len(t, declinedOnly, 1)
+		_, _, err = service.ListIncoming(1, "overdue", 0, 0)
+		assert.ErrorIs(t, err, services.ErrInvalidPaymentRequestStatus)
+	})
+
+	t.Run("Expired requests can no longer be answered", func(t *testing.T) {
+		service, requestRepo, _, _ := setup()
+		request, err := service.CreateRequest(bob, 2, 1, 10, "", nil)
+		require.NoError(t, err)
+		requestRepo.requests[request.ID].ExpiresAt = time.Now().Add(-time.Minute)
+
+		_, _, err = service.AcceptRequest(alice, request.ID, "1234")
+		assert.ErrorIs(t, err, services.ErrPaymentRequestExpired)
+		_, err = service.CancelRequest(2, request.ID)
+		assert.ErrorIs(t, err, services.ErrPaymentRequestExpired)
+
+		expired, err := requestRepo.ExpireDue(time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), expired)
+		assert.Equal(t, models.PaymentRequestStatusExpired, requestRepo.requests[request.ID].Status)
+	})
+
+	t.Run("Requests are checked when made", func(t *testing.T) {
+		service, _, _, _ := setup()
+		past := time.Now().Add(-time.Hour)
+
+		tests := []struct {
+			name      string
+			requester int64
+			payer     int64
+			amount    int64
+			expiresAt *time.Time
+			wantErr   error
+		}{
+			{"non-positive amount", 2, 1, 0, nil, services.ErrInvalidAmount},
+			{"expiry in the past", 2, 1, 10, &past, services.ErrInvalidPaymentRequestExpiry},
+			{"wallet of someone else", 1, 2, 10, nil, services.ErrRequesterWalletNotOwned},
+			{"unknown requester wallet", 9, 1, 10, nil, services.ErrRequesterWalletNotFound},
+			{"pseudonymous payer", 2, 3, 10, nil, services.ErrPayerWalletNotFound},
+			{"request to yourself", 2, 2, 10, nil, services.ErrSelfPaymentRequest},
+			{"currency mismatch", 2, 4, 10, nil, services.ErrCurrencyMismatch},
+		}
+		for _, tt := range tests {
+			_, err := service.CreateRequest(bob, tt.requester, tt.payer, tt.amount, "", tt.expiresAt)
+			assert.ErrorIs(t, err, tt.wantErr, tt.name)
+		}
+	})
+}
+
+// mockPaymentRequestRepo keeps payment requests in memory. Like the database, it reads
+// pending requests past their expiry as expired.
+type mockPaymentRequestRepo struct {
+	requests map[int64]*models.PaymentRequest
+}
+
+func (m *mockPaymentRequestRepo) view(request *models.PaymentRequest) *models.PaymentRequest {
+	copied := *request
+	if copied.Status == models.PaymentRequestStatusPending && !copied.ExpiresAt.After(time.Now()) {
+		copied.Status = models.PaymentRequestStatusExpired
+	}
+	return &copied
+}
+
+func (m *mockPaymentRequestRepo) Create(request *models.PaymentRequest) error {
+	request.ID = int64(len(m.requests) + 1)
+	request.CreatedAt = time.Now()
+	stored := *request
+	m.requests[request.ID] = &stored
+	return nil
+}
+
+func (m *mockPaymentRequestRepo) FindByID(id int64) (*models.PaymentRequest, error) {
+	if request, ok := m.requests[id]; ok {
+		return m.view(request), nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *mockPaymentRequestRepo) Find(filter models.PaymentRequestFilter) ([]*models.PaymentRequest, error) {
+	var requests []*models.PaymentRequest
+	for _, stored := range m.requests {
+		request := m.view(stored)
+		if (filter.RequesterUserID == 0 || request.RequesterUserID == filter.RequesterUserID) &&
+			(filter.PayerUserID == 0 || request.PayerUserID == filter.PayerUserID) &&
+			(filter.Status == "" || request.Status == filter.Status) &&
+			(filter.BeforeID == 0 || request.ID < filter.BeforeID) {
+			requests = append(requests, request)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID > requests[j].ID })
+	if len(requests) > filter.Limit {
+		requests = requests[:filter.Limit]
+	}
+	return requests, nil
+}
+
+func (m *mockPaymentRequestRepo) UpdateStatus(id int64, from, to models.PaymentRequestStatus) (*models.PaymentRequest, error) {
+	request, ok := m.requests[id]
+	if !ok || m.view(request).Status != from {
+		return nil, sql.ErrNoRows
+	}
+	request.Status = to
+	return m.view(request), nil
+}
+
+func (m *mockPaymentRequestRepo) ExpireDue(now time.Time) (int64, error) {
+	var expired int64
+	for _, request := range m.requests {
+		if request.Status == models.PaymentRequestStatusPending && !request.ExpiresAt.After(now) {
+			request.Status = models.PaymentRequestStatusExpired
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// markPaid marks a pending request paid as the transfer paying it executes
+func (m *mockPaymentRequestRepo) markPaid(id, transferID int64) error {
+	request, ok := m.requests[id]
+	if !ok || m.view(request).Status != models.PaymentRequestStatusPending {
+		return repository.ErrPaymentRequestNotPending
+	}
+	request.Status = models.PaymentRequestStatusPaid
+	request.TransferID = &transferID
+	return nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"verve/internal/repository"
+)
+
+const defaultPermissionCacheTTL = time.Minute
+
+// PermissionService resolves the permissions roles grant. The permissions of each role are
+// cached, so checking a permission does not read the database on every request.
+type PermissionService struct {
+	roleRepo repository.RoleRepository
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*rolePermissions
+}
+
+// rolePermissions are the cached permissions of a role
+type rolePermissions struct {
+	permissions map[string]bool
+	loadedAt    time.Time
+}
+
+// NewPermissionService creates a new PermissionService. The permissions of a role are cached
+// for cacheTTL, or for a minute when cacheTTL is zero.
+func NewPermissionService(roleRepo repository.RoleRepository, cacheTTL time.Duration) *PermissionService {
+	if cacheTTL <= 0 {
+		cacheTTL = defaultPermissionCacheTTL
+	}
+	return &PermissionService{
+		roleRepo: roleRepo,
+		cacheTTL: cacheTTL,
+		cache:    make(map[string]*rolePermissions),
+	}
+}
+
+// permissionsOf returns the permissions of a role, from the cache unless it is stale
+func (s *PermissionService) permissionsOf(role string) (map[string]bool, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[role]
+	s.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < s.cacheTTL {
+		return cached.permissions, nil
+	}
+
+	names, err := s.roleRepo.GetPermissions(role)
+	if err != nil {
+		return nil, err
+	}
+	permissions := make(map[string]bool, len(names))
+	for _, name := range names {
+		permissions[name] = true
+	}
+
+	s.mu.Lock()
+	s.cache[role] = &rolePermissions{permissions: permissions, loadedAt: time.Now()}
+	s.mu.Unlock()
+	return permissions, nil
+}
+
+// HasPermission reports whether any of the roles grants a permission
+func (s *PermissionService) HasPermission(roles []string, permission string) (bool, error) {
+	for _, role := range roles {
+		permissions, err := s.permissionsOf(role)
+		if err != nil {
+			return false, err
+		}
+		if permissions[permission] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// EffectivePermissions returns every permission granted by the roles, sorted by name
+func (s *PermissionService) EffectivePermissions(roles []string) ([]string, error) {
+	effective := make(map[string]bool)
+	for _, role := range roles {
+		permissions, err := s.permissionsOf(role)
+		if err != nil {
+			return nil, err
+		}
+		for permission := range permissions {
+			effective[permission] = true
+		}
+	}
+
+	names := make([]string, 0, len(effective))
+	for permission := range effective {
+		names = append(names, permission)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Invalidate drops the cached permissions, so changes to role permissions apply right away
+func (s *PermissionService) Invalidate() {
+	s.mu.Lock()
+	s.cache = make(map[string]*rolePermissions)
+	s.mu.Unlock()
+}
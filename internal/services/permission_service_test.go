@@ -0,0 +1,49 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermissionService(t *testing.T) {
+	roleRepo := &mockRoleRepo{permissions: map[string][]string{
+		"admin":   {"assign_badge", "create_badge", "view_users"},
+		"manager": {"assign_badge", "view_team"},
+	}}
+	service := services.NewPermissionService(roleRepo, time.Hour)
+
+	t.Run("Any role can grant a permission", func(t *testing.T) {
+		allowed, err := service.HasPermission([]string{"user", "manager"}, "assign_badge")
+		require.NoError(t, err)
+		assert.True(t, allowed)
+
+		allowed, err = service.HasPermission([]string{"user", "manager"}, "create_badge")
+		require.NoError(t, err)
+		assert.False(t, allowed)
+	})
+
+	t.Run("Effective permissions combine every role", func(t *testing.T) {
+		permissions, err := service.EffectivePermissions([]string{"admin", "manager"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"assign_badge", "create_badge", "view_team", "view_users"}, permissions)
+	})
+
+	t.Run("Permissions are cached until invalidated", func(t *testing.T) {
+		reads := roleRepo.permissionReads
+		_, err := service.HasPermission([]string{"manager"}, "view_team")
+		require.NoError(t, err)
+		assert.Equal(t, reads, roleRepo.permissionReads)
+
+		roleRepo.permissions["manager"] = []string{"create_badge"}
+		service.Invalidate()
+
+		allowed, err := service.HasPermission([]string{"manager"}, "create_badge")
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, reads+1, roleRepo.permissionReads)
+	})
+}
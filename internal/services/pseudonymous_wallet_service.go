@@ -1,29 +1,148 @@
 package services
 
 import (
+	"crypto/ecdsa"
+	"database/sql"
+	"errors"
+	"strconv"
+	"verve/internal/models"
 	"verve/internal/repository"
 	"verve/pkg/utils"
 )
 
+var (
+	ErrInvalidPublicKey           = errors.New("public key must be a base64 uncompressed P-256 point")
+	ErrInvalidProofOfPossession   = errors.New("proof of possession does not match the public key")
+	ErrPseudonymousWalletNotFound = errors.New("pseudonymous wallet not found")
+	ErrWalletKeyRevoked           = errors.New("the key of this wallet was revoked")
+)
+
+// PseudonymousWalletService manages wallets controlled by a client-held key instead of a user account.
+// The server only ever sees public keys; every change must be signed by the client.
 type PseudonymousWalletService struct {
-	repo repository.PseudonymousWalletRepository
+	repo         repository.PseudonymousWalletRepository
+	auditService *AuditService
+}
+
+func NewPseudonymousWalletService(repo repository.PseudonymousWalletRepository, auditService *AuditService) *PseudonymousWalletService {
+	return &PseudonymousWalletService{repo: repo, auditService: auditService}
 }
 
-func NewPseudonymousWalletService(repo repository.PseudonymousWalletRepository) *PseudonymousWalletService {
-	return &PseudonymousWalletService{repo: repo}
+// walletKeyState is the active key of a wallet as recorded in the audit log. Keys are
+// recorded by fingerprint, so the log never holds the keys themselves.
+type walletKeyState struct {
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
+	RevokeReason   string `json:"revoke_reason,omitempty"`
 }
 
-// CreatePseudonymousWallet generates a keypair, stores public key, returns wallet and private key
-func (s *PseudonymousWalletService) CreatePseudonymousWallet() (walletID int64, publicKey string, privateKey string, err error) {
-	priv, err := utils.GenerateECDSAKeyPair()
+// CreatePseudonymousWallet registers a wallet for a client-generated key. proof is the
+// signature of KeyRegistrationMessage by that key, showing the client holds the private key.
+func (s *PseudonymousWalletService) CreatePseudonymousWallet(actor models.Actor, publicKey, proof string) (*models.PseudonymousWallet, error) {
+	if err := verifyProofOfPossession(publicKey, proof); err != nil {
+		return nil, err
+	}
+	wallet, err := s.repo.CreatePseudonymousWallet(publicKey)
 	if err != nil {
-		return 0, "", "", err
+		return nil, err
+	}
+	s.auditService.Record(actor, models.AuditActionWalletRegister, "pseudonymous_wallet", strconv.FormatInt(wallet.ID, 10),
+		nil, walletKeyState{KeyFingerprint: utils.KeyFingerprint(publicKey)})
+	return wallet, nil
+}
+
+// RotateKey replaces the active key of a wallet. signature is the signature of KeyRotationMessage
+// by the active key, and proof the signature of KeyRegistrationMessage by the new key.
+func (s *PseudonymousWalletService) RotateKey(actor models.Actor, walletID int64, newPublicKey, proof, signature string) (*models.PseudonymousWalletKey, error) {
+	wallet, activeKey, err := s.activeKey(walletID)
+	if err != nil {
+		return nil, err
+	}
+	if !utils.VerifySignature(activeKey, utils.KeyRotationMessage(walletID, newPublicKey), signature) {
+		return nil, ErrInvalidSignature
+	}
+	if err := verifyProofOfPossession(newPublicKey, proof); err != nil {
+		return nil, err
 	}
-	pubStr := utils.PublicKeyToString(&priv.PublicKey)
-	w, err := s.repo.CreatePseudonymousWallet(pubStr)
+	key, err := s.repo.RotateKey(walletID, wallet.PublicKey, newPublicKey, signature)
 	if err != nil {
-		return 0, "", "", err
+		return nil, err
+	}
+	s.auditService.Record(actor, models.AuditActionWalletKeyRotate, "pseudonymous_wallet", strconv.FormatInt(walletID, 10),
+		walletKeyState{KeyFingerprint: utils.KeyFingerprint(wallet.PublicKey)}, walletKeyState{KeyFingerprint: utils.KeyFingerprint(newPublicKey)})
+	return key, nil
+}
+
+// RevokeKey revokes the active key of a wallet on request of its holder, who signs
+// KeyRevocationMessage. The wallet cannot send coins afterwards.
+func (s *PseudonymousWalletService) RevokeKey(actor models.Actor, walletID int64, signature, reason string) error {
+	wallet, activeKey, err := s.activeKey(walletID)
+	if err != nil {
+		return err
+	}
+	if !utils.VerifySignature(activeKey, utils.KeyRevocationMessage(walletID, wallet.PublicKey), signature) {
+		return ErrInvalidSignature
+	}
+	return s.revokeKey(actor, wallet, reason)
+}
+
+// AdminRevokeKey revokes the active key of a wallet without the holder's signature,
+// for keys known to be compromised.
+func (s *PseudonymousWalletService) AdminRevokeKey(actor models.Actor, walletID int64, reason string) error {
+	wallet, _, err := s.activeKey(walletID)
+	if err != nil {
+		return err
+	}
+	return s.revokeKey(actor, wallet, reason)
+}
+
+func (s *PseudonymousWalletService) revokeKey(actor models.Actor, wallet *models.PseudonymousWallet, reason string) error {
+	if err := s.repo.RevokeKey(wallet.ID, wallet.PublicKey, reason); err != nil {
+		return err
+	}
+	s.auditService.Record(actor, models.AuditActionWalletKeyRevoke, "pseudonymous_wallet", strconv.FormatInt(wallet.ID, 10),
+		walletKeyState{KeyFingerprint: utils.KeyFingerprint(wallet.PublicKey)}, walletKeyState{RevokeReason: reason})
+	return nil
+}
+
+// GetKeyHistory lists every key a wallet has had, newest first.
+func (s *PseudonymousWalletService) GetKeyHistory(walletID int64) ([]*models.PseudonymousWalletKey, error) {
+	if _, err := s.findWallet(walletID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindKeys(walletID)
+}
+
+func (s *PseudonymousWalletService) findWallet(walletID int64) (*models.PseudonymousWallet, error) {
+	wallet, err := s.repo.FindByID(walletID)
+	if err == sql.ErrNoRows {
+		return nil, ErrPseudonymousWalletNotFound
+	}
+	return wallet, err
+}
+
+func (s *PseudonymousWalletService) activeKey(walletID int64) (*models.PseudonymousWallet, *ecdsa.PublicKey, error) {
+	wallet, err := s.findWallet(walletID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if wallet.PublicKey == "" {
+		return nil, nil, ErrWalletKeyRevoked
+	}
+	pub, err := utils.DecodePublicKey(wallet.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wallet, pub, nil
+}
+
+// verifyProofOfPossession checks that proof is the signature of KeyRegistrationMessage by publicKey
+func verifyProofOfPossession(publicKey, proof string) error {
+	pub, err := utils.DecodePublicKey(publicKey)
+	if err != nil {
+		return ErrInvalidPublicKey
+	}
+	if !utils.VerifySignature(pub, utils.KeyRegistrationMessage(publicKey), proof) {
+		return ErrInvalidProofOfPossession
 	}
-	// TODO: Securely handle private key
-	return w.ID, pubStr, "", nil
+	return nil
 }
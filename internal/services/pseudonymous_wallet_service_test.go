@@ -0,0 +1,123 @@
+package services_test
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"verve/internal/models"
+	"verve/internal/repository"
+	"verve/internal/services"
+	"verve/pkg/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPseudonymousWalletKeyLifecycle(t *testing.T) {
+	repo := &mockPseudonymousWalletRepo{wallets: make(map[int64]*models.PseudonymousWallet)}
+	auditRepo := &mockAuditRepo{}
+	service := services.NewPseudonymousWalletService(repo, services.NewAuditService(auditRepo))
+
+	newKey := func() (*ecdsa.PrivateKey, string) {
+		key, err := utils.GenerateECDSAKeyPair()
+		require.NoError(t, err)
+		return key, utils.PublicKeyToString(&key.PublicKey)
+	}
+	sign := func(key *ecdsa.PrivateKey, message string) string {
+		signature, err := utils.SignMessage(key, message)
+		require.NoError(t, err)
+		return signature
+	}
+
+	firstKey, firstPub := newKey()
+	secondKey, secondPub := newKey()
+	thirdKey, thirdPub := newKey()
+
+	t.Run("Registration requires proof of possession", func(t *testing.T) {
+		_, err := service.CreatePseudonymousWallet(models.Actor{}, firstPub, sign(secondKey, utils.KeyRegistrationMessage(firstPub)))
+		assert.ErrorIs(t, err, services.ErrInvalidProofOfPossession)
+
+		_, err = service.CreatePseudonymousWallet(models.Actor{}, "not-a-key", "")
+		assert.ErrorIs(t, err, services.ErrInvalidPublicKey)
+	})
+
+	wallet, err := service.CreatePseudonymousWallet(models.Actor{}, firstPub, sign(firstKey, utils.KeyRegistrationMessage(firstPub)))
+	require.NoError(t, err)
+
+	t.Run("Same key cannot be registered twice", func(t *testing.T) {
+		_, err := service.CreatePseudonymousWallet(models.Actor{}, firstPub, sign(firstKey, utils.KeyRegistrationMessage(firstPub)))
+		assert.ErrorIs(t, err, repository.ErrPublicKeyInUse)
+	})
+
+	t.Run("Rotation must be endorsed by the active key", func(t *testing.T) {
+		_, err := service.RotateKey(models.Actor{}, wallet.ID, secondPub,
+			sign(secondKey, utils.KeyRegistrationMessage(secondPub)),
+			sign(secondKey, utils.KeyRotationMessage(wallet.ID, secondPub)),
+		)
+		assert.ErrorIs(t, err, services.ErrInvalidSignature)
+	})
+
+	t.Run("Rotation retires the previous key", func(t *testing.T) {
+		_, err := service.RotateKey(models.Actor{}, wallet.ID, secondPub,
+			sign(secondKey, utils.KeyRegistrationMessage(secondPub)),
+			sign(firstKey, utils.KeyRotationMessage(wallet.ID, secondPub)),
+		)
+		require.NoError(t, err)
+
+		// The old key can no longer act for the wallet
+		_, err = service.RotateKey(models.Actor{}, wallet.ID, thirdPub,
+			sign(thirdKey, utils.KeyRegistrationMessage(thirdPub)),
+			sign(firstKey, utils.KeyRotationMessage(wallet.ID, thirdPub)),
+		)
+		assert.ErrorIs(t, err, services.ErrInvalidSignature)
+
+		keys, err := service.GetKeyHistory(wallet.ID)
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, secondPub, keys[0].PublicKey)
+		assert.Equal(t, models.WalletKeyStatusActive, keys[0].Status)
+		assert.Equal(t, models.WalletKeyStatusRotated, keys[1].Status)
+	})
+
+	t.Run("Revoked wallet cannot rotate", func(t *testing.T) {
+		err := service.RevokeKey(models.Actor{}, wallet.ID, sign(secondKey, utils.KeyRevocationMessage(wallet.ID, secondPub)), "Device lost")
+		require.NoError(t, err)
+
+		_, err = service.RotateKey(models.Actor{}, wallet.ID, thirdPub,
+			sign(thirdKey, utils.KeyRegistrationMessage(thirdPub)),
+			sign(secondKey, utils.KeyRotationMessage(wallet.ID, thirdPub)),
+		)
+		assert.ErrorIs(t, err, services.ErrWalletKeyRevoked)
+	})
+
+	t.Run("Key changes are audited by fingerprint only", func(t *testing.T) {
+		type keyState struct {
+			KeyFingerprint string `json:"key_fingerprint"`
+		}
+		state := func(raw json.RawMessage) keyState {
+			var state keyState
+			require.NoError(t, json.Unmarshal(raw, &state))
+			return state
+		}
+
+		require.Len(t, auditRepo.entries, 3)
+		register, rotate, revoke := auditRepo.entries[0], auditRepo.entries[1], auditRepo.entries[2]
+		assert.Equal(t, models.AuditActionWalletRegister, register.Action)
+		assert.Equal(t, utils.KeyFingerprint(firstPub), state(register.After).KeyFingerprint)
+		assert.Equal(t, models.AuditActionWalletKeyRotate, rotate.Action)
+		assert.Equal(t, utils.KeyFingerprint(firstPub), state(rotate.Before).KeyFingerprint)
+		assert.Equal(t, utils.KeyFingerprint(secondPub), state(rotate.After).KeyFingerprint)
+		assert.Equal(t, models.AuditActionWalletKeyRevoke, revoke.Action)
+		assert.Equal(t, utils.KeyFingerprint(secondPub), state(revoke.Before).KeyFingerprint)
+
+		for _, entry := range auditRepo.entries {
+			assert.Equal(t, strconv.FormatInt(wallet.ID, 10), entry.TargetID)
+			for _, raw := range []json.RawMessage{entry.Before, entry.After, entry.Diff} {
+				for _, pub := range []string{firstPub, secondPub, thirdPub} {
+					assert.NotContains(t, string(raw), pub)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+// adminRole is the role that must always keep a holder and the permission to manage roles
+const adminRole = "admin"
+
+// managePermission is the permission needed to manage roles and permissions
+const managePermission = "manage_roles"
+
+var (
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrPermissionNotFound = errors.New("permission not found")
+	ErrInvalidRoleName    = errors.New("role name must be 1 to 50 characters")
+	ErrProtectedRole      = errors.New("the admin role cannot be deleted or lose the manage_roles permission")
+	ErrLastAdmin          = errors.New("the last admin cannot lose the admin role")
+	ErrRoleNotAssigned    = errors.New("user does not have the role")
+)
+
+// RoleService manages roles, the permissions they grant and the roles of users. Every
+// change is audited. Permission changes apply right away; role changes apply to a user
+// from their next sign-in or token refresh.
+type RoleService struct {
+	roleRepo          repository.RoleRepository
+	userRepo          repository.UserRepository
+	permissionService *PermissionService
+	auditService      *AuditService
+}
+
+// NewRoleService creates a new RoleService.
+func NewRoleService(roleRepo repository.RoleRepository, userRepo repository.UserRepository, permissionService *PermissionService, auditService *AuditService) *RoleService {
+	return &RoleService{
+		roleRepo:          roleRepo,
+		userRepo:          userRepo,
+		permissionService: permissionService,
+		auditService:      auditService,
+	}
+}
+
+// ListRoles returns every role with the permissions it grants
+func (s *RoleService) ListRoles() ([]*models.Role, error) {
+	return s.roleRepo.FindAll()
+}
+
+// ListPermissions returns every permission a role can grant
+func (s *RoleService) ListPermissions() ([]*models.Permission, error) {
+	return s.roleRepo.FindAllPermissions()
+}
+
+// findRole returns a role with its permissions, or ErrRoleNotFound
+func (s *RoleService) findRole(name string) (*models.Role, error) {
+	role, err := s.roleRepo.FindByName(name)
+	if err == sql.ErrNoRows {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if role.Permissions, err = s.roleRepo.GetPermissions(name); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// CreateRole creates a role that grants no permission yet
+func (s *RoleService) CreateRole(actor models.Actor, name string) (*models.Role, error) {
+	if name == "" || len(name) > 50 {
+		return nil, ErrInvalidRoleName
+	}
+
+	role := &models.Role{Name: name}
+	if err := s.roleRepo.Create(role); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditActionRoleCreate, "role", name, nil, role)
+	return role, nil
+}
+
+// DeleteRole deletes a role no user holds
+func (s *RoleService) DeleteRole(actor models.Actor, name string) error {
+	if name == adminRole {
+		return ErrProtectedRole
+	}
+	role, err := s.findRole(name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.roleRepo.Delete(role.ID); err != nil {
+		return err
+	}
+	s.permissionService.Invalidate()
+
+	s.auditService.Record(actor, models.AuditActionRoleDelete, "role", name, role, nil)
+	return nil
+}
+
+// GrantPermission grants a permission to a role
+func (s *RoleService) GrantPermission(actor models.Actor, roleName, permission string) (*models.Role, error) {
+	return s.changePermission(actor, roleName, permission, true)
+}
+
+// RevokePermission revokes a permission from a role
+func (s *RoleService) RevokePermission(actor models.Actor, roleName, permission string) (*models.Role, error) {
+	if roleName == adminRole && permission == managePermission {
+		return nil, ErrProtectedRole
+	}
+	return s.changePermission(actor, roleName, permission, false)
+}
+
+func (s *RoleService) changePermission(actor models.Actor, roleName, permission string, grant bool) (*models.Role, error) {
+	before, err := s.findRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	action := models.AuditActionRolePermissionRevoke
+	if grant {
+		action = models.AuditActionRolePermissionGrant
+		err = s.roleRepo.GrantPermission(before.ID, permission)
+		if err == sql.ErrNoRows {
+			return nil, ErrPermissionNotFound
+		}
+	} else {
+		err = s.roleRepo.RevokePermission(before.ID, permission)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.permissionService.Invalidate()
+
+	after, err := s.findRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+	s.auditService.Record(actor, action, "role", roleName, before, after)
+	return after, nil
+}
+
+// userRoles are the roles of a user as recorded in the audit log
+type userRoles struct {
+	Roles []string `json:"roles"`
+}
+
+// AssignRole gives a user a role
+func (s *RoleService) AssignRole(actor models.Actor, userID int, roleName string) (*models.UserPermissions, error) {
+	return s.changeUserRole(actor, userID, roleName, true)
+}
+
+// RemoveRole takes a role from a user. The last admin keeps the admin role.
+func (s *RoleService) RemoveRole(actor models.Actor, userID int, roleName string) (*models.UserPermissions, error) {
+	return s.changeUserRole(actor, userID, roleName, false)
+}
+
+func (s *RoleService) changeUserRole(actor models.Actor, userID int, roleName string, assign bool) (*models.UserPermissions, error) {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return nil, err
+	}
+	role, err := s.findRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+	before, err := s.roleRepo.GetForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	action := models.AuditActionUserRoleRemove
+	if assign {
+		action = models.AuditActionUserRoleAssign
+		err = s.roleRepo.AssignToUser(userID, role.ID)
+	} else {
+		err = s.roleRepo.RemoveFromUser(userID, role.ID, roleName == adminRole)
+		if errors.Is(err, repository.ErrLastRoleHolder) {
+			return nil, ErrLastAdmin
+		}
+		if err == sql.ErrNoRows {
+			return nil, ErrRoleNotAssigned
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.GetUserPermissions(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.auditService.Record(actor, action, "user", strconv.Itoa(userID), userRoles{Roles: sortedRoles(before)}, userRoles{Roles: permissions.Roles})
+	return permissions, nil
+}
+
+// GetUserPermissions returns the roles of a user and the permissions they grant
+func (s *RoleService) GetUserPermissions(userID int) (*models.UserPermissions, error) {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return nil, err
+	}
+	roles, err := s.roleRepo.GetForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := s.permissionService.EffectivePermissions(roles)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UserPermissions{UserID: userID, Roles: sortedRoles(roles), Permissions: permissions}, nil
+}
+
+func sortedRoles(roles []string) []string {
+	sorted := append([]string{}, roles...)
+	sort.Strings(sorted)
+	return sorted
+}
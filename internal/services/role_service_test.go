@@ -0,0 +1,232 @@
+package services_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleService(t *testing.T) {
+	roleRepo := &mockRoleRepo{
+		roles:       map[int][]string{1: {"admin", "user"}, 2: {"user"}},
+		permissions: map[string][]string{"admin": {"manage_roles", "create_badge"}, "user": nil},
+		names:       map[int]string{1: "admin", 2: "user"},
+		catalog:     []string{"manage_roles", "create_badge", "assign_badge"},
+	}
+	auditRepo := &mockAuditRepo{}
+	permissionService := services.NewPermissionService(roleRepo, time.Hour)
+	service := services.NewRoleService(roleRepo, newMockUserRepo(), permissionService, services.NewAuditService(auditRepo))
+
+	t.Run("Granted permissions apply right away", func(t *testing.T) {
+		allowed, err := permissionService.HasPermission([]string{"user"}, "assign_badge")
+		require.NoError(t, err)
+		require.False(t, allowed)
+
+		role, err := service.GrantPermission(models.Actor{UserID: 1}, "user", "assign_badge")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"assign_badge"}, role.Permissions)
+
+		allowed, err = permissionService.HasPermission([]string{"user"}, "assign_badge")
+		require.NoError(t, err)
+		assert.True(t, allowed)
+
+		entry := auditRepo.entries[len(auditRepo.entries)-1]
+		assert.Equal(t, models.AuditActionRolePermissionGrant, entry.Action)
+		assert.Equal(t, 1, *entry.ActorID)
+		assert.Equal(t, "user", entry.TargetID)
+		var after models.Role
+		require.NoError(t, json.Unmarshal(entry.After, &after))
+		assert.Equal(t, []string{"assign_badge"}, after.Permissions)
+	})
+
+	t.Run("Unknown permissions cannot be granted", func(t *testing.T) {
+		_, err := service.GrantPermission(models.Actor{UserID: 1}, "user", "launch_rockets")
+		assert.ErrorIs(t, err, services.ErrPermissionNotFound)
+	})
+
+	t.Run("The admin role is protected", func(t *testing.T) {
+		err := service.DeleteRole(models.Actor{UserID: 1}, "admin")
+		assert.ErrorIs(t, err, services.ErrProtectedRole)
+
+		_, err = service.RevokePermission(models.Actor{UserID: 1}, "admin", "manage_roles")
+		assert.ErrorIs(t, err, services.ErrProtectedRole)
+	})
+
+	t.Run("Roles held by users cannot be deleted", func(t *testing.T) {
+		err := service.DeleteRole(models.Actor{UserID: 1}, "user")
+		assert.ErrorIs(t, err, repository.ErrRoleInUse)
+	})
+
+	t.Run("The last admin keeps the admin role", func(t *testing.T) {
+		_, err := service.RemoveRole(models.Actor{UserID: 1}, 1, "admin")
+		assert.ErrorIs(t, err, services.ErrLastAdmin)
+
+		permissions, err := service.AssignRole(models.Actor{UserID: 1}, 2, "admin")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"admin", "user"}, permissions.Roles)
+		assert.Contains(t, permissions.Permissions, "manage_roles")
+
+		permissions, err = service.RemoveRole(models.Actor{UserID: 2}, 1, "admin")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user"}, permissions.Roles)
+
+		entry := auditRepo.entries[len(auditRepo.entries)-1]
+		assert.Equal(t, models.AuditActionUserRoleRemove, entry.Action)
+		assert.JSONEq(t, `{"roles":["admin","user"]}`, string(entry.Before))
+		assert.JSONEq(t, `{"roles":["user"]}`, string(entry.After))
+	})
+
+	t.Run("Roles can be created and deleted", func(t *testing.T) {
+		_, err := service.CreateRole(models.Actor{UserID: 2}, "manager")
+		require.NoError(t, err)
+
+		_, err = service.CreateRole(models.Actor{UserID: 2}, "manager")
+		assert.ErrorIs(t, err, repository.ErrRoleExists)
+
+		require.NoError(t, service.DeleteRole(models.Actor{UserID: 2}, "manager"))
+		_, err = service.RemoveRole(models.Actor{UserID: 2}, 2, "manager")
+		assert.ErrorIs(t, err, services.ErrRoleNotFound)
+	})
+}
+
+// mockRoleRepo holds the roles of users by name. Roles known by ID are listed in names, and
+// catalog lists the permissions that exist.
+type mockRoleRepo struct {
+	roles           map[int][]string
+	permissions     map[string][]string
+	names           map[int]string
+	catalog         []string
+	permissionReads int
+}
+
+func (m *mockRoleRepo) FindByName(name string) (*models.Role, error) {
+	for id, roleName := range m.names {
+		if roleName == name {
+			return &models.Role{ID: id, Name: name}, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *mockRoleRepo) AssignToUser(userID, roleID int) error {
+	for _, role := range m.roles[userID] {
+		if role == m.names[roleID] {
+			return repository.ErrRoleAlreadyAssigned
+		}
+	}
+	m.roles[userID] = append(m.roles[userID], m.names[roleID])
+	return nil
+}
+
+func (m *mockRoleRepo) GetForUser(userID int) ([]string, error) {
+	return m.roles[userID], nil
+}
+
+func (m *mockRoleRepo) GetPermissions(roleName string) ([]string, error) {
+	m.permissionReads++
+	return m.permissions[roleName], nil
+}
+
+func (m *mockRoleRepo) FindAll() ([]*models.Role, error) {
+	var roles []*models.Role
+	for id, name := range m.names {
+		roles = append(roles, &models.Role{ID: id, Name: name, Permissions: m.permissions[name]})
+	}
+	return roles, nil
+}
+
+func (m *mockRoleRepo) Create(role *models.Role) error {
+	if _, err := m.FindByName(role.Name); err == nil {
+		return repository.ErrRoleExists
+	}
+	role.ID = len(m.names) + 1
+	m.names[role.ID] = role.Name
+	return nil
+}
+
+func (m *mockRoleRepo) Delete(roleID int) error {
+	if len(m.holders(m.names[roleID])) > 0 {
+		return repository.ErrRoleInUse
+	}
+	delete(m.permissions, m.names[roleID])
+	delete(m.names, roleID)
+	return nil
+}
+
+func (m *mockRoleRepo) FindAllPermissions() ([]*models.Permission, error) {
+	var permissions []*models.Permission
+	for i, name := range m.catalog {
+		permissions = append(permissions, &models.Permission{ID: i + 1, Name: name})
+	}
+	return permissions, nil
+}
+
+func (m *mockRoleRepo) GrantPermission(roleID int, permission string) error {
+	known := false
+	for _, name := range m.catalog {
+		known = known || name == permission
+	}
+	if !known {
+		return sql.ErrNoRows
+	}
+	name := m.names[roleID]
+	for _, granted := range m.permissions[name] {
+		if granted == permission {
+			return nil
+		}
+	}
+	m.permissions[name] = append(m.permissions[name], permission)
+	return nil
+}
+
+func (m *mockRoleRepo) RevokePermission(roleID int, permission string) error {
+	name := m.names[roleID]
+	m.permissions[name] = removeString(m.permissions[name], permission)
+	return nil
+}
+
+func (m *mockRoleRepo) RemoveFromUser(userID, roleID int, keepLastHolder bool) error {
+	name := m.names[roleID]
+	holders := m.holders(name)
+	held := false
+	for _, holder := range holders {
+		held = held || holder == userID
+	}
+	if !held {
+		return sql.ErrNoRows
+	}
+	if keepLastHolder && len(holders) == 1 {
+		return repository.ErrLastRoleHolder
+	}
+	m.roles[userID] = removeString(m.roles[userID], name)
+	return nil
+}
+
+func (m *mockRoleRepo) holders(role string) []int {
+	var holders []int
+	for userID, roles := range m.roles {
+		for _, name := range roles {
+			if name == role {
+				holders = append(holders, userID)
+			}
+		}
+	}
+	return holders
+}
+
+func removeString(values []string, value string) []string {
+	var kept []string
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
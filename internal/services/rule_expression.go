@@ -0,0 +1,193 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"verve/internal/models"
+)
+
+const (
+	maxRuleExpressionDepth = 8
+	maxRuleExpressionNodes = 50
+)
+
+// ErrInvalidAchievementRule is returned when a rule, or a condition it holds, is malformed
+var ErrInvalidAchievementRule = errors.New("invalid achievement rule")
+
+// parseRule parses and validates the condition of a rule. A rule that is not an expression
+// is an expression of a single condition.
+func parseRule(rule *models.AchievementRule) (*models.RuleExpression, error) {
+	if models.BadgeLevelRank(rule.Level) < 0 {
+		return nil, errors.New("level must be bronze, silver or gold")
+	}
+
+	expr := &models.RuleExpression{Condition: rule.RuleType, Value: rule.ConditionValue}
+	if rule.RuleType == models.RuleTypeExpression {
+		expr = &models.RuleExpression{}
+		if err := json.Unmarshal(rule.ConditionValue, expr); err != nil {
+			return nil, fmt.Errorf("invalid expression: %w", err)
+		}
+	}
+
+	nodes := 0
+	if err := validateExpression(expr, 1, &nodes); err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+func validateExpression(expr *models.RuleExpression, depth int, nodes *int) error {
+	*nodes++
+	if depth > maxRuleExpressionDepth {
+		return fmt.Errorf("expression is nested deeper than %d levels", maxRuleExpressionDepth)
+	}
+	if *nodes > maxRuleExpressionNodes {
+		return fmt.Errorf("expression has more than %d nodes", maxRuleExpressionNodes)
+	}
+
+	switch expr.Operator {
+	case models.RuleOperatorAnd, models.RuleOperatorOr:
+		if len(expr.Operands) == 0 {
+			return fmt.Errorf("%s needs at least one expression", expr.Operator)
+		}
+	case models.RuleOperatorNot:
+		if len(expr.Operands) != 1 {
+			return errors.New("not takes exactly one expression")
+		}
+	default:
+		return validateCondition(expr.Condition, expr.Value)
+	}
+
+	for _, operand := range expr.Operands {
+		if operand == nil {
+			return fmt.Errorf("%s holds an empty expression", expr.Operator)
+		}
+		if err := validateExpression(operand, depth+1, nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateCondition validates the condition format based on rule type
+func validateCondition(ruleType string, value json.RawMessage) error {
+	switch ruleType {
+	case models.RuleTypeTransactionCount:
+		var condition models.TransactionCountCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return errors.New("invalid transaction count condition format")
+		}
+		if condition.MinTransactions <= 0 {
+			return errors.New("min_transactions must be positive")
+		}
+		if _, err := ParseTimeFrame(condition.TimeFrame); err != nil {
+			return err
+		}
+
+	case models.RuleTypeTransferAmount:
+		var condition models.TransferAmountCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return errors.New("invalid transfer amount condition format")
+		}
+		if condition.MinAmount <= 0 {
+			return errors.New("min_amount must be positive")
+		}
+		if _, err := ParseTimeFrame(condition.TimeFrame); err != nil {
+			return err
+		}
+		if err := validateDirection(condition.Direction); err != nil {
+			return err
+		}
+
+	case models.RuleTypeConsecutiveDays:
+		var condition models.ConsecutiveDaysCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return errors.New("invalid consecutive days condition format")
+		}
+		if condition.Days <= 0 {
+			return errors.New("days must be positive")
+		}
+		if condition.ActivityType != models.ActivityTypeLogin && condition.ActivityType != models.ActivityTypeTransfer {
+			return errors.New("activity_type must be login or transfer")
+		}
+		if condition.MinActivities < 0 {
+			return errors.New("min_activities must not be negative")
+		}
+
+	case models.RuleTypeDistinctCounterparties:
+		var condition models.DistinctCounterpartiesCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return errors.New("invalid distinct counterparties condition format")
+		}
+		if condition.MinCounterparties <= 0 {
+			return errors.New("min_counterparties must be positive")
+		}
+		if _, err := ParseTimeFrame(condition.TimeFrame); err != nil {
+			return err
+		}
+		if err := validateDirection(condition.Direction); err != nil {
+			return err
+		}
+
+	case models.RuleTypeFirstTransfer:
+		var condition models.FirstTransferCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return errors.New("invalid first transfer condition format")
+		}
+		if condition.Direction != "" && condition.Direction != models.DirectionSent && condition.Direction != models.DirectionReceived {
+			return errors.New("direction must be sent or received")
+		}
+
+	case models.RuleTypeHasBadge:
+		var condition models.HasBadgeCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return errors.New("invalid has badge condition format")
+		}
+		if condition.BadgeID <= 0 {
+			return errors.New("badge_id must be positive")
+		}
+
+	case models.RuleTypeAccountAge:
+		var condition models.AccountAgeCondition
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return errors.New("invalid account age condition format")
+		}
+		if condition.MinAge == "" {
+			return errors.New("min_age is required")
+		}
+		if _, err := ParseTimeFrame(condition.MinAge); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unsupported rule type %q", ruleType)
+	}
+
+	return nil
+}
+
+func validateDirection(direction string) error {
+	switch direction {
+	case "", models.DirectionSent, models.DirectionReceived, models.DirectionTotal:
+		return nil
+	}
+	return errors.New("direction must be sent, received or total")
+}
+
+// referencedBadges returns the badges the has_badge conditions of an expression refer to
+func referencedBadges(expr *models.RuleExpression) []int {
+	if expr.Operator == "" {
+		var condition models.HasBadgeCondition
+		if expr.Condition == models.RuleTypeHasBadge && json.Unmarshal(expr.Value, &condition) == nil {
+			return []int{condition.BadgeID}
+		}
+		return nil
+	}
+
+	var badgeIDs []int
+	for _, operand := range expr.Operands {
+		badgeIDs = append(badgeIDs, referencedBadges(operand)...)
+	}
+	return badgeIDs
+}
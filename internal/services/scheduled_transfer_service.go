@@ -0,0 +1,443 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+	"verve/pkg/utils"
+)
+
+const (
+	defaultScheduleLimit = 20
+	maxScheduleLimit     = 100
+
+	// scheduleLease is how long a scheduler may take to run a claimed schedule before
+	// another scheduler takes over
+	scheduleLease = 5 * time.Minute
+	// scheduleBatchSize bounds the schedules claimed at once
+	scheduleBatchSize = 50
+	// maxMissedRunScan bounds the missed runs counted after downtime
+	maxMissedRunScan = 100000
+)
+
+var (
+	ErrScheduleNotFound     = errors.New("scheduled transfer not found")
+	ErrScheduleNotActive    = errors.New("scheduled transfer is not active")
+	ErrScheduleNotPaused    = errors.New("scheduled transfer is not paused")
+	ErrScheduleFinished     = errors.New("scheduled transfer is already completed or cancelled")
+	ErrInvalidCron          = errors.New("invalid cron expression")
+	ErrInvalidTimezone      = errors.New("unknown timezone")
+	ErrInvalidCatchUp       = errors.New("catch_up must be latest or skip")
+	ErrInvalidRunAt         = errors.New("run_at must be in the future")
+	ErrRunAtRequired        = errors.New("run_at is required for a one-off transfer")
+	ErrInvalidMaxRuns       = errors.New("max_runs must be positive")
+	ErrOneOffLimits         = errors.New("end_at and max_runs only apply to recurring transfers")
+	ErrNoScheduledRuns      = errors.New("schedule has no runs before its end")
+	ErrInvalidScheduleState = errors.New("status must be active, paused, completed or cancelled")
+)
+
+// ScheduledTransferService makes transfers at a later time, once or on a cron schedule. Runs
+// are made by RunScheduler through the checks of InitiateTransfer; the PIN is checked once,
+// when the schedule is created.
+type ScheduledTransferService struct {
+	scheduleRepo    repository.ScheduledTransferRepository
+	transferService *TransferService
+	missedAfter     time.Duration
+}
+
+// NewScheduledTransferService creates a new ScheduledTransferService. A run is missed when the
+// scheduler gets to it more than missedAfter after it was due.
+func NewScheduledTransferService(
+	scheduleRepo repository.ScheduledTransferRepository,
+	transferService *TransferService,
+	missedAfter time.Duration,
+) *ScheduledTransferService {
+	return &ScheduledTransferService{
+		scheduleRepo:    scheduleRepo,
+		transferService: transferService,
+		missedAfter:     missedAfter,
+	}
+}
+
+// CreateSchedule schedules a transfer out of a wallet of the actor. Without a cron expression
+// the transfer is made once at runAt; with one it recurs, from runAt on when given, until
+// schedule.EndAt or schedule.MaxRuns runs. The transfer is checked as by InitiateTransfer,
+// PIN included, before the schedule is stored.
+func (s *ScheduledTransferService) CreateSchedule(
+	actor models.Actor,
+	schedule *models.ScheduledTransfer,
+	runAt *time.Time,
+	pin string,
+) (*models.ScheduledTransfer, error) {
+	note, err := s.transferService.CheckTransfer(
+		actor,
+		schedule.SenderWalletID,
+		schedule.ReceiverWalletID,
+		schedule.Amount,
+		models.TransferNote{Message: schedule.Message, Category: schedule.Category, Visibility: schedule.Visibility},
+		pin,
+	)
+	if err != nil {
+		return nil, err
+	}
+	schedule.Message, schedule.Category, schedule.Visibility = note.Message, note.Category, note.Visibility
+
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	switch schedule.CatchUp {
+	case "":
+		schedule.CatchUp = models.CatchUpLatest
+	case models.CatchUpLatest, models.CatchUpSkip:
+	default:
+		return nil, ErrInvalidCatchUp
+	}
+	if schedule.MaxRuns != nil && *schedule.MaxRuns <= 0 {
+		return nil, ErrInvalidMaxRuns
+	}
+
+	now := time.Now()
+	if runAt != nil && !runAt.After(now) {
+		return nil, ErrInvalidRunAt
+	}
+
+	var firstRun time.Time
+	if schedule.Cron == "" {
+		if runAt == nil {
+			return nil, ErrRunAtRequired
+		}
+		if schedule.EndAt != nil || schedule.MaxRuns != nil {
+			return nil, ErrOneOffLimits
+		}
+		firstRun = *runAt
+	} else {
+		cron, err := utils.ParseCron(schedule.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCron, err)
+		}
+		start := now
+		if runAt != nil {
+			// The first run may fall on runAt itself
+			start = runAt.Add(-time.Nanosecond)
+		}
+		firstRun = cron.Next(start.In(loc))
+		if firstRun.IsZero() || (schedule.EndAt != nil && firstRun.After(*schedule.EndAt)) {
+			return nil, ErrNoScheduledRuns
+		}
+	}
+
+	schedule.UserID = actor.UserID
+	schedule.RunCount = 0
+	schedule.NextRunAt = &firstRun
+	schedule.Status = models.ScheduledTransferStatusActive
+	if err := s.scheduleRepo.Create(schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// GetSchedule returns a scheduled transfer of a user
+func (s *ScheduledTransferService) GetSchedule(userID int, id int64) (*models.ScheduledTransfer, error) {
+	schedule, err := s.scheduleRepo.FindByID(id)
+	if err == sql.ErrNoRows {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if schedule.UserID != userID {
+		return nil, ErrScheduleNotFound
+	}
+	return schedule, nil
+}
+
+// ListSchedules returns a page of the scheduled transfers of a user, newest first, and the
+// ID to pass as beforeID for the next page, 0 when there is none
+func (s *ScheduledTransferService) ListSchedules(userID int, status models.ScheduledTransferStatus, beforeID int64, limit int) ([]*models.ScheduledTransfer, int64, error) {
+	switch status {
+	case "", models.ScheduledTransferStatusActive, models.ScheduledTransferStatusPaused,
+		models.ScheduledTransferStatusCompleted, models.ScheduledTransferStatusCancelled:
+	default:
+		return nil, 0, ErrInvalidScheduleState
+	}
+	limit = clampScheduleLimit(limit)
+
+	// Fetch one extra schedule to know whether another page follows
+	schedules, err := s.scheduleRepo.Find(models.ScheduledTransferFilter{UserID: userID, Status: status, BeforeID: beforeID, Limit: limit + 1})
+	if err != nil {
+		return nil, 0, err
+	}
+	var nextBeforeID int64
+	if len(schedules) > limit {
+		schedules = schedules[:limit]
+		nextBeforeID = schedules[limit-1].ID
+	}
+	return schedules, nextBeforeID, nil
+}
+
+// ListExecutions returns a page of the runs of a scheduled transfer of a user, newest first,
+// and the ID to pass as beforeID for the next page, 0 when there is none
+func (s *ScheduledTransferService) ListExecutions(userID int, id, beforeID int64, limit int) ([]*models.ScheduledTransferExecution, int64, error) {
+	if _, err := s.GetSchedule(userID, id); err != nil {
+		return nil, 0, err
+	}
+	limit = clampScheduleLimit(limit)
+
+	executions, err := s.scheduleRepo.FindExecutions(id, beforeID, limit+1)
+	if err != nil {
+		return nil, 0, err
+	}
+	var nextBeforeID int64
+	if len(executions) > limit {
+		executions = executions[:limit]
+		nextBeforeID = executions[limit-1].ID
+	}
+	return executions, nextBeforeID, nil
+}
+
+func clampScheduleLimit(limit int) int {
+	if limit <= 0 {
+		return defaultScheduleLimit
+	}
+	if limit > maxScheduleLimit {
+		return maxScheduleLimit
+	}
+	return limit
+}
+
+// PauseSchedule stops an active scheduled transfer of a user from running
+func (s *ScheduledTransferService) PauseSchedule(userID int, id int64) (*models.ScheduledTransfer, error) {
+	schedule, err := s.GetSchedule(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status != models.ScheduledTransferStatusActive {
+		return nil, ErrScheduleNotActive
+	}
+	return s.update(schedule, models.ScheduledTransferStatusPaused, schedule.NextRunAt, ErrScheduleNotActive)
+}
+
+// ResumeSchedule lets a paused scheduled transfer of a user run again. Runs that fell due
+// while it was paused are not made: a recurring transfer goes on with its next run from now,
+// and a one-off transfer that was due runs right away.
+func (s *ScheduledTransferService) ResumeSchedule(userID int, id int64) (*models.ScheduledTransfer, error) {
+	schedule, err := s.GetSchedule(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status != models.ScheduledTransferStatusPaused {
+		return nil, ErrScheduleNotPaused
+	}
+
+	now := time.Now()
+	next := schedule.NextRunAt
+	if next != nil && next.Before(now) {
+		if schedule.Cron == "" {
+			next = &now
+		} else {
+			next, err = s.nextRun(schedule, now)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if next == nil {
+		return s.update(schedule, models.ScheduledTransferStatusCompleted, nil, ErrScheduleNotPaused)
+	}
+	return s.update(schedule, models.ScheduledTransferStatusActive, next, ErrScheduleNotPaused)
+}
+
+// CancelSchedule stops a scheduled transfer of a user for good
+func (s *ScheduledTransferService) CancelSchedule(userID int, id int64) (*models.ScheduledTransfer, error) {
+	schedule, err := s.GetSchedule(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status != models.ScheduledTransferStatusActive && schedule.Status != models.ScheduledTransferStatusPaused {
+		return nil, ErrScheduleFinished
+	}
+	return s.update(schedule, models.ScheduledTransferStatusCancelled, nil, ErrScheduleFinished)
+}
+
+// update moves a schedule to another status, failing with conflict when its status changed
+// since it was read
+func (s *ScheduledTransferService) update(
+	schedule *models.ScheduledTransfer,
+	status models.ScheduledTransferStatus,
+	nextRunAt *time.Time,
+	conflict error,
+) (*models.ScheduledTransfer, error) {
+	updated, err := s.scheduleRepo.UpdateStatus(schedule.ID, schedule.Status, status, nextRunAt)
+	if err == sql.ErrNoRows {
+		return nil, conflict
+	}
+	return updated, err
+}
+
+// nextRun returns the first run of a recurring schedule after t, or nil when it has no runs
+// left before its end date or its maximum count
+func (s *ScheduledTransferService) nextRun(schedule *models.ScheduledTransfer, t time.Time) (*time.Time, error) {
+	if schedule.MaxRuns != nil && schedule.RunCount >= *schedule.MaxRuns {
+		return nil, nil
+	}
+	cron, err := utils.ParseCron(schedule.Cron)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	next := cron.Next(t.In(loc))
+	if next.IsZero() || (schedule.EndAt != nil && next.After(*schedule.EndAt)) {
+		return nil, nil
+	}
+	return &next, nil
+}
+
+// RunDue makes the runs of the schedules due at now and returns how many schedules it ran.
+//
+// When the scheduler was down, several runs of a recurring schedule may have fallen due.
+// The catch-up policy of the schedule decides which are made: with latest, the latest of
+// them is made and the earlier ones are skipped; with skip, all of them are skipped unless
+// the latest is less than missedAfter late. Skipped runs are recorded, but do not count
+// towards the maximum number of runs. A failed run is recorded and counts like any other.
+func (s *ScheduledTransferService) RunDue(now time.Time) (int, error) {
+	schedules, err := s.scheduleRepo.ClaimDue(now, scheduleLease, scheduleBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for _, schedule := range schedules {
+		if err := s.runSchedule(schedule, now); err != nil {
+			// The schedule is claimed again when its lease ends
+			log.Printf("Failed to run scheduled transfer %d: %v", schedule.ID, err)
+		}
+	}
+	return len(schedules), nil
+}
+
+func (s *ScheduledTransferService) runSchedule(schedule *models.ScheduledTransfer, now time.Time) error {
+	due := *schedule.NextRunAt
+	latest, missed := due, 0
+	if schedule.Cron != "" {
+		cron, err := utils.ParseCron(schedule.Cron)
+		if err != nil {
+			return err
+		}
+		loc, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			return err
+		}
+		// Find the latest run due by now; the earlier ones were missed
+		for i := 0; i < maxMissedRunScan; i++ {
+			next := cron.Next(latest.In(loc))
+			if next.IsZero() || next.After(now) || (schedule.EndAt != nil && next.After(*schedule.EndAt)) {
+				break
+			}
+			latest = next
+			missed++
+		}
+	}
+
+	run := true
+	if schedule.CatchUp == models.CatchUpSkip && now.Sub(latest) > s.missedAfter {
+		run = false
+		missed++
+	}
+	if missed > 0 {
+		if err := s.scheduleRepo.RecordSkipped(schedule.ID, due, missed); err != nil {
+			return err
+		}
+	}
+
+	runs := 0
+	if run {
+		if err := s.execute(schedule, latest); err != nil {
+			return err
+		}
+		runs = 1
+	}
+
+	var next *time.Time
+	if schedule.Cron != "" {
+		schedule.RunCount += runs
+		var err error
+		if next, err = s.nextRun(schedule, latest); err != nil {
+			return err
+		}
+	}
+	return s.scheduleRepo.Advance(schedule.ID, runs, next)
+}
+
+// execute makes the run of a schedule due at scheduledFor, once
+func (s *ScheduledTransferService) execute(schedule *models.ScheduledTransfer, scheduledFor time.Time) error {
+	execution, err := s.scheduleRepo.StartExecution(schedule.ID, scheduledFor)
+	if errors.Is(err, repository.ErrExecutionExists) {
+		// A scheduler that stopped before advancing the schedule started this run
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	actor := models.Actor{UserID: schedule.UserID, RequestID: fmt.Sprintf("scheduled-transfer-%d-%d", schedule.ID, execution.ID)}
+	transfer, err := s.transferService.InitiateScheduledTransfer(
+		actor,
+		schedule.SenderWalletID,
+		schedule.ReceiverWalletID,
+		schedule.Amount,
+		schedule.IsAnonymous,
+		models.TransferNote{Message: schedule.Message, Category: schedule.Category, Visibility: schedule.Visibility},
+	)
+
+	status, errorMessage := models.ScheduledTransferExecutionSucceeded, ""
+	if err != nil {
+		status, errorMessage = models.ScheduledTransferExecutionFailed, err.Error()
+	}
+	var transferID *int64
+	if transfer != nil {
+		transferID = &transfer.ID
+	}
+	return s.scheduleRepo.FinishExecution(execution.ID, status, transferID, errorMessage)
+}
+
+// RunScheduler makes the runs of due schedules every interval until ctx is cancelled, and
+// settles runs a crashed scheduler left unfinished
+func (s *ScheduledTransferService) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if recovered, err := s.scheduleRepo.RecoverRunning(now.Add(-scheduleLease)); err != nil {
+				log.Printf("Failed to recover scheduled transfer runs: %v", err)
+			} else if recovered > 0 {
+				log.Printf("Recovered %d interrupted scheduled transfer runs", recovered)
+			}
+
+			// Keep going while full batches are due, so a backlog clears within one tick
+			for {
+				ran, err := s.RunDue(now)
+				if err != nil {
+					log.Printf("Failed to run scheduled transfers: %v", err)
+				}
+				if err != nil || ran < scheduleBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,451 @@
+package services_test
+
+import (
+	"database/sql"
+	"sort"
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestScheduledTransfers(t *testing.T) {
+	pinHash, err := bcrypt.GenerateFromPassword([]byte("1234"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	setup := func() (*services.ScheduledTransferService, *mockScheduledTransferRepo, *mockTransferRepo) {
+		wallets := map[int64]*models.Wallet{
+			1: {ID: 1, UserID: 1, Currency: "USD", Balance: 100},
+			2: {ID: 2, UserID: 2, Currency: "USD"},
+		}
+		userRepo := newMockUserRepo()
+		userRepo.users[1].PinRequiredForTransfer = true
+		userRepo.users[1].PinHash = string(pinHash)
+		transferRepo := newMockTransferRepo()
+		txRepo := &mockTransactionRepo{wallets: wallets, transferRepo: transferRepo}
+		walletRepo := &mockWalletRepo{wallets: wallets}
+		transferService := services.NewTransferService(transferRepo, txRepo, userRepo, walletRepo, nil, services.NewAuditService(&mockAuditRepo{}))
+		scheduleRepo := newMockScheduledTransferRepo()
+		return services.NewScheduledTransferService(scheduleRepo, transferService, 5*time.Minute), scheduleRepo, transferRepo
+	}
+	alice := models.Actor{UserID: 1}
+	hourly := func(amount int64) *models.ScheduledTransfer {
+		return &models.ScheduledTransfer{SenderWalletID: 1, ReceiverWalletID: 2, Amount: amount, Cron: "0 * * * *"}
+	}
+	// base is a due run of an hourly schedule
+	base := time.Now().UTC().Truncate(time.Hour).Add(-24 * time.Hour)
+
+	t.Run("A one-off transfer runs once at its time", func(t *testing.T) {
+		service, scheduleRepo, transferRepo := setup()
+		runAt := time.Now().Add(time.Hour)
+		schedule, err := service.CreateSchedule(alice, &models.ScheduledTransfer{
+			SenderWalletID: 1, ReceiverWalletID: 2, Amount: 25, Message: "Rent",
+		}, &runAt, "1234")
+		require.NoError(t, err)
+		assert.Equal(t, models.ScheduledTransferStatusActive, schedule.Status)
+		assert.Equal(t, "UTC", schedule.Timezone)
+		assert.Equal(t, models.CatchUpLatest, schedule.CatchUp)
+		require.NotNil(t, schedule.NextRunAt)
+		assert.True(t, runAt.Equal(*schedule.NextRunAt))
+
+		ran, err := service.RunDue(runAt.Add(-time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 0, ran)
+		assert.Empty(t, transferRepo.transfers)
+
+		ran, err = service.RunDue(runAt)
+		require.NoError(t, err)
+		assert.Equal(t, 1, ran)
+		require.Len(t, transferRepo.transfers, 1)
+		transfer := transferRepo.transfers[1]
+		assert.Equal(t, models.TransferStatusCompleted, transfer.Status)
+		assert.Equal(t, int64(25), transfer.Amount)
+		assert.Equal(t, "Rent", transfer.Message)
+
+		schedule, err = service.GetSchedule(1, schedule.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ScheduledTransferStatusCompleted, schedule.Status)
+		assert.Equal(t, 1, schedule.RunCount)
+		assert.Nil(t, schedule.NextRunAt)
+
+		executions, _, err := service.ListExecutions(1, schedule.ID, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, executions, 1)
+		assert.Equal(t, models.ScheduledTransferExecutionSucceeded, executions[0].Status)
+		require.NotNil(t, executions[0].TransferID)
+		assert.Equal(t, transfer.ID, *executions[0].TransferID)
+		assert.Len(t, scheduleRepo.executions, 1)
+
+		ran, err = service.RunDue(runAt.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 0, ran)
+		assert.Len(t, transferRepo.transfers, 1)
+	})
+
+	t.Run("A recurring transfer completes after its maximum runs", func(t *testing.T) {
+		service, scheduleRepo, transferRepo := setup()
+		schedule := hourly(10)
+		maxRuns := 2
+		schedule.MaxRuns = &maxRuns
+		schedule, err := service.CreateSchedule(alice, schedule, nil, "1234")
+		require.NoError(t, err)
+		scheduleRepo.schedules[schedule.ID].NextRunAt = &base
+
+		for i := 0; i < 3; i++ {
+			_, err := service.RunDue(base.Add(time.Duration(i) * time.Hour))
+			require.NoError(t, err)
+		}
+		assert.Len(t, transferRepo.transfers, 2)
+		stored := scheduleRepo.schedules[schedule.ID]
+		assert.Equal(t, models.ScheduledTransferStatusCompleted, stored.Status)
+		assert.Equal(t, 2, stored.RunCount)
+		assert.Nil(t, stored.NextRunAt)
+	})
+
+	t.Run("After downtime the latest missed run is made and the others skipped", func(t *testing.T) {
+		service, scheduleRepo, transferRepo := setup()
+		schedule, err := service.CreateSchedule(alice, hourly(10), nil, "1234")
+		require.NoError(t, err)
+		scheduleRepo.schedules[schedule.ID].NextRunAt = &base
+
+		_, err = service.RunDue(base.Add(3*time.Hour + 30*time.Minute))
+		require.NoError(t, err)
+		assert.Len(t, transferRepo.transfers, 1)
+
+		stored := scheduleRepo.schedules[schedule.ID]
+		assert.Equal(t, 1, stored.RunCount)
+		require.NotNil(t, stored.NextRunAt)
+		assert.True(t, base.Add(4*time.Hour).Equal(*stored.NextRunAt))
+
+		executions, _, err := service.ListExecutions(1, schedule.ID, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, executions, 2)
+		assert.Equal(t, models.ScheduledTransferExecutionSucceeded, executions[0].Status)
+		assert.True(t, base.Add(3*time.Hour).Equal(executions[0].ScheduledFor))
+		assert.Equal(t, models.ScheduledTransferExecutionSkipped, executions[1].Status)
+		assert.True(t, base.Equal(executions[1].ScheduledFor))
+		assert.Equal(t, 3, executions[1].SkippedRuns)
+	})
+
+	t.Run("The skip policy drops runs that are too late", func(t *testing.T) {
+		service, scheduleRepo, transferRepo := setup()
+		schedule := hourly(10)
+		schedule.CatchUp = models.CatchUpSkip
+		schedule, err := service.CreateSchedule(alice, schedule, nil, "1234")
+		require.NoError(t, err)
+		scheduleRepo.schedules[schedule.ID].NextRunAt = &base
+
+		_, err = service.RunDue(base.Add(2 * time.Minute))
+		require.NoError(t, err)
+		assert.Len(t, transferRepo.transfers, 1)
+
+		_, err = service.RunDue(base.Add(3*time.Hour + 30*time.Minute))
+		require.NoError(t, err)
+		assert.Len(t, transferRepo.transfers, 1)
+
+		stored := scheduleRepo.schedules[schedule.ID]
+		assert.Equal(t, 1, stored.RunCount)
+		require.NotNil(t, stored.NextRunAt)
+		assert.True(t, base.Add(4*time.Hour).Equal(*stored.NextRunAt))
+		executions, _, err := service.ListExecutions(1, schedule.ID, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, executions, 2)
+		assert.Equal(t, models.ScheduledTransferExecutionSkipped, executions[0].Status)
+		assert.Equal(t, 3, executions[0].SkippedRuns)
+	})
+
+	t.Run("Failed runs are recorded and count towards the maximum", func(t *testing.T) {
+		service, scheduleRepo, _ := setup()
+		schedule := hourly(1000)
+		maxRuns := 1
+		schedule.MaxRuns = &maxRuns
+		schedule, err := service.CreateSchedule(alice, schedule, nil, "1234")
+		require.NoError(t, err)
+		scheduleRepo.schedules[schedule.ID].NextRunAt = &base
+
+		_, err = service.RunDue(base)
+		require.NoError(t, err)
+
+		executions, _, err := service.ListExecutions(1, schedule.ID, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, executions, 1)
+		assert.Equal(t, models.ScheduledTransferExecutionFailed, executions[0].Status)
+		assert.Equal(t, repository.ErrInsufficientFunds.Error(), executions[0].Error)
+		require.NotNil(t, executions[0].TransferID)
+		assert.Equal(t, models.ScheduledTransferStatusCompleted, scheduleRepo.schedules[schedule.ID].Status)
+	})
+
+	t.Run("A run started before is not made twice", func(t *testing.T) {
+		service, scheduleRepo, transferRepo := setup()
+		schedule, err := service.CreateSchedule(alice, hourly(10), nil, "1234")
+		require.NoError(t, err)
+		scheduleRepo.schedules[schedule.ID].NextRunAt = &base
+
+		_, err = service.RunDue(base)
+		require.NoError(t, err)
+		// As if the scheduler stopped before advancing the schedule
+		scheduleRepo.schedules[schedule.ID].NextRunAt = &base
+		_, err = service.RunDue(base)
+		require.NoError(t, err)
+
+		assert.Len(t, transferRepo.transfers, 1)
+		assert.True(t, base.Add(time.Hour).Equal(*scheduleRepo.schedules[schedule.ID].NextRunAt))
+	})
+
+	t.Run("Paused schedules do not run and resume without catching up", func(t *testing.T) {
+		service, scheduleRepo, transferRepo := setup()
+		schedule, err := service.CreateSchedule(alice, hourly(10), nil, "1234")
+		require.NoError(t, err)
+
+		_, err = service.ResumeSchedule(1, schedule.ID)
+		assert.ErrorIs(t, err, services.ErrScheduleNotPaused)
+		_, err = service.PauseSchedule(2, schedule.ID)
+		assert.ErrorIs(t, err, services.ErrScheduleNotFound)
+
+		paused, err := service.PauseSchedule(1, schedule.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ScheduledTransferStatusPaused, paused.Status)
+		_, err = service.PauseSchedule(1, schedule.ID)
+		assert.ErrorIs(t, err, services.ErrScheduleNotActive)
+
+		scheduleRepo.schedules[schedule.ID].NextRunAt = &base
+		ran, err := service.RunDue(time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 0, ran)
+
+		resumed, err := service.ResumeSchedule(1, schedule.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ScheduledTransferStatusActive, resumed.Status)
+		require.NotNil(t, resumed.NextRunAt)
+		assert.True(t, resumed.NextRunAt.After(time.Now()))
+		assert.Empty(t, transferRepo.transfers)
+
+		cancelled, err := service.CancelSchedule(1, schedule.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ScheduledTransferStatusCancelled, cancelled.Status)
+		assert.Nil(t, cancelled.NextRunAt)
+		_, err = service.CancelSchedule(1, schedule.ID)
+		assert.ErrorIs(t, err, services.ErrScheduleFinished)
+		_, err = service.ResumeSchedule(1, schedule.ID)
+		assert.ErrorIs(t, err, services.ErrScheduleNotPaused)
+
+		cancelledOnly, _, err := service.ListSchedules(1, models.ScheduledTransferStatusCancelled, 0, 0)
+		require.NoError(t, err)
+		assert.Len(t, cancelledOnly, 1)
+		_, _, err = service.ListSchedules(1, "stopped", 0, 0)
+		assert.ErrorIs(t, err, services.ErrInvalidScheduleState)
+	})
+
+	t.Run("Runs follow the timezone of the schedule", func(t *testing.T) {
+		service, _, _ := setup()
+		schedule := hourly(10)
+		schedule.Cron = "30 9 * * 1-5"
+		schedule.Timezone = "America/New_York"
+		schedule, err := service.CreateSchedule(alice, schedule, nil, "1234")
+		require.NoError(t, err)
+
+		loc, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+		next := schedule.NextRunAt.In(loc)
+		assert.Equal(t, 9, next.Hour())
+		assert.Equal(t, 30, next.Minute())
+		assert.NotEqual(t, time.Saturday, next.Weekday())
+		assert.NotEqual(t, time.Sunday, next.Weekday())
+	})
+
+	t.Run("Schedules are checked when made", func(t *testing.T) {
+		service, _, _ := setup()
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+		zero, two := 0, 2
+
+		tests := []struct {
+			name     string
+			schedule models.ScheduledTransfer
+			runAt    *time.Time
+			pin      string
+			wantErr  error
+		}{
+			{"wrong PIN", models.ScheduledTransfer{SenderWalletID: 1, ReceiverWalletID: 2, Amount: 10}, &future, "0000", services.ErrInvalidPIN},
+			{"wallet of someone else", models.ScheduledTransfer{SenderWalletID: 2, ReceiverWalletID: 1, Amount: 10}, &future, "1234", services.ErrWalletNotOwned},
+			{"non-positive amount", models.ScheduledTransfer{SenderWalletID: 1, ReceiverWalletID: 2}, &future, "1234", services.ErrInvalidAmount},
+			{"one-off without a time", models.ScheduledTransfer{SenderWalletID: 1, ReceiverWalletID: 2, Amount: 10}, nil, "1234", services.ErrRunAtRequired},
+			{"time in the past", models.ScheduledTransfer{SenderWalletID: 1, ReceiverWalletID: 2, Amount: 10}, &past, "1234", services.ErrInvalidRunAt},
+			{"one-off with a maximum", models.ScheduledTransfer{SenderWalletID: 1, ReceiverWalletID: 2, Amount: 10, MaxRuns: &two}, &future, "1234", services.ErrOneOffLimits},
+			{"invalid cron", models.ScheduledTransfer{SenderWalletID: 1, ReceiverWalletID: 2, Amount: 10, Cron: "61 * * * *"}, nil, "1234", services.ErrInvalidCron},
+			{"unknown timezone", models.ScheduledTransfer{SenderWalletID: 1, ReceiverWalletID: 2, Amount: 10, Cron: "@daily", Timezone: "Mars/Olympus"}, nil, "1234", services.ErrInvalidTimezone},
+			{"unknown catch-up policy", models.ScheduledTransfer{SenderWalletID: 1, ReceiverWalletID: 2, Amount: 10, Cron: "@daily", CatchUp: "all"}, nil, "1234", services.ErrInvalidCatchUp},
+			{"zero maximum", models.ScheduledTransfer{SenderWalletID: 1, ReceiverWalletID: 2, Amount: 10, Cron: "@daily", MaxRuns: &zero}, nil, "1234", services.ErrInvalidMaxRuns},
+			{"end before the first run", models.ScheduledTransfer{SenderWalletID: 1, ReceiverWalletID: 2, Amount: 10, Cron: "@yearly", EndAt: &future}, &future, "1234", services.ErrNoScheduledRuns},
+		}
+		for _, tt := range tests {
+			schedule := tt.schedule
+			_, err := service.CreateSchedule(alice, &schedule, tt.runAt, tt.pin)
+			assert.ErrorIs(t, err, tt.wantErr, tt.name)
+		}
+	})
+}
+
+// mockScheduledTransferRepo keeps scheduled transfers and their runs in memory
+type mockScheduledTransferRepo struct {
+	schedules   map[int64]*models.ScheduledTransfer
+	lockedUntil map[int64]time.Time
+	executions  map[int64]*models.ScheduledTransferExecution
+}
+
+func newMockScheduledTransferRepo() *mockScheduledTransferRepo {
+	return &mockScheduledTransferRepo{
+		schedules:   map[int64]*models.ScheduledTransfer{},
+		lockedUntil: map[int64]time.Time{},
+		executions:  map[int64]*models.ScheduledTransferExecution{},
+	}
+}
+
+func (m *mockScheduledTransferRepo) Create(schedule *models.ScheduledTransfer) error {
+	schedule.ID = int64(len(m.schedules) + 1)
+	schedule.CreatedAt = time.Now()
+	stored := *schedule
+	m.schedules[schedule.ID] = &stored
+	return nil
+}
+
+func (m *mockScheduledTransferRepo) FindByID(id int64) (*models.ScheduledTransfer, error) {
+	if schedule, ok := m.schedules[id]; ok {
+		copied := *schedule
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *mockScheduledTransferRepo) Find(filter models.ScheduledTransferFilter) ([]*models.ScheduledTransfer, error) {
+	var schedules []*models.ScheduledTransfer
+	for _, schedule := range m.schedules {
+		if schedule.UserID == filter.UserID &&
+			(filter.Status == "" || schedule.Status == filter.Status) &&
+			(filter.BeforeID == 0 || schedule.ID < filter.BeforeID) {
+			copied := *schedule
+			schedules = append(schedules, &copied)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID > schedules[j].ID })
+	if len(schedules) > filter.Limit {
+		schedules = schedules[:filter.Limit]
+	}
+	return schedules, nil
+}
+
+func (m *mockScheduledTransferRepo) UpdateStatus(id int64, from, to models.ScheduledTransferStatus, nextRunAt *time.Time) (*models.ScheduledTransfer, error) {
+	schedule, ok := m.schedules[id]
+	if !ok || schedule.Status != from {
+		return nil, sql.ErrNoRows
+	}
+	schedule.Status = to
+	schedule.NextRunAt = nextRunAt
+	return m.FindByID(id)
+}
+
+func (m *mockScheduledTransferRepo) ClaimDue(now time.Time, lease time.Duration, limit int) ([]*models.ScheduledTransfer, error) {
+	var schedules []*models.ScheduledTransfer
+	for id, schedule := range m.schedules {
+		if schedule.Status == models.ScheduledTransferStatusActive && schedule.NextRunAt != nil &&
+			!schedule.NextRunAt.After(now) && !m.lockedUntil[id].After(now) {
+			copied := *schedule
+			schedules = append(schedules, &copied)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	if len(schedules) > limit {
+		schedules = schedules[:limit]
+	}
+	for _, schedule := range schedules {
+		m.lockedUntil[schedule.ID] = now.Add(lease)
+	}
+	return schedules, nil
+}
+
+func (m *mockScheduledTransferRepo) Advance(id int64, runs int, nextRunAt *time.Time) error {
+	schedule := m.schedules[id]
+	schedule.RunCount += runs
+	delete(m.lockedUntil, id)
+	if schedule.Status == models.ScheduledTransferStatusCancelled {
+		schedule.NextRunAt = nil
+		return nil
+	}
+	schedule.NextRunAt = nextRunAt
+	if nextRunAt == nil {
+		schedule.Status = models.ScheduledTransferStatusCompleted
+	}
+	return nil
+}
+
+func (m *mockScheduledTransferRepo) StartExecution(scheduleID int64, scheduledFor time.Time) (*models.ScheduledTransferExecution, error) {
+	for _, execution := range m.executions {
+		if execution.ScheduleID == scheduleID && execution.ScheduledFor.Equal(scheduledFor) {
+			return nil, repository.ErrExecutionExists
+		}
+	}
+	execution := &models.ScheduledTransferExecution{
+		ID:           int64(len(m.executions) + 1),
+		ScheduleID:   scheduleID,
+		ScheduledFor: scheduledFor,
+		Status:       models.ScheduledTransferExecutionRunning,
+		StartedAt:    time.Now(),
+	}
+	m.executions[execution.ID] = execution
+	copied := *execution
+	return &copied, nil
+}
+
+func (m *mockScheduledTransferRepo) FinishExecution(id int64, status models.ScheduledTransferExecutionStatus, transferID *int64, errorMessage string) error {
+	execution := m.executions[id]
+	finishedAt := time.Now()
+	execution.Status = status
+	execution.TransferID = transferID
+	execution.Error = errorMessage
+	execution.FinishedAt = &finishedAt
+	return nil
+}
+
+func (m *mockScheduledTransferRepo) RecordSkipped(scheduleID int64, scheduledFor time.Time, skippedRuns int) error {
+	for _, execution := range m.executions {
+		if execution.ScheduleID == scheduleID && execution.ScheduledFor.Equal(scheduledFor) {
+			return nil
+		}
+	}
+	now := time.Now()
+	id := int64(len(m.executions) + 1)
+	m.executions[id] = &models.ScheduledTransferExecution{
+		ID:           id,
+		ScheduleID:   scheduleID,
+		ScheduledFor: scheduledFor,
+		Status:       models.ScheduledTransferExecutionSkipped,
+		SkippedRuns:  skippedRuns,
+		StartedAt:    now,
+		FinishedAt:   &now,
+	}
+	return nil
+}
+
+func (m *mockScheduledTransferRepo) FindExecutions(scheduleID, beforeID int64, limit int) ([]*models.ScheduledTransferExecution, error) {
+	var executions []*models.ScheduledTransferExecution
+	for _, execution := range m.executions {
+		if execution.ScheduleID == scheduleID && (beforeID == 0 || execution.ID < beforeID) {
+			copied := *execution
+			executions = append(executions, &copied)
+		}
+	}
+	sort.Slice(executions, func(i, j int) bool { return executions[i].ID > executions[j].ID })
+	if len(executions) > limit {
+		executions = executions[:limit]
+	}
+	return executions, nil
+}
+
+func (m *mockScheduledTransferRepo) RecoverRunning(staleBefore time.Time) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+	"verve/internal/auth"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+const defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, the session has been revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// SessionService issues access and refresh tokens for login sessions. Refresh tokens are
+// opaque, stored hashed and rotated on every use; replaying a rotated token revokes the session.
+type SessionService struct {
+	sessionRepo     repository.SessionRepository
+	roleRepo        repository.RoleRepository
+	activityService *ActivityService
+	ttl             time.Duration
+}
+
+// NewSessionService creates a new SessionService. A session expires when it was not refreshed
+// for ttl, or for 30 days when ttl is zero.
+func NewSessionService(sessionRepo repository.SessionRepository, roleRepo repository.RoleRepository, activityService *ActivityService, ttl time.Duration) *SessionService {
+	if ttl <= 0 {
+		ttl = defaultRefreshTokenTTL
+	}
+	return &SessionService{sessionRepo: sessionRepo, roleRepo: roleRepo, activityService: activityService, ttl: ttl}
+}
+
+// StartSession opens a session for a user who just signed in. Every sign-in, local or
+// through an OAuth callback, starts a session and is recorded as a login activity.
+func (s *SessionService) StartSession(userID int, roles []string, userAgent, ipAddress string) (*models.SessionTokens, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		ID:        id,
+		UserID:    userID,
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := s.sessionRepo.Create(session, hashRefreshToken(refreshToken)); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := auth.GenerateJWT(userID, roles, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.activityService.Record(userID, models.ActivityTypeLogin, nil)
+	return &models.SessionTokens{AccessToken: accessToken, RefreshToken: refreshToken, Session: session}, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh token.
+// Roles are read again so role changes apply from the next refresh on. A user who stays
+// signed in comes back through a refresh, so the first refresh of a day counts as a login
+// activity too.
+func (s *SessionService) Refresh(refreshToken, userAgent, ipAddress string) (*models.SessionTokens, error) {
+	tokenHash := hashRefreshToken(refreshToken)
+	session, current, err := s.sessionRepo.FindByRefreshToken(tokenHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !current {
+		return nil, s.revokeReusedSession(session)
+	}
+	if !session.Active(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newRefreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(s.ttl)
+	err = s.sessionRepo.RotateRefreshToken(session.ID, tokenHash, hashRefreshToken(newRefreshToken), expiresAt, userAgent, ipAddress)
+	if errors.Is(err, repository.ErrRefreshTokenNotCurrent) {
+		// A concurrent refresh with the same token won the race
+		return nil, s.revokeReusedSession(session)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roleRepo.GetForUser(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := auth.GenerateJWT(session.UserID, roles, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	lastUsedDay := session.LastUsedAt.UTC().Truncate(24 * time.Hour)
+	session.ExpiresAt = expiresAt
+	session.LastUsedAt = time.Now()
+	session.UserAgent = userAgent
+	session.IPAddress = ipAddress
+
+	// Activity days are UTC days, as in the daily rollups
+	if lastUsedDay.Before(session.LastUsedAt.UTC().Truncate(24 * time.Hour)) {
+		s.activityService.Record(session.UserID, models.ActivityTypeLogin, nil)
+	}
+	return &models.SessionTokens{AccessToken: accessToken, RefreshToken: newRefreshToken, Session: session}, nil
+}
+
+// revokeReusedSession ends a session whose rotated refresh token was presented again
+func (s *SessionService) revokeReusedSession(session *models.Session) error {
+	log.Printf("Refresh token reuse detected for session %s of user %d, revoking it", session.ID, session.UserID)
+	if err := s.sessionRepo.Revoke(session.ID, models.SessionRevokedTokenReuse); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// IsSessionActive reports whether access tokens of a session are still accepted
+func (s *SessionService) IsSessionActive(sessionID string) (bool, error) {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return session.Active(time.Now()), nil
+}
+
+// ListSessions returns the active sessions of a user, flagging the one the request came from
+func (s *SessionService) ListSessions(userID int, currentSessionID string) ([]*models.Session, error) {
+	sessions, err := s.sessionRepo.FindActiveByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one session of a user
+func (s *SessionService) RevokeSession(userID int, sessionID, reason string) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err == sql.ErrNoRows || (err == nil && session.UserID != userID) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.sessionRepo.Revoke(sessionID, reason)
+}
+
+// RevokeOtherSessions ends every session of a user except the current one
+func (s *SessionService) RevokeOtherSessions(userID int, currentSessionID string) (int64, error) {
+	return s.sessionRepo.RevokeAllForUser(userID, currentSessionID, models.SessionRevokedOtherSession)
+}
+
+// RunExpiryPurge deletes expired sessions every interval until ctx is cancelled.
+func (s *SessionService) RunExpiryPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.sessionRepo.DeleteExpired(); err != nil {
+				log.Printf("Failed to purge expired sessions: %v", err)
+			}
+		}
+	}
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
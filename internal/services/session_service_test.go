@@ -0,0 +1,229 @@
+package services_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+	"verve/internal/auth"
+	"verve/internal/models"
+	"verve/internal/repository"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionRefreshRotation(t *testing.T) {
+	sessionRepo := newMockSessionRepo()
+	roleRepo := &mockRoleRepo{roles: map[int][]string{1: {"user"}}}
+	activityRepo := &mockActivityRepo{}
+	activityService := services.NewActivityService(activityRepo, &mockWalletRepo{})
+	service := services.NewSessionService(sessionRepo, roleRepo, activityService, time.Hour)
+
+	started, err := service.StartSession(1, []string{"user"}, "test-agent", "127.0.0.1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, started.RefreshToken)
+
+	claims, err := auth.ValidateToken(started.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, started.Session.ID, claims.SessionID)
+
+	t.Run("Refresh rotates the refresh token", func(t *testing.T) {
+		roleRepo.roles[1] = []string{"user", "admin"}
+
+		refreshed, err := service.Refresh(started.RefreshToken, "test-agent", "127.0.0.2")
+		require.NoError(t, err)
+		assert.NotEqual(t, started.RefreshToken, refreshed.RefreshToken)
+		assert.Equal(t, started.Session.ID, refreshed.Session.ID)
+
+		claims, err := auth.ValidateToken(refreshed.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user", "admin"}, claims.Roles)
+
+		active, err := service.IsSessionActive(started.Session.ID)
+		require.NoError(t, err)
+		assert.True(t, active)
+
+		// Signing in counts as a login, refreshing the same day does not
+		require.Len(t, activityRepo.events, 1)
+		assert.Equal(t, models.ActivityTypeLogin, activityRepo.events[0].ActivityType)
+
+		t.Run("Reusing a rotated token revokes the session", func(t *testing.T) {
+			_, err := service.Refresh(started.RefreshToken, "attacker", "203.0.113.7")
+			assert.ErrorIs(t, err, services.ErrRefreshTokenReused)
+
+			active, err := service.IsSessionActive(started.Session.ID)
+			require.NoError(t, err)
+			assert.False(t, active)
+
+			// The legitimate client is signed out as well
+			_, err = service.Refresh(refreshed.RefreshToken, "test-agent", "127.0.0.2")
+			assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+		})
+	})
+
+	t.Run("Unknown refresh tokens are rejected", func(t *testing.T) {
+		_, err := service.Refresh("not-a-token", "test-agent", "127.0.0.1")
+		assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+	})
+
+	t.Run("The first refresh of a day counts as a login", func(t *testing.T) {
+		tokens, err := service.StartSession(1, []string{"user"}, "laptop", "127.0.0.1")
+		require.NoError(t, err)
+		logins := len(activityRepo.events)
+		sessionRepo.sessions[tokens.Session.ID].LastUsedAt = time.Now().Add(-48 * time.Hour)
+
+		tokens, err = service.Refresh(tokens.RefreshToken, "laptop", "127.0.0.1")
+		require.NoError(t, err)
+		assert.Len(t, activityRepo.events, logins+1)
+
+		_, err = service.Refresh(tokens.RefreshToken, "laptop", "127.0.0.1")
+		require.NoError(t, err)
+		assert.Len(t, activityRepo.events, logins+1)
+	})
+}
+
+func TestRevokeSession(t *testing.T) {
+	sessionRepo := newMockSessionRepo()
+	service := services.NewSessionService(sessionRepo, &mockRoleRepo{}, services.NewActivityService(&mockActivityRepo{}, &mockWalletRepo{}), time.Hour)
+
+	first, err := service.StartSession(1, nil, "laptop", "127.0.0.1")
+	require.NoError(t, err)
+	second, err := service.StartSession(1, nil, "phone", "127.0.0.1")
+	require.NoError(t, err)
+	other, err := service.StartSession(2, nil, "laptop", "127.0.0.1")
+	require.NoError(t, err)
+
+	t.Run("Sessions of other users cannot be revoked", func(t *testing.T) {
+		err := service.RevokeSession(1, other.Session.ID, models.SessionRevokedByUser)
+		assert.ErrorIs(t, err, services.ErrSessionNotFound)
+	})
+
+	t.Run("Listing flags the current session", func(t *testing.T) {
+		sessions, err := service.ListSessions(1, first.Session.ID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		for _, session := range sessions {
+			assert.Equal(t, session.ID == first.Session.ID, session.Current)
+		}
+	})
+
+	t.Run("Revoked sessions cannot be refreshed", func(t *testing.T) {
+		require.NoError(t, service.RevokeSession(1, second.Session.ID, models.SessionRevokedByUser))
+
+		_, err := service.Refresh(second.RefreshToken, "phone", "127.0.0.1")
+		assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+
+		sessions, err := service.ListSessions(1, first.Session.ID)
+		require.NoError(t, err)
+		assert.Len(t, sessions, 1)
+	})
+
+	t.Run("Signing out elsewhere keeps the current session", func(t *testing.T) {
+		_, err := service.StartSession(1, nil, "tablet", "127.0.0.1")
+		require.NoError(t, err)
+
+		revoked, err := service.RevokeOtherSessions(1, first.Session.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), revoked)
+
+		active, err := service.IsSessionActive(first.Session.ID)
+		require.NoError(t, err)
+		assert.True(t, active)
+	})
+}
+
+type mockSessionRepo struct {
+	sessions      map[string]*models.Session
+	currentTokens map[string]string // token hash -> session ID
+	rotatedTokens map[string]string
+}
+
+func newMockSessionRepo() *mockSessionRepo {
+	return &mockSessionRepo{
+		sessions:      make(map[string]*models.Session),
+		currentTokens: make(map[string]string),
+		rotatedTokens: make(map[string]string),
+	}
+}
+
+func (m *mockSessionRepo) Create(session *models.Session, refreshTokenHash string) error {
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = session.CreatedAt
+	stored := *session
+	m.sessions[session.ID] = &stored
+	m.currentTokens[refreshTokenHash] = session.ID
+	return nil
+}
+
+func (m *mockSessionRepo) FindByID(id string) (*models.Session, error) {
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	found := *session
+	return &found, nil
+}
+
+func (m *mockSessionRepo) FindByRefreshToken(tokenHash string) (*models.Session, bool, error) {
+	if id, ok := m.currentTokens[tokenHash]; ok {
+		session, err := m.FindByID(id)
+		return session, true, err
+	}
+	if id, ok := m.rotatedTokens[tokenHash]; ok {
+		session, err := m.FindByID(id)
+		return session, false, err
+	}
+	return nil, false, sql.ErrNoRows
+}
+
+func (m *mockSessionRepo) RotateRefreshToken(sessionID, oldHash, newHash string, expiresAt time.Time, userAgent, ipAddress string) error {
+	if m.currentTokens[oldHash] != sessionID || m.sessions[sessionID].RevokedAt != nil {
+		return repository.ErrRefreshTokenNotCurrent
+	}
+	delete(m.currentTokens, oldHash)
+	m.rotatedTokens[oldHash] = sessionID
+	m.currentTokens[newHash] = sessionID
+
+	session := m.sessions[sessionID]
+	session.ExpiresAt = expiresAt
+	session.UserAgent = userAgent
+	session.IPAddress = ipAddress
+	session.LastUsedAt = time.Now()
+	return nil
+}
+
+func (m *mockSessionRepo) FindActiveByUser(userID int) ([]*models.Session, error) {
+	var sessions []*models.Session
+	for _, session := range m.sessions {
+		if session.UserID == userID && session.Active(time.Now()) {
+			found := *session
+			sessions = append(sessions, &found)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *mockSessionRepo) Revoke(sessionID, reason string) error {
+	if session, ok := m.sessions[sessionID]; ok && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+		session.RevokedReason = reason
+	}
+	return nil
+}
+
+func (m *mockSessionRepo) RevokeAllForUser(userID int, exceptSessionID, reason string) (int64, error) {
+	var revoked int64
+	for id, session := range m.sessions {
+		if session.UserID == userID && id != exceptSessionID && session.Active(time.Now()) {
+			m.Revoke(id, reason)
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (m *mockSessionRepo) DeleteExpired() (int64, error) {
+	return 0, nil
+}
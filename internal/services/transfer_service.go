@@ -1,74 +1,409 @@
 package services
 
 import (
+	"database/sql"
 	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 	"verve/internal/models"
 	"verve/internal/repository"
+	"verve/pkg/utils"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// maxSignatureLifetime bounds how far in the future a signed transfer may expire
+	maxSignatureLifetime = 15 * time.Minute
+
+	maxTransferMessageLength = 500
+)
+
+var (
+	nonceFormat    = regexp.MustCompile(`^[A-Za-z0-9_-]{16,128}$`)
+	categoryFormat = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+var (
+	ErrInvalidAmount          = errors.New("amount must be positive")
+	ErrInvalidPIN             = errors.New("invalid PIN")
+	ErrSenderWalletNotFound   = errors.New("sender wallet not found")
+	ErrReceiverWalletNotFound = errors.New("receiver wallet not found")
+	ErrWalletNotOwned         = errors.New("sender wallet does not belong to you")
+	ErrSelfTransfer           = errors.New("cannot transfer to the same wallet")
+	ErrCurrencyMismatch       = errors.New("sender and receiver wallets use different currencies")
+	ErrInvalidNonce           = errors.New("nonce must be 16 to 128 letters, digits, '-' or '_'")
+	ErrSignatureExpired       = errors.New("signature has expired")
+	ErrSignatureExpiryTooFar  = errors.New("signature expiry is too far in the future")
+	ErrInvalidSignature       = errors.New("invalid signature")
+	ErrNonceReused            = errors.New("nonce was already used by this wallet")
+	ErrMessageTooLong         = errors.New("message must be at most 500 characters")
+	ErrInvalidCategory        = errors.New("category must be at most 50 lowercase letters, digits and dashes, such as customer-obsession")
+	ErrInvalidVisibility      = errors.New("visibility must be public, recipient_only or private")
+)
+
+// TransferObserver is told about every transfer that completed. It is called on the
+// request path, so slow work belongs in a goroutine.
+type TransferObserver interface {
+	TransferCompleted(transfer *models.Transfer)
+}
+
 // TransferService orchestrates the creation and execution of transfers.
 type TransferService struct {
 	transferRepo repository.TransferRepository
 	txRepo       repository.TransactionRepository
-	ledgerRepo   repository.LedgerRepository
 	userRepo     repository.UserRepository
+	walletRepo   repository.WalletRepository
+	pseudoRepo   repository.PseudonymousWalletRepository
+	auditService *AuditService
+	observers    []TransferObserver
 }
 
 // NewTransferService creates a new TransferService.
 func NewTransferService(
 	transferRepo repository.TransferRepository,
 	txRepo repository.TransactionRepository,
-	ledgerRepo repository.LedgerRepository,
 	userRepo repository.UserRepository,
+	walletRepo repository.WalletRepository,
+	pseudoRepo repository.PseudonymousWalletRepository,
+	auditService *AuditService,
 ) *TransferService {
 	return &TransferService{
 		transferRepo: transferRepo,
 		txRepo:       txRepo,
-		ledgerRepo:   ledgerRepo,
 		userRepo:     userRepo,
+		walletRepo:   walletRepo,
+		pseudoRepo:   pseudoRepo,
+		auditService: auditService,
 	}
 }
 
-// InitiateTransfer creates a new transfer record and processes it.
+// AddObserver registers an observer of completed transfers. Observers are added at startup.
+func (s *TransferService) AddObserver(observer TransferObserver) {
+	s.observers = append(s.observers, observer)
+}
+
+// InitiateTransfer records a pending transfer out of a wallet of the actor, with the note of
+// the sender, and executes it. When execution fails the transfer is marked failed and
+// returned along with the error.
 func (s *TransferService) InitiateTransfer(
-	userID int,
+	actor models.Actor,
 	senderWalletID, receiverWalletID, amount int64,
 	isAnonymous bool,
+	note models.TransferNote,
 	pin string,
 ) (*models.Transfer, error) {
-	if amount <= 0 {
-		return nil, errors.New("amount must be positive")
+	note, err := s.CheckTransfer(actor, senderWalletID, receiverWalletID, amount, note, pin)
+	if err != nil {
+		return nil, err
 	}
+	return s.createAndExecute(actor, newTransfer(senderWalletID, receiverWalletID, amount, isAnonymous, note))
+}
 
-	user, err := s.userRepo.FindByID(userID)
+// InitiateRequestedTransfer pays a payment request with a transfer made like InitiateTransfer.
+// The request is marked paid in the transaction that moves the coins; when it is no longer
+// pending by then, nothing moves and the transfer fails with
+// repository.ErrPaymentRequestNotPending.
+func (s *TransferService) InitiateRequestedTransfer(
+	actor models.Actor,
+	paymentRequestID int64,
+	senderWalletID, receiverWalletID, amount int64,
+	note models.TransferNote,
+	pin string,
+) (*models.Transfer, error) {
+	note, err := s.CheckTransfer(actor, senderWalletID, receiverWalletID, amount, note, pin)
 	if err != nil {
 		return nil, err
 	}
+	transfer := newTransfer(senderWalletID, receiverWalletID, amount, false, note)
+	transfer.PaymentRequestID = &paymentRequestID
+	return s.createAndExecute(actor, transfer)
+}
+
+// InitiateScheduledTransfer records and executes a run of a scheduled transfer like
+// InitiateTransfer, except for the PIN, which was checked when the schedule was created.
+func (s *TransferService) InitiateScheduledTransfer(
+	actor models.Actor,
+	senderWalletID, receiverWalletID, amount int64,
+	isAnonymous bool,
+	note models.TransferNote,
+) (*models.Transfer, error) {
+	note, err := s.checkTransfer(actor.UserID, senderWalletID, receiverWalletID, amount, note)
+	if err != nil {
+		return nil, err
+	}
+	return s.createAndExecute(actor, newTransfer(senderWalletID, receiverWalletID, amount, isAnonymous, note))
+}
+
+// CheckTransfer makes the checks InitiateTransfer makes before recording a transfer, the PIN
+// included, and returns the note with its default visibility filled in.
+func (s *TransferService) CheckTransfer(
+	actor models.Actor,
+	senderWalletID, receiverWalletID, amount int64,
+	note models.TransferNote,
+	pin string,
+) (models.TransferNote, error) {
+	note, err := s.checkTransfer(actor.UserID, senderWalletID, receiverWalletID, amount, note)
+	if err != nil {
+		return note, err
+	}
+
+	user, err := s.userRepo.FindByID(actor.UserID)
+	if err != nil {
+		return note, err
+	}
 
 	if user.PinRequiredForTransfer {
 		if err := bcrypt.CompareHashAndPassword([]byte(user.PinHash), []byte(pin)); err != nil {
-			return nil, errors.New("invalid PIN")
+			return note, ErrInvalidPIN
 		}
 	}
-	// ... existing code ...
-	transfer := &models.Transfer{
+	return note, nil
+}
+
+// checkTransfer checks the amount, the note and the wallets of a transfer of a user
+func (s *TransferService) checkTransfer(
+	userID int,
+	senderWalletID, receiverWalletID, amount int64,
+	note models.TransferNote,
+) (models.TransferNote, error) {
+	if amount <= 0 {
+		return note, ErrInvalidAmount
+	}
+	note, err := validateTransferNote(note)
+	if err != nil {
+		return note, err
+	}
+	return note, s.checkWallets(userID, senderWalletID, receiverWalletID)
+}
+
+// newTransfer builds a pending transfer with the note of the sender
+func newTransfer(senderWalletID, receiverWalletID, amount int64, isAnonymous bool, note models.TransferNote) *models.Transfer {
+	return &models.Transfer{
 		SenderWalletID:   senderWalletID,
 		ReceiverWalletID: receiverWalletID,
 		Amount:           amount,
 		Status:           models.TransferStatusPending,
 		IsAnonymous:      isAnonymous,
+		Message:          note.Message,
+		Category:         note.Category,
+		Visibility:       note.Visibility,
+	}
+}
+
+// createAndExecute records a pending transfer that passed its checks and executes it
+func (s *TransferService) createAndExecute(actor models.Actor, transfer *models.Transfer) (*models.Transfer, error) {
+	if err := s.transferRepo.Create(transfer); err != nil {
+		return nil, err
+	}
+
+	return s.execute(actor, transfer)
+}
+
+// validateTransferNote checks the note of a transfer and fills in its default visibility
+func validateTransferNote(note models.TransferNote) (models.TransferNote, error) {
+	note.Message = strings.TrimSpace(note.Message)
+	if utf8.RuneCountInString(note.Message) > maxTransferMessageLength {
+		return note, ErrMessageTooLong
 	}
-	// ... existing code ...
-	if isAnonymous && s.ledgerRepo != nil {
-		_ = s.ledgerRepo.LogAnonymousTransfer(senderWalletID, receiverWalletID, amount, nil, "")
+	if note.Category != "" && (len(note.Category) > 50 || !categoryFormat.MatchString(note.Category)) {
+		return note, ErrInvalidCategory
 	}
 
+	switch note.Visibility {
+	case "":
+		note.Visibility = models.TransferVisibilityRecipientOnly
+	case models.TransferVisibilityPublic, models.TransferVisibilityRecipientOnly, models.TransferVisibilityPrivate:
+	default:
+		return note, ErrInvalidVisibility
+	}
+	return note, nil
+}
+
+// InitiatePseudonymousTransfer executes a transfer out of a pseudonymous wallet. Instead of a
+// user session, the transfer is authorized by a signature of SignedTransferMessage made with
+// the private key of the sender wallet. Each nonce can be used once per wallet. The actor
+// carries the request the transfer is made from, but no user.
+func (s *TransferService) InitiatePseudonymousTransfer(
+	actor models.Actor,
+	senderWalletID, receiverWalletID, amount int64,
+	nonce string,
+	expiresAt int64,
+	signature string,
+) (*models.Transfer, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if senderWalletID == receiverWalletID {
+		return nil, ErrSelfTransfer
+	}
+	if !nonceFormat.MatchString(nonce) {
+		return nil, ErrInvalidNonce
+	}
+
+	expiry := time.Unix(expiresAt, 0)
+	now := time.Now()
+	if !expiry.After(now) {
+		return nil, ErrSignatureExpired
+	}
+	if expiry.After(now.Add(maxSignatureLifetime)) {
+		return nil, ErrSignatureExpiryTooFar
+	}
+
+	sender, err := s.pseudoRepo.FindByID(senderWalletID)
+	if err == sql.ErrNoRows {
+		return nil, ErrSenderWalletNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if sender.PublicKey == "" {
+		return nil, ErrWalletKeyRevoked
+	}
+
+	// The signature is checked before anything about the receiver is revealed
+	pub, err := utils.DecodePublicKey(sender.PublicKey)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	message := utils.SignedTransferMessage(senderWalletID, receiverWalletID, amount, nonce, expiresAt)
+	if !utils.VerifySignature(pub, message, signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	senderWallet, err := s.walletRepo.FindByID(senderWalletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkReceiver(senderWallet, receiverWalletID); err != nil {
+		return nil, err
+	}
+
+	transfer := &models.Transfer{
+		SenderWalletID:   senderWalletID,
+		ReceiverWalletID: receiverWalletID,
+		Amount:           amount,
+		Status:           models.TransferStatusPending,
+		IsAnonymous:      true,
+		Visibility:       models.TransferVisibilityRecipientOnly,
+		Authorization: &models.TransferSignature{
+			Nonce:     nonce,
+			ExpiresAt: expiry,
+			Signature: signature,
+			PublicKey: sender.PublicKey,
+		},
+	}
+	if err := s.transferRepo.Create(transfer); err != nil {
+		if errors.Is(err, repository.ErrNonceAlreadyUsed) {
+			return nil, ErrNonceReused
+		}
+		return nil, err
+	}
+
+	return s.execute(actor, transfer)
+}
+
+// execute moves the coins of a pending transfer, marking it failed when that is not possible.
+// The outcome is audited either way.
+func (s *TransferService) execute(actor models.Actor, transfer *models.Transfer) (*models.Transfer, error) {
+	if _, _, err := s.txRepo.ExecuteTransfer(transfer); err != nil {
+		if markErr := s.transferRepo.MarkFailed(transfer.ID, err.Error()); markErr != nil {
+			return nil, markErr
+		}
+		transfer.Status = models.TransferStatusFailed
+		transfer.FailureReason = err.Error()
+		s.auditService.Record(actor, models.AuditActionTransferCreate, "transfer", strconv.FormatInt(transfer.ID, 10), nil, transfer)
+		return transfer, err
+	}
+	s.auditService.Record(actor, models.AuditActionTransferCreate, "transfer", strconv.FormatInt(transfer.ID, 10), nil, transfer)
+
+	for _, observer := range s.observers {
+		observer.TransferCompleted(transfer)
+	}
+	return transfer, nil
+}
+
+// checkWallets ensures the sender wallet belongs to the user and can pay the receiver wallet.
+func (s *TransferService) checkWallets(userID int, senderWalletID, receiverWalletID int64) error {
+	if senderWalletID == receiverWalletID {
+		return ErrSelfTransfer
+	}
+
+	sender, err := s.walletRepo.FindByID(senderWalletID)
+	if err != nil {
+		return err
+	}
+	if sender == nil {
+		return ErrSenderWalletNotFound
+	}
+	if sender.UserID != userID {
+		return ErrWalletNotOwned
+	}
+
+	return s.checkReceiver(sender, receiverWalletID)
+}
+
+// checkReceiver ensures the receiver wallet exists and uses the currency of the sender wallet.
+func (s *TransferService) checkReceiver(sender *models.Wallet, receiverWalletID int64) error {
+	receiver, err := s.walletRepo.FindByID(receiverWalletID)
+	if err != nil {
+		return err
+	}
+	if receiver == nil {
+		return ErrReceiverWalletNotFound
+	}
+
+	if sender.Currency != receiver.Currency {
+		return ErrCurrencyMismatch
+	}
+	return nil
+}
+
+// GetTransferStatus retrieves the status of a transfer by its ID for its sender or receiver,
+// and returns sql.ErrNoRows to anyone else. The receiver of an anonymous transfer is not told
+// where it came from, and the message and category are left out unless the viewer may see them.
+func (s *TransferService) GetTransferStatus(viewerID int, id int64) (*models.Transfer, error) {
+	transfer, err := s.transferRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	isSender, err := s.walletOwnedBy(transfer.SenderWalletID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if isSender {
+		return transfer, nil
+	}
+	isReceiver, err := s.walletOwnedBy(transfer.ReceiverWalletID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if !isReceiver {
+		return nil, sql.ErrNoRows
+	}
+
+	if transfer.IsAnonymous {
+		transfer.SenderWalletID = 0
+		transfer.Authorization = nil
+		transfer.FailureReason = ""
+	}
+	if transfer.Visibility == models.TransferVisibilityPrivate {
+		transfer.Message = ""
+		transfer.Category = ""
+	}
 	return transfer, nil
 }
 
-// GetTransferStatus retrieves the status of a transfer by its ID.
-func (s *TransferService) GetTransferStatus(id int64) (*models.Transfer, error) {
-	return s.transferRepo.FindByID(id)
+// walletOwnedBy reports whether a wallet belongs to a user
+func (s *TransferService) walletOwnedBy(walletID int64, userID int) (bool, error) {
+	wallet, err := s.walletRepo.FindByID(walletID)
+	if err != nil {
+		return false, err
+	}
+	return wallet != nil && wallet.UserID != 0 && wallet.UserID == userID, nil
 }
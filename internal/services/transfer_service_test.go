@@ -0,0 +1,519 @@
+package services_test
+
+import (
+	"crypto/ecdsa"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+	"verve/internal/services"
+	"verve/pkg/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitiateTransferChecks(t *testing.T) {
+	wallets := map[int64]*models.Wallet{
+		1: {ID: 1, UserID: 1, Currency: "USD", Balance: 100},
+		2: {ID: 2, UserID: 2, Currency: "USD"},
+		3: {ID: 3, UserID: 2, Currency: "EUR"},
+	}
+
+	tests := []struct {
+		name     string
+		userID   int
+		sender   int64
+		receiver int64
+		amount   int64
+		wantErr  error
+	}{
+		{"non-positive amount", 1, 1, 2, 0, services.ErrInvalidAmount},
+		{"self transfer", 1, 1, 1, 10, services.ErrSelfTransfer},
+		{"unknown sender", 1, 9, 2, 10, services.ErrSenderWalletNotFound},
+		{"wallet owned by someone else", 2, 1, 2, 10, services.ErrWalletNotOwned},
+		{"unknown receiver", 1, 1, 9, 10, services.ErrReceiverWalletNotFound},
+		{"currency mismatch", 1, 1, 3, 10, services.ErrCurrencyMismatch},
+		{"insufficient funds", 1, 1, 2, 1000, repository.ErrInsufficientFunds},
+		{"valid transfer", 1, 1, 2, 10, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transferRepo := newMockTransferRepo()
+			txRepo := &mockTransactionRepo{wallets: wallets, transferRepo: transferRepo}
+			service := services.NewTransferService(transferRepo, txRepo, newMockUserRepo(), &mockWalletRepo{wallets: wallets}, nil, services.NewAuditService(&mockAuditRepo{}))
+
+			transfer, err := service.InitiateTransfer(models.Actor{UserID: tt.userID}, tt.sender, tt.receiver, tt.amount, false, models.TransferNote{}, "")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			switch {
+			case tt.wantErr == nil:
+				assert.Equal(t, models.TransferStatusCompleted, transfer.Status)
+				assert.Equal(t, models.TransferStatusCompleted, transferRepo.transfers[transfer.ID].Status)
+			case transfer != nil:
+				// Failures after validation are persisted with their reason
+				assert.Equal(t, models.TransferStatusFailed, transferRepo.transfers[transfer.ID].Status)
+				assert.Equal(t, tt.wantErr.Error(), transferRepo.transfers[transfer.ID].FailureReason)
+			default:
+				assert.Empty(t, transferRepo.transfers)
+			}
+		})
+	}
+}
+
+type transferRecorder struct {
+	completed []int64
+}
+
+func (r *transferRecorder) TransferCompleted(transfer *models.Transfer) {
+	r.completed = append(r.completed, transfer.ID)
+}
+
+func TestTransferLifecycle(t *testing.T) {
+	wallets := map[int64]*models.Wallet{
+		1: {ID: 1, UserID: 1, Currency: "USD", Balance: 100},
+		2: {ID: 2, UserID: 2, Currency: "USD"},
+	}
+	transferRepo := newMockTransferRepo()
+	txRepo := &mockTransactionRepo{wallets: wallets, transferRepo: transferRepo}
+	auditRepo := &mockAuditRepo{}
+	service := services.NewTransferService(transferRepo, txRepo, newMockUserRepo(), &mockWalletRepo{wallets: wallets}, nil, services.NewAuditService(auditRepo))
+	recorder := &transferRecorder{}
+	service.AddObserver(recorder)
+
+	t.Run("a transfer that goes through is completed", func(t *testing.T) {
+		transfer, err := service.InitiateTransfer(models.Actor{UserID: 1}, 1, 2, 40, false, models.TransferNote{}, "")
+		require.NoError(t, err)
+
+		stored := transferRepo.transfers[transfer.ID]
+		assert.Equal(t, models.TransferStatusCompleted, stored.Status)
+		assert.Empty(t, stored.FailureReason)
+		require.NotNil(t, transfer.TransactionID)
+		assert.Equal(t, []int64{transfer.ID}, recorder.completed)
+
+		require.Len(t, auditRepo.entries, 1)
+		assert.Equal(t, models.AuditActionTransferCreate, auditRepo.entries[0].Action)
+		assert.Equal(t, strconv.FormatInt(transfer.ID, 10), auditRepo.entries[0].TargetID)
+	})
+
+	t.Run("a transfer the sender cannot afford is failed", func(t *testing.T) {
+		transfer, err := service.InitiateTransfer(models.Actor{UserID: 1}, 1, 2, 500, false, models.TransferNote{}, "")
+		assert.ErrorIs(t, err, repository.ErrInsufficientFunds)
+		require.NotNil(t, transfer)
+
+		stored := transferRepo.transfers[transfer.ID]
+		assert.Equal(t, models.TransferStatusFailed, stored.Status)
+		assert.Equal(t, repository.ErrInsufficientFunds.Error(), stored.FailureReason)
+		assert.Equal(t, models.TransferStatusFailed, transfer.Status)
+		assert.Nil(t, transfer.TransactionID)
+
+		// Failed transfers are audited too, but nobody is told they completed
+		assert.Len(t, recorder.completed, 1)
+		require.Len(t, auditRepo.entries, 2)
+		assert.Equal(t, strconv.FormatInt(transfer.ID, 10), auditRepo.entries[1].TargetID)
+	})
+}
+
+func TestTransferNotes(t *testing.T) {
+	wallets := map[int64]*models.Wallet{
+		1: {ID: 1, UserID: 1, Currency: "USD", Balance: 100},
+		2: {ID: 2, UserID: 2, Currency: "USD"},
+	}
+	transferRepo := newMockTransferRepo()
+	txRepo := &mockTransactionRepo{wallets: wallets, transferRepo: transferRepo}
+	service := services.NewTransferService(transferRepo, txRepo, newMockUserRepo(), &mockWalletRepo{wallets: wallets}, nil, services.NewAuditService(&mockAuditRepo{}))
+
+	send := func(note models.TransferNote) (*models.Transfer, error) {
+		return service.InitiateTransfer(models.Actor{UserID: 1}, 1, 2, 1, false, note, "")
+	}
+
+	t.Run("invalid notes are rejected before anything is stored", func(t *testing.T) {
+		_, err := send(models.TransferNote{Message: strings.Repeat("a", 501)})
+		assert.ErrorIs(t, err, services.ErrMessageTooLong)
+		_, err = send(models.TransferNote{Category: "Team Work"})
+		assert.ErrorIs(t, err, services.ErrInvalidCategory)
+		_, err = send(models.TransferNote{Visibility: "everyone"})
+		assert.ErrorIs(t, err, services.ErrInvalidVisibility)
+		assert.Empty(t, transferRepo.transfers)
+	})
+
+	t.Run("visibility defaults to recipient only", func(t *testing.T) {
+		transfer, err := send(models.TransferNote{Message: "  Thanks!  ", Category: "teamwork"})
+		require.NoError(t, err)
+		assert.Equal(t, "Thanks!", transfer.Message)
+		assert.Equal(t, models.TransferVisibilityRecipientOnly, transfer.Visibility)
+	})
+
+	visibleTo := map[models.TransferVisibility][]int{
+		models.TransferVisibilityPublic:        {1, 2},
+		models.TransferVisibilityRecipientOnly: {1, 2},
+		models.TransferVisibilityPrivate:       {1},
+	}
+	for visibility, viewers := range visibleTo {
+		t.Run(string(visibility)+" notes are shown to their audience only", func(t *testing.T) {
+			transfer, err := send(models.TransferNote{Message: "Thanks!", Category: "teamwork", Visibility: visibility})
+			require.NoError(t, err)
+
+			// Only the sender and the receiver see a transfer at all
+			_, err = service.GetTransferStatus(3, transfer.ID)
+			assert.ErrorIs(t, err, sql.ErrNoRows)
+
+			for viewerID := 1; viewerID <= 2; viewerID++ {
+				seen, err := service.GetTransferStatus(viewerID, transfer.ID)
+				require.NoError(t, err)
+				if slices.Contains(viewers, viewerID) {
+					assert.Equal(t, "Thanks!", seen.Message, "viewer %d", viewerID)
+					assert.Equal(t, "teamwork", seen.Category, "viewer %d", viewerID)
+				} else {
+					assert.Empty(t, seen.Message, "viewer %d", viewerID)
+					assert.Empty(t, seen.Category, "viewer %d", viewerID)
+				}
+			}
+			// Redacting a view leaves the stored transfer alone
+			assert.Equal(t, "Thanks!", transferRepo.transfers[transfer.ID].Message)
+		})
+	}
+
+	t.Run("the receiver of an anonymous transfer is not told the sender", func(t *testing.T) {
+		transfer, err := service.InitiateTransfer(models.Actor{UserID: 1}, 1, 2, 5, true, models.TransferNote{Message: "Thanks!", Visibility: models.TransferVisibilityPublic}, "")
+		require.NoError(t, err)
+
+		seen, err := service.GetTransferStatus(2, transfer.ID)
+		require.NoError(t, err)
+		assert.Zero(t, seen.SenderWalletID)
+		assert.Equal(t, "Thanks!", seen.Message)
+		seen, err = service.GetTransferStatus(1, transfer.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), seen.SenderWalletID)
+	})
+}
+
+func TestInitiatePseudonymousTransfer(t *testing.T) {
+	key, err := utils.GenerateECDSAKeyPair()
+	require.NoError(t, err)
+	otherKey, err := utils.GenerateECDSAKeyPair()
+	require.NoError(t, err)
+
+	wallets := map[int64]*models.Wallet{
+		1: {ID: 1, UserID: 1, Currency: "USD", Balance: 100},
+		2: {ID: 2, UserID: 2, Currency: "USD"},
+		4: {ID: 4, Currency: "USD", Balance: 100},
+	}
+	pseudoRepo := &mockPseudonymousWalletRepo{wallets: map[int64]*models.PseudonymousWallet{
+		4: {ID: 4, PublicKey: utils.PublicKeyToString(&key.PublicKey), IsPseudonymous: true},
+	}}
+	transferRepo := newMockTransferRepo()
+	txRepo := &mockTransactionRepo{wallets: wallets, transferRepo: transferRepo}
+	service := services.NewTransferService(transferRepo, txRepo, newMockUserRepo(), &mockWalletRepo{wallets: wallets}, pseudoRepo, services.NewAuditService(&mockAuditRepo{}))
+
+	validUntil := time.Now().Add(5 * time.Minute).Unix()
+	sign := func(signer *ecdsa.PrivateKey, amount int64, nonce string, expiresAt int64) string {
+		signature, err := utils.SignMessage(signer, utils.SignedTransferMessage(4, 2, amount, nonce, expiresAt))
+		require.NoError(t, err)
+		return signature
+	}
+
+	tests := []struct {
+		name      string
+		sender    int64
+		amount    int64
+		nonce     string
+		expiresAt int64
+		signature string
+		wantErr   error
+	}{
+		{"valid transfer", 4, 10, "nonce-000000000001", validUntil, sign(key, 10, "nonce-000000000001", validUntil), nil},
+		{"replayed nonce", 4, 10, "nonce-000000000001", validUntil, sign(key, 10, "nonce-000000000001", validUntil), services.ErrNonceReused},
+		{"signed by another key", 4, 10, "nonce-000000000002", validUntil, sign(otherKey, 10, "nonce-000000000002", validUntil), services.ErrInvalidSignature},
+		{"tampered amount", 4, 50, "nonce-000000000003", validUntil, sign(key, 10, "nonce-000000000003", validUntil), services.ErrInvalidSignature},
+		{"expired signature", 4, 10, "nonce-000000000004", time.Now().Add(-time.Minute).Unix(), sign(key, 10, "nonce-000000000004", time.Now().Add(-time.Minute).Unix()), services.ErrSignatureExpired},
+		{"expiry too far ahead", 4, 10, "nonce-000000000005", time.Now().Add(time.Hour).Unix(), sign(key, 10, "nonce-000000000005", time.Now().Add(time.Hour).Unix()), services.ErrSignatureExpiryTooFar},
+		{"short nonce", 4, 10, "abc", validUntil, sign(key, 10, "abc", validUntil), services.ErrInvalidNonce},
+		{"regular wallet", 1, 10, "nonce-000000000006", validUntil, sign(key, 10, "nonce-000000000006", validUntil), services.ErrSenderWalletNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfer, err := service.InitiatePseudonymousTransfer(models.Actor{}, tt.sender, 2, tt.amount, tt.nonce, tt.expiresAt, tt.signature)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, models.TransferStatusCompleted, transfer.Status)
+			assert.True(t, transfer.IsAnonymous)
+			require.NotNil(t, transfer.Authorization)
+			assert.Equal(t, tt.signature, transfer.Authorization.Signature)
+			assert.Equal(t, pseudoRepo.wallets[4].PublicKey, transfer.Authorization.PublicKey)
+		})
+	}
+}
+
+// mockPseudonymousWalletRepo keeps wallets and their key history in memory
+type mockPseudonymousWalletRepo struct {
+	wallets map[int64]*models.PseudonymousWallet
+	keys    []*models.PseudonymousWalletKey
+}
+
+func (m *mockPseudonymousWalletRepo) CreatePseudonymousWallet(publicKey string) (*models.PseudonymousWallet, error) {
+	for _, key := range m.keys {
+		if key.PublicKey == publicKey {
+			return nil, repository.ErrPublicKeyInUse
+		}
+	}
+	wallet := &models.PseudonymousWallet{ID: int64(len(m.wallets) + 1), PublicKey: publicKey, IsPseudonymous: true}
+	m.wallets[wallet.ID] = wallet
+	m.keys = append(m.keys, &models.PseudonymousWalletKey{WalletID: wallet.ID, PublicKey: publicKey, Status: models.WalletKeyStatusActive})
+	return wallet, nil
+}
+
+func (m *mockPseudonymousWalletRepo) FindByID(id int64) (*models.PseudonymousWallet, error) {
+	if wallet, ok := m.wallets[id]; ok {
+		copied := *wallet
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *mockPseudonymousWalletRepo) FindByPublicKey(publicKey string) (*models.PseudonymousWallet, error) {
+	for _, wallet := range m.wallets {
+		if wallet.PublicKey == publicKey {
+			return wallet, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *mockPseudonymousWalletRepo) retireActiveKey(walletID int64, publicKey string, status models.WalletKeyStatus) error {
+	if m.wallets[walletID].PublicKey != publicKey {
+		return repository.ErrWalletKeyChanged
+	}
+	for _, key := range m.keys {
+		if key.WalletID == walletID && key.Status == models.WalletKeyStatusActive {
+			key.Status = status
+		}
+	}
+	return nil
+}
+
+func (m *mockPseudonymousWalletRepo) RotateKey(walletID int64, oldPublicKey, newPublicKey, rotationSignature string) (*models.PseudonymousWalletKey, error) {
+	for _, key := range m.keys {
+		if key.PublicKey == newPublicKey {
+			return nil, repository.ErrPublicKeyInUse
+		}
+	}
+	if err := m.retireActiveKey(walletID, oldPublicKey, models.WalletKeyStatusRotated); err != nil {
+		return nil, err
+	}
+	key := &models.PseudonymousWalletKey{WalletID: walletID, PublicKey: newPublicKey, Status: models.WalletKeyStatusActive, RotationSignature: rotationSignature}
+	m.keys = append(m.keys, key)
+	m.wallets[walletID].PublicKey = newPublicKey
+	return key, nil
+}
+
+func (m *mockPseudonymousWalletRepo) RevokeKey(walletID int64, publicKey, reason string) error {
+	if err := m.retireActiveKey(walletID, publicKey, models.WalletKeyStatusRevoked); err != nil {
+		return err
+	}
+	m.wallets[walletID].PublicKey = ""
+	return nil
+}
+
+func (m *mockPseudonymousWalletRepo) FindKeys(walletID int64) ([]*models.PseudonymousWalletKey, error) {
+	var keys []*models.PseudonymousWalletKey
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		if m.keys[i].WalletID == walletID {
+			keys = append(keys, m.keys[i])
+		}
+	}
+	return keys, nil
+}
+
+type mockTransferRepo struct {
+	transfers map[int64]*models.Transfer
+}
+
+func newMockTransferRepo() *mockTransferRepo {
+	return &mockTransferRepo{transfers: make(map[int64]*models.Transfer)}
+}
+
+func (m *mockTransferRepo) Create(transfer *models.Transfer) error {
+	for _, existing := range m.transfers {
+		if transfer.Authorization != nil && existing.Authorization != nil &&
+			existing.SenderWalletID == transfer.SenderWalletID && existing.Authorization.Nonce == transfer.Authorization.Nonce {
+			return repository.ErrNonceAlreadyUsed
+		}
+	}
+	transfer.ID = int64(len(m.transfers) + 1)
+	stored := *transfer
+	m.transfers[transfer.ID] = &stored
+	return nil
+}
+
+func (m *mockTransferRepo) FindByID(id int64) (*models.Transfer, error) {
+	if transfer, ok := m.transfers[id]; ok {
+		copied := *transfer
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("transfer not found")
+}
+
+func (m *mockTransferRepo) UpdateStatus(id int64, status models.TransferStatus) error {
+	m.transfers[id].Status = status
+	return nil
+}
+
+func (m *mockTransferRepo) MarkFailed(id int64, reason string) error {
+	m.transfers[id].Status = models.TransferStatusFailed
+	m.transfers[id].FailureReason = reason
+	return nil
+}
+
+type mockTransactionRepo struct {
+	wallets      map[int64]*models.Wallet
+	transferRepo *mockTransferRepo
+	requestRepo  *mockPaymentRequestRepo  // Marks the payment requests transfers pay
+	statement    []*models.StatementEntry // Entries of every wallet statement, oldest first
+}
+
+func (m *mockTransactionRepo) TransferCoins(senderWalletID, receiverWalletID, amount int64) (*models.Transaction, []*models.LedgerEntry, error) {
+	if m.wallets[senderWalletID].Balance < amount {
+		return nil, nil, repository.ErrInsufficientFunds
+	}
+	return &models.Transaction{ID: 1, SenderWalletID: senderWalletID, ReceiverWalletID: receiverWalletID, Amount: amount}, nil, nil
+}
+
+func (m *mockTransactionRepo) ExecuteTransfer(transfer *models.Transfer) (*models.Transaction, []*models.LedgerEntry, error) {
+	transaction, entries, err := m.TransferCoins(transfer.SenderWalletID, transfer.ReceiverWalletID, transfer.Amount)
+	if err != nil {
+		return nil, nil, err
+	}
+	if transfer.PaymentRequestID != nil {
+		if err := m.requestRepo.markPaid(*transfer.PaymentRequestID, transfer.ID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := m.transferRepo.UpdateStatus(transfer.ID, models.TransferStatusCompleted); err != nil {
+		return nil, nil, err
+	}
+	transfer.Status = models.TransferStatusCompleted
+	transfer.TransactionID = &transaction.ID
+	return transaction, entries, nil
+}
+
+func (m *mockTransactionRepo) FindWalletStatement(walletID int64, filter models.TransactionHistoryFilter) ([]*models.StatementEntry, error) {
+	var entries []*models.StatementEntry
+	for i := len(m.statement) - 1; i >= 0 && len(entries) < filter.Limit; i-- {
+		entry := *m.statement[i]
+		hidden := entry.IsAnonymous && entry.Direction == models.DirectionReceived
+		switch {
+		case filter.BeforeEntryID > 0 && entry.EntryID >= filter.BeforeEntryID,
+			filter.Direction != "" && entry.Direction != filter.Direction,
+			filter.CounterpartyWalletID != nil && (hidden || *entry.CounterpartyWalletID != *filter.CounterpartyWalletID),
+			filter.MinAmount != nil && entry.Amount < *filter.MinAmount,
+			filter.MaxAmount != nil && entry.Amount > *filter.MaxAmount:
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+type mockWalletRepo struct {
+	wallets map[int64]*models.Wallet
+}
+
+func (m *mockWalletRepo) Create(wallet *models.Wallet) error {
+	wallet.ID = int64(len(m.wallets) + 1)
+	m.wallets[wallet.ID] = wallet
+	return nil
+}
+
+func (m *mockWalletRepo) FindByUserID(userID int) ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	for _, wallet := range m.wallets {
+		if wallet.UserID == userID {
+			wallets = append(wallets, *wallet)
+		}
+	}
+	return wallets, nil
+}
+
+func (m *mockWalletRepo) FindByID(id int64) (*models.Wallet, error) {
+	if wallet, ok := m.wallets[id]; ok {
+		copied := *wallet
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+// mockUserRepo holds two users that do not need a PIN to transfer
+type mockUserRepo struct {
+	users map[int]*models.User
+}
+
+func newMockUserRepo() *mockUserRepo {
+	return &mockUserRepo{
+		users: map[int]*models.User{
+			1: {ID: 1, Username: "alice"},
+			2: {ID: 2, Username: "bob"},
+		},
+	}
+}
+
+func (m *mockUserRepo) Create(user *models.User, passwordHash, pinHash string) (int, error) {
+	user.ID = len(m.users) + 1
+	m.users[user.ID] = user
+	return user.ID, nil
+}
+
+func (m *mockUserRepo) FindByID(id int) (*models.User, error) {
+	if user, ok := m.users[id]; ok {
+		return user, nil
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (m *mockUserRepo) FindByUsername(username string) (*models.User, error) {
+	for _, user := range m.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (m *mockUserRepo) FindByEmailAndProvider(email, provider string) (*models.User, error) {
+	return nil, fmt.Errorf("user not found")
+}
+
+func (m *mockUserRepo) FindByProviderID(providerID string) (*models.User, error) {
+	return nil, fmt.Errorf("user not found")
+}
+
+func (m *mockUserRepo) SetPin(userID int, pinHash string) error {
+	return nil
+}
+
+func (m *mockUserRepo) Update(user *models.User) error {
+	m.users[user.ID] = user
+	return nil
+}
+
+func (m *mockUserRepo) FindAll() ([]*models.User, error) {
+	users := make([]*models.User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
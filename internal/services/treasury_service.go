@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+	"verve/internal/config"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+var (
+	ErrTreasuryNotConfigured = errors.New("treasury grants are not configured")
+	ErrTreasuryWalletMissing = errors.New("treasury wallet not found")
+	ErrGrantCycleInProgress  = errors.New("a grant cycle is already running")
+)
+
+// TreasuryService mints coins into the treasury wallet and distributes them to users in grant cycles.
+type TreasuryService struct {
+	treasuryRepo repository.TreasuryRepository
+	userRepo     repository.UserRepository
+	walletRepo   repository.WalletRepository
+	auditService *AuditService
+	config       config.TreasuryConfig
+
+	mu      sync.Mutex
+	running map[int64]bool
+}
+
+// NewTreasuryService creates a new TreasuryService.
+func NewTreasuryService(
+	treasuryRepo repository.TreasuryRepository,
+	userRepo repository.UserRepository,
+	walletRepo repository.WalletRepository,
+	auditService *AuditService,
+	cfg config.TreasuryConfig,
+) *TreasuryService {
+	return &TreasuryService{
+		treasuryRepo: treasuryRepo,
+		userRepo:     userRepo,
+		walletRepo:   walletRepo,
+		auditService: auditService,
+		config:       cfg,
+		running:      make(map[int64]bool),
+	}
+}
+
+// TreasuryWallet returns the treasury user and its wallet.
+func (s *TreasuryService) TreasuryWallet() (*models.User, *models.Wallet, error) {
+	if s.config.Username == "" {
+		return nil, nil, ErrTreasuryNotConfigured
+	}
+
+	user, err := s.userRepo.FindByUsername(s.config.Username)
+	if err != nil {
+		return nil, nil, ErrTreasuryWalletMissing
+	}
+
+	wallets, err := s.walletRepo.FindByUserID(user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(wallets) == 0 {
+		return nil, nil, ErrTreasuryWalletMissing
+	}
+
+	// The oldest wallet is the one seeded with the treasury
+	wallet := wallets[0]
+	for _, w := range wallets[1:] {
+		if w.ID < wallet.ID {
+			wallet = w
+		}
+	}
+	return user, &wallet, nil
+}
+
+// GrantAmountPerUser returns the configured amount paid to each user per cycle.
+func (s *TreasuryService) GrantAmountPerUser() int64 {
+	return int64(s.config.GrantAmountPerUser)
+}
+
+// StartGrantCycle snapshots every active user and starts paying them in the background.
+func (s *TreasuryService) StartGrantCycle(actor models.Actor) (*models.GrantCycle, error) {
+	if s.config.GrantAmountPerUser <= 0 {
+		return nil, ErrTreasuryNotConfigured
+	}
+
+	treasuryUser, wallet, err := s.TreasuryWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	running, err := s.treasuryRepo.FindRunningCycles()
+	if err != nil {
+		return nil, err
+	}
+	if len(running) > 0 {
+		return nil, ErrGrantCycleInProgress
+	}
+
+	cycle := &models.GrantCycle{
+		TreasuryWalletID: wallet.ID,
+		AmountPerUser:    int64(s.config.GrantAmountPerUser),
+	}
+	if actor.UserID != 0 {
+		cycle.StartedBy = &actor.UserID
+	}
+	if err := s.treasuryRepo.CreateCycle(cycle, treasuryUser.ID, wallet.Currency); err != nil {
+		return nil, err
+	}
+	s.auditService.Record(actor, models.AuditActionGrantCycleStart, "grant_cycle", strconv.FormatInt(cycle.ID, 10), nil, cycle)
+
+	go s.runCycle(cycle.ID)
+
+	return cycle, nil
+}
+
+// ResumeInterruptedCycles restarts every cycle that was still running when the server stopped.
+func (s *TreasuryService) ResumeInterruptedCycles() error {
+	cycles, err := s.treasuryRepo.FindRunningCycles()
+	if err != nil {
+		return err
+	}
+	for _, cycle := range cycles {
+		log.Printf("Resuming treasury grant cycle %d", cycle.ID)
+		go s.runCycle(cycle.ID)
+	}
+	return nil
+}
+
+// GetGrantCycle returns a cycle along with its progress.
+func (s *TreasuryService) GetGrantCycle(id int64) (*models.GrantCycle, error) {
+	return s.treasuryRepo.FindCycleByID(id)
+}
+
+// ListGrantCycles returns the most recent cycles, newest first.
+func (s *TreasuryService) ListGrantCycles(limit int) ([]*models.GrantCycle, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.treasuryRepo.FindCycles(limit)
+}
+
+// GetGrantCycleReport returns the result of every payout in a cycle.
+func (s *TreasuryService) GetGrantCycleReport(id int64) (*models.GrantCycle, []*models.GrantPayout, error) {
+	cycle, err := s.treasuryRepo.FindCycleByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	payouts, err := s.treasuryRepo.FindPayouts(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cycle, payouts, nil
+}
+
+// runCycle pays out every pending grant of a cycle, one user at a time.
+func (s *TreasuryService) runCycle(cycleID int64) {
+	s.mu.Lock()
+	if s.running[cycleID] {
+		s.mu.Unlock()
+		return
+	}
+	s.running[cycleID] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, cycleID)
+		s.mu.Unlock()
+	}()
+
+	if err := s.processCycle(cycleID); err != nil {
+		log.Printf("Treasury grant cycle %d failed: %v", cycleID, err)
+		if err := s.treasuryRepo.UpdateCycleStatus(cycleID, models.GrantCycleStatusFailed, err.Error()); err != nil {
+			log.Printf("Failed to mark treasury grant cycle %d as failed: %v", cycleID, err)
+		}
+		return
+	}
+
+	if err := s.treasuryRepo.UpdateCycleStatus(cycleID, models.GrantCycleStatusCompleted, ""); err != nil {
+		log.Printf("Failed to mark treasury grant cycle %d as completed: %v", cycleID, err)
+	}
+}
+
+func (s *TreasuryService) processCycle(cycleID int64) error {
+	cycle, err := s.treasuryRepo.FindCycleByID(cycleID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.mintShortfall(cycle); err != nil {
+		return err
+	}
+
+	delay := time.Duration(s.config.GrantDelayMs) * time.Millisecond
+	for first := true; ; first = false {
+		if !first && delay > 0 {
+			time.Sleep(delay)
+		}
+
+		payout, err := s.treasuryRepo.PayNextPayout(cycle.ID, cycle.TreasuryWalletID)
+		if payout == nil {
+			// Either none are left or the next one could not be read
+			return err
+		}
+		if err != nil {
+			if err := s.treasuryRepo.FailPayout(payout.ID, err.Error()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// mintShortfall mints whatever the treasury is missing to pay the remaining payouts of a cycle.
+func (s *TreasuryService) mintShortfall(cycle *models.GrantCycle) error {
+	remaining := int64(cycle.Progress.Pending) * cycle.AmountPerUser
+	if remaining == 0 {
+		return nil
+	}
+
+	wallet, err := s.walletRepo.FindByID(cycle.TreasuryWalletID)
+	if err != nil {
+		return err
+	}
+	if wallet == nil {
+		return ErrTreasuryWalletMissing
+	}
+
+	shortfall := remaining - wallet.Balance
+	if shortfall <= 0 {
+		return nil
+	}
+
+	if _, err := s.treasuryRepo.Mint(wallet.ID, shortfall); err != nil {
+		return err
+	}
+	return s.treasuryRepo.AddMintedAmount(cycle.ID, shortfall)
+}
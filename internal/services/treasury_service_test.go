@@ -0,0 +1,323 @@
+package services_test
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+	"verve/internal/config"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreasuryGrants(t *testing.T) {
+	const treasuryWalletID = 10
+	cfg := config.TreasuryConfig{GrantAmountPerUser: 100, Username: "treasury@system.local"}
+
+	// setup creates a treasury holding balance and users 1 and 2 with a wallet each
+	setup := func(cfg config.TreasuryConfig, balance int64) (*services.TreasuryService, *mockTreasuryRepo, *mockWalletRepo) {
+		userRepo := newMockUserRepo()
+		userRepo.users[3] = &models.User{ID: 3, Username: "treasury@system.local"}
+		walletRepo := &mockWalletRepo{wallets: map[int64]*models.Wallet{
+			treasuryWalletID: {ID: treasuryWalletID, UserID: 3, Currency: "USD", Balance: balance},
+			1:                {ID: 1, UserID: 1, Currency: "USD"},
+			2:                {ID: 2, UserID: 2, Currency: "USD"},
+		}}
+		treasuryRepo := newMockTreasuryRepo(map[int]int64{1: 1, 2: 2})
+		treasuryRepo.balances[treasuryWalletID] = balance
+		return services.NewTreasuryService(treasuryRepo, userRepo, walletRepo, services.NewAuditService(&mockAuditRepo{}), cfg), treasuryRepo, walletRepo
+	}
+	waitForCycle := func(t *testing.T, service *services.TreasuryService, id int64) *models.GrantCycle {
+		var cycle *models.GrantCycle
+		require.Eventually(t, func() bool {
+			var err error
+			cycle, err = service.GetGrantCycle(id)
+			return err == nil && cycle.Status != models.GrantCycleStatusRunning
+		}, time.Second, time.Millisecond)
+		return cycle
+	}
+
+	t.Run("A cycle pays every user once and mints what the treasury is missing", func(t *testing.T) {
+		service, treasuryRepo, _ := setup(cfg, 50)
+		cycle, err := service.StartGrantCycle(models.Actor{UserID: 1})
+		require.NoError(t, err)
+
+		cycle = waitForCycle(t, service, cycle.ID)
+		assert.Equal(t, models.GrantCycleStatusCompleted, cycle.Status)
+		assert.Equal(t, int64(150), cycle.MintedAmount)
+		assert.Equal(t, models.GrantProgress{Total: 2, Completed: 2}, cycle.Progress)
+
+		_, payouts, err := service.GetGrantCycleReport(cycle.ID)
+		require.NoError(t, err)
+		for _, payout := range payouts {
+			assert.NotNil(t, payout.TransactionID)
+		}
+		assert.Equal(t, int64(100), treasuryRepo.balance(1))
+		assert.Equal(t, int64(100), treasuryRepo.balance(2))
+		assert.Zero(t, treasuryRepo.balance(treasuryWalletID))
+	})
+
+	t.Run("A payout that cannot be paid fails alone", func(t *testing.T) {
+		service, treasuryRepo, _ := setup(cfg, 200)
+		treasuryRepo.failWallets[2] = errors.New("wallet is frozen")
+		cycle, err := service.StartGrantCycle(models.Actor{UserID: 1})
+		require.NoError(t, err)
+
+		cycle = waitForCycle(t, service, cycle.ID)
+		assert.Equal(t, models.GrantCycleStatusCompleted, cycle.Status)
+		assert.Equal(t, models.GrantProgress{Total: 2, Completed: 1, Failed: 1}, cycle.Progress)
+
+		_, payouts, err := service.GetGrantCycleReport(cycle.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.GrantPayoutStatusFailed, payouts[1].Status)
+		assert.Equal(t, "wallet is frozen", payouts[1].FailureReason)
+		assert.Nil(t, payouts[1].TransactionID)
+		assert.Zero(t, treasuryRepo.balance(2))
+		assert.Equal(t, int64(100), treasuryRepo.balance(treasuryWalletID))
+	})
+
+	t.Run("A resumed cycle only pays what is still pending", func(t *testing.T) {
+		service, treasuryRepo, _ := setup(cfg, 100)
+		// Alice was paid before the server stopped
+		transactionID := int64(99)
+		treasuryRepo.cycles[1] = &models.GrantCycle{ID: 1, TreasuryWalletID: treasuryWalletID, AmountPerUser: 100, Status: models.GrantCycleStatusRunning}
+		treasuryRepo.payouts = []*models.GrantPayout{
+			{ID: 1, CycleID: 1, UserID: 1, WalletID: 1, Amount: 100, Status: models.GrantPayoutStatusCompleted, TransactionID: &transactionID},
+			{ID: 2, CycleID: 1, UserID: 2, WalletID: 2, Amount: 100, Status: models.GrantPayoutStatusPending},
+		}
+		treasuryRepo.balances[1] = 100
+
+		require.NoError(t, service.ResumeInterruptedCycles())
+		cycle := waitForCycle(t, service, 1)
+		assert.Equal(t, models.GrantCycleStatusCompleted, cycle.Status)
+		assert.Zero(t, cycle.MintedAmount)
+		assert.Equal(t, int64(100), treasuryRepo.balance(1))
+		assert.Equal(t, int64(100), treasuryRepo.balance(2))
+	})
+
+	t.Run("Servers running the same cycle pay each user once", func(t *testing.T) {
+		service, treasuryRepo, walletRepo := setup(cfg, 0)
+		recipients := map[int]int64{}
+		for userID := 101; userID <= 150; userID++ {
+			recipients[userID] = int64(userID) + 1000
+		}
+		treasuryRepo.recipients = recipients
+		treasuryRepo.balances[treasuryWalletID] = 5000
+		walletRepo.wallets[treasuryWalletID].Balance = 5000
+		other := services.NewTreasuryService(treasuryRepo, newMockUserRepo(), walletRepo, services.NewAuditService(&mockAuditRepo{}), cfg)
+
+		cycle, err := service.StartGrantCycle(models.Actor{UserID: 1})
+		require.NoError(t, err)
+		require.NoError(t, other.ResumeInterruptedCycles())
+
+		cycle = waitForCycle(t, service, cycle.ID)
+		assert.Equal(t, 50, cycle.Progress.Completed)
+		for userID, walletID := range recipients {
+			assert.Equal(t, int64(100), treasuryRepo.balance(walletID), "user %d", userID)
+		}
+		assert.Zero(t, treasuryRepo.balance(treasuryWalletID))
+	})
+
+	t.Run("Cycles need a configured grant and run one at a time", func(t *testing.T) {
+		service, treasuryRepo, _ := setup(config.TreasuryConfig{Username: "treasury@system.local"}, 0)
+		_, err := service.StartGrantCycle(models.Actor{UserID: 1})
+		assert.ErrorIs(t, err, services.ErrTreasuryNotConfigured)
+
+		service, treasuryRepo, _ = setup(config.TreasuryConfig{GrantAmountPerUser: 100}, 0)
+		_, err = service.StartGrantCycle(models.Actor{UserID: 1})
+		assert.ErrorIs(t, err, services.ErrTreasuryNotConfigured)
+
+		service, treasuryRepo, _ = setup(cfg, 0)
+		treasuryRepo.cycles[1] = &models.GrantCycle{ID: 1, Status: models.GrantCycleStatusRunning}
+		_, err = service.StartGrantCycle(models.Actor{UserID: 1})
+		assert.ErrorIs(t, err, services.ErrGrantCycleInProgress)
+
+		_, err = service.ListGrantCycles(1000)
+		require.NoError(t, err)
+		assert.Equal(t, 20, treasuryRepo.lastLimit)
+		_, err = service.ListGrantCycles(5)
+		require.NoError(t, err)
+		assert.Equal(t, 5, treasuryRepo.lastLimit)
+	})
+}
+
+// mockTreasuryRepo keeps grant cycles and payouts in memory. Like the database, it pays a
+// payout and completes it in one step, and hands each payout to one caller only.
+type mockTreasuryRepo struct {
+	mu            sync.Mutex
+	recipients    map[int]int64 // Wallet paid for each user
+	balances      map[int64]int64
+	failWallets   map[int64]error
+	cycles        map[int64]*models.GrantCycle
+	payouts       []*models.GrantPayout
+	transactionID int64
+	lastLimit     int
+}
+
+func newMockTreasuryRepo(recipients map[int]int64) *mockTreasuryRepo {
+	return &mockTreasuryRepo{
+		recipients:  recipients,
+		balances:    map[int64]int64{},
+		failWallets: map[int64]error{},
+		cycles:      map[int64]*models.GrantCycle{},
+	}
+}
+
+func (m *mockTreasuryRepo) balance(walletID int64) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.balances[walletID]
+}
+
+func (m *mockTreasuryRepo) Mint(walletID, amount int64) (*models.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.balances[walletID] += amount
+	m.transactionID++
+	return &models.Transaction{ID: m.transactionID, ReceiverWalletID: walletID, Amount: amount}, nil
+}
+
+func (m *mockTreasuryRepo) CreateCycle(cycle *models.GrantCycle, excludeUserID int, currency string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cycle.ID = int64(len(m.cycles) + 1)
+	cycle.Status = models.GrantCycleStatusRunning
+	stored := *cycle
+	m.cycles[cycle.ID] = &stored
+
+	userIDs := make([]int, 0, len(m.recipients))
+	for userID := range m.recipients {
+		if userID != excludeUserID {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Ints(userIDs)
+	for _, userID := range userIDs {
+		m.payouts = append(m.payouts, &models.GrantPayout{
+			ID:       int64(len(m.payouts) + 1),
+			CycleID:  cycle.ID,
+			UserID:   userID,
+			WalletID: m.recipients[userID],
+			Amount:   cycle.AmountPerUser,
+			Status:   models.GrantPayoutStatusPending,
+		})
+	}
+	return nil
+}
+
+func (m *mockTreasuryRepo) FindCycleByID(id int64) (*models.GrantCycle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.cycles[id]
+	if !ok {
+		return nil, errors.New("cycle not found")
+	}
+	cycle := *stored
+	cycle.Progress = models.GrantProgress{}
+	for _, payout := range m.payouts {
+		if payout.CycleID != id {
+			continue
+		}
+		cycle.Progress.Total++
+		switch payout.Status {
+		case models.GrantPayoutStatusPending:
+			cycle.Progress.Pending++
+		case models.GrantPayoutStatusCompleted:
+			cycle.Progress.Completed++
+		case models.GrantPayoutStatusFailed:
+			cycle.Progress.Failed++
+		}
+	}
+	return &cycle, nil
+}
+
+func (m *mockTreasuryRepo) FindCycles(limit int) ([]*models.GrantCycle, error) {
+	m.mu.Lock()
+	m.lastLimit = limit
+	m.mu.Unlock()
+	return nil, nil
+}
+
+func (m *mockTreasuryRepo) FindRunningCycles() ([]*models.GrantCycle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var cycles []*models.GrantCycle
+	for _, cycle := range m.cycles {
+		if cycle.Status == models.GrantCycleStatusRunning {
+			copied := *cycle
+			cycles = append(cycles, &copied)
+		}
+	}
+	return cycles, nil
+}
+
+func (m *mockTreasuryRepo) AddMintedAmount(cycleID, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cycles[cycleID].MintedAmount += amount
+	return nil
+}
+
+func (m *mockTreasuryRepo) UpdateCycleStatus(id int64, status models.GrantCycleStatus, failureReason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cycles[id].Status = status
+	m.cycles[id].FailureReason = failureReason
+	return nil
+}
+
+func (m *mockTreasuryRepo) FindPayouts(cycleID int64) ([]*models.GrantPayout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var payouts []*models.GrantPayout
+	for _, payout := range m.payouts {
+		if payout.CycleID == cycleID {
+			copied := *payout
+			payouts = append(payouts, &copied)
+		}
+	}
+	return payouts, nil
+}
+
+func (m *mockTreasuryRepo) PayNextPayout(cycleID, treasuryWalletID int64) (*models.GrantPayout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, payout := range m.payouts {
+		if payout.CycleID != cycleID || payout.Status != models.GrantPayoutStatusPending {
+			continue
+		}
+		if err := m.failWallets[payout.WalletID]; err != nil {
+			copied := *payout
+			return &copied, err
+		}
+		if m.balances[treasuryWalletID] < payout.Amount {
+			copied := *payout
+			return &copied, errors.New("insufficient funds")
+		}
+		m.balances[treasuryWalletID] -= payout.Amount
+		m.balances[payout.WalletID] += payout.Amount
+		m.transactionID++
+		transactionID := m.transactionID
+		payout.Status = models.GrantPayoutStatusCompleted
+		payout.TransactionID = &transactionID
+		copied := *payout
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (m *mockTreasuryRepo) FailPayout(id int64, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, payout := range m.payouts {
+		if payout.ID == id && payout.Status == models.GrantPayoutStatusPending {
+			payout.Status = models.GrantPayoutStatusFailed
+			payout.FailureReason = reason
+		}
+	}
+	return nil
+}
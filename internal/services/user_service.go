@@ -1,6 +1,7 @@
 package services
 
 import (
+	"strconv"
 	"verve/internal/models"
 	"verve/internal/repository"
 
@@ -8,15 +9,21 @@ import (
 )
 
 type UserService struct {
-	userRepo repository.UserRepository
-	roleRepo repository.RoleRepository
+	userRepo     repository.UserRepository
+	roleRepo     repository.RoleRepository
+	auditService *AuditService
 }
 
-func NewUserService(userRepo repository.UserRepository, roleRepo repository.RoleRepository) *UserService {
-	return &UserService{userRepo: userRepo, roleRepo: roleRepo}
+func NewUserService(userRepo repository.UserRepository, roleRepo repository.RoleRepository, auditService *AuditService) *UserService {
+	return &UserService{userRepo: userRepo, roleRepo: roleRepo, auditService: auditService}
 }
 
-func (s *UserService) CreateUser(username, password, pin string, roleNames []string) (*models.User, error) {
+// pinState is whether a user has a PIN, as recorded in the audit log. The PIN itself is never recorded.
+type pinState struct {
+	PinSet bool `json:"pin_set"`
+}
+
+func (s *UserService) CreateUser(actor models.Actor, username, password, pin string, roleNames []string) (*models.User, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
@@ -50,17 +57,28 @@ func (s *UserService) CreateUser(username, password, pin string, roleNames []str
 		if err := s.roleRepo.AssignToUser(userID, role.ID); err != nil {
 			return nil, err
 		}
+		user.Roles = append(user.Roles, roleName)
 	}
 
+	s.auditService.Record(actor, models.AuditActionUserCreate, "user", strconv.Itoa(userID), nil, user)
 	return user, nil
 }
 
-func (s *UserService) SetPin(userID int, pin string) error {
+func (s *UserService) SetPin(actor models.Actor, userID int, pin string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
 	hashedPin, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
-	return s.userRepo.SetPin(userID, string(hashedPin))
+	if err := s.userRepo.SetPin(userID, string(hashedPin)); err != nil {
+		return err
+	}
+
+	s.auditService.Record(actor, models.AuditActionUserPinChange, "user", strconv.Itoa(userID), pinState{PinSet: user.PinHash != ""}, pinState{PinSet: true})
+	return nil
 }
 
 func (s *UserService) VerifyPin(userID int, pin string) error {
@@ -71,11 +89,12 @@ func (s *UserService) VerifyPin(userID int, pin string) error {
 	return bcrypt.CompareHashAndPassword([]byte(user.PinHash), []byte(pin))
 }
 
-func (s *UserService) UpdateUser(userID int, displayName, profilePhotoURL *string, pinRequiredForTransfer *bool) (*models.User, error) {
+func (s *UserService) UpdateUser(actor models.Actor, userID int, displayName, profilePhotoURL *string, pinRequiredForTransfer *bool) (*models.User, error) {
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil {
 		return nil, err
 	}
+	before := *user
 
 	if displayName != nil {
 		user.DisplayName = *displayName
@@ -90,6 +109,8 @@ func (s *UserService) UpdateUser(userID int, displayName, profilePhotoURL *strin
 	if err := s.userRepo.Update(user); err != nil {
 		return nil, err
 	}
+
+	s.auditService.Record(actor, models.AuditActionUserUpdate, "user", strconv.Itoa(userID), before, user)
 	return user, nil
 }
 
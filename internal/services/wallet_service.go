@@ -1,16 +1,28 @@
 package services
 
 import (
+	"errors"
 	"verve/internal/models"
 	"verve/internal/repository"
 )
 
+const (
+	defaultStatementLimit = 20
+	maxStatementLimit     = 100
+)
+
+var (
+	ErrWalletNotFound     = errors.New("wallet not found")
+	ErrWalletAccessDenied = errors.New("this wallet does not belong to you")
+)
+
 type WalletService struct {
 	walletRepo repository.WalletRepository
+	txRepo     repository.TransactionRepository
 }
 
-func NewWalletService(walletRepo repository.WalletRepository) *WalletService {
-	return &WalletService{walletRepo: walletRepo}
+func NewWalletService(walletRepo repository.WalletRepository, txRepo repository.TransactionRepository) *WalletService {
+	return &WalletService{walletRepo: walletRepo, txRepo: txRepo}
 }
 
 func (s *WalletService) CreateWallet(userID int, currency string) (*models.Wallet, error) {
@@ -33,3 +45,47 @@ func (s *WalletService) GetWalletsByUserID(userID int) ([]models.Wallet, error)
 func (s *WalletService) GetWalletByID(id int64) (*models.Wallet, error) {
 	return s.walletRepo.FindByID(id)
 }
+
+// GetTransactionHistory returns a page of the statement of a wallet owned by userID,
+// newest first, along with the entry ID to continue from (0 on the last page).
+// The sender of an anonymous transfer is redacted from the receiver's statement.
+func (s *WalletService) GetTransactionHistory(userID int, walletID int64, filter models.TransactionHistoryFilter) ([]*models.StatementEntry, int64, error) {
+	wallet, err := s.walletRepo.FindByID(walletID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if wallet == nil {
+		return nil, 0, ErrWalletNotFound
+	}
+	if wallet.UserID != userID {
+		return nil, 0, ErrWalletAccessDenied
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultStatementLimit
+	}
+	if filter.Limit > maxStatementLimit {
+		filter.Limit = maxStatementLimit
+	}
+
+	// Fetch one extra entry to know whether another page follows
+	limit := filter.Limit
+	filter.Limit++
+	entries, err := s.txRepo.FindWalletStatement(walletID, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	var nextBeforeID int64
+	if len(entries) > limit {
+		entries = entries[:limit]
+		nextBeforeID = entries[limit-1].EntryID
+	}
+
+	for _, entry := range entries {
+		if entry.IsAnonymous && entry.Direction == models.DirectionReceived {
+			entry.CounterpartyWalletID = nil
+			entry.CounterpartyUserID = nil
+		}
+	}
+	return entries, nextBeforeID, nil
+}
@@ -0,0 +1,95 @@
+package services_test
+
+import (
+	"testing"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionHistory(t *testing.T) {
+	wallets := map[int64]*models.Wallet{
+		1: {ID: 1, UserID: 1, Currency: "USD", Balance: 42},
+		2: {ID: 2, UserID: 2, Currency: "USD"},
+	}
+	entry := func(id int64, direction string, amount, balance, counterparty int64, isAnonymous bool) *models.StatementEntry {
+		counterpartyUserID := int(counterparty)
+		return &models.StatementEntry{
+			EntryID: id, TransactionID: id, Direction: direction, Amount: amount, RunningBalance: balance,
+			CounterpartyWalletID: &counterparty, CounterpartyUserID: &counterpartyUserID, IsAnonymous: isAnonymous,
+		}
+	}
+	txRepo := &mockTransactionRepo{wallets: wallets, statement: []*models.StatementEntry{
+		entry(1, models.DirectionReceived, 50, 50, 2, false),
+		entry(2, models.DirectionSent, 20, 30, 2, false),
+		entry(3, models.DirectionReceived, 10, 40, 2, true),
+		entry(4, models.DirectionSent, 5, 35, 2, true),
+		entry(5, models.DirectionReceived, 7, 42, 3, false),
+	}}
+	service := services.NewWalletService(&mockWalletRepo{wallets: wallets}, txRepo)
+
+	entryIDs := func(entries []*models.StatementEntry) []int64 {
+		var ids []int64
+		for _, entry := range entries {
+			ids = append(ids, entry.EntryID)
+		}
+		return ids
+	}
+	counterparty := int64(2)
+
+	tests := []struct {
+		name   string
+		filter models.TransactionHistoryFilter
+		want   []int64
+	}{
+		{"everything, newest first", models.TransactionHistoryFilter{}, []int64{5, 4, 3, 2, 1}},
+		{"sent only", models.TransactionHistoryFilter{Direction: models.DirectionSent}, []int64{4, 2}},
+		{"received only", models.TransactionHistoryFilter{Direction: models.DirectionReceived}, []int64{5, 3, 1}},
+		// The anonymous sender of entry 3 is not found by filtering on them
+		{"one counterparty", models.TransactionHistoryFilter{CounterpartyWalletID: &counterparty}, []int64{4, 2, 1}},
+		{"received from one counterparty", models.TransactionHistoryFilter{Direction: models.DirectionReceived, CounterpartyWalletID: &counterparty}, []int64{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, nextBeforeID, err := service.GetTransactionHistory(1, 1, tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, entryIDs(entries))
+			assert.Zero(t, nextBeforeID)
+		})
+	}
+
+	t.Run("Anonymous senders are redacted, anonymous receivers are not", func(t *testing.T) {
+		entries, _, err := service.GetTransactionHistory(1, 1, models.TransactionHistoryFilter{})
+		require.NoError(t, err)
+		byID := make(map[int64]*models.StatementEntry)
+		for _, entry := range entries {
+			byID[entry.EntryID] = entry
+		}
+		assert.Nil(t, byID[3].CounterpartyWalletID)
+		assert.Nil(t, byID[3].CounterpartyUserID)
+		assert.Equal(t, int64(40), byID[3].RunningBalance)
+		require.NotNil(t, byID[4].CounterpartyWalletID)
+		assert.Equal(t, int64(2), *byID[4].CounterpartyWalletID)
+	})
+
+	t.Run("Pages continue from the cursor", func(t *testing.T) {
+		entries, nextBeforeID, err := service.GetTransactionHistory(1, 1, models.TransactionHistoryFilter{Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{5, 4}, entryIDs(entries))
+		assert.Equal(t, int64(4), nextBeforeID)
+
+		entries, nextBeforeID, err = service.GetTransactionHistory(1, 1, models.TransactionHistoryFilter{Limit: 2, BeforeEntryID: nextBeforeID})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{3, 2}, entryIDs(entries))
+		assert.Equal(t, int64(2), nextBeforeID)
+	})
+
+	t.Run("Only the owner reads a statement", func(t *testing.T) {
+		_, _, err := service.GetTransactionHistory(2, 1, models.TransactionHistoryFilter{})
+		assert.ErrorIs(t, err, services.ErrWalletAccessDenied)
+		_, _, err = service.GetTransactionHistory(1, 9, models.TransactionHistoryFilter{})
+		assert.ErrorIs(t, err, services.ErrWalletNotFound)
+	})
+}
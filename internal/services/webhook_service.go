@@ -0,0 +1,414 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"verve/internal/models"
+	"verve/internal/repository"
+)
+
+const (
+	defaultWebhookDeliveryLimit = 50
+	maxWebhookDeliveryLimit     = 200
+
+	// webhookBatchSize is how many due deliveries a run claims at a time
+	webhookBatchSize = 20
+
+	// webhookLease is how long claimed deliveries are kept from other runs. It must outlast
+	// a batch of requests that all time out.
+	webhookLease = 10 * time.Minute
+
+	// webhookErrorBodyLimit is how much of an error response is kept in the delivery log
+	webhookErrorBodyLimit = 512
+)
+
+// Headers of a webhook request
+const (
+	WebhookEventHeader     = "X-Verve-Event"
+	WebhookDeliveryHeader  = "X-Verve-Delivery"
+	WebhookTimestampHeader = "X-Verve-Timestamp"
+	WebhookSignatureHeader = "X-Verve-Signature"
+)
+
+var (
+	ErrInvalidWebhookURL  = errors.New("webhook URL must be an absolute http or https URL")
+	ErrNoWebhookEvents    = errors.New("at least one event type is required")
+	ErrUnknownEventType   = errors.New("unknown event type")
+	ErrWebhookEndpointOff = errors.New("webhook endpoint is disabled")
+)
+
+// WebhookService manages the webhook endpoints admins register and delivers the domain
+// events they subscribed to. Events are queued as deliveries when the event dispatcher hands
+// them over, and posted separately, so a slow endpoint does not hold up other subscribers.
+type WebhookService struct {
+	webhookRepo  repository.WebhookRepository
+	auditService *AuditService
+	client       *http.Client
+	maxAttempts  int
+	retryDelay   time.Duration
+}
+
+// NewWebhookService creates a new WebhookService. A failed delivery is retried after
+// retryDelay, then twice as long each time, until maxAttempts attempts failed.
+func NewWebhookService(
+	webhookRepo repository.WebhookRepository,
+	auditService *AuditService,
+	client *http.Client,
+	maxAttempts int,
+	retryDelay time.Duration,
+) *WebhookService {
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	return &WebhookService{
+		webhookRepo:  webhookRepo,
+		auditService: auditService,
+		client:       client,
+		maxAttempts:  maxAttempts,
+		retryDelay:   retryDelay,
+	}
+}
+
+// SignWebhookPayload returns the signature sent in the X-Verve-Signature header: the
+// hex-encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with the endpoint secret. Receivers
+// recompute it to check that a request comes from Verve and was not changed, and reject old
+// timestamps to stop replays by third parties.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ListEndpoints returns every webhook endpoint
+func (s *WebhookService) ListEndpoints() ([]*models.WebhookEndpoint, error) {
+	return s.webhookRepo.FindEndpoints()
+}
+
+// GetEndpoint returns a webhook endpoint
+func (s *WebhookService) GetEndpoint(id int) (*models.WebhookEndpoint, error) {
+	return s.webhookRepo.FindEndpointByID(id)
+}
+
+// CreateEndpoint registers an endpoint for the given event types. The endpoint is returned
+// with the secret its deliveries are signed with, which is not shown again.
+func (s *WebhookService) CreateEndpoint(actor models.Actor, rawURL, description string, eventTypes []string) (*models.WebhookEndpoint, error) {
+	eventTypes, err := validateWebhook(rawURL, eventTypes)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		URL:         rawURL,
+		Description: description,
+		EventTypes:  eventTypes,
+		Secret:      secret,
+		IsActive:    true,
+		CreatedBy:   actor.UserID,
+	}
+	if err := s.webhookRepo.CreateEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditActionWebhookCreate, "webhook", strconv.Itoa(endpoint.ID), nil, endpoint)
+	return endpoint, nil
+}
+
+// UpdateEndpoint changes the fields of an endpoint that are given. Deliveries already queued
+// keep their body.
+func (s *WebhookService) UpdateEndpoint(
+	actor models.Actor,
+	id int,
+	rawURL, description *string,
+	eventTypes []string,
+	isActive *bool,
+) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.webhookRepo.FindEndpointByID(id)
+	if err != nil {
+		return nil, err
+	}
+	before := *endpoint
+
+	if rawURL != nil {
+		endpoint.URL = *rawURL
+	}
+	if description != nil {
+		endpoint.Description = *description
+	}
+	if eventTypes != nil {
+		endpoint.EventTypes = eventTypes
+	}
+	if isActive != nil {
+		endpoint.IsActive = *isActive
+	}
+	if endpoint.EventTypes, err = validateWebhook(endpoint.URL, endpoint.EventTypes); err != nil {
+		return nil, err
+	}
+
+	if err := s.webhookRepo.UpdateEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditActionWebhookUpdate, "webhook", strconv.Itoa(id), before, endpoint)
+	return endpoint, nil
+}
+
+// DeleteEndpoint deletes an endpoint and its delivery log
+func (s *WebhookService) DeleteEndpoint(actor models.Actor, id int) error {
+	endpoint, err := s.webhookRepo.FindEndpointByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.webhookRepo.DeleteEndpoint(id); err != nil {
+		return err
+	}
+
+	s.auditService.Record(actor, models.AuditActionWebhookDelete, "webhook", strconv.Itoa(id), endpoint, nil)
+	return nil
+}
+
+// validateWebhook checks the URL and event types of an endpoint, and returns the event
+// types without duplicates
+func validateWebhook(rawURL string, eventTypes []string) ([]string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+	if len(eventTypes) == 0 {
+		return nil, ErrNoWebhookEvents
+	}
+
+	seen := make(map[string]bool)
+	var unique []string
+	for _, eventType := range eventTypes {
+		if !models.IsEventType(eventType) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownEventType, eventType)
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			unique = append(unique, eventType)
+		}
+	}
+	return unique, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// HandleEvent queues a delivery of an event to each active endpoint subscribed to its type.
+// It is registered with the event dispatcher, which hands over every event at least once;
+// an endpoint gets a single delivery of an event however often it is handed over.
+func (s *WebhookService) HandleEvent(event *models.OutboxEvent) error {
+	endpoints, err := s.webhookRepo.FindSubscribedEndpoints(event.EventType)
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+
+	data, err := webhookData(event)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(models.WebhookPayload{
+		ID:        event.ID,
+		Type:      event.EventType,
+		CreatedAt: event.CreatedAt,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpointIDs := make([]int, len(endpoints))
+	for i, endpoint := range endpoints {
+		endpointIDs[i] = endpoint.ID
+	}
+	return s.webhookRepo.EnqueueDeliveries(event.ID, event.EventType, body, endpointIDs)
+}
+
+// webhookData returns the payload of an event as sent to endpoints. The sender of an
+// anonymous transfer is left out.
+func webhookData(event *models.OutboxEvent) (json.RawMessage, error) {
+	if event.EventType != models.EventTypeTransferCompleted {
+		return event.Payload, nil
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(event.Payload, &data); err != nil {
+		return nil, err
+	}
+	if anonymous, _ := data["is_anonymous"].(bool); !anonymous {
+		return event.Payload, nil
+	}
+	delete(data, "sender_wallet_id")
+	return json.Marshal(data)
+}
+
+// DeliverDue posts the deliveries that are due and returns how many it claimed
+func (s *WebhookService) DeliverDue() (int, error) {
+	now := time.Now()
+	deliveries, err := s.webhookRepo.ClaimDeliveries(now, webhookLease, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	endpoints := make(map[int]*models.WebhookEndpoint)
+	for _, delivery := range deliveries {
+		endpoint, ok := endpoints[delivery.EndpointID]
+		if !ok {
+			endpoint, err = s.webhookRepo.FindEndpointByID(delivery.EndpointID)
+			if err == sql.ErrNoRows {
+				// Deleted along with its deliveries since they were claimed
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			endpoints[delivery.EndpointID] = endpoint
+		}
+		if err := s.deliver(endpoint, delivery); err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// deliver makes an attempt to post a delivery and records its outcome
+func (s *WebhookService) deliver(endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) error {
+	attempts := delivery.Attempts + 1
+	if !endpoint.IsActive {
+		return s.webhookRepo.MarkDeliveryFailed(delivery.ID, attempts, 0, ErrWebhookEndpointOff.Error())
+	}
+
+	statusCode, err := s.post(endpoint, delivery)
+	if err == nil {
+		return s.webhookRepo.MarkDeliverySucceeded(delivery.ID, attempts, statusCode)
+	}
+
+	if attempts >= s.maxAttempts {
+		log.Printf("Webhook delivery %d to endpoint %d failed after %d attempts: %v", delivery.ID, endpoint.ID, attempts, err)
+		return s.webhookRepo.MarkDeliveryFailed(delivery.ID, attempts, statusCode, err.Error())
+	}
+	nextAttemptAt := time.Now().Add(s.retryDelay << (attempts - 1))
+	return s.webhookRepo.RescheduleDelivery(delivery.ID, attempts, statusCode, err.Error(), nextAttemptAt)
+}
+
+// post sends a signed delivery to its endpoint. Any 2xx response is a success.
+func (s *WebhookService) post(endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Verve-Webhooks")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(endpoint.Secret, timestamp, delivery.Body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyLimit))
+	return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(excerpt)))
+}
+
+// ListDeliveries returns a page of the delivery log matching filter, newest first, and the
+// ID to pass as filter.BeforeID for the next page, 0 when there is none
+func (s *WebhookService) ListDeliveries(filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, int64, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultWebhookDeliveryLimit
+	}
+	if filter.Limit > maxWebhookDeliveryLimit {
+		filter.Limit = maxWebhookDeliveryLimit
+	}
+
+	// Fetch one extra delivery to know whether another page follows
+	limit := filter.Limit
+	filter.Limit++
+	deliveries, err := s.webhookRepo.FindDeliveries(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	var nextBeforeID int64
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		nextBeforeID = deliveries[limit-1].ID
+	}
+	return deliveries, nextBeforeID, nil
+}
+
+// ReplayDelivery queues a new delivery of the body of a logged delivery of an endpoint,
+// signed afresh when it is sent. The original delivery stays in the log unchanged.
+func (s *WebhookService) ReplayDelivery(actor models.Actor, endpointID int, deliveryID int64) (*models.WebhookDelivery, error) {
+	delivery, err := s.webhookRepo.FindDeliveryByID(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.EndpointID != endpointID {
+		return nil, sql.ErrNoRows
+	}
+
+	replay, err := s.webhookRepo.ReplayDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditActionWebhookReplay, "webhook", strconv.Itoa(endpointID), nil, replay)
+	return replay, nil
+}
+
+// RunDeliveries posts due deliveries every interval until ctx is cancelled. Batches are
+// posted back to back while deliveries are due.
+func (s *WebhookService) RunDeliveries(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				claimed, err := s.DeliverDue()
+				if err != nil {
+					log.Printf("Failed to deliver webhooks: %v", err)
+				}
+				if err != nil || claimed < webhookBatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package services_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"verve/internal/models"
+	"verve/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver stands in for an endpoint, checking the signature of every request
+type webhookReceiver struct {
+	mu       sync.Mutex
+	secret   string
+	failures int // Requests to fail before succeeding
+	received []models.WebhookPayload
+	headers  []http.Header
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	timestamp, err := strconv.ParseInt(req.Header.Get(services.WebhookTimestampHeader), 10, 64)
+	if err != nil || req.Header.Get(services.WebhookSignatureHeader) != services.SignWebhookPayload(r.secret, timestamp, body) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	if r.failures > 0 {
+		r.failures--
+		http.Error(w, "try later", http.StatusServiceUnavailable)
+		return
+	}
+
+	var payload models.WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.received = append(r.received, payload)
+	r.headers = append(r.headers, req.Header.Clone())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestWebhooksEndToEnd(t *testing.T) {
+	admin := models.Actor{UserID: 2}
+
+	setup := func(maxAttempts int) (*mockOutboxRepo, *services.EventDispatcher, *services.WebhookService, *mockWebhookRepo) {
+		outbox := &mockOutboxRepo{events: make(map[int64]*models.OutboxEvent)}
+		webhookRepo := &mockWebhookRepo{}
+		webhookService := services.NewWebhookService(webhookRepo, services.NewAuditService(&mockAuditRepo{}), http.DefaultClient, maxAttempts, time.Minute)
+		dispatcher := services.NewEventDispatcher(outbox, 3, time.Second)
+		dispatcher.Subscribe("webhooks", webhookService.HandleEvent)
+		return outbox, dispatcher, webhookService, webhookRepo
+	}
+
+	publish := func(outbox *mockOutboxRepo, eventType string, payload interface{}) {
+		outbox.add(eventType)
+		outbox.events[outbox.nextID].Payload, _ = json.Marshal(payload)
+	}
+
+	t.Run("Subscribed endpoints receive signed events", func(t *testing.T) {
+		outbox, dispatcher, webhookService, _ := setup(3)
+
+		transfers := &webhookReceiver{}
+		transferServer := httptest.NewServer(transfers)
+		defer transferServer.Close()
+		badges := &webhookReceiver{}
+		badgeServer := httptest.NewServer(badges)
+		defer badgeServer.Close()
+
+		endpoint, err := webhookService.CreateEndpoint(admin, transferServer.URL, "HR portal", []string{models.EventTypeTransferCompleted})
+		require.NoError(t, err)
+		transfers.secret = endpoint.Secret
+		endpoint, err = webhookService.CreateEndpoint(admin, badgeServer.URL, "Chat bot", []string{models.EventTypeBadgeAwarded})
+		require.NoError(t, err)
+		badges.secret = endpoint.Secret
+
+		publish(outbox, models.EventTypeTransferCompleted, models.TransferCompletedEvent{TransferID: 1, SenderWalletID: 10, ReceiverWalletID: 20, Amount: 50, IsAnonymous: true})
+		publish(outbox, models.EventTypeUserCreated, models.UserCreatedEvent{UserID: 3, Username: "carol"})
+		_, err = dispatcher.DispatchDue()
+		require.NoError(t, err)
+
+		// Handing the event over again does not deliver it twice
+		require.NoError(t, webhookService.HandleEvent(outbox.events[1]))
+
+		delivered, err := webhookService.DeliverDue()
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+
+		require.Len(t, transfers.received, 1)
+		assert.Empty(t, badges.received)
+		payload := transfers.received[0]
+		assert.Equal(t, int64(1), payload.ID)
+		assert.Equal(t, models.EventTypeTransferCompleted, payload.Type)
+		assert.Equal(t, models.EventTypeTransferCompleted, transfers.headers[0].Get(services.WebhookEventHeader))
+
+		var data map[string]interface{}
+		require.NoError(t, json.Unmarshal(payload.Data, &data))
+		assert.Equal(t, float64(20), data["receiver_wallet_id"])
+		assert.NotContains(t, data, "sender_wallet_id", "anonymous senders are not revealed")
+	})
+
+	t.Run("Failed deliveries back off, fail for good and can be replayed", func(t *testing.T) {
+		outbox, dispatcher, webhookService, webhookRepo := setup(3)
+
+		receiver := &webhookReceiver{failures: 3}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		endpoint, err := webhookService.CreateEndpoint(admin, server.URL, "", []string{models.EventTypeBadgeAwarded})
+		require.NoError(t, err)
+		receiver.secret = endpoint.Secret
+
+		publish(outbox, models.EventTypeBadgeAwarded, models.BadgeAwardedEvent{UserBadgeID: 5, UserID: 1, BadgeID: 7})
+		_, err = dispatcher.DispatchDue()
+		require.NoError(t, err)
+
+		start := time.Now()
+		_, err = webhookService.DeliverDue()
+		require.NoError(t, err)
+		delivery := webhookRepo.deliveries[0]
+		assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Equal(t, http.StatusServiceUnavailable, delivery.LastStatusCode)
+		assert.Contains(t, delivery.LastError, "try later")
+		assert.WithinDuration(t, start.Add(time.Minute), delivery.NextAttemptAt, time.Second)
+
+		webhookRepo.makeDue()
+		_, err = webhookService.DeliverDue()
+		require.NoError(t, err)
+		assert.WithinDuration(t, start.Add(2*time.Minute), delivery.NextAttemptAt, time.Second)
+
+		webhookRepo.makeDue()
+		_, err = webhookService.DeliverDue()
+		require.NoError(t, err)
+		assert.Equal(t, models.WebhookDeliveryFailed, delivery.Status)
+		assert.Equal(t, 3, delivery.Attempts)
+		assert.Empty(t, receiver.received)
+
+		replay, err := webhookService.ReplayDelivery(admin, endpoint.ID, delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, delivery.ID, *replay.ReplayOfID)
+
+		_, err = webhookService.DeliverDue()
+		require.NoError(t, err)
+		require.Len(t, receiver.received, 1)
+		assert.Equal(t, models.WebhookDeliverySucceeded, replay.Status)
+		assert.Equal(t, models.WebhookDeliveryFailed, delivery.Status, "the original delivery stays in the log")
+
+		deliveries, nextBeforeID, err := webhookService.ListDeliveries(models.WebhookDeliveryFilter{EndpointID: endpoint.ID})
+		require.NoError(t, err)
+		assert.Zero(t, nextBeforeID)
+		require.Len(t, deliveries, 2)
+		assert.Equal(t, replay.ID, deliveries[0].ID)
+
+		_, err = webhookService.ReplayDelivery(admin, endpoint.ID+1, delivery.ID)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("Endpoints need a URL and known event types", func(t *testing.T) {
+		_, _, webhookService, _ := setup(3)
+
+		_, err := webhookService.CreateEndpoint(admin, "ftp://example.com", "", []string{models.EventTypeUserCreated})
+		assert.ErrorIs(t, err, services.ErrInvalidWebhookURL)
+		_, err = webhookService.CreateEndpoint(admin, "https://example.com/hook", "", nil)
+		assert.ErrorIs(t, err, services.ErrNoWebhookEvents)
+		_, err = webhookService.CreateEndpoint(admin, "https://example.com/hook", "", []string{"user.deleted"})
+		assert.ErrorIs(t, err, services.ErrUnknownEventType)
+
+		endpoint, err := webhookService.CreateEndpoint(admin, "https://example.com/hook", "", []string{models.EventTypeUserCreated, models.EventTypeUserCreated})
+		require.NoError(t, err)
+		assert.Equal(t, []string{models.EventTypeUserCreated}, endpoint.EventTypes)
+		assert.NotEmpty(t, endpoint.Secret)
+	})
+}
+
+// mockWebhookRepo keeps endpoints and deliveries in memory, oldest first
+type mockWebhookRepo struct {
+	endpoints  []*models.WebhookEndpoint
+	deliveries []*models.WebhookDelivery
+}
+
+// makeDue ends the leases and backoffs of the pending deliveries
+func (m *mockWebhookRepo) makeDue() {
+	for _, delivery := range m.deliveries {
+		delivery.NextAttemptAt = time.Now().Add(-time.Second)
+	}
+}
+
+func (m *mockWebhookRepo) CreateEndpoint(endpoint *models.WebhookEndpoint) error {
+	endpoint.ID = len(m.endpoints) + 1
+	endpoint.CreatedAt = time.Now()
+	endpoint.UpdatedAt = endpoint.CreatedAt
+	m.endpoints = append(m.endpoints, endpoint)
+	return nil
+}
+
+func (m *mockWebhookRepo) FindEndpointByID(id int) (*models.WebhookEndpoint, error) {
+	for _, endpoint := range m.endpoints {
+		if endpoint.ID == id {
+			return endpoint, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *mockWebhookRepo) FindEndpoints() ([]*models.WebhookEndpoint, error) {
+	return m.endpoints, nil
+}
+
+func (m *mockWebhookRepo) FindSubscribedEndpoints(eventType string) ([]*models.WebhookEndpoint, error) {
+	var endpoints []*models.WebhookEndpoint
+	for _, endpoint := range m.endpoints {
+		for _, subscribed := range endpoint.EventTypes {
+			if endpoint.IsActive && subscribed == eventType {
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+func (m *mockWebhookRepo) UpdateEndpoint(endpoint *models.WebhookEndpoint) error {
+	endpoint.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *mockWebhookRepo) DeleteEndpoint(id int) error {
+	for i, endpoint := range m.endpoints {
+		if endpoint.ID == id {
+			m.endpoints = append(m.endpoints[:i], m.endpoints[i+1:]...)
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *mockWebhookRepo) EnqueueDeliveries(eventID int64, eventType string, body []byte, endpointIDs []int) error {
+	for _, endpointID := range endpointIDs {
+		queued := false
+		for _, delivery := range m.deliveries {
+			queued = queued || (delivery.EndpointID == endpointID && delivery.EventID == eventID && delivery.ReplayOfID == nil)
+		}
+		if !queued {
+			m.add(&models.WebhookDelivery{EndpointID: endpointID, EventID: eventID, EventType: eventType, Body: body})
+		}
+	}
+	return nil
+}
+
+func (m *mockWebhookRepo) add(delivery *models.WebhookDelivery) {
+	delivery.ID = int64(len(m.deliveries) + 1)
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.NextAttemptAt = time.Now()
+	delivery.CreatedAt = time.Now()
+	m.deliveries = append(m.deliveries, delivery)
+}
+
+func (m *mockWebhookRepo) ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	for _, delivery := range m.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) && len(deliveries) < limit {
+			delivery.NextAttemptAt = now.Add(lease)
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (m *mockWebhookRepo) MarkDeliverySucceeded(deliveryID int64, attempts, statusCode int) error {
+	now := time.Now()
+	delivery := m.deliveries[deliveryID-1]
+	delivery.Status = models.WebhookDeliverySucceeded
+	delivery.Attempts = attempts
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+	delivery.DeliveredAt = &now
+	return nil
+}
+
+func (m *mockWebhookRepo) RescheduleDelivery(deliveryID int64, attempts, statusCode int, lastError string, nextAttemptAt time.Time) error {
+	delivery := m.deliveries[deliveryID-1]
+	delivery.Attempts = attempts
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = lastError
+	delivery.NextAttemptAt = nextAttemptAt
+	return nil
+}
+
+func (m *mockWebhookRepo) MarkDeliveryFailed(deliveryID int64, attempts, statusCode int, lastError string) error {
+	delivery := m.deliveries[deliveryID-1]
+	delivery.Status = models.WebhookDeliveryFailed
+	delivery.Attempts = attempts
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = lastError
+	return nil
+}
+
+func (m *mockWebhookRepo) FindDeliveryByID(id int64) (*models.WebhookDelivery, error) {
+	if id < 1 || id > int64(len(m.deliveries)) {
+		return nil, sql.ErrNoRows
+	}
+	return m.deliveries[id-1], nil
+}
+
+func (m *mockWebhookRepo) FindDeliveries(filter models.WebhookDeliveryFilter) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	for _, delivery := range m.deliveries {
+		switch {
+		case filter.EndpointID > 0 && delivery.EndpointID != filter.EndpointID,
+			filter.Status != "" && delivery.Status != filter.Status,
+			filter.BeforeID > 0 && delivery.ID >= filter.BeforeID:
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+	if len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+	return deliveries, nil
+}
+
+func (m *mockWebhookRepo) ReplayDelivery(deliveryID int64) (*models.WebhookDelivery, error) {
+	original, err := m.FindDeliveryByID(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	replay := &models.WebhookDelivery{
+		EndpointID: original.EndpointID,
+		EventID:    original.EventID,
+		EventType:  original.EventType,
+		Body:       original.Body,
+		ReplayOfID: &original.ID,
+	}
+	m.add(replay)
+	return replay, nil
+}
@@ -0,0 +1,42 @@
+-- Migration: Create treasury grant cycles and per-user payouts
+
+CREATE TYPE grant_cycle_status AS ENUM ('running', 'completed', 'failed');
+CREATE TYPE grant_payout_status AS ENUM ('pending', 'completed', 'failed');
+
+CREATE TABLE treasury_grant_cycles (
+    id SERIAL PRIMARY KEY,
+    treasury_wallet_id INTEGER NOT NULL REFERENCES wallets(id),
+    amount_per_user BIGINT NOT NULL CHECK (amount_per_user > 0),
+    minted_amount BIGINT NOT NULL DEFAULT 0,
+    status grant_cycle_status NOT NULL DEFAULT 'running',
+    failure_reason TEXT,
+    started_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Only one cycle may be running at a time
+CREATE UNIQUE INDEX idx_treasury_grant_cycles_running ON treasury_grant_cycles(status) WHERE status = 'running';
+
+-- One payout row per user and cycle; the unique constraint guarantees a user
+-- is never paid twice for the same cycle
+CREATE TABLE treasury_grant_payouts (
+    id SERIAL PRIMARY KEY,
+    cycle_id INTEGER NOT NULL REFERENCES treasury_grant_cycles(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    wallet_id INTEGER NOT NULL REFERENCES wallets(id),
+    amount BIGINT NOT NULL,
+    status grant_payout_status NOT NULL DEFAULT 'pending',
+    transaction_id INTEGER REFERENCES transactions(id),
+    failure_reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(cycle_id, user_id)
+);
+
+CREATE INDEX idx_treasury_grant_payouts_cycle_status ON treasury_grant_payouts(cycle_id, status);
+
+CREATE TRIGGER update_treasury_grant_payouts_updated_at
+BEFORE UPDATE ON treasury_grant_payouts
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
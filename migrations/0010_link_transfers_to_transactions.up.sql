@@ -0,0 +1,7 @@
+-- Migration: Link executed transfers to their transaction and record failure reasons
+
+ALTER TABLE transactions
+    ADD COLUMN transfer_id INTEGER UNIQUE REFERENCES transfers(id);
+
+ALTER TABLE transfers
+    ADD COLUMN failure_reason TEXT;
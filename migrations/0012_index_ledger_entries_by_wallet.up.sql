@@ -0,0 +1,2 @@
+-- Wallet statements read the ledger of a single wallet in entry order
+CREATE INDEX idx_ledger_entries_wallet_id ON ledger_entries(wallet_id, id);
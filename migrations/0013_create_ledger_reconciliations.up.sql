@@ -0,0 +1,13 @@
+-- Migration: Store the reports of ledger reconciliation runs
+
+CREATE TABLE ledger_reconciliations (
+    id SERIAL PRIMARY KEY,
+    triggered_by INTEGER REFERENCES users(id), -- NULL for scheduled runs
+    wallets_checked INTEGER NOT NULL,
+    transactions_checked INTEGER NOT NULL,
+    entries_checked INTEGER NOT NULL,
+    discrepancy_count INTEGER NOT NULL,
+    discrepancies JSONB NOT NULL DEFAULT '[]',
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,53 @@
+-- Migration: Hash-chain ledger entries and store signed checkpoints of the chain
+
+-- Position of the entry in the chain and the hashes linking it to the previous entry.
+-- Entries written before this migration are chained by the server on startup.
+ALTER TABLE ledger_entries
+    ADD COLUMN chain_seq BIGINT UNIQUE,
+    ADD COLUMN prev_hash TEXT,
+    ADD COLUMN entry_hash TEXT;
+
+-- Single row holding the tip of the chain. Writers lock it to append in order.
+CREATE TABLE ledger_chain_head (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    last_seq BIGINT NOT NULL DEFAULT 0,
+    last_hash TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO ledger_chain_head DEFAULT VALUES;
+
+CREATE TABLE ledger_checkpoints (
+    id SERIAL PRIMARY KEY,
+    chain_seq BIGINT NOT NULL,
+    entry_hash TEXT NOT NULL,
+    signature TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_ledger_checkpoints_chain_seq ON ledger_checkpoints(chain_seq);
+
+-- The ledger is append-only. The only change allowed is chaining an entry that was not chained yet.
+CREATE OR REPLACE FUNCTION prevent_ledger_entry_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.entry_hash IS NULL
+        AND NEW.transaction_id IS NOT DISTINCT FROM OLD.transaction_id
+        AND NEW.wallet_id IS NOT DISTINCT FROM OLD.wallet_id
+        AND NEW.entry_type = OLD.entry_type
+        AND NEW.amount = OLD.amount
+        AND NEW.extra IS NOT DISTINCT FROM OLD.extra
+        AND NEW.created_at IS NOT DISTINCT FROM OLD.created_at THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'ledger_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER prevent_ledger_entry_changes
+BEFORE UPDATE OR DELETE ON ledger_entries
+FOR EACH ROW
+EXECUTE FUNCTION prevent_ledger_entry_changes();
+
+CREATE TRIGGER prevent_ledger_truncate
+BEFORE TRUNCATE ON ledger_entries
+FOR EACH STATEMENT
+EXECUTE FUNCTION prevent_ledger_entry_changes();
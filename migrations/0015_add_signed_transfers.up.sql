@@ -0,0 +1,10 @@
+-- Migration: Store the signature authorizing a pseudonymous transfer
+
+ALTER TABLE transfers
+    ADD COLUMN nonce TEXT,
+    ADD COLUMN signature TEXT,
+    ADD COLUMN public_key TEXT,
+    ADD COLUMN signature_expires_at TIMESTAMP WITH TIME ZONE;
+
+-- A signed message can only ever be used once
+CREATE UNIQUE INDEX idx_transfers_sender_nonce ON transfers(sender_wallet_id, nonce) WHERE nonce IS NOT NULL;
@@ -0,0 +1,23 @@
+-- Migration: Keep the history of the keys controlling each pseudonymous wallet
+
+CREATE TYPE wallet_key_status AS ENUM ('active', 'rotated', 'revoked');
+
+CREATE TABLE pseudonymous_wallet_keys (
+    id SERIAL PRIMARY KEY,
+    wallet_id INTEGER NOT NULL REFERENCES wallets(id),
+    public_key TEXT NOT NULL UNIQUE,
+    status wallet_key_status NOT NULL DEFAULT 'active',
+    rotation_signature TEXT, -- Signature of the previous key endorsing this one
+    revocation_reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    retired_at TIMESTAMP WITH TIME ZONE
+);
+
+-- A wallet has at most one key able to sign at any time
+CREATE UNIQUE INDEX idx_pseudonymous_wallet_keys_active ON pseudonymous_wallet_keys(wallet_id) WHERE status = 'active';
+
+-- wallets.public_key mirrors the active key and is NULL once it is revoked
+INSERT INTO pseudonymous_wallet_keys (wallet_id, public_key, status, created_at)
+SELECT id, public_key, 'active', created_at
+FROM wallets
+WHERE is_pseudonymous AND public_key IS NOT NULL;
@@ -0,0 +1,27 @@
+-- Migration: Store login sessions with rotating refresh tokens
+
+CREATE TABLE sessions (
+    id VARCHAR(32) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash VARCHAR(64) NOT NULL UNIQUE, -- SHA-256 of the current refresh token
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    revoked_reason VARCHAR(50)
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
+
+-- Refresh tokens a session rotated away from. Presenting one again means the token
+-- was stolen or replayed, and the whole session is revoked.
+CREATE TABLE session_refresh_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    session_id VARCHAR(32) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    rotated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_session_refresh_tokens_session_id ON session_refresh_tokens(session_id);
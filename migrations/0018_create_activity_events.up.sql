@@ -0,0 +1,33 @@
+-- Migration: Record user activity and roll it up per day to back streaks
+
+CREATE TABLE activity_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    activity_type VARCHAR(30) NOT NULL, -- e.g., 'login', 'transfer'
+    reference_id BIGINT, -- e.g., the transfer of a transfer activity
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_activity_events_user_occurred_at ON activity_events(user_id, occurred_at);
+
+-- Number of activities of each type per user and UTC day
+CREATE TABLE activity_daily_rollups (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    activity_type VARCHAR(30) NOT NULL,
+    day DATE NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, activity_type, day)
+);
+
+-- Days active before activities were recorded, so existing streaks are kept
+INSERT INTO activity_daily_rollups (user_id, activity_type, day, count)
+SELECT w.user_id, 'transfer', (t.updated_at AT TIME ZONE 'UTC')::date, COUNT(*)
+FROM transfers t
+JOIN wallets w ON w.id = t.sender_wallet_id
+WHERE t.status = 'completed' AND w.user_id IS NOT NULL
+GROUP BY w.user_id, (t.updated_at AT TIME ZONE 'UTC')::date;
+
+INSERT INTO activity_daily_rollups (user_id, activity_type, day, count)
+SELECT user_id, 'login', (created_at AT TIME ZONE 'UTC')::date, COUNT(*)
+FROM sessions
+GROUP BY user_id, (created_at AT TIME ZONE 'UTC')::date;
@@ -0,0 +1,47 @@
+-- Migration: Badge revocation, expiry and tiered badge levels
+
+-- Rules of a tiered badge carry the level they unlock. Rules without a level apply to every level.
+ALTER TABLE achievement_rules ADD COLUMN level VARCHAR(10)
+    CHECK (level IN ('bronze', 'silver', 'gold'));
+
+ALTER TABLE user_badges
+    ADD COLUMN level VARCHAR(10) CHECK (level IN ('bronze', 'silver', 'gold')),
+    ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN revoked_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN revoked_by INTEGER REFERENCES users(id), -- NULL when the award expired
+    ADD COLUMN revoke_reason TEXT;
+
+-- A user holds at most one live award of a badge, but may earn it again once it was
+-- revoked or expired. Expired awards are closed before a new one is inserted.
+ALTER TABLE user_badges DROP CONSTRAINT user_badges_user_id_badge_id_key;
+CREATE UNIQUE INDEX user_badges_active_award_key ON user_badges(user_id, badge_id) WHERE revoked_at IS NULL;
+CREATE INDEX idx_user_badges_expires_at ON user_badges(expires_at) WHERE revoked_at IS NULL AND expires_at IS NOT NULL;
+
+-- Every change to an award: awards, level upgrades, revocations and expiries
+CREATE TABLE user_badge_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_badge_id INTEGER NOT NULL REFERENCES user_badges(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    badge_id INTEGER NOT NULL REFERENCES badges(id),
+    event_type VARCHAR(20) NOT NULL, -- awarded, upgraded, revoked or expired
+    previous_level VARCHAR(10),
+    level VARCHAR(10),
+    actor_id INTEGER REFERENCES users(id), -- NULL for changes made by the system
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_badge_events_user_id ON user_badge_events(user_id, created_at);
+
+-- Existing awards start their history
+INSERT INTO user_badge_events (user_badge_id, user_id, badge_id, event_type, actor_id, created_at)
+SELECT id, user_id, badge_id, 'awarded', awarded_by, awarded_at
+FROM user_badges;
+
+INSERT INTO permissions (name) VALUES ('revoke_badge');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.name = 'revoke_badge';
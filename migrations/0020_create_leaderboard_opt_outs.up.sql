@@ -0,0 +1,9 @@
+-- Migration: Let users opt out of leaderboards
+
+CREATE TABLE leaderboard_opt_outs (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    opted_out_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Leaderboard windows select transfers by the time their coins moved
+CREATE INDEX idx_transactions_created_at ON transactions(created_at) WHERE transfer_id IS NOT NULL;
@@ -0,0 +1,9 @@
+-- Migration: Permission to register users, which was granted to the admin role by name only
+
+INSERT INTO permissions (name) VALUES ('create_user');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.name = 'create_user';
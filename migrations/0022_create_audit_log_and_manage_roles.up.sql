@@ -0,0 +1,24 @@
+-- Migration: Audit log, and the permission to manage roles and permissions
+
+-- Append-only record of changes made through the API
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INTEGER REFERENCES users(id), -- NULL for changes made by the system
+    action VARCHAR(100) NOT NULL, -- e.g. 'role.create', 'role.permission.grant'
+    target_type VARCHAR(50) NOT NULL,
+    target_id VARCHAR(100) NOT NULL,
+    before_state JSONB,
+    after_state JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX idx_audit_log_target ON audit_log(target_type, target_id);
+
+INSERT INTO permissions (name) VALUES ('manage_roles');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.name = 'manage_roles';
@@ -0,0 +1,37 @@
+-- Migration: Request details and diffs in the audit log, which becomes append-only
+
+ALTER TABLE audit_log
+    ADD COLUMN diff JSONB,
+    ADD COLUMN ip_address VARCHAR(45),
+    ADD COLUMN user_agent TEXT,
+    ADD COLUMN request_id VARCHAR(100);
+
+CREATE INDEX idx_audit_log_actor ON audit_log(actor_id, id);
+CREATE INDEX idx_audit_log_action ON audit_log(action, id);
+CREATE INDEX idx_audit_log_request_id ON audit_log(request_id) WHERE request_id IS NOT NULL;
+
+-- The audit log is append-only
+CREATE OR REPLACE FUNCTION prevent_audit_log_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER prevent_audit_log_changes
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW
+EXECUTE FUNCTION prevent_audit_log_changes();
+
+CREATE TRIGGER prevent_audit_log_truncate
+BEFORE TRUNCATE ON audit_log
+FOR EACH STATEMENT
+EXECUTE FUNCTION prevent_audit_log_changes();
+
+INSERT INTO permissions (name) VALUES ('view_audit_log');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.name = 'view_audit_log';
@@ -0,0 +1,29 @@
+-- Migration: Transactional outbox of domain events
+
+-- Events are written in the same transaction as the change they describe, and delivered
+-- to subscribers by the dispatcher
+CREATE TABLE event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(100) NOT NULL, -- e.g. 'transfer.completed'
+    aggregate_type VARCHAR(50) NOT NULL,
+    aggregate_id VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processed', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_to TEXT[] NOT NULL DEFAULT '{}', -- Subscribers that handled the event
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_event_outbox_due ON event_outbox(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_event_outbox_dead ON event_outbox(id) WHERE status = 'dead';
+
+INSERT INTO permissions (name) VALUES ('manage_events');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.name = 'manage_events';
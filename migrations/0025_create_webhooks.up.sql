@@ -0,0 +1,43 @@
+-- Migration: Outbound webhooks
+
+CREATE TABLE webhook_endpoints (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    description TEXT,
+    event_types TEXT[] NOT NULL, -- Domain event types delivered to the endpoint
+    secret VARCHAR(128) NOT NULL, -- Key of the HMAC-SHA256 signature of every delivery
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every delivery of an event to an endpoint, with the outcome of its last attempt
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    endpoint_id INTEGER NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL REFERENCES event_outbox(id),
+    event_type VARCHAR(100) NOT NULL,
+    body JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_status_code INTEGER,
+    last_error TEXT,
+    replay_of_id BIGINT REFERENCES webhook_deliveries(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+-- An event is delivered to an endpoint once, however often the dispatcher hands it over
+CREATE UNIQUE INDEX idx_webhook_deliveries_event ON webhook_deliveries(endpoint_id, event_id) WHERE replay_of_id IS NULL;
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, id DESC);
+
+INSERT INTO permissions (name) VALUES ('manage_webhooks');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.name = 'manage_webhooks';
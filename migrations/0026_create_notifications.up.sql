@@ -0,0 +1,17 @@
+-- Migration: User notifications
+
+CREATE TABLE notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL, -- e.g. 'transfer.received'
+    message TEXT NOT NULL,
+    data JSONB,
+    event_id BIGINT REFERENCES event_outbox(id), -- Domain event the notification was made for
+    read_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    -- Events are delivered at least once, but notify a user once
+    UNIQUE (user_id, event_id)
+);
+
+CREATE INDEX idx_notifications_user ON notifications(user_id, id DESC);
+CREATE INDEX idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;
@@ -0,0 +1,29 @@
+-- Migration: Transfer messages, categories and visibility, and the kudos feed
+
+ALTER TABLE transfers
+    ADD COLUMN message TEXT,
+    ADD COLUMN category VARCHAR(50), -- e.g. 'teamwork'
+    ADD COLUMN visibility VARCHAR(20) NOT NULL DEFAULT 'recipient_only'
+        CHECK (visibility IN ('public', 'recipient_only', 'private'));
+
+-- The kudos feed lists public transfers that completed, newest first
+CREATE INDEX idx_transfers_public ON transfers(id DESC) WHERE visibility = 'public' AND status = 'completed';
+CREATE INDEX idx_transfers_public_category ON transfers(category, id DESC) WHERE visibility = 'public' AND status = 'completed';
+
+CREATE TABLE kudos_reactions (
+    transfer_id INTEGER NOT NULL REFERENCES transfers(id),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reaction VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (transfer_id, user_id, reaction)
+);
+
+CREATE TABLE kudos_comments (
+    id BIGSERIAL PRIMARY KEY,
+    transfer_id INTEGER NOT NULL REFERENCES transfers(id),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_kudos_comments_transfer ON kudos_comments(transfer_id, id);
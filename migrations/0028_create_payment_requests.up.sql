@@ -0,0 +1,29 @@
+-- Migration: Payment requests
+
+CREATE TABLE payment_requests (
+    id BIGSERIAL PRIMARY KEY,
+    requester_user_id INTEGER NOT NULL REFERENCES users(id),
+    requester_wallet_id INTEGER NOT NULL REFERENCES wallets(id),
+    payer_user_id INTEGER NOT NULL REFERENCES users(id),
+    payer_wallet_id INTEGER NOT NULL REFERENCES wallets(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    message TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'paid', 'declined', 'cancelled', 'expired')),
+    transfer_id INTEGER REFERENCES transfers(id),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (status <> 'paid' OR transfer_id IS NOT NULL)
+);
+
+CREATE INDEX idx_payment_requests_payer ON payment_requests(payer_user_id, id DESC);
+CREATE INDEX idx_payment_requests_requester ON payment_requests(requester_user_id, id DESC);
+CREATE INDEX idx_payment_requests_pending ON payment_requests(expires_at) WHERE status = 'pending';
+-- A transfer pays at most one request
+CREATE UNIQUE INDEX idx_payment_requests_transfer ON payment_requests(transfer_id) WHERE transfer_id IS NOT NULL;
+
+CREATE TRIGGER update_payment_requests_updated_at
+BEFORE UPDATE ON payment_requests
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,50 @@
+-- Migration: Scheduled and recurring transfers
+
+CREATE TABLE scheduled_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    sender_wallet_id INTEGER NOT NULL REFERENCES wallets(id),
+    receiver_wallet_id INTEGER NOT NULL REFERENCES wallets(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    is_anonymous BOOLEAN NOT NULL DEFAULT FALSE,
+    message TEXT,
+    category VARCHAR(50),
+    visibility VARCHAR(20) NOT NULL DEFAULT 'recipient_only'
+        CHECK (visibility IN ('public', 'recipient_only', 'private')),
+    cron VARCHAR(100), -- NULL for a one-off transfer
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    catch_up VARCHAR(20) NOT NULL DEFAULT 'latest' CHECK (catch_up IN ('latest', 'skip')),
+    end_at TIMESTAMP WITH TIME ZONE,
+    max_runs INTEGER CHECK (max_runs > 0),
+    run_count INTEGER NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    status VARCHAR(20) NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'paused', 'completed', 'cancelled')),
+    locked_until TIMESTAMP WITH TIME ZONE, -- Set while a scheduler runs the schedule
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_scheduled_transfers_user ON scheduled_transfers(user_id, id DESC);
+CREATE INDEX idx_scheduled_transfers_due ON scheduled_transfers(next_run_at) WHERE status = 'active';
+
+CREATE TRIGGER update_scheduled_transfers_updated_at
+BEFORE UPDATE ON scheduled_transfers
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE scheduled_transfer_executions (
+    id BIGSERIAL PRIMARY KEY,
+    schedule_id BIGINT NOT NULL REFERENCES scheduled_transfers(id) ON DELETE CASCADE,
+    scheduled_for TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('running', 'succeeded', 'failed', 'skipped')),
+    skipped_runs INTEGER NOT NULL DEFAULT 0,
+    transfer_id INTEGER REFERENCES transfers(id),
+    error TEXT,
+    started_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    -- A run is made at most once, even when a scheduler crashed in the middle of it
+    UNIQUE (schedule_id, scheduled_for)
+);
+
+CREATE INDEX idx_scheduled_transfer_executions_schedule ON scheduled_transfer_executions(schedule_id, id DESC);
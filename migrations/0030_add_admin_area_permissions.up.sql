@@ -0,0 +1,10 @@
+-- Migration: Permissions for the treasury, ledger and pseudonymous wallet admin routes,
+-- which were open to the admin role by name only
+
+INSERT INTO permissions (name) VALUES ('manage_treasury'), ('manage_ledger'), ('manage_pseudonymous_wallets');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.name IN ('manage_treasury', 'manage_ledger', 'manage_pseudonymous_wallets');
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression of five fields: minute, hour, day of month, month
+// and day of week. Each field is *, a value, a range a-b, a step */n or a-b/n, or a comma
+// separated list of those. Days of the week run from 0 (Sunday) to 6, 7 is Sunday as well.
+type CronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64 // Bit i is set when value i matches
+	anyDayOfMonth, anyDayOfWeek                bool
+}
+
+const (
+	cronDaysOfMonth = uint64(1<<32 - 2) // Bits 1 to 31
+	cronDaysOfWeek  = uint64(1<<7 - 1)  // Bits 0 to 6
+)
+
+var cronMacros = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+// ParseCron parses a cron expression, or one of @yearly, @monthly, @weekly, @daily and @hourly
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+
+	schedule := &CronSchedule{}
+	bounds := []struct {
+		name     string
+		min, max int
+		bits     *uint64
+	}{
+		{"minute", 0, 59, &schedule.minute},
+		{"hour", 0, 23, &schedule.hour},
+		{"day of month", 1, 31, &schedule.dayOfMonth},
+		{"month", 1, 12, &schedule.month},
+		{"day of week", 0, 7, &schedule.dayOfWeek},
+	}
+	for i, field := range fields {
+		bits, err := parseCronField(field, bounds[i].min, bounds[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", bounds[i].name, field, err)
+		}
+		*bounds[i].bits = bits
+	}
+	if schedule.dayOfWeek&(1<<7) != 0 {
+		schedule.dayOfWeek |= 1
+	}
+	// A day field is unrestricted when it matches every day, however it is written
+	schedule.anyDayOfMonth = schedule.dayOfMonth&cronDaysOfMonth == cronDaysOfMonth
+	schedule.anyDayOfWeek = schedule.dayOfWeek&cronDaysOfWeek == cronDaysOfWeek
+	return schedule, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, ok := strings.Cut(part, "/"); ok {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return 0, fmt.Errorf("step must be a positive number")
+			}
+			part = rangePart
+		}
+
+		low, high := min, max
+		if part != "*" {
+			lowPart, highPart, isRange := strings.Cut(part, "-")
+			var err error
+			if low, err = strconv.Atoi(lowPart); err != nil {
+				return 0, fmt.Errorf("%q is not a number", lowPart)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highPart); err != nil {
+					return 0, fmt.Errorf("%q is not a number", highPart)
+				}
+			} else if step > 1 {
+				// a/n runs from a to the end of the field
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("values must be between %d and %d", min, max)
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time after t that matches the schedule, in the location of t, or
+// the zero time when none does within five years.
+//
+// The schedule is matched against the wall clock of that location. A wall time skipped when
+// the clocks go forward runs at the instant it would have been without the change; a wall
+// time repeated when they go back runs once.
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	// wall walks the wall clock in UTC, where every day has 24 hours
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC).Add(time.Minute)
+	limit := wall.Year() + 5
+
+	for wall.Year() <= limit {
+		switch {
+		case c.month&(1<<uint(wall.Month())) == 0:
+			wall = time.Date(wall.Year(), wall.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchesDay(wall):
+			wall = time.Date(wall.Year(), wall.Month(), wall.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hour&(1<<uint(wall.Hour())) == 0:
+			wall = wall.Truncate(time.Hour).Add(time.Hour)
+		case c.minute&(1<<uint(wall.Minute())) == 0:
+			wall = wall.Add(time.Minute)
+		default:
+			if next := inLocation(wall, loc); next.After(t) {
+				return next
+			}
+			// An earlier instant of a repeated wall time, or a skipped one, that t is past
+			wall = wall.Add(time.Minute)
+		}
+	}
+	return time.Time{}
+}
+
+// inLocation returns the instant of a wall clock time in loc. A wall time that does not exist
+// because the clocks went forward is read with the offset from before the change.
+func inLocation(wall time.Time, loc *time.Location) time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, loc)
+	local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	if gap := wall.Sub(local); gap != 0 {
+		// time.Date read the wall time with one of the two offsets around the change; the one
+		// from before it gives the later instant
+		if other := t.Add(gap); other.After(t) {
+			return other
+		}
+	}
+	return t
+}
+
+// matchesDay applies the cron rule that a day matches either day field when both are
+// restricted, and the restricted one otherwise
+func (c *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if c.anyDayOfMonth || c.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
@@ -0,0 +1,118 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+	"verve/pkg/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	at := func(loc *time.Location, value string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+		require.NoError(t, err)
+		return parsed
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "steps and ranges",
+			expr: "*/20 9-10 * * *",
+			from: at(time.UTC, "2026-03-02 09:30"),
+			want: []time.Time{at(time.UTC, "2026-03-02 09:40"), at(time.UTC, "2026-03-02 10:00"), at(time.UTC, "2026-03-02 10:20"), at(time.UTC, "2026-03-02 10:40"), at(time.UTC, "2026-03-03 09:00")},
+		},
+		{
+			name: "stepped range and list",
+			expr: "0 8-18/5,23 * * *",
+			from: at(time.UTC, "2026-03-02 09:00"),
+			want: []time.Time{at(time.UTC, "2026-03-02 13:00"), at(time.UTC, "2026-03-02 18:00"), at(time.UTC, "2026-03-02 23:00"), at(time.UTC, "2026-03-03 08:00")},
+		},
+		{
+			name: "day of month or day of week when both are restricted",
+			expr: "0 12 13 * 5",
+			from: at(time.UTC, "2026-03-01 00:00"),
+			// Fridays in March 2026 are the 6th, 13th, 20th and 27th; April 13th is a Monday
+			want: []time.Time{at(time.UTC, "2026-03-06 12:00"), at(time.UTC, "2026-03-13 12:00"), at(time.UTC, "2026-03-20 12:00"), at(time.UTC, "2026-03-27 12:00"), at(time.UTC, "2026-04-03 12:00"), at(time.UTC, "2026-04-10 12:00"), at(time.UTC, "2026-04-13 12:00")},
+		},
+		{
+			name: "a day field stepped by one is unrestricted",
+			expr: "0 12 13 * */1",
+			from: at(time.UTC, "2026-03-01 00:00"),
+			want: []time.Time{at(time.UTC, "2026-03-13 12:00"), at(time.UTC, "2026-04-13 12:00")},
+		},
+		{
+			name: "Sunday as 7",
+			expr: "0 0 * * 7",
+			from: at(time.UTC, "2026-03-02 00:00"),
+			want: []time.Time{at(time.UTC, "2026-03-08 00:00"), at(time.UTC, "2026-03-15 00:00")},
+		},
+		{
+			name: "daily across the fall-back change",
+			expr: "0 9 * * *",
+			from: at(newYork, "2026-10-31 09:00"),
+			want: []time.Time{at(newYork, "2026-11-01 09:00"), at(newYork, "2026-11-02 09:00")},
+		},
+		{
+			name: "a repeated wall time runs once",
+			expr: "30 1 * * *",
+			from: at(newYork, "2026-10-31 12:00"),
+			want: []time.Time{time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC), time.Date(2026, 11, 2, 6, 30, 0, 0, time.UTC)},
+		},
+		{
+			name: "hourly across the fall-back change",
+			expr: "0 * * * *",
+			from: time.Date(2026, 11, 1, 4, 30, 0, 0, time.UTC).In(newYork),
+			want: []time.Time{time.Date(2026, 11, 1, 5, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 8, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "a skipped wall time runs after the spring-forward change",
+			expr: "30 2 * * *",
+			from: at(newYork, "2026-03-07 12:00"),
+			// 02:30 does not exist on March 8th; 02:30 EST is 03:30 EDT
+			want: []time.Time{at(newYork, "2026-03-08 03:30"), at(newYork, "2026-03-09 02:30")},
+		},
+		{
+			name: "daily across the spring-forward change",
+			expr: "0 9 * * *",
+			from: at(newYork, "2026-03-07 09:00"),
+			want: []time.Time{at(newYork, "2026-03-08 09:00"), at(newYork, "2026-03-09 09:00")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := utils.ParseCron(tt.expr)
+			require.NoError(t, err)
+			next := tt.from
+			for _, want := range tt.want {
+				next = schedule.Next(next)
+				assert.True(t, want.Equal(next), "want %v, got %v", want, next)
+			}
+		})
+	}
+
+	t.Run("a schedule that never matches has no next run", func(t *testing.T) {
+		schedule, err := utils.ParseCron("0 0 31 2 *")
+		require.NoError(t, err)
+		assert.True(t, schedule.Next(at(time.UTC, "2026-01-01 00:00")).IsZero())
+	})
+}
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every"} {
+		_, err := utils.ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+	for _, expr := range []string{"@yearly", "@monthly", "@weekly", "@daily", "@hourly", "0,15,30,45 */2 1-15 1-12/3 1-5"} {
+		_, err := utils.ParseCron(expr)
+		assert.NoError(t, err, expr)
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
 )
@@ -39,7 +42,10 @@ func SignMessage(priv *ecdsa.PrivateKey, message string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	sig := append(r.Bytes(), s.Bytes()...)
+	// r and s are padded to 32 bytes each, as expected by VerifySignature
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
 	return base64.StdEncoding.EncodeToString(sig), nil
 }
 
@@ -51,12 +57,64 @@ func DecodePublicKey(pubStr string) (*ecdsa.PublicKey, error) {
 	}
 	x, y := elliptic.Unmarshal(elliptic.P256(), bytes)
 	if x == nil || y == nil {
-		return nil, err
+		return nil, errors.New("invalid public key")
 	}
 	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
 }
 
+// KeyFingerprint identifies a base64-encoded public key without revealing it
+func KeyFingerprint(publicKey string) string {
+	sum := sha256.Sum256([]byte(publicKey))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
 // TransferMessage creates a canonical message string for signing/verification
 func TransferMessage(senderWalletID, receiverWalletID, amount int64) string {
 	return fmt.Sprintf("%d:%d:%d", senderWalletID, receiverWalletID, amount)
 }
+
+// SignedTransferMessage creates the canonical message a pseudonymous wallet signs to authorize
+// a transfer. The nonce and the expiry (Unix seconds) make each signature usable only once.
+func SignedTransferMessage(senderWalletID, receiverWalletID, amount int64, nonce string, expiresAt int64) string {
+	return fmt.Sprintf("%s:%s:%d", TransferMessage(senderWalletID, receiverWalletID, amount), nonce, expiresAt)
+}
+
+// ParsePrivateKeyPEM decodes a PEM-encoded P-256 ECDSA private key in SEC 1 or PKCS #8 form
+func ParsePrivateKeyPEM(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		parsed, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if pkcs8Err != nil {
+			return nil, err
+		}
+		key, _ = parsed.(*ecdsa.PrivateKey)
+	}
+	if key == nil || key.Curve != elliptic.P256() {
+		return nil, errors.New("not a P-256 ECDSA private key")
+	}
+	return key, nil
+}
+
+// CheckpointMessage creates the canonical message signed for a ledger checkpoint
+func CheckpointMessage(chainSeq int64, entryHash string) string {
+	return fmt.Sprintf("ledger-checkpoint:%d:%s", chainSeq, entryHash)
+}
+
+// KeyRegistrationMessage creates the message a key signs to prove its holder has the private key
+func KeyRegistrationMessage(publicKey string) string {
+	return "register-key:" + publicKey
+}
+
+// KeyRotationMessage creates the message the active key of a wallet signs to endorse its successor
+func KeyRotationMessage(walletID int64, newPublicKey string) string {
+	return fmt.Sprintf("rotate-key:%d:%s", walletID, newPublicKey)
+}
+
+// KeyRevocationMessage creates the message a key signs to revoke itself
+func KeyRevocationMessage(walletID int64, publicKey string) string {
+	return fmt.Sprintf("revoke-key:%d:%s", walletID, publicKey)
+}